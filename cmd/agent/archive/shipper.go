@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 17:12:40 2026 +0800
+ */
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5
+	// 上传失败后重新从logs server记录的游标开始上传的间隔
+	retryInterval = 10 * time.Second
+	// 单条日志的最大长度
+	maxEntryBytes = 16 * 1024 * 1024
+
+	cursorPath  = "/plugin/logs/api/archive/cursor"
+	entriesPath = "/plugin/logs/api/archive/entries"
+)
+
+// 将本机journal日志上传至logs server归档
+type shipper struct {
+	server        string
+	token         string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+
+	// logs server已归档的最后一条日志的游标
+	after string
+}
+
+// logs server接口的返回格式
+type serverResponse struct {
+	Code int                  `json:"code"`
+	Data public.ArchiveCursor `json:"data"`
+	Msg  string               `json:"msg"`
+}
+
+// 未开启归档时不上传
+func StartShipper() error {
+	archiveconf := conf.Global_Config.Archive
+	if archiveconf == nil || !archiveconf.Enabled {
+		return nil
+	}
+	if archiveconf.ServerAddr == "" {
+		return errors.New("archive server_addr is required when archive is enabled")
+	}
+	if archiveconf.Token == "" {
+		return errors.New("archive token is required when archive is enabled")
+	}
+	s := &shipper{
+		server:        strings.TrimSuffix(archiveconf.ServerAddr, "/"),
+		token:         archiveconf.Token,
+		batchSize:     archiveconf.BatchSize,
+		flushInterval: time.Duration(archiveconf.FlushInterval) * time.Second,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultBatchSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defaultFlushInterval * time.Second
+	}
+	if archiveconf.ServerCAFile != "" {
+		ca, err := global.FileReadBytes(archiveconf.ServerCAFile)
+		if err != nil {
+			return errors.Errorf("fail to read ca file %s: %s", archiveconf.ServerCAFile, err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return errors.Errorf("no valid certificate in ca file %s", archiveconf.ServerCAFile)
+		}
+		s.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}
+	}
+
+	global.ERManager.Wg.Add(1)
+	go s.run()
+	global.ERManager.ErrorTransmit("archive", "info", errors.Errorf("log archive shipper started: %s", s.server), false, false)
+	return nil
+}
+
+func (s *shipper) run() {
+	defer global.ERManager.Wg.Done()
+
+	for {
+		if err := s.ship(global.ERManager.GoCancelCtx); err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "archive shipping interrupted"), false, false)
+		}
+		select {
+		case <-global.ERManager.GoCancelCtx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+/*
+从logs server记录的游标开始持续读取journal并分批上传
+
+上传失败或游标不一致时返回，由run重新获取游标后继续上传
+*/
+func (s *shipper) ship(_ctx context.Context) error {
+	cursor, err := s.serverCursor(_ctx)
+	if err != nil {
+		return err
+	}
+	s.after = cursor
+
+	ctx, cancel := context.WithCancel(_ctx)
+	options := append([]string{}, journald.FollowLogDefaultOptions...)
+	options = append(options, "--follow", "--no-tail")
+	if s.after != "" {
+		options = append(options, "--after-cursor="+s.after)
+	}
+	cmd := exec.CommandContext(ctx, "journalctl", options...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return errors.Errorf("fail to get journalctl stdout: %s", err.Error())
+	}
+	metrics.JournalctlStarted()
+	if err := cmd.Start(); err != nil {
+		metrics.JournalctlFailed(err, true)
+		cancel()
+		return errors.Errorf("fail to start journalctl: %s", err.Error())
+	}
+	defer func() {
+		cancel()
+		cmd.Wait()
+	}()
+
+	lines := make(chan []byte, s.batchSize)
+	read_err := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+		for scanner.Scan() {
+			line := append([]byte{}, scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		read_err <- scanner.Err()
+	}()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := []json.RawMessage{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				if err := <-read_err; err != nil {
+					return errors.Errorf("fail to read journalctl output: %s", err.Error())
+				}
+				return errors.New("journalctl exited")
+			}
+			if !json.Valid(line) {
+				continue
+			}
+			batch = append(batch, line)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := s.upload(ctx, batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+}
+
+func (s *shipper) serverCursor(_ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(_ctx, http.MethodGet, s.server+cursorPath, nil)
+	if err != nil {
+		return "", errors.Errorf("fail to create archive cursor request: %s", err.Error())
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Data.Cursor, nil
+}
+
+func (s *shipper) upload(_ctx context.Context, _entries []json.RawMessage) error {
+	body := &bytes.Buffer{}
+	writer := gzip.NewWriter(body)
+	if err := json.NewEncoder(writer).Encode(&public.ArchiveBatch{After: s.after, Entries: _entries}); err != nil {
+		return errors.Errorf("fail to encode archive batch: %s", err.Error())
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Errorf("fail to compress archive batch: %s", err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(_ctx, http.MethodPost, s.server+entriesPath, body)
+	if err != nil {
+		return errors.Errorf("fail to create archive upload request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	s.after = resp.Data.Cursor
+	return nil
+}
+
+func (s *shipper) do(_req *http.Request) (*serverResponse, error) {
+	_req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(_req)
+	if err != nil {
+		return nil, errors.Errorf("archive request to %s failed: %s", _req.URL.Path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	resp_bytes, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, errors.Errorf("fail to read archive response: %s", err.Error())
+	}
+	server_resp := &serverResponse{}
+	if err := json.Unmarshal(resp_bytes, server_resp); err != nil {
+		return nil, errors.Errorf("invalid archive response(%d): %s", resp.StatusCode, string(resp_bytes))
+	}
+	if server_resp.Code != http.StatusOK {
+		return nil, errors.Errorf("archive request to %s rejected: %s", _req.URL.Path, server_resp.Msg)
+	}
+	return server_resp, nil
+}
@@ -25,8 +25,13 @@ const config_type = "logs_agent.yaml"
 var config_dir string
 
 type ServerConfig struct {
-	Logs    *LogsConf
-	Logopts *logger.LogOpts `yaml:"log"`
+	Logs     *LogsConf
+	Journal  *JournalConf    `yaml:"journal"`
+	Filetail *FiletailConf   `yaml:"filetail"`
+	Archive  *ArchiveConf    `yaml:"archive"`
+	Metrics  *MetricsConf    `yaml:"metrics"`
+	Syslog   *SyslogConf     `yaml:"syslog"`
+	Logopts  *logger.LogOpts `yaml:"log"`
 }
 
 func ConfigFile() string {
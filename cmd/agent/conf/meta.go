@@ -7,9 +7,84 @@
  */
 package conf
 
+import "gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+
 type LogsConf struct {
 	Https_enabled bool   `yaml:"https_enabled"`
 	CertFile      string `yaml:"cert_file"`
 	KeyFile       string `yaml:"key_file"`
 	Addr          string `yaml:"server_listen_addr"`
+	// 签发logs server客户端证书的CA，不为空时要求连接方提供由该CA签发的证书（mTLS），需要同时开启https
+	CAFile string `yaml:"ca_file"`
+}
+
+type JournalConf struct {
+	// exec: 调用journalctl；native: 直接解析journal文件，不支持时回退至journalctl
+	Reader string `yaml:"reader"`
+}
+
+type FiletailConf struct {
+	Allow_list []string `yaml:"allow_list"`
+}
+
+// 将journal日志上传至logs server归档
+type ArchiveConf struct {
+	Enabled bool `yaml:"enabled"`
+	// logs server地址，如http://10.0.0.1:9996
+	ServerAddr string `yaml:"server_addr"`
+	// 校验logs server https证书的CA，为空时使用系统证书
+	ServerCAFile string `yaml:"server_ca_file"`
+	// 与logs_server.yaml中archive.tokens里本机UUID对应的令牌一致，开启归档时必须配置
+	Token string `yaml:"token"`
+	// 单次上传的最大日志条数及最长间隔（秒）
+	BatchSize     int `yaml:"batch_size"`
+	FlushInterval int `yaml:"flush_interval"`
+}
+
+// 在/metrics以Prometheus文本格式提供指标
+type MetricsConf struct {
+	Enabled bool `yaml:"enabled"`
+	// 不为空时要求请求携带Authorization: Bearer <token>
+	Token string `yaml:"token"`
+	// 是否按服务单元及优先级统计本机新写入的journal日志条数
+	JournalEntries bool `yaml:"journal_entries"`
+}
+
+// 将journal日志持续转发至syslog服务器（RFC 5424）
+type SyslogConf struct {
+	Enabled bool `yaml:"enabled"`
+	// 保存各转发目标已发送的最后一条日志的游标，重启后从游标之后继续转发
+	StateDir string          `yaml:"state_dir"`
+	Targets  []*SyslogTarget `yaml:"targets"`
+}
+
+type SyslogTarget struct {
+	// 转发目标名称，用于区分游标文件，不能重复
+	Name string `yaml:"name"`
+	// udp、tcp或tls
+	Protocol string `yaml:"protocol"`
+	// syslog服务器地址，如10.0.0.1:514
+	Addr string `yaml:"addr"`
+	// tls：校验服务器证书的CA，为空时使用系统证书；服务器要求客户端证书时配置cert_file、key_file
+	CAFile   string `yaml:"ca_file"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// syslog facility，如local0；为空时使用日志的SYSLOG_FACILITY
+	Facility string `yaml:"facility"`
+	// 转发的日志，为空时转发全部日志
+	Filter *SyslogFilter `yaml:"filter"`
+}
+
+// 与JournalctlOptions中的查询条件一致
+type SyslogFilter struct {
+	Unit           string                `yaml:"unit"`
+	Identifier     string                `yaml:"identifier"`
+	Priority       string                `yaml:"priority"`
+	Transport      string                `yaml:"transport"`
+	User           string                `yaml:"user"`
+	Grep           string                `yaml:"grep"`
+	GrepRegex      bool                  `yaml:"grep_regex"`
+	GrepIgnoreCase bool                  `yaml:"grep_ignore_case"`
+	Matches        [][]public.FieldMatch `yaml:"matches"`
+	Query          string                `yaml:"query"`
 }
@@ -24,6 +24,10 @@ const (
 	ReadCmdStderrTimeout = 5 * time.Second
 
 	HeartbeatPeriod = 5 * time.Second // 日志采集组件状态检测周期
+
+	FilePollInterval = 500 * time.Millisecond // 文本日志文件实时模式下检测文件变化的周期
+
+	JournalPollInterval = 500 * time.Millisecond // 原生journal读取方式实时模式下检测journal文件变化的周期
 )
 
 var OsName string
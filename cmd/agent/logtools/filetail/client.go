@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 10:12:40 2026 +0800
+ */
+package filetail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// 文本日志文件采集客户端，与JournaldClient共用/ws/entry及public.JMessage协议
+type FileClient struct {
+	ID string
+
+	Active bool
+
+	wswriteMutex sync.Mutex
+
+	wsconn *websocket.Conn
+
+	// 允许访问的文件白名单
+	allowList []string
+
+	options *public.JournalctlOptions
+	path    string
+
+	CancelC context.Context
+	CancelF context.CancelFunc
+
+	wg sync.WaitGroup
+
+	CloseReadMsgCh chan struct{}
+
+	// 实时模式下检测文件变化的周期
+	pollInterval time.Duration
+
+	index *pageIndex
+}
+
+func CreateFileClient(_conn *websocket.Conn, _allow_list []string, _poll_interval time.Duration) *FileClient {
+	cancelCtx, cancelFunc := context.WithCancel(FiletailCtx)
+	return &FileClient{
+		wsconn:         _conn,
+		allowList:      _allow_list,
+		CancelC:        cancelCtx,
+		CancelF:        cancelFunc,
+		CloseReadMsgCh: make(chan struct{}, 10),
+		pollInterval:   _poll_interval,
+	}
+}
+
+func (fclient *FileClient) ReadMessageFromClient() {
+	for {
+		select {
+		case <-fclient.CloseReadMsgCh:
+			global.ERManager.ErrorTransmit("filetail", "warn", errors.New("fclient.ReadMessageFromClient() exit: cancelctx canceled"), false, false)
+			return
+		default:
+			msgType, jmsgBytes, err := fclient.wsconn.ReadMessage()
+			if err != nil {
+				if fclient.Active {
+					global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("error while reading message(msgType: %d): %s", msgType, err.Error()), false, false)
+					fclient.Close(true)
+				}
+				return
+			}
+
+			jmsg := &public.JMessage{}
+			if err := json.Unmarshal(jmsgBytes, jmsg); jmsgBytes != nil && err != nil {
+				global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("error while unmarshalling json options: %s, jmsg: %+v", err.Error(), string(jmsgBytes)), false, true)
+				fclient.Close(true)
+				return
+			}
+
+			switch jmsg.Type {
+			case public.UpdateOptionsMsg:
+				if jmsg.JOptions == nil {
+					global.ERManager.ErrorTransmit("filetail", "error", errors.New("joptions is nil"), false, false)
+					continue
+				}
+				// 释放上一次查询的资源
+				fclient.stopQuery()
+
+				path, err := CheckAllowed(jmsg.JOptions.File, fclient.allowList)
+				if err != nil {
+					global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+					fclient.writeError(public.ErrCodeInvalidOptions, err)
+					fclient.writeData(public.LogEntryData, nil)
+					continue
+				}
+
+				cancelCtx, cancelFunc := context.WithCancel(FiletailCtx)
+				fclient.CancelC = cancelCtx
+				fclient.CancelF = cancelFunc
+				fclient.options = jmsg.JOptions
+				fclient.path = path
+
+				if fclient.options.Notail {
+					fclient.startPage()
+				} else {
+					fclient.wg.Add(1)
+					go fclient.follow()
+				}
+			case public.UnitListMsg:
+				fclient.writeData(public.UnitData, map[string][]string{
+					"file": ListAllowed(fclient.allowList),
+				})
+			case public.UpdatePageMsg:
+				if fclient.index == nil || jmsg.JOptions == nil {
+					continue
+				}
+				fclient.options.From = jmsg.JOptions.From
+				fclient.options.Size = jmsg.JOptions.Size
+				fclient.writePage()
+			default:
+				global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("unsupport message type: %+v", jmsg), false, false)
+				fclient.writeError(public.ErrCodeUnsupported, errors.Errorf("unsupported message type: %d", jmsg.Type))
+			}
+		}
+	}
+}
+
+// 分页查询：建立行索引后返回首页
+func (fclient *FileClient) startPage() {
+	since, err := parseOptionTime(fclient.options.Since)
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+		fclient.writeError(public.ErrCodeInvalidOptions, err)
+		fclient.writeData(public.LogEntryData, nil)
+		return
+	}
+	until, err := parseOptionTime(fclient.options.Until)
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+		fclient.writeError(public.ErrCodeInvalidOptions, err)
+		fclient.writeData(public.LogEntryData, nil)
+		return
+	}
+
+	index, err := buildPageIndex(fclient.path, since, until, parseSeverity(fclient.options.Severity))
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+		fclient.writeError(public.ErrCodeJournalRead, err)
+		fclient.writeData(public.LogEntryData, nil)
+		return
+	}
+	fclient.index = index
+	fclient.writePage()
+}
+
+func (fclient *FileClient) writePage() {
+	hits, err := fclient.index.page(fclient.options.From, fclient.options.Size, filepath.Base(fclient.path))
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+		fclient.writeError(public.ErrCodeQueryFailed, err)
+		fclient.writeData(public.LogEntryData, nil)
+		return
+	}
+	fclient.writeData(public.LogEntryData, &public.PageData{
+		Total: fclient.index.total(),
+		Hits:  hits,
+		More:  fclient.options.From+len(hits) < fclient.index.total(),
+	})
+}
+
+// 实时查询：周期性读取文件新增内容
+func (fclient *FileClient) follow() {
+	defer fclient.wg.Done()
+
+	f, err := newFollower(fclient.path)
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+		fclient.writeError(public.ErrCodeJournalRead, err)
+		fclient.writeData(public.LogEntryData, nil)
+		return
+	}
+	defer f.close()
+
+	targetname := filepath.Base(fclient.path)
+	severity := parseSeverity(fclient.options.Severity)
+	send := func(_lines []string) {
+		for _, line := range _lines {
+			if guessLevel(line) > severity {
+				continue
+			}
+			fclient.writeData(public.LogEntryData, generateEntry(line, f.timestamp(line), targetname))
+		}
+	}
+
+	lines, err := f.tail(followInitLines)
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+	}
+	send(lines)
+
+	ticker := time.NewTicker(fclient.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fclient.CancelC.Done():
+			global.ERManager.ErrorTransmit("filetail", "debug", errors.New("fclient.follow() exit: cancelctx canceled"), false, false)
+			return
+		case <-ticker.C:
+			lines, err := f.poll()
+			send(lines)
+			if err != nil {
+				global.ERManager.ErrorTransmit("filetail", "error", errors.Wrap(err, " "), false, false)
+			}
+		}
+	}
+}
+
+func (fclient *FileClient) writeData(_type public.StdoutDataType, _data interface{}) {
+	fclient.writeMessage(&public.JMessage{
+		Type: public.DataMsg,
+		Data: &public.StdoutData{
+			Type: _type,
+			Data: _data,
+		},
+	})
+}
+
+// 通知客户端查询失败
+func (fclient *FileClient) writeError(_code string, _err error) {
+	fclient.writeMessage(&public.JMessage{
+		Type: public.ErrorMsg,
+		Data: &public.ErrorInfo{Code: _code, Message: _err.Error()},
+	})
+}
+
+func (fclient *FileClient) writeMessage(jmsg *public.JMessage) {
+	jmsgBytes, err := json.Marshal(jmsg)
+	if err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("fail to marshal message: %s", err.Error()), false, true)
+		return
+	}
+
+	if fclient.wsconn == nil {
+		return
+	}
+
+	fclient.wswriteMutex.Lock()
+	defer fclient.wswriteMutex.Unlock()
+	if err := fclient.wsconn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("error while writing message to ws client: %s", err.Error()), false, false)
+		return
+	}
+	metrics.WebsocketSentBytes.Add(float64(len(jmsgBytes)), metrics.SourceFile)
+}
+
+func (fclient *FileClient) stopQuery() {
+	fclient.CancelF()
+	fclient.wg.Wait()
+	if fclient.index != nil {
+		fclient.index.close()
+		fclient.index = nil
+	}
+}
+
+func (fclient *FileClient) ReturnJournalctlOptions() *public.JournalctlOptions {
+	return fclient.options
+}
+
+/*
+_closeconn: 是否关闭websocket连接
+*/
+func (fclient *FileClient) Close(_closeconn bool) {
+	global.ERManager.ErrorTransmit("filetail", "info", errors.Errorf("==========%-50s==========", fmt.Sprintf("file client %s call close", fclient.ID)), false, false)
+
+	fclient.stopQuery()
+
+	if _closeconn && fclient.wsconn != nil {
+		fclient.Active = false
+		fclient.wswriteMutex.Lock()
+		if err := fclient.wsconn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+			global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("write close message to wsconn failed: %s", err.Error()), false, false)
+		}
+		fclient.wswriteMutex.Unlock()
+		fclient.wsconn.Close()
+	}
+}
+
+func parseOptionTime(_t string) (time.Time, error) {
+	if _t == "" {
+		return time.Time{}, nil
+	}
+	usec, err := public.ParseJournalTime(_t)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time option %s: %s", _t, err.Error())
+	}
+	return time.UnixMicro(int64(usec)), nil
+}
+
+func parseSeverity(_severity string) int {
+	severity, err := strconv.Atoi(_severity)
+	if err != nil || severity < 0 || severity > 7 {
+		return 7
+	}
+	return severity
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 10:12:40 2026 +0800
+ */
+package filetail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+跟踪文本日志文件的追加写入
+
+兼容logrotate的两种轮转方式：
+  - create(rename): 原文件被重命名后创建同名新文件，读完旧文件剩余内容后切换至新文件
+  - copytruncate: 原文件被截断，文件大小小于已读取偏移量时从头读取
+*/
+type follower struct {
+	path string
+
+	file *os.File
+	info os.FileInfo
+
+	offset  int64
+	partial []byte
+
+	// 最近一次解析出的时间戳，用于无时间戳的续行
+	lastTimestamp time.Time
+}
+
+func newFollower(_path string) (*follower, error) {
+	f := &follower{path: _path}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *follower) open() error {
+	file, info, err := openAllowed(f.path)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	f.info = info
+	f.offset = 0
+	f.partial = nil
+	return nil
+}
+
+// 定位至文件末尾并返回最后_n行
+func (f *follower) tail(_n int) ([]string, error) {
+	size := f.info.Size()
+	start := size - maxLineLength
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, size-start)
+	if _, err := f.file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil, errors.Errorf("fail to read %s: %s", f.path, err.Error())
+	}
+	f.offset = size
+
+	// 文件末尾不完整的行留待下次读取
+	if idx := bytes.LastIndexByte(buf, '\n'); idx != len(buf)-1 {
+		f.partial = append(f.partial, buf[idx+1:]...)
+		buf = buf[:idx+1]
+	}
+	lines := splitLines(buf)
+	// 起始位置不在行首时丢弃第一行
+	if start > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > _n {
+		lines = lines[len(lines)-_n:]
+	}
+	return lines, nil
+}
+
+// 读取新追加的完整行，并处理文件轮转
+func (f *follower) poll() ([]string, error) {
+	lines, err := f.readAppended()
+	if err != nil {
+		return lines, err
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 文件已被重命名，新文件尚未创建
+			return lines, nil
+		}
+		return lines, errors.Errorf("fail to stat %s: %s", f.path, err.Error())
+	}
+
+	switch {
+	case !os.SameFile(f.info, info):
+		// rename轮转：旧文件内容已读完，剩余的不完整行视为一行
+		if len(f.partial) > 0 {
+			lines = append(lines, string(f.partial))
+		}
+		f.file.Close()
+		if err := f.open(); err != nil {
+			return lines, err
+		}
+		more, err := f.readAppended()
+		lines = append(lines, more...)
+		return lines, err
+	case info.Size() < f.offset:
+		// copytruncate轮转
+		f.offset = 0
+		f.partial = nil
+		more, err := f.readAppended()
+		lines = append(lines, more...)
+		return lines, err
+	}
+	return lines, nil
+}
+
+func (f *follower) readAppended() ([]string, error) {
+	lines := []string{}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.file.ReadAt(buf, f.offset)
+		if n > 0 {
+			f.offset += int64(n)
+			data := append(f.partial, buf[:n]...)
+			idx := bytes.LastIndexByte(data, '\n')
+			if idx < 0 {
+				if len(data) > maxLineLength {
+					lines = append(lines, string(data[:maxLineLength]))
+					data = nil
+				}
+				f.partial = data
+			} else {
+				lines = append(lines, splitLines(data[:idx+1])...)
+				f.partial = append([]byte{}, data[idx+1:]...)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return lines, nil
+			}
+			return lines, errors.Errorf("fail to read %s: %s", f.path, err.Error())
+		}
+	}
+}
+
+// 行首无时间戳时沿用上一行的时间戳，均无时使用当前时间
+func (f *follower) timestamp(_line string) time.Time {
+	if t := parseTimestamp(_line); !t.IsZero() {
+		f.lastTimestamp = t
+		return t
+	}
+	if !f.lastTimestamp.IsZero() {
+		return f.lastTimestamp
+	}
+	return time.Now()
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+	}
+}
+
+func splitLines(_data []byte) []string {
+	lines := []string{}
+	for _, l := range bytes.Split(_data, []byte{'\n'}) {
+		l = bytes.TrimRight(l, "\r")
+		if len(l) == 0 {
+			continue
+		}
+		if len(l) > maxLineLength {
+			l = l[:maxLineLength]
+		}
+		lines = append(lines, string(l))
+	}
+	return lines
+}
+
+type lineRef struct {
+	offset    int64
+	length    int
+	timestamp int64
+}
+
+/*
+分页查询时的行索引，仅保存满足条件的行偏移量，不缓存日志内容
+
+文件保持打开，rename轮转后仍可读取原文件
+*/
+type pageIndex struct {
+	file *os.File
+	refs []lineRef
+}
+
+func buildPageIndex(_path string, _since, _until time.Time, _severity int) (*pageIndex, error) {
+	file, info, err := openAllowed(_path)
+	if err != nil {
+		return nil, err
+	}
+
+	pi := &pageIndex{file: file}
+	reader := bufio.NewReaderSize(io.NewSectionReader(file, 0, info.Size()), 64*1024)
+	var offset int64
+	last_timestamp := info.ModTime()
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// 超长行：读取剩余部分
+			rest, rerr := reader.ReadBytes('\n')
+			line = append(append([]byte{}, line...), rest...)
+			err = rerr
+		}
+		length := len(line)
+		if length > 0 {
+			text := string(bytes.TrimRight(line, "\r\n"))
+			if t := parseTimestamp(text); !t.IsZero() {
+				last_timestamp = t
+			}
+			if len(text) > 0 && matchTimeRange(last_timestamp, _since, _until) && guessLevel(text) <= _severity {
+				pi.refs = append(pi.refs, lineRef{
+					offset:    offset,
+					length:    length,
+					timestamp: last_timestamp.UnixMilli(),
+				})
+			}
+			offset += int64(length)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			file.Close()
+			return nil, errors.Errorf("fail to read %s: %s", _path, err.Error())
+		}
+	}
+	return pi, nil
+}
+
+func matchTimeRange(_t, _since, _until time.Time) bool {
+	if !_since.IsZero() && _t.Before(_since) {
+		return false
+	}
+	if !_until.IsZero() && _t.After(_until) {
+		return false
+	}
+	return true
+}
+
+func (pi *pageIndex) total() int {
+	return len(pi.refs)
+}
+
+func (pi *pageIndex) page(_from, _size int, _targetname string) ([]map[string]interface{}, error) {
+	if _from < 0 {
+		_from = 0
+	}
+	end := _from + _size
+	if end > len(pi.refs) {
+		end = len(pi.refs)
+	}
+	if _from > end {
+		_from = end
+	}
+
+	entries := make([]map[string]interface{}, 0, end-_from)
+	for _, ref := range pi.refs[_from:end] {
+		length := ref.length
+		if length > maxLineLength {
+			length = maxLineLength
+		}
+		buf := make([]byte, length)
+		n, err := pi.file.ReadAt(buf, ref.offset)
+		if err != nil && err != io.EOF {
+			return nil, errors.Errorf("fail to read line at %d: %s", ref.offset, err.Error())
+		}
+		text := string(bytes.TrimRight(buf[:n], "\r\n"))
+		entries = append(entries, generateEntry(text, time.UnixMilli(ref.timestamp), _targetname))
+	}
+	return entries, nil
+}
+
+func (pi *pageIndex) close() {
+	if pi.file != nil {
+		pi.file.Close()
+	}
+}
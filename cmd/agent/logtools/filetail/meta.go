@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 10:12:40 2026 +0800
+ */
+package filetail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"github.com/pkg/errors"
+)
+
+var FiletailCtx, FiletailCancel = context.WithCancel(global.RootCtx)
+
+const (
+	// 实时模式下首次返回文件末尾的行数，与journalctl --follow保持一致
+	followInitLines = 10
+
+	// 单行日志最大长度，超出部分截断
+	maxLineLength = 64 * 1024
+)
+
+// 文本日志中常见的时间戳格式
+var timestampLayouts = []struct {
+	re     *regexp.Regexp
+	layout string
+	local  bool
+}{
+	// 2024-12-16T08:43:58.123456+08:00
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`), time.RFC3339Nano, false},
+	// 2024-12-16 08:43:58,123 / 2024-12-16 08:43:58.123 / 2024/12/16 08:43:58
+	{regexp.MustCompile(`\d{4}[-/]\d{2}[-/]\d{2}[ T]\d{2}:\d{2}:\d{2}`), "2006-01-02 15:04:05", true},
+	// nginx/apache: 16/Dec/2024:08:43:58 +0800
+	{regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`), "02/Jan/2006:15:04:05 -0700", false},
+	// syslog: Dec 16 08:43:58
+	{regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`), time.Stamp, true},
+	// audit: msg=audit(1734309838.123:456)
+	{regexp.MustCompile(`audit\((\d+)\.(\d+):\d+\)`), "", false},
+}
+
+// 按关键字推测日志等级，数值与journald PRIORITY保持一致
+var levelKeywords = []struct {
+	re    *regexp.Regexp
+	level int
+}{
+	{regexp.MustCompile(`(?i)\b(emerg|emergency|panic)\b`), 0},
+	{regexp.MustCompile(`(?i)\b(alert)\b`), 1},
+	{regexp.MustCompile(`(?i)\b(crit|critical|fatal)\b`), 2},
+	{regexp.MustCompile(`(?i)\b(err|error|failed|failure)\b`), 3},
+	{regexp.MustCompile(`(?i)\b(warn|warning)\b`), 4},
+	{regexp.MustCompile(`(?i)\b(notice)\b`), 5},
+	{regexp.MustCompile(`(?i)\b(debug|trace)\b`), 7},
+}
+
+const defaultLevel = 6
+
+// 解析日志行首部的时间戳，无法解析时返回零值
+func parseTimestamp(_line string) time.Time {
+	head := _line
+	if len(head) > 128 {
+		head = head[:128]
+	}
+	for _, tl := range timestampLayouts {
+		loc := tl.re.FindStringSubmatchIndex(head)
+		if loc == nil {
+			continue
+		}
+		matched := head[loc[0]:loc[1]]
+		if tl.layout == "" {
+			sec, err := strconv.ParseInt(head[loc[2]:loc[3]], 10, 64)
+			if err != nil {
+				continue
+			}
+			msec, _ := strconv.ParseInt((head[loc[4]:loc[5]] + "000")[:3], 10, 64)
+			return time.Unix(sec, msec*int64(time.Millisecond))
+		}
+		if tl.layout == "2006-01-02 15:04:05" {
+			matched = strings.NewReplacer("/", "-", "T", " ").Replace(matched)
+		}
+		var t time.Time
+		var err error
+		if tl.local {
+			t, err = time.ParseInLocation(tl.layout, matched, time.Local)
+		} else {
+			t, err = time.Parse(tl.layout, matched)
+		}
+		if err != nil {
+			continue
+		}
+		// syslog格式不包含年份
+		if t.Year() == 0 {
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func guessLevel(_line string) int {
+	head := _line
+	if len(head) > 256 {
+		head = head[:256]
+	}
+	for _, lk := range levelKeywords {
+		if lk.re.MatchString(head) {
+			return lk.level
+		}
+	}
+	return defaultLevel
+}
+
+// 生成与journald.generateEntry字段一致的日志条目
+func generateEntry(_line string, _timestamp time.Time, _targetname string) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp":  strconv.FormatInt(_timestamp.UnixMilli(), 10),
+		"level":      strconv.Itoa(guessLevel(_line)),
+		"message":    _line,
+		"targetname": _targetname,
+	}
+}
+
+/*
+校验文件路径是否在白名单内，返回解析符号链接后的真实路径，调用方须打开该路径
+
+仅按真实路径匹配白名单，防止通过白名单内的符号链接访问其他文件；白名单中不含通配符的目录
+部分本身为符号链接时，按解析后的目录匹配
+
+@_allow_list: 白名单，支持filepath.Match通配符
+*/
+func CheckAllowed(_path string, _allow_list []string) (string, error) {
+	if !filepath.IsAbs(_path) {
+		return "", errors.Errorf("file path must be absolute: %s", _path)
+	}
+	cleaned := filepath.Clean(_path)
+	real, err := filepath.EvalSymlinks(cleaned)
+	if err != nil {
+		return "", errors.Errorf("fail to resolve file path %s: %s", cleaned, err.Error())
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", errors.Errorf("fail to stat file %s: %s", real, err.Error())
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.Errorf("not a regular file: %s", real)
+	}
+
+	for _, pattern := range _allow_list {
+		for _, p := range resolvePattern(pattern) {
+			if ok, err := filepath.Match(p, real); err == nil && ok {
+				return real, nil
+			}
+		}
+	}
+	return "", errors.Errorf("file is not in allow list: %s", cleaned)
+}
+
+// 返回白名单模式本身及解析目录部分符号链接后的模式
+func resolvePattern(_pattern string) []string {
+	patterns := []string{_pattern}
+	dir, base := filepath.Split(filepath.Clean(_pattern))
+	if strings.ContainsAny(dir, `*?[\`) {
+		return patterns
+	}
+	real_dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return patterns
+	}
+	if resolved := filepath.Join(real_dir, base); resolved != _pattern {
+		patterns = append(patterns, resolved)
+	}
+	return patterns
+}
+
+/*
+打开经CheckAllowed校验的文件
+
+不跟随最后一级符号链接，防止校验后、打开前（或轮转后重新打开时）文件被替换为符号链接
+*/
+func openAllowed(_path string) (*os.File, os.FileInfo, error) {
+	file, err := os.OpenFile(_path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, nil, errors.Errorf("fail to open %s: %s", _path, err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.Errorf("fail to stat %s: %s", _path, err.Error())
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, errors.Errorf("not a regular file: %s", _path)
+	}
+	return file, info, nil
+}
+
+// 列出白名单中当前存在的文件
+func ListAllowed(_allow_list []string) []string {
+	files := []string{}
+	seen := map[string]struct{}{}
+	for _, pattern := range _allow_list {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			global.ERManager.ErrorTransmit("filetail", "error", errors.Errorf("invalid allow list pattern %s: %s", pattern, err.Error()), false, false)
+			continue
+		}
+		for _, m := range matches {
+			if _, ok := seen[m]; ok {
+				continue
+			}
+			if _, err := CheckAllowed(m, _allow_list); err != nil {
+				continue
+			}
+			seen[m] = struct{}{}
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 15:08:44 2026 +0800
+ */
+package filetail
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*
+root/
+├── logs/app.log
+├── logs/link.log -> ../secret/shadow
+├── logs/dir.log/
+├── secret/shadow
+└── linked -> logs
+*/
+func allowFixture(t *testing.T) string {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"logs/dir.log", "secret"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"logs/app.log", "secret/shadow"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("line\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../secret/shadow", filepath.Join(root, "logs", "link.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("logs", filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestCheckAllowed(t *testing.T) {
+	root := allowFixture(t)
+	app := filepath.Join(root, "logs", "app.log")
+
+	tests := []struct {
+		name  string
+		path  string
+		allow []string
+		want  string
+	}{
+		{"exact", app, []string{app}, app},
+		{"wildcard", app, []string{filepath.Join(root, "logs", "*.log")}, app},
+		{"unclean path", filepath.Join(root, "logs", "..", "logs", "app.log"), []string{filepath.Join(root, "logs", "*.log")}, app},
+		// 白名单内的符号链接指向白名单外的文件
+		{"symlink out of allow list", filepath.Join(root, "logs", "link.log"), []string{filepath.Join(root, "logs", "*.log")}, ""},
+		{"symlink allowed by name", filepath.Join(root, "logs", "link.log"), []string{filepath.Join(root, "logs", "link.log")}, ""},
+		// 通过符号链接目录访问白名单内的文件，返回真实路径
+		{"path through symlinked dir", filepath.Join(root, "linked", "app.log"), []string{filepath.Join(root, "logs", "*.log")}, app},
+		// 白名单中的目录本身为符号链接
+		{"symlinked allow list dir", app, []string{filepath.Join(root, "linked", "*.log")}, app},
+		{"symlinked allow list dir, link out", filepath.Join(root, "linked", "link.log"), []string{filepath.Join(root, "linked", "*.log")}, ""},
+		{"not in allow list", filepath.Join(root, "secret", "shadow"), []string{filepath.Join(root, "logs", "*")}, ""},
+		{"directory", filepath.Join(root, "logs", "dir.log"), []string{filepath.Join(root, "logs", "*.log")}, ""},
+		{"missing", filepath.Join(root, "logs", "missing.log"), []string{filepath.Join(root, "logs", "*.log")}, ""},
+		{"relative", "logs/app.log", []string{"logs/*.log"}, ""},
+	}
+	for _, tt := range tests {
+		got, err := CheckAllowed(tt.path, tt.allow)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: %s allowed as %s", tt.name, tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestListAllowed(t *testing.T) {
+	root := allowFixture(t)
+	got := ListAllowed([]string{filepath.Join(root, "logs", "*.log")})
+	if want := []string{filepath.Join(root, "logs", "app.log")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// 校验后文件被替换为符号链接时拒绝打开
+func TestOpenAllowedReplacedBySymlink(t *testing.T) {
+	root := allowFixture(t)
+	app := filepath.Join(root, "logs", "app.log")
+	path, err := CheckAllowed(app, []string{app})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "secret", "shadow"), app); err != nil {
+		t.Fatal(err)
+	}
+	if file, _, err := openAllowed(path); err == nil {
+		file.Close()
+		t.Fatalf("opened %s after it was replaced by a symlink", path)
+	}
+}
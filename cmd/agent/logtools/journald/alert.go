@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 15:02:51 2026 +0800
+ */
+package journald
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 告警规则时间窗口的上限，单位秒
+	maxAlertWindow = 24 * 60 * 60
+	// 评估告警状态的间隔
+	alertEvaluateInterval = time.Second
+)
+
+// AlertRuleMsg的查询条件及告警条件
+func parseAlertCondition(_jmsg *public.JMessage) (*public.AlertCondition, error) {
+	if _jmsg.JOptions == nil {
+		return nil, errors.New("joptions is required for alert rule")
+	}
+	bytes, err := json.Marshal(_jmsg.Data)
+	if err != nil {
+		return nil, errors.Errorf("invalid alert condition: %s", err.Error())
+	}
+	condition := &public.AlertCondition{}
+	if err := json.Unmarshal(bytes, condition); err != nil {
+		return nil, errors.Errorf("invalid alert condition: %s", err.Error())
+	}
+	if condition.Threshold < 0 {
+		return nil, errors.Errorf("invalid alert threshold: %d", condition.Threshold)
+	}
+	if condition.Window <= 0 || condition.Window > maxAlertWindow {
+		return nil, errors.Errorf("alert window must be between 1 and %d seconds: %d", maxAlertWindow, condition.Window)
+	}
+	return condition, nil
+}
+
+// 告警规则的滑动时间窗口
+type alertWindow struct {
+	mutex     sync.Mutex
+	threshold int
+	window    time.Duration
+
+	// 时间窗口内最近的至多threshold+1条日志的微秒时间戳，升序：超过threshold即告警，不需要保留更早的日志
+	times []uint64
+	// 最近一条日志的MESSAGE
+	message string
+	// 上一次返回的告警状态，尚未返回时为空
+	state string
+}
+
+func newAlertWindow(_condition *public.AlertCondition) *alertWindow {
+	return &alertWindow{
+		threshold: _condition.Threshold,
+		window:    time.Duration(_condition.Window) * time.Second,
+	}
+}
+
+// 统计一条满足查询条件的日志，时间窗口之前的日志忽略
+func (w *alertWindow) add(_raw_entry map[string]interface{}) {
+	realtime_str, _ := _raw_entry["__REALTIME_TIMESTAMP"].(string)
+	realtime, err := strconv.ParseUint(realtime_str, 10, 64)
+	if err != nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if realtime < uint64(time.Now().Add(-w.window).UnixMicro()) {
+		return
+	}
+	i := sort.Search(len(w.times), func(_i int) bool { return w.times[_i] > realtime })
+	w.times = append(w.times, 0)
+	copy(w.times[i+1:], w.times[i:])
+	w.times[i] = realtime
+	if len(w.times) > w.threshold+1 {
+		w.times = w.times[len(w.times)-w.threshold-1:]
+	}
+	if message, ok := _raw_entry["MESSAGE"].(string); ok {
+		w.message = message
+	}
+}
+
+// 移除时间窗口之外的日志并计算告警状态，状态未变化时返回nil
+func (w *alertWindow) evaluate(_now time.Time) *public.AlertEvent {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	start := uint64(_now.Add(-w.window).UnixMicro())
+	expired := sort.Search(len(w.times), func(_i int) bool { return w.times[_i] >= start })
+	w.times = w.times[expired:]
+
+	state := public.AlertResolved
+	if len(w.times) > w.threshold {
+		state = public.AlertFiring
+	}
+	if state == w.state {
+		return nil
+	}
+	w.state = state
+	return &public.AlertEvent{
+		State:     state,
+		Count:     len(w.times),
+		Timestamp: _now.UnixMilli(),
+		Message:   w.message,
+	}
+}
+
+/*
+评估告警规则：先统计时间窗口内已有的日志，再从最后一条日志之后开始实时查询
+
+每隔alertEvaluateInterval评估一次，首次评估及状态变化时返回告警状态
+*/
+func (jclient *JournaldClient) serveAlert(_window *alertWindow) {
+	defer jclient.wg.Done()
+
+	now := time.Now()
+	scope := *jclient.options
+	scope.Notail = true
+	scope.Since = now.Add(-_window.window).Format("2006-01-02 15:04:05")
+	scope.Until = now.Format("2006-01-02 15:04:05")
+	source := jclient.newPageSource(&scope)
+	last_cursor := ""
+	err := source.each(jclient.CancelC, func(_raw_entry map[string]interface{}) error {
+		_window.add(_raw_entry)
+		if cursor, ok := _raw_entry["__CURSOR"].(string); ok {
+			last_cursor = cursor
+		}
+		return nil
+	})
+	source.close()
+	if jclient.CancelC.Err() != nil {
+		return
+	}
+	if err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "fail to read journal entries in alert window"), false, false)
+		jclient.sendError(public.ErrCodeJournalRead, err, nil)
+		return
+	}
+	jclient.options.AfterCursor = last_cursor
+	jclient.startFollow(jclient.options)
+
+	ticker := time.NewTicker(alertEvaluateInterval)
+	defer ticker.Stop()
+	for {
+		if event := _window.evaluate(time.Now()); event != nil {
+			if !jclient.sendStdoutData(&public.StdoutData{Type: public.AlertData, Data: event}) {
+				return
+			}
+		}
+		select {
+		case <-jclient.CancelC.Done():
+			global.ERManager.ErrorTransmit("journald", "warn", errors.New("jclient.serveAlert() exit, cancelctx canceled"), false, false)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 09:37:41 2026 +0800
+ */
+package journald
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func alertEntry(_t time.Time, _message string) map[string]interface{} {
+	return map[string]interface{}{
+		"__REALTIME_TIMESTAMP": strconv.FormatInt(_t.UnixMicro(), 10),
+		"MESSAGE":              _message,
+	}
+}
+
+func TestAlertWindowAdd(t *testing.T) {
+	now := time.Now()
+	w := newAlertWindow(&public.AlertCondition{Threshold: 2, Window: 60})
+
+	// 时间窗口之前及没有时间戳的日志忽略
+	w.add(alertEntry(now.Add(-2*time.Minute), "expired"))
+	w.add(map[string]interface{}{"MESSAGE": "no timestamp"})
+	if len(w.times) != 0 || w.message != "" {
+		t.Fatalf("ignored entries were counted: %v %q", w.times, w.message)
+	}
+
+	// 乱序到达的日志按时间排序，超过threshold+1条时只保留最近的日志
+	offsets := []time.Duration{-10, -30, -20, -5, -40}
+	for i, offset := range offsets {
+		w.add(alertEntry(now.Add(offset*time.Second), strconv.Itoa(i)))
+	}
+	want := []uint64{
+		uint64(now.Add(-20 * time.Second).UnixMicro()),
+		uint64(now.Add(-10 * time.Second).UnixMicro()),
+		uint64(now.Add(-5 * time.Second).UnixMicro()),
+	}
+	if !reflect.DeepEqual(w.times, want) {
+		t.Errorf("times: got %v, want %v", w.times, want)
+	}
+	if w.message != "4" {
+		t.Errorf("message: got %q, want the last added", w.message)
+	}
+}
+
+func TestAlertWindowEvaluate(t *testing.T) {
+	now := time.Now()
+	w := newAlertWindow(&public.AlertCondition{Threshold: 1, Window: 60})
+
+	// 首次评估返回当前状态，状态不变时不返回
+	event := w.evaluate(now)
+	if event == nil || event.State != public.AlertResolved || event.Count != 0 {
+		t.Fatalf("first evaluate: got %+v", event)
+	}
+	if event := w.evaluate(now); event != nil {
+		t.Fatalf("unchanged state: got %+v", event)
+	}
+
+	// 等于threshold时不告警
+	w.add(alertEntry(now.Add(-50*time.Second), "first"))
+	if event := w.evaluate(now); event != nil {
+		t.Fatalf("count equal to threshold: got %+v", event)
+	}
+
+	w.add(alertEntry(now.Add(-10*time.Second), "second"))
+	event = w.evaluate(now)
+	if event == nil || event.State != public.AlertFiring || event.Count != 2 || event.Message != "second" {
+		t.Fatalf("count over threshold: got %+v", event)
+	}
+	if event.Timestamp != now.UnixMilli() {
+		t.Errorf("timestamp: got %d, want %d", event.Timestamp, now.UnixMilli())
+	}
+
+	// 较早的日志移出时间窗口后恢复
+	event = w.evaluate(now.Add(20 * time.Second))
+	if event == nil || event.State != public.AlertResolved || event.Count != 1 {
+		t.Fatalf("entry expired: got %+v", event)
+	}
+	if event := w.evaluate(now.Add(time.Minute + 20*time.Second)); event != nil {
+		t.Fatalf("still resolved: got %+v", event)
+	}
+	if len(w.times) != 0 {
+		t.Errorf("expired entries kept: %v", w.times)
+	}
+}
+
+// threshold为0时一条日志即告警，时间窗口内保留的日志条数不超过threshold+1
+func TestAlertWindowThresholdZero(t *testing.T) {
+	now := time.Now()
+	w := newAlertWindow(&public.AlertCondition{Threshold: 0, Window: 60})
+	for i := 0; i < 100; i++ {
+		w.add(alertEntry(now.Add(-time.Duration(i)*time.Millisecond), "entry"))
+	}
+	if len(w.times) != 1 {
+		t.Errorf("times: got %d entries, want 1", len(w.times))
+	}
+	event := w.evaluate(now)
+	if event == nil || event.State != public.AlertFiring || event.Count != 1 {
+		t.Fatalf("got %+v", event)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 18:40:16 2026 +0800
+ */
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+var BootListDefaultOptions = []string{"--list-boots", "--no-pager", "--utc", "--output=json"}
+
+var (
+	// 启动序号或32位boot ID
+	bootRegexp = regexp.MustCompile(`^(-?[0-9]+|[0-9a-f]{32})$`)
+	// 不支持json输出的journalctl按文本解析：" -1 <boot id> Mon 2024-01-01 08:00:00 UTC—Mon 2024-01-01 09:00:00 UTC"
+	bootLineRegexp = regexp.MustCompile(`^\s*(-?[0-9]+)\s+([0-9a-f]{32})\s+\S+\s+([0-9-]{10} [0-9:]{8}) UTC.*?([0-9-]{10} [0-9:]{8}) UTC`)
+)
+
+func checkBoot(_boot string) error {
+	if _boot != "" && !bootRegexp.MatchString(_boot) {
+		return errors.Errorf("invalid boot: %q", _boot)
+	}
+	return nil
+}
+
+// 启动列表，native不可用时回退至journalctl
+func (jclient *JournaldClient) listBoots(_ctx context.Context) ([]public.BootInfo, error) {
+	if jclient.Reader == NativeReader {
+		boots, err := nativeListBoots()
+		if err == nil {
+			return boots, nil
+		}
+		global.ERManager.ErrorTransmit("journald", "warn", errors.Wrap(err, "native journal reader unavailable, fall back to journalctl"), false, false)
+	}
+	return execListBoots(_ctx)
+}
+
+func nativeListBoots() ([]public.BootInfo, error) {
+	journal, err := sdjournal.OpenLocal()
+	if err != nil {
+		return nil, err
+	}
+	defer journal.Close()
+
+	boots, err := journal.Boots()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]public.BootInfo, 0, len(boots))
+	for i, b := range boots {
+		list = append(list, public.BootInfo{
+			Index:          i - len(boots) + 1,
+			BootID:         b.ID.String(),
+			FirstTimestamp: int64(b.First / 1000),
+			LastTimestamp:  int64(b.Last / 1000),
+		})
+	}
+	return list, nil
+}
+
+func execListBoots(_ctx context.Context) ([]public.BootInfo, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(_ctx, "journalctl", BootListDefaultOptions...)
+	cmd.Stderr = stderr
+	metrics.JournalctlStarted()
+	output, err := cmd.Output()
+	metrics.JournalctlFailed(err, cmd.ProcessState == nil)
+	if err != nil {
+		return nil, errors.Errorf("err while running journalctl: %s, %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseBootList(output)
+}
+
+// journalctl --list-boots的输出，旧版本journalctl不支持json时按文本解析
+func parseBootList(_output []byte) ([]public.BootInfo, error) {
+	list := []public.BootInfo{}
+	if output := bytes.TrimSpace(_output); bytes.HasPrefix(output, []byte("[")) {
+		boots := []struct {
+			Index      int    `json:"index"`
+			BootID     string `json:"boot_id"`
+			FirstEntry int64  `json:"first_entry"`
+			LastEntry  int64  `json:"last_entry"`
+		}{}
+		if err := json.Unmarshal(output, &boots); err != nil {
+			return nil, errors.Errorf("fail to unmarshal boot list: %s", err)
+		}
+		for _, b := range boots {
+			list = append(list, public.BootInfo{
+				Index:          b.Index,
+				BootID:         b.BootID,
+				FirstTimestamp: b.FirstEntry / 1000,
+				LastTimestamp:  b.LastEntry / 1000,
+			})
+		}
+		return list, nil
+	}
+
+	for _, line := range strings.Split(string(_output), "\n") {
+		fields := bootLineRegexp.FindStringSubmatch(line)
+		if fields == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(fields[1])
+		first, err := time.Parse("2006-01-02 15:04:05", fields[3])
+		if err != nil {
+			return nil, errors.Errorf("fail to parse boot list: %s", line)
+		}
+		last, err := time.Parse("2006-01-02 15:04:05", fields[4])
+		if err != nil {
+			return nil, errors.Errorf("fail to parse boot list: %s", line)
+		}
+		list = append(list, public.BootInfo{
+			Index:          index,
+			BootID:         fields[2],
+			FirstTimestamp: first.UnixMilli(),
+			LastTimestamp:  last.UnixMilli(),
+		})
+	}
+	return list, nil
+}
+
+// 与journalctl -b一致：0及负数相对于最后一次启动，正数从第一次启动（1）开始计数
+func resolveBoot(_boot string, _journal *sdjournal.Journal) (sdjournal.ID128, error) {
+	if err := checkBoot(_boot); err != nil {
+		return sdjournal.ID128{}, err
+	}
+	if len(_boot) == 32 {
+		return sdjournal.ParseID128(_boot)
+	}
+
+	offset, _ := strconv.Atoi(_boot)
+	boots, err := _journal.Boots()
+	if err != nil {
+		return sdjournal.ID128{}, err
+	}
+	index, ok := bootIndex(offset, len(boots))
+	if !ok {
+		return sdjournal.ID128{}, errors.Errorf("no journal boot entry found for boot %s", _boot)
+	}
+	return boots[index].ID, nil
+}
+
+// 启动序号在按时间排列的_count次启动中的位置
+func bootIndex(_offset, _count int) (int, bool) {
+	index := _offset - 1
+	if _offset <= 0 {
+		index = _count - 1 + _offset
+	}
+	return index, index >= 0 && index < _count
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 13:21:38 2026 +0800
+ */
+package journald
+
+import (
+	"reflect"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestCheckBoot(t *testing.T) {
+	tests := []struct {
+		boot string
+		ok   bool
+	}{
+		{"", true},
+		{"0", true},
+		{"-1", true},
+		{"12", true},
+		{"b4fc98c3b50d44d9a6f0a99d8c210581", true},
+		{"B4FC98C3B50D44D9A6F0A99D8C210581", false},
+		{"b4fc98c3b50d44d9a6f0a99d8c21058", false},
+		{"b4fc98c3-b50d-44d9-a6f0-a99d8c210581", false},
+		{"+1", false},
+		{"--1", false},
+		{"-1 --all", false},
+		{"all", false},
+	}
+	for _, tt := range tests {
+		if err := checkBoot(tt.boot); (err == nil) != tt.ok {
+			t.Errorf("%q: got %v", tt.boot, err)
+		}
+	}
+}
+
+func TestBootIndex(t *testing.T) {
+	tests := []struct {
+		offset, count int
+		index         int
+		ok            bool
+	}{
+		// 0及负数相对于最后一次启动
+		{0, 3, 2, true},
+		{-1, 3, 1, true},
+		{-2, 3, 0, true},
+		{-3, 3, -1, false},
+		// 正数从第一次启动开始
+		{1, 3, 0, true},
+		{3, 3, 2, true},
+		{4, 3, 3, false},
+		{0, 0, -1, false},
+		{1, 0, 0, false},
+	}
+	for _, tt := range tests {
+		index, ok := bootIndex(tt.offset, tt.count)
+		if ok != tt.ok || (ok && index != tt.index) {
+			t.Errorf("bootIndex(%d, %d): got %d %v, want %d %v", tt.offset, tt.count, index, ok, tt.index, tt.ok)
+		}
+	}
+}
+
+func TestResolveBoot(t *testing.T) {
+	journal, err := sdjournal.OpenDirs(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	boot_id := fixtureRawEntries(t)[0]["_BOOT_ID"].(string)
+
+	tests := []struct {
+		boot string
+		// 为空时应返回错误
+		want string
+	}{
+		{"0", boot_id},
+		{"1", boot_id},
+		{"-1", ""},
+		{"2", ""},
+		// boot ID不检查是否存在
+		{boot_id, boot_id},
+		{"00000000000000000000000000000001", "00000000000000000000000000000001"},
+		{"x", ""},
+	}
+	for _, tt := range tests {
+		id, err := resolveBoot(tt.boot, journal)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: got %s, expected error", tt.boot, id)
+			}
+			continue
+		}
+		if err != nil || id.String() != tt.want {
+			t.Errorf("%q: got %s %v, want %s", tt.boot, id, err, tt.want)
+		}
+	}
+}
+
+func TestParseBootList(t *testing.T) {
+	want := []public.BootInfo{
+		{Index: -1, BootID: "2a0c1e1b5d2f4f6e9d8c7b6a59483726", FirstTimestamp: 1704096000000, LastTimestamp: 1704099600000},
+		{Index: 0, BootID: "b4fc98c3b50d44d9a6f0a99d8c210581", FirstTimestamp: 1704103200000, LastTimestamp: 1704106861000},
+	}
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{
+			name: "json",
+			output: `[{"index":-1,"boot_id":"2a0c1e1b5d2f4f6e9d8c7b6a59483726","first_entry":1704096000000123,"last_entry":1704099600000456},` +
+				`{"index":0,"boot_id":"b4fc98c3b50d44d9a6f0a99d8c210581","first_entry":1704103200000000,"last_entry":1704106861000999}]` + "\n",
+		},
+		{
+			// systemd 250及以前
+			name: "text",
+			output: "-1 2a0c1e1b5d2f4f6e9d8c7b6a59483726 Mon 2024-01-01 08:00:00 UTC—Mon 2024-01-01 09:00:00 UTC\n" +
+				" 0 b4fc98c3b50d44d9a6f0a99d8c210581 Mon 2024-01-01 10:00:00 UTC—Mon 2024-01-01 11:01:01 UTC\n",
+		},
+		{
+			// 带表头、以空格分隔起止时间
+			name: "text with header",
+			output: "IDX BOOT ID                          FIRST ENTRY                 LAST ENTRY\n" +
+				" -1 2a0c1e1b5d2f4f6e9d8c7b6a59483726 Mon 2024-01-01 08:00:00 UTC Mon 2024-01-01 09:00:00 UTC\n" +
+				"  0 b4fc98c3b50d44d9a6f0a99d8c210581 Mon 2024-01-01 10:00:00 UTC Mon 2024-01-01 11:01:01 UTC\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseBootList([]byte(tt.output))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, want)
+		}
+	}
+
+	for _, output := range []string{"", "\n", "[]", "No journal boot entry found\n"} {
+		got, err := parseBootList([]byte(output))
+		if err != nil || len(got) != 0 {
+			t.Errorf("%q: got %+v %v", output, got, err)
+		}
+	}
+	if _, err := parseBootList([]byte(`[{"index":"x"}]`)); err == nil {
+		t.Error("invalid json: expected error")
+	}
+	if _, err := parseBootList([]byte(" 0 b4fc98c3b50d44d9a6f0a99d8c210581 Mon 2024-13-01 10:00:00 UTC—Mon 2024-01-01 11:00:00 UTC")); err == nil {
+		t.Error("invalid time: expected error")
+	}
+}
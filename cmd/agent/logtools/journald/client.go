@@ -15,7 +15,6 @@ import (
 	"io"
 	"os/exec"
 	"os/user"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
 	"github.com/gorilla/websocket"
 )
@@ -39,7 +39,11 @@ type JournaldClient struct {
 
 	Active bool
 
-	wswriteMutex sync.Mutex
+	// journal读取方式：exec（默认）、native
+	Reader string
+
+	// 同一连接上的子查询共用
+	wswriteMutex *sync.Mutex
 	wsreadMutex  sync.Mutex
 
 	wsconn *websocket.Conn
@@ -54,6 +58,9 @@ type JournaldClient struct {
 
 	wg   sync.WaitGroup
 	once sync.Once
+	// 每个client只运行一个WriteMessageToClient，连接或子查询关闭时停止
+	writerStart sync.Once
+	writerStop  sync.Once
 
 	dataCh          chan *public.StdoutData
 	errCh           chan []byte
@@ -65,13 +72,40 @@ type JournaldClient struct {
 
 	UnitsMap map[string][]string
 
-	PageEntryBuff PageEntryBuffSortByTimestamp
+	// 分页查询
+	pager *pager
+
+	// 按MESSAGE内容搜索，未设置grep时为nil
+	grep *grepMatcher
+	// 查询语句中未能交由journalctl过滤的条件，未设置query时为nil
+	query *queryFilter
+
+	// 告警规则的时间窗口，评估告警规则时不为nil
+	alert *alertWindow
+
+	// 实时查询已发送的最后一条日志的游标，随每条日志返回，客户端重连时据此继续
+	lastCursor string
+
+	// 子查询的stream，发送的消息中带有该stream；为空时为连接本身的查询
+	stream string
+	// 查询context的父context，子查询结束时取消
+	parentCtx context.Context
+	// 子查询待处理的消息，由子查询自身的goroutine依次处理
+	inbox      chan *public.JMessage
+	streamStop context.CancelFunc
+	streamDone chan struct{}
+	// 连接上的子查询，key: stream
+	streamsMutex sync.Mutex
+	streams      map[string]*JournaldClient
 }
 
 func CreateJournaldClient(_conn *websocket.Conn, _timeout time.Duration) *JournaldClient {
 	cancelCtx, cancelFunc := context.WithCancel(JournaldCtx)
 	return &JournaldClient{
 		wsconn:          _conn,
+		wswriteMutex:    &sync.Mutex{},
+		parentCtx:       JournaldCtx,
+		Reader:          ExecReader,
 		defaultOptions:  FollowLogDefaultOptions,
 		options:         nil,
 		CancelC:         cancelCtx,
@@ -83,6 +117,7 @@ func CreateJournaldClient(_conn *websocket.Conn, _timeout time.Duration) *Journa
 		closeWriteMsgCh: make(chan struct{}, 10),
 		timeout:         _timeout,
 		UnitsMap:        make(map[string][]string),
+		streams:         make(map[string]*JournaldClient),
 	}
 }
 
@@ -123,68 +158,263 @@ OuterLoop:
 				global.ERManager.ErrorTransmit("journald", "debug", errors.Errorf("jmsg.type: %+v, jmsg.joptions:%+v, jmsg.data: %+v", jmsg.Type, jmsg.JOptions, jmsg.Data), false, false)
 			})
 
-			switch jmsg.Type {
-			case public.UpdateOptionsMsg:
-				// TODO: 连续发送相同的查询请求暂时跳过
-				if jclient.options == jmsg.JOptions {
-					time.Sleep(1 * time.Second)
-					continue OuterLoop
-				}
+			// 指定stream的消息由对应的子查询处理，与连接上的其他查询互不影响
+			if jmsg.Stream != "" {
+				jclient.dispatchStream(jmsg)
+				continue OuterLoop
+			}
+			jclient.handleMessage(jmsg)
+		}
+	}
+}
 
-				if jclient.Jcmd != nil {
-					global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("==========%-50s==========", "reset journalctl options"), false, false)
-					global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("jmsg.type: %+v, jmsg.joptions:%+v, jmsg.data: %+v", jmsg.Type, jmsg.JOptions, jmsg.Data), false, false)
-					// 释放上一次查询的资源
-					jclient.Close(false, false, false)
-				}
+// 处理客户端发送的一条消息，每个JournaldClient同一时间只运行一个日志查询
+func (jclient *JournaldClient) handleMessage(_jmsg *public.JMessage) {
+	jclient.writerStart.Do(func() {
+		go jclient.WriteMessageToClient()
+	})
+
+	var err error
+	switch _jmsg.Type {
+	case public.UpdateOptionsMsg:
+		if _jmsg.JOptions == nil {
+			err := errors.New("joptions is required")
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "invalid query"), false, false)
+			jclient.sendError(public.ErrCodeInvalidOptions, err, nil)
+			return
+		}
+		// TODO: 连续发送相同的查询请求暂时跳过
+		if jclient.options == _jmsg.JOptions {
+			time.Sleep(1 * time.Second)
+			return
+		}
 
-				cancelCtx, cancelFunc := context.WithCancel(JournaldCtx)
-				jclient.CancelC = cancelCtx
-				jclient.CancelF = cancelFunc
-				jclient.options = jmsg.JOptions
-				jclient.PageEntryBuff = nil
-				jclient.Jcmd = exec.Command("journalctl", jclient.assembleOptions(jclient.defaultOptions, jmsg.JOptions)...)
-				go jclient.WriteMessageToClient()
-				jclient.ProcessData(jclient.Jcmd, public.LogEntryData)
-			case public.UnitListMsg:
-				cmd := exec.Command("systemctl", UnitListDefaultOptions...)
-				go jclient.WriteMessageToClient()
-				jclient.ProcessData(cmd, public.UnitData)
-			case public.UpdatePageMsg:
-				if len(jclient.PageEntryBuff) == 0 {
-					continue OuterLoop
-				}
-				jclient.options.From = jmsg.JOptions.From
-				jclient.options.Size = jmsg.JOptions.Size
-				start_index, end_index := jclient.options.From, jclient.options.From+jclient.options.Size
-				if len(jclient.PageEntryBuff) <= end_index {
-					end_index = len(jclient.PageEntryBuff)
-				}
-				if len(jclient.PageEntryBuff) <= start_index {
-					start_index = len(jclient.PageEntryBuff)
-				}
+		grep, err := jclient.resetQuery(_jmsg)
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+			jclient.sendError(public.ErrCodeInvalidOptions, err, nil)
+			jclient.sendAbnormal()
+			return
+		}
+		jclient.grep = grep
+
+		if _jmsg.JOptions.Notail {
+			// 分页查询：按游标逐页读取，总数在后台统计
+			jclient.pager = newPager(jclient.newPageSource(_jmsg.JOptions), grep)
+			jclient.wg.Add(1)
+			go jclient.servePages(jclient.pager)
+			jclient.wg.Add(1)
+			go jclient.countPageTotal(jclient.pager)
+			jclient.pager.reqCh <- _jmsg.JOptions
+			return
+		}
 
-				jclient.dataCh <- &public.StdoutData{
-					Type: public.LogEntryData,
-					Data: strings.Join(jclient.PageEntryBuff[start_index:end_index], "\n"),
-				}
-			default:
-				global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("unsupport message type: %+v", jmsg), false, false)
+		jclient.startFollow(_jmsg.JOptions)
+	case public.ExportMsg:
+		// 导出全部查询结果，忽略分页参数
+		format, _ := _jmsg.Data.(string)
+		if _jmsg.JOptions == nil {
+			_jmsg.JOptions = &public.JournalctlOptions{}
+		}
+		_jmsg.JOptions.Notail = true
+		_, err = jclient.resetQuery(_jmsg)
+		var encoder public.ExportEncoder
+		if err == nil {
+			encoder, err = public.NewExportEncoder(format, _jmsg.JOptions.Fields)
+		}
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "invalid export request"), false, false)
+			jclient.sendError(public.ErrCodeInvalidOptions, err, nil)
+			jclient.sendStdoutData(&public.StdoutData{Type: public.ExportData, Data: &public.ExportChunk{Done: true}})
+			return
+		}
+		jclient.wg.Add(1)
+		go jclient.serveExport(encoder)
+	case public.AlertRuleMsg:
+		condition, err := parseAlertCondition(_jmsg)
+		if err == nil {
+			options := *_jmsg.JOptions
+			// 时间窗口内的日志由serveAlert预先统计，实时查询从统计到的最后一条日志之后继续
+			options.Notail, options.AfterCursor = false, ""
+			_jmsg.JOptions = &options
+			_, err = jclient.resetQuery(_jmsg)
+		}
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "invalid alert rule"), false, false)
+			jclient.sendError(public.ErrCodeInvalidOptions, err, nil)
+			return
+		}
+		jclient.alert = newAlertWindow(condition)
+		jclient.wg.Add(1)
+		go jclient.serveAlert(jclient.alert)
+	case public.UnitListMsg:
+		cmd := exec.Command("systemctl", UnitListDefaultOptions...)
+		jclient.ProcessData(cmd, public.UnitData)
+	case public.BootListMsg:
+		jclient.wg.Add(1)
+		go jclient.serveOnce(jclient.CancelC, public.BootData, "boot list", func(_ctx context.Context) (interface{}, error) {
+			return jclient.listBoots(_ctx)
+		})
+	case public.EntryDetailMsg:
+		options := _jmsg.JOptions
+		jclient.wg.Add(1)
+		go jclient.serveOnce(jclient.CancelC, public.EntryDetailData, "entry detail", func(_ctx context.Context) (interface{}, error) {
+			if options == nil {
+				return nil, nil
+			}
+			return jclient.entryDetail(_ctx, options.Cursor)
+		})
+	case public.ContextMsg:
+		options := _jmsg.JOptions
+		jclient.wg.Add(1)
+		go jclient.serveOnce(jclient.CancelC, public.ContextEntryData, "entry context", func(_ctx context.Context) (interface{}, error) {
+			if options == nil {
+				return nil, nil
+			}
+			return jclient.entryContext(_ctx, options)
+		})
+	case public.TimelineMsg:
+		options := _jmsg.JOptions
+		jclient.wg.Add(1)
+		go jclient.serveOnce(jclient.CancelC, public.TimelineData, "timeline", func(_ctx context.Context) (interface{}, error) {
+			if options == nil {
+				return nil, nil
 			}
+			return jclient.timeline(_ctx, options)
+		})
+	case public.UpdatePageMsg:
+		if jclient.pager == nil || _jmsg.JOptions == nil {
+			return
+		}
+		page_options := *jclient.options
+		page_options.From = _jmsg.JOptions.From
+		page_options.Size = _jmsg.JOptions.Size
+		page_options.Cursor = _jmsg.JOptions.Cursor
+		page_options.Direction = _jmsg.JOptions.Direction
+		select {
+		case jclient.pager.reqCh <- &page_options:
+		default:
+			global.ERManager.ErrorTransmit("journald", "warn", errors.Errorf("too many pending page requests, drop: %+v", page_options), false, false)
 		}
+	default:
+		global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("unsupport message type: %+v", _jmsg), false, false)
+		jclient.sendError(public.ErrCodeUnsupported, errors.Errorf("unsupported message type: %d", _jmsg.Type), nil)
+	}
+}
+
+/*
+在后台执行一次查询并返回结果，查询期间仍可以读取客户端消息
+
+_ctx为发起查询时的CancelC，被新的查询请求或连接关闭取消时不返回结果
+*/
+func (jclient *JournaldClient) serveOnce(_ctx context.Context, _type public.StdoutDataType, _name string, _query func(context.Context) (interface{}, error)) {
+	defer jclient.wg.Done()
+
+	data, err := _query(_ctx)
+	if _ctx.Err() != nil {
+		global.ERManager.ErrorTransmit("journald", "warn", errors.Errorf("%s query canceled", _name), false, false)
+		return
+	}
+	if err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrapf(err, "fail to query %s", _name), false, false)
+		jclient.sendError(public.ErrCodeQueryFailed, err, nil)
+	}
+	select {
+	case <-_ctx.Done():
+	case jclient.dataCh <- &public.StdoutData{Type: _type, Data: data}:
 	}
 }
 
+/*
+释放上一次查询的资源并使用_jmsg的查询条件，返回按MESSAGE搜索的matcher
+
+查询条件不合法时返回错误，_jmsg.JOptions不能为nil
+*/
+func (jclient *JournaldClient) resetQuery(_jmsg *public.JMessage) (*grepMatcher, error) {
+	if jclient.Jcmd != nil || jclient.options != nil {
+		global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("==========%-50s==========", "reset journalctl options"), false, false)
+		global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("jmsg.type: %+v, jmsg.joptions:%+v, jmsg.data: %+v", _jmsg.Type, _jmsg.JOptions, _jmsg.Data), false, false)
+		// 释放上一次查询的资源
+		jclient.Close(false, false, false)
+	}
+
+	jclient.discardStderr()
+	cancelCtx, cancelFunc := context.WithCancel(jclient.parentCtx)
+	jclient.CancelC = cancelCtx
+	jclient.CancelF = cancelFunc
+	jclient.options = _jmsg.JOptions
+	jclient.pager = nil
+	jclient.alert = nil
+	jclient.Jcmd = nil
+	jclient.lastCursor = _jmsg.JOptions.AfterCursor
+
+	grep, query, err := checkQueryOptions(_jmsg.JOptions)
+	jclient.query = query
+	return grep, err
+}
+
+// 校验查询条件，返回按MESSAGE搜索的matcher及查询语句的过滤条件
+func checkQueryOptions(_options *public.JournalctlOptions) (*grepMatcher, *queryFilter, error) {
+	if _options == nil {
+		return nil, nil, errors.New("joptions is required")
+	}
+	if _, err := public.UserUID(_options.User); err != nil {
+		return nil, nil, err
+	}
+	grep, err := newGrepMatcher(_options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkFieldMatches(_options.Matches); err != nil {
+		return nil, nil, err
+	}
+	query, err := newQueryFilter(_options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkBoot(_options.Boot); err != nil {
+		return nil, nil, err
+	}
+	if err := checkFieldNames(_options.Fields); err != nil {
+		return nil, nil, err
+	}
+	if err := checkAfterCursor(_options); err != nil {
+		return nil, nil, err
+	}
+	if err := checkTimeRange(_options); err != nil {
+		return nil, nil, err
+	}
+	return grep, query, nil
+}
+
+// 开始实时查询，native不可用时回退至journalctl
+func (jclient *JournaldClient) startFollow(_options *public.JournalctlOptions) {
+	if jclient.Reader == NativeReader {
+		journal, err := openNativeJournal(_options)
+		if err == nil {
+			jclient.wg.Add(1)
+			go jclient.readFromJournal(journal)
+			return
+		}
+		global.ERManager.ErrorTransmit("journald", "warn", errors.Wrap(err, "native journal reader unavailable, fall back to journalctl"), false, false)
+	}
+
+	jclient.Jcmd = exec.Command("journalctl", jclient.assembleOptions(jclient.defaultOptions, _options)...)
+	jclient.ProcessData(jclient.Jcmd, public.LogEntryData)
+}
+
 func (jclient *JournaldClient) ProcessData(_cmd *exec.Cmd, _data_type public.StdoutDataType) {
 	cmd_stdout, err := _cmd.StdoutPipe()
 	if err != nil {
 		global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("cannot get stdout pipe: %s", err), false, false)
+		jclient.sendError(public.ErrCodeCommandFailed, errors.Errorf("cannot get stdout pipe: %s", err), nil)
 		jclient.Close(true, false, false)
 		return
 	}
 	cmd_stderr, err := _cmd.StderrPipe()
 	if err != nil {
 		global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("cannot get stderr pipe: %s", err), false, false)
+		jclient.sendError(public.ErrCodeCommandFailed, errors.Errorf("cannot get stderr pipe: %s", err), nil)
 		jclient.Close(true, false, false)
 		return
 	}
@@ -197,12 +427,24 @@ func (jclient *JournaldClient) ProcessData(_cmd *exec.Cmd, _data_type public.Std
 	go func(__cmd *exec.Cmd) {
 		defer global.ERManager.Wg.Done()
 		__cmd.WaitDelay = time.Second * 2
+		metrics.JournalctlStarted()
 		err = __cmd.Run()
+		// 主动kill的journalctl进程不计为失败
+		if __cmd.ProcessState == nil || __cmd.ProcessState.ExitCode() != -1 {
+			metrics.JournalctlFailed(err, __cmd.ProcessState == nil)
+		}
 		if err != nil {
 			global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("err while running cmd: %d, %s", __cmd.ProcessState.ExitCode(), err.Error()), false, false)
+			// command无法启动，如journalctl不存在
+			if __cmd.ProcessState == nil {
+				jclient.sendError(public.ErrCodeCommandFailed, errors.Errorf("fail to run %s: %s", __cmd.Args[0], err.Error()), nil)
+				return
+			}
 			// 主动kill journalctl process，exitcode: -1, 不释放资源
-			if __cmd.ProcessState.ExitCode() != -1 {
-				jclient.Close(false, false, true)
+			if exit_code := __cmd.ProcessState.ExitCode(); exit_code != -1 {
+				stderr := jclient.readStderr()
+				jclient.sendError(public.ErrCodeCommandFailed, errors.Errorf("%s exited with code %d", __cmd.Args[0], exit_code), stderr)
+				jclient.Close(false, false, false)
 				return
 			}
 		}
@@ -234,53 +476,9 @@ func (jclient *JournaldClient) WriteMessageToClient() {
 				jdata.Type = public.LogEntryData
 				if jclient.options.Notail {
 					// 分页查询
-					var raw_page_entries []string
-					var ok bool
-
-					buffdata, ok := data.Data.(string)
-					if !ok {
-						global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to assert page enties json to string: %+v(%T)", data.Data, data.Data), false, true)
-						jclient.Close(true, true, false)
-						return
-					}
-
-					if buffdata != "abnormal" {
-						if len(jclient.PageEntryBuff) == 0 {
-							jclient.PageEntryBuff = strings.Split(buffdata, "\n")
-							jclient.PageEntryBuff = jclient.PageEntryBuff[:len(jclient.PageEntryBuff)-1]
-							sort.Sort(jclient.PageEntryBuff)
-							start_index, end_index := jclient.options.From, jclient.options.Size
-							if len(jclient.PageEntryBuff) <= jclient.options.Size {
-								end_index = len(jclient.PageEntryBuff)
-							}
-							raw_page_entries = jclient.PageEntryBuff[start_index:end_index]
-						} else {
-							raw_page_entries = strings.Split(buffdata, "\n")
-							if !ok {
-								global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to assert page enties json to []string: %+v(%T)", data.Data, data.Data), false, true)
-								jclient.Close(true, true, false)
-								return
-							}
-						}
-
-						page_entries := make([]map[string]interface{}, 0)
-						for _, entry_json := range raw_page_entries {
-							if len(entry_json) == 0 {
-								continue
-							}
-							raw_entry := map[string]interface{}{}
-							if err := json.Unmarshal([]byte(entry_json), &raw_entry); err != nil {
-								global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to unmarshal Journald JSON: %s; raw data: %+v(%d)", err, entry_json, len(entry_json)), false, true)
-								jclient.Close(true, true, false)
-								return
-							}
-							page_entries = append(page_entries, jclient.generateEntry(raw_entry))
-						}
-
-						jdata.Data = &public.PageData{
-							Total: len(jclient.PageEntryBuff),
-							Hits:  page_entries,
-						}
+					page, ok := data.Data.(*public.PageData)
+					if ok && page != nil {
+						jdata.Data = page
 					} else {
 						jdata.Data = nil
 					}
@@ -296,13 +494,35 @@ func (jclient *JournaldClient) WriteMessageToClient() {
 					if jsondata != "abnormal" {
 						if err := json.Unmarshal([]byte(jsondata), &raw_entry); err != nil {
 							global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to unmarshal Journald JSON: %s; raw data: %s(%d)", err, data.Data.(string), len(data.Data.(string))), false, true)
+							jclient.sendError(public.ErrCodeDecodeFailed, errors.Errorf("fail to unmarshal journal entry: %s", err.Error()), nil)
 							jclient.Close(true, true, false)
 							return
 						}
-						jdata.Data = jclient.generateEntry(raw_entry)
+						// journalctl不支持--grep时在agent端过滤
+						if jclient.grep != nil && !jclient.grep.matchRaw(raw_entry) {
+							continue
+						}
+						if jclient.query != nil && !jclient.query.matchRaw(raw_entry) {
+							continue
+						}
+						entry := generateEntry(raw_entry)
+						addExtraFields(entry, raw_entry, jclient.options.Fields)
+						if jclient.grep != nil {
+							jclient.grep.markEntry(entry)
+						}
+						if cursor, ok := raw_entry["__CURSOR"].(string); ok {
+							jclient.lastCursor = cursor
+						}
+						// 告警规则只统计日志条数，不返回日志
+						if jclient.alert != nil {
+							jclient.alert.add(raw_entry)
+							continue
+						}
+						jdata.Data = entry
 					} else {
 						jdata.Data = nil
 					}
+					jdata.Cursor = jclient.lastCursor
 				}
 			// 服务单元查询
 			case public.UnitData:
@@ -316,11 +536,36 @@ func (jclient *JournaldClient) WriteMessageToClient() {
 				} else {
 					jdata.Data = nil
 				}
+			// 启动列表查询
+			case public.BootData:
+				jdata.Type = public.BootData
+				jdata.Data = data.Data
+			// 单条日志详情查询
+			case public.EntryDetailData:
+				jdata.Type = public.EntryDetailData
+				jdata.Data = data.Data
+			// 日志上下文查询
+			case public.ContextEntryData:
+				jdata.Type = public.ContextEntryData
+				jdata.Data = data.Data
+			// 日志时间轴查询
+			case public.TimelineData:
+				jdata.Type = public.TimelineData
+				jdata.Data = data.Data
+			// 查询结果导出
+			case public.ExportData:
+				jdata.Type = public.ExportData
+				jdata.Data = data.Data
+			// 告警状态
+			case public.AlertData:
+				jdata.Type = public.AlertData
+				jdata.Data = data.Data
 			}
 
 			jmsg := &public.JMessage{
-				Type: public.DataMsg,
-				Data: jdata,
+				Type:   public.DataMsg,
+				Data:   jdata,
+				Stream: jclient.stream,
 			}
 			jmsgBytes, err := json.Marshal(jmsg)
 			if err != nil {
@@ -334,13 +579,15 @@ func (jclient *JournaldClient) WriteMessageToClient() {
 			jclient.wswriteMutex.Lock()
 			if err := jclient.wsconn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
 				global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("error while writing message to ws client: %s", err.Error()), false, true)
+			} else {
+				metrics.WebsocketSentBytes.Add(float64(len(jmsgBytes)), metrics.SourceJournald)
 			}
 			jclient.wswriteMutex.Unlock()
 		}
 	}
 }
 
-func (jclient *JournaldClient) generateEntry(_raw_entry map[string]interface{}) map[string]interface{} {
+func generateEntry(_raw_entry map[string]interface{}) map[string]interface{} {
 	entry := map[string]interface{}{}
 	if _raw_entry["__REALTIME_TIMESTAMP"].(string) != "" {
 		timestamp_int64, err := strconv.ParseInt(_raw_entry["__REALTIME_TIMESTAMP"].(string), 10, 64)
@@ -350,25 +597,27 @@ func (jclient *JournaldClient) generateEntry(_raw_entry map[string]interface{})
 		}
 		entry["timestamp"] = strconv.Itoa(int(timestamp_int64 / 1000))
 	}
-	if _raw_entry["PRIORITY"] != nil && _raw_entry["PRIORITY"].(string) != "" {
-		entry["level"] = _raw_entry["PRIORITY"].(string)
+	// 不可打印或重复的字段在journalctl --output=json中为数组
+	if level := public.ExportFieldValue(_raw_entry, "PRIORITY"); level != "" {
+		entry["level"] = level
 	}
-	if _raw_entry["MESSAGE"].(string) != "" {
-		entry["message"] = _raw_entry["MESSAGE"].(string)
+	if cursor, ok := _raw_entry["__CURSOR"].(string); ok {
+		entry["cursor"] = cursor
 	}
-	if _raw_entry["_TRANSPORT"].(string) != "" {
-		switch _raw_entry["_TRANSPORT"].(string) {
-		case "journal":
-			if _raw_entry["UNIT"] != nil {
-				entry["targetname"] = _raw_entry["UNIT"].(string)
-			} else if _raw_entry["SYSLOG_IDENTIFIER"] != nil {
-				entry["targetname"] = _raw_entry["SYSLOG_IDENTIFIER"].(string)
-			}
-		// TODO: 暂时无法提供syslog日志
-		case "syslog", "kernel", "audit":
-			if _raw_entry["SYSLOG_IDENTIFIER"] != nil {
-				entry["targetname"] = _raw_entry["SYSLOG_IDENTIFIER"].(string)
-			}
+	if message := public.ExportFieldValue(_raw_entry, "MESSAGE"); message != "" {
+		entry["message"] = message
+	}
+	switch public.ExportFieldValue(_raw_entry, "_TRANSPORT") {
+	case "journal":
+		if _raw_entry["UNIT"] != nil {
+			entry["targetname"] = public.ExportFieldValue(_raw_entry, "UNIT")
+		} else if _raw_entry["SYSLOG_IDENTIFIER"] != nil {
+			entry["targetname"] = public.ExportFieldValue(_raw_entry, "SYSLOG_IDENTIFIER")
+		}
+	// TODO: 暂时无法提供syslog日志
+	case "syslog", "kernel", "audit":
+		if _raw_entry["SYSLOG_IDENTIFIER"] != nil {
+			entry["targetname"] = public.ExportFieldValue(_raw_entry, "SYSLOG_IDENTIFIER")
 		}
 	}
 	return entry
@@ -440,31 +689,14 @@ func (jclient *JournaldClient) readFromStdout(_type public.StdoutDataType, _stdo
 			switch _type {
 			case public.LogEntryData:
 				dataT.Type = public.LogEntryData
-				if jclient.options.Notail {
-					text, err := jclient.readAllOnce(_stdout)
-					if err != nil {
-						if strings.Contains(err.Error(), "EOF") {
-							global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "jclient.readFromStdout() exit: "), false, false)
-							dataT.Data = "abnormal"
-							jclient.dataCh <- dataT
-							return
-						}
-						global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "jclient.readFromStdout() exit: "), false, true)
-						dataT.Data = "abnormal"
-						jclient.dataCh <- dataT
-						return
-					}
-					dataT.Data = text
-				} else {
-					text := jclient.readOneLineOnce(reader)
-					if text == "" {
-						dataT.Data = "abnormal"
-						jclient.dataCh <- dataT
-						global.ERManager.ErrorTransmit("journald", "debug", errors.New("jclient.readFromStdout() exit: EOF"), false, false)
-						return
-					}
-					dataT.Data = text
+				text := jclient.readOneLineOnce(reader)
+				if text == "" {
+					dataT.Data = "abnormal"
+					jclient.dataCh <- dataT
+					global.ERManager.ErrorTransmit("journald", "debug", errors.New("jclient.readFromStdout() exit: EOF"), false, false)
+					return
 				}
+				dataT.Data = text
 			case public.UnitData:
 				dataT.Type = public.UnitData
 				text, err := jclient.readAllOnce(_stdout)
@@ -510,6 +742,13 @@ func (jclient *JournaldClient) readAllOnce(_reader io.ReadCloser) (string, error
 func (jclient *JournaldClient) readFromStderr(_stderr io.ReadCloser) {
 	defer jclient.wg.Done()
 	defer _stderr.Close()
+	// 通知readStderr stderr已读取完毕
+	defer func() {
+		select {
+		case jclient.errCh <- nil:
+		default:
+		}
+	}()
 	reader := bufio.NewReader(_stderr)
 	for {
 		select {
@@ -535,31 +774,51 @@ func (jclient *JournaldClient) assembleOptions(_initOptions []string, _options *
 	if _options.Notail {
 		_initOptions = append(_initOptions, "--no-tail")
 	} else {
-		_initOptions = append(_initOptions, "--follow")
+		_initOptions = append(_initOptions, followOptions(_options)...)
 	}
+	return append(_initOptions, assembleMatches(_options)...)
+}
+
+// 查询条件对应的journalctl参数
+func assembleMatches(_options *public.JournalctlOptions) []string {
+	matches := []string{}
 	if _options.Notail && _options.Since != "" && _options.Until != "" {
-		_initOptions = append(_initOptions, "--since", _options.Since, "--until", _options.Until)
+		matches = append(matches, "--since", _options.Since, "--until", _options.Until)
+	}
+	if _options.Boot != "" && checkBoot(_options.Boot) == nil {
+		matches = append(matches, "--boot="+_options.Boot)
 	}
 	if _options.Unit != "" {
-		_initOptions = append(_initOptions, "--unit", _options.Unit)
+		matches = append(matches, "--unit", _options.Unit)
 	}
 	if _options.Identifier != "" {
-		_initOptions = append(_initOptions, "--identifier", _options.Identifier)
+		matches = append(matches, "--identifier", _options.Identifier)
 	}
 	if _options.Severity != "" {
-		_initOptions = append(_initOptions, "--priority", _options.Severity)
+		matches = append(matches, "--priority", _options.Severity)
 	}
+	fixed := []string{}
 	if _options.Transport != "" {
-		_initOptions = append(_initOptions, fmt.Sprintf("_TRANSPORT=%s", _options.Transport))
+		fixed = append(fixed, fmt.Sprintf("_TRANSPORT=%s", _options.Transport))
 	}
 	if _options.User != "" {
-		uid := strings.Split(_options.User, ":")[1]
-		if uid == "" {
-			global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("user field in options is invalid: %s", _options.User), false, false)
+		// user已由checkQueryOptions校验，不合法时以空UID匹配，不放宽查询范围
+		uid, err := public.UserUID(_options.User)
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
 		}
-		_initOptions = append(_initOptions, "_UID="+uid)
+		fixed = append(fixed, "_UID="+uid)
 	}
-	return _initOptions
+	if err := checkFieldMatches(_options.Matches); err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+		matches = append(matches, fixed...)
+	} else {
+		matches = append(matches, fieldMatchArgs(fixed, queryFieldMatches(_options))...)
+	}
+	if grep, err := newGrepMatcher(_options); err == nil && grep != nil && journalctlGrepSupported() {
+		matches = append(matches, grep.args()...)
+	}
+	return matches
 }
 
 func (jclient *JournaldClient) ReturnJournalctlOptions() *public.JournalctlOptions {
@@ -576,6 +835,16 @@ _printstderr: 因journalctl command执行异常而调用releasesource，且打
 func (jclient *JournaldClient) Close(_closeconn, _closechan, _printstderr bool) {
 	global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("==========%-50s==========", fmt.Sprintf("journald client %s call close", jclient.ID)), false, false)
 
+	// 只结束当前查询时保留WriteMessageToClient，供之后的查询使用
+	stop_writer := _closeconn || !jclient.Active
+
+	if jclient.stream != "" {
+		// 子查询与其他查询共享websocket连接，只释放查询资源
+		_closeconn = false
+	} else if _closeconn {
+		jclient.closeStreams()
+	}
+
 	if _closeconn && jclient.wsconn != nil {
 		jclient.Active = false
 		jclient.wswriteMutex.Lock()
@@ -588,23 +857,7 @@ func (jclient *JournaldClient) Close(_closeconn, _closechan, _printstderr bool)
 
 	// 是否打印journalctl command stderr错误信息
 	if _printstderr {
-		readStderrTimeout, cancel := context.WithTimeout(JournaldCtx, jclient.timeout)
-		defer cancel()
-	OuterLoop:
-		for {
-			select {
-			case <-readStderrTimeout.Done():
-				global.ERManager.ErrorTransmit("journald", "warn", errors.New("read stderr timeout, kill journalctl process"), false, false)
-				break OuterLoop
-			case stderrLine, isOpen := <-jclient.errCh:
-				if string(stderrLine) != "" {
-					global.ERManager.ErrorTransmit("journald", "error", errors.New(string(stderrLine)), false, false)
-				}
-				if !isOpen {
-					break OuterLoop
-				}
-			}
-		}
+		jclient.readStderr()
 	}
 
 	if jclient.Jcmd != nil && jclient.Jcmd.Process != nil {
@@ -625,7 +878,11 @@ func (jclient *JournaldClient) Close(_closeconn, _closechan, _printstderr bool)
 	jclient.wg.Wait()
 	global.ERManager.ErrorTransmit("journald", "info", errors.Errorf("journald client:%s all goroutines done", jclient.ID), false, false)
 
-	jclient.closeWriteMsgCh <- struct{}{}
+	if stop_writer {
+		jclient.writerStop.Do(func() {
+			jclient.closeWriteMsgCh <- struct{}{}
+		})
+	}
 
 	if _closechan {
 		jclient.once.Do(func() {
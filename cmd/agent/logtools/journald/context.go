@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 19:46:05 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+// 上下文查询时cursor前、后分别允许返回的最大条数
+const maxContextEntries = 500
+
+// 根据上下文查询范围生成查询条件
+func contextScopeOptions(_options *public.JournalctlOptions) (*public.JournalctlOptions, error) {
+	cursor, err := sdjournal.ParseCursor(_options.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	switch _options.ContextScope {
+	case "", public.ContextScopeAll:
+		return &public.JournalctlOptions{Notail: true}, nil
+	case public.ContextScopeBoot:
+		return &public.JournalctlOptions{Notail: true, Boot: cursor.BootID.String()}, nil
+	case public.ContextScopeQuery:
+		scope := *_options
+		scope.Notail = true
+		if err := checkFieldMatches(scope.Matches); err != nil {
+			return nil, err
+		}
+		if err := checkBoot(scope.Boot); err != nil {
+			return nil, err
+		}
+		if _, err := newQueryFilter(&scope); err != nil {
+			return nil, err
+		}
+		if err := checkTimeRange(&scope); err != nil {
+			return nil, err
+		}
+		return &scope, nil
+	}
+	return nil, errors.Errorf("unsupported context scope: %s", _options.ContextScope)
+}
+
+// 查询cursor对应日志及其前后的日志，cursor对应的日志不受查询范围限制
+func (jclient *JournaldClient) entryContext(_ctx context.Context, _options *public.JournalctlOptions) (*public.EntryContext, error) {
+	if _options.Before < 0 || _options.After < 0 || _options.Before > maxContextEntries || _options.After > maxContextEntries {
+		return nil, errors.Errorf("before/after must be between 0 and %d", maxContextEntries)
+	}
+	if err := checkFieldNames(_options.Fields); err != nil {
+		return nil, err
+	}
+	scope, err := contextScopeOptions(_options)
+	if err != nil {
+		return nil, err
+	}
+	anchor, err := jclient.entryDetail(_ctx, _options.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	source := jclient.newPageSource(scope)
+	defer source.close()
+	raw_entries := []map[string]interface{}{}
+	if _options.Before > 0 {
+		before, err := source.fetch(_ctx, _options.Cursor, false, _options.Before)
+		if err != nil {
+			return nil, err
+		}
+		raw_entries = append(raw_entries, before...)
+	}
+	context := &public.EntryContext{Anchor: -1}
+	if anchor != nil {
+		context.Anchor = len(raw_entries)
+		raw_entries = append(raw_entries, anchor)
+	}
+	if _options.After > 0 {
+		after, err := source.fetch(_ctx, _options.Cursor, true, _options.After)
+		if err != nil {
+			return nil, err
+		}
+		raw_entries = append(raw_entries, after...)
+	}
+
+	grep, _ := newGrepMatcher(_options)
+	context.Hits = make([]map[string]interface{}, 0, len(raw_entries))
+	for _, raw_entry := range raw_entries {
+		entry := generateEntry(raw_entry)
+		addExtraFields(entry, raw_entry, _options.Fields)
+		if grep != nil {
+			grep.markEntry(entry)
+		}
+		context.Hits = append(context.Hits, entry)
+	}
+	return context, nil
+}
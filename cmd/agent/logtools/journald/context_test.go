@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 11:40:17 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+// 按查询范围读取前后日志时从游标开始，时间范围在agent端判断
+func TestContextScopeQuery(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+	options := fixtureTimeRange(t)
+	options.Notail = false
+	options.ContextScope = public.ContextScopeQuery
+
+	tests := []struct {
+		anchor string
+		before []string
+		after  []string
+	}{
+		{"3", []string{"2"}, []string{"4", "5"}},
+		{"2", []string{}, []string{"3", "4", "5"}},
+		{"5", []string{"2", "3", "4"}, []string{}},
+		// 锚点不受查询范围限制
+		{"6", []string{"2", "3", "4", "5"}, []string{}},
+		{"1", []string{}, []string{"2", "3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		options.Cursor = cursors[tt.anchor]
+		scope, err := contextScopeOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := fixtureSource(t, scope)
+		before, err := source.fetch(context.Background(), options.Cursor, false, maxContextEntries)
+		if err != nil {
+			t.Fatalf("anchor %s before: %s", tt.anchor, err)
+		}
+		after, err := source.fetch(context.Background(), options.Cursor, true, maxContextEntries)
+		if err != nil {
+			t.Fatalf("anchor %s after: %s", tt.anchor, err)
+		}
+		if got := rawSeqs(before); !reflect.DeepEqual(got, tt.before) {
+			t.Errorf("anchor %s before: got %v, want %v", tt.anchor, got, tt.before)
+		}
+		if got := rawSeqs(after); !reflect.DeepEqual(got, tt.after) {
+			t.Errorf("anchor %s after: got %v, want %v", tt.anchor, got, tt.after)
+		}
+	}
+}
+
+// 锚点在查询范围之外且与范围之间还有日志
+func TestContextScopeQueryAnchorOutsideRange(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+	options := fixtureSeqRange(t, "3", "4")
+	options.Notail = false
+	options.ContextScope = public.ContextScopeQuery
+
+	tests := []struct {
+		anchor string
+		before []string
+		after  []string
+	}{
+		{"1", []string{}, []string{"3", "4"}},
+		{"6", []string{"3", "4"}, []string{}},
+	}
+	for _, tt := range tests {
+		options.Cursor = cursors[tt.anchor]
+		scope, err := contextScopeOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := fixtureSource(t, scope)
+		before, err := source.fetch(context.Background(), options.Cursor, false, maxContextEntries)
+		if err != nil {
+			t.Fatalf("anchor %s before: %s", tt.anchor, err)
+		}
+		after, err := source.fetch(context.Background(), options.Cursor, true, maxContextEntries)
+		if err != nil {
+			t.Fatalf("anchor %s after: %s", tt.anchor, err)
+		}
+		if got := rawSeqs(before); !reflect.DeepEqual(got, tt.before) {
+			t.Errorf("anchor %s before: got %v, want %v", tt.anchor, got, tt.before)
+		}
+		if got := rawSeqs(after); !reflect.DeepEqual(got, tt.after) {
+			t.Errorf("anchor %s after: got %v, want %v", tt.anchor, got, tt.after)
+		}
+	}
+}
+
+func TestContextScopeOptions(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+	tests := []struct {
+		options *public.JournalctlOptions
+		ok      bool
+	}{
+		{&public.JournalctlOptions{Cursor: cursors["1"]}, true},
+		{&public.JournalctlOptions{Cursor: cursors["1"], ContextScope: public.ContextScopeBoot}, true},
+		{&public.JournalctlOptions{Cursor: cursors["1"], ContextScope: public.ContextScopeQuery, Since: "2026-10-01", Until: "2026-10-02"}, true},
+		{&public.JournalctlOptions{Cursor: cursors["1"], ContextScope: public.ContextScopeQuery, Since: "yesterday", Until: "today"}, false},
+		{&public.JournalctlOptions{Cursor: cursors["1"], ContextScope: public.ContextScopeQuery, Query: "PRIORITY>"}, false},
+		{&public.JournalctlOptions{Cursor: cursors["1"], ContextScope: "unknown"}, false},
+		{&public.JournalctlOptions{Cursor: "invalid"}, false},
+	}
+	for _, tt := range tests {
+		scope, err := contextScopeOptions(tt.options)
+		if (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v", tt.options, err)
+			continue
+		}
+		if err == nil && !scope.Notail {
+			t.Errorf("%+v: scope is not a paging query", tt.options)
+		}
+	}
+}
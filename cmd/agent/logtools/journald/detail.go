@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 19:12:30 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"io"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"github.com/pkg/errors"
+)
+
+// 单条日志允许额外返回的字段数
+const maxExtraFields = 32
+
+func checkFieldNames(_fields []string) error {
+	if len(_fields) > maxExtraFields {
+		return errors.Errorf("too many fields: %d > %d", len(_fields), maxExtraFields)
+	}
+	for _, field := range _fields {
+		if !fieldNameRegexp.MatchString(field) {
+			return errors.Errorf("invalid journal field name: %q", field)
+		}
+	}
+	return nil
+}
+
+// 为返回的日志条目添加调用方指定的字段
+func addExtraFields(_entry, _raw_entry map[string]interface{}, _fields []string) {
+	if len(_fields) == 0 {
+		return
+	}
+	extra := map[string]interface{}{}
+	for _, field := range _fields {
+		if value, ok := _raw_entry[field]; ok {
+			extra[field] = value
+		}
+	}
+	_entry["fields"] = extra
+}
+
+// 根据__CURSOR查询单条日志的全部字段，不存在时返回nil
+func (jclient *JournaldClient) entryDetail(_ctx context.Context, _cursor string) (map[string]interface{}, error) {
+	if _, err := sdjournal.ParseCursor(_cursor); err != nil {
+		return nil, err
+	}
+	if jclient.Reader == NativeReader {
+		journal, err := sdjournal.OpenLocal()
+		if err == nil && journal.Supported() {
+			defer journal.Close()
+			return nativeEntryDetail(journal, _cursor)
+		}
+		if err == nil {
+			journal.Close()
+			err = sdjournal.ErrUnsupportedCompression
+		}
+		global.ERManager.ErrorTransmit("journald", "warn", errors.Wrap(err, "native journal reader unavailable, fall back to journalctl"), false, false)
+	}
+	return execEntryDetail(_ctx, _cursor)
+}
+
+func nativeEntryDetail(_journal *sdjournal.Journal, _cursor string) (map[string]interface{}, error) {
+	if err := _journal.SeekCursor(_cursor); err != nil {
+		return nil, err
+	}
+	entry, err := _journal.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sdjournal.TestCursor(entry, _cursor) {
+		return nil, nil
+	}
+	return entry.JSONMap(), nil
+}
+
+func execEntryDetail(_ctx context.Context, _cursor string) (map[string]interface{}, error) {
+	// --cursor定位到不早于cursor的第一条日志
+	source := &execPageSource{args: []string{"--cursor=" + _cursor}}
+	raw_entries, err := source.fetch(_ctx, "", true, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw_entries) == 0 || entryCursor(raw_entries[0]) != _cursor {
+		return nil, nil
+	}
+	return raw_entries[0], nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 12:24:51 2026 +0800
+ */
+package journald
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+)
+
+func TestCheckFieldNames(t *testing.T) {
+	if err := checkFieldNames(nil); err != nil {
+		t.Errorf("no fields: %s", err)
+	}
+	if err := checkFieldNames([]string{"_SYSTEMD_UNIT", "TAG", "CODE_LINE"}); err != nil {
+		t.Errorf("valid fields: %s", err)
+	}
+	for _, field := range []string{"", "tag", "9TAG", "TAG=x", "--output"} {
+		if err := checkFieldNames([]string{"TAG", field}); err == nil {
+			t.Errorf("%q: expected error", field)
+		}
+	}
+
+	fields := []string{}
+	for i := 0; i < maxExtraFields; i++ {
+		fields = append(fields, "TAG")
+	}
+	if err := checkFieldNames(fields); err != nil {
+		t.Errorf("%d fields: %s", maxExtraFields, err)
+	}
+	if err := checkFieldNames(append(fields, "TAG")); err == nil {
+		t.Errorf("%d fields: expected error", maxExtraFields+1)
+	}
+}
+
+func TestAddExtraFields(t *testing.T) {
+	raw_entry := map[string]interface{}{
+		"MESSAGE": "repeated fields",
+		"TAG":     []interface{}{"one", "two", "three"},
+		"BINARY":  []interface{}{float64(97), float64(0)},
+		"_PID":    "1",
+	}
+
+	// 未指定字段时不添加fields
+	entry := map[string]interface{}{"message": "repeated fields"}
+	addExtraFields(entry, raw_entry, nil)
+	if _, ok := entry["fields"]; ok {
+		t.Errorf("no fields: got %v", entry["fields"])
+	}
+
+	// 只返回日志中存在的字段，数组原样返回
+	addExtraFields(entry, raw_entry, []string{"TAG", "BINARY", "_PID", "MISSING"})
+	want := map[string]interface{}{
+		"TAG":    []interface{}{"one", "two", "three"},
+		"BINARY": []interface{}{float64(97), float64(0)},
+		"_PID":   "1",
+	}
+	if !reflect.DeepEqual(entry["fields"], want) {
+		t.Errorf("got %v, want %v", entry["fields"], want)
+	}
+
+	addExtraFields(entry, raw_entry, []string{"MISSING"})
+	if !reflect.DeepEqual(entry["fields"], map[string]interface{}{}) {
+		t.Errorf("missing field: got %v", entry["fields"])
+	}
+}
+
+// 直接读取journal文件的结果与journalctl --output=json一致，包括重复字段和二进制字段
+func TestNativeEntryDetail(t *testing.T) {
+	journal, err := sdjournal.OpenDirs(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	last := ""
+	for _, want := range fixtureRawEntries(t) {
+		cursor := want["__CURSOR"].(string)
+		got, err := nativeEntryDetail(journal, cursor)
+		if err != nil {
+			t.Fatalf("%s: %s", cursor, err)
+		}
+		for field, value := range want {
+			if !reflect.DeepEqual(got[field], value) {
+				t.Errorf("%s: field %s: got %#v, want %#v", cursor, field, got[field], value)
+			}
+		}
+		last = cursor
+	}
+
+	// cursor指向的日志不存在时返回nil
+	missing := strings.Replace(last, ";t=", "0;t=", 1)
+	got, err := nativeEntryDetail(journal, missing)
+	if err != nil || got != nil {
+		t.Errorf("missing cursor: got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 10:03:52 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// 通知客户端查询失败，直接写入websocket连接，不经过dataCh
+func (jclient *JournaldClient) sendError(_code string, _err error, _stderr []string) {
+	jclient.writeError(jclient.stream, &public.ErrorInfo{Code: _code, Message: _err.Error(), Stderr: _stderr})
+}
+
+func (jclient *JournaldClient) writeError(_stream string, _info *public.ErrorInfo) {
+	if jclient.wsconn == nil {
+		return
+	}
+	jmsgBytes, err := json.Marshal(&public.JMessage{
+		Type:   public.ErrorMsg,
+		Data:   _info,
+		Stream: _stream,
+	})
+	if err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to marshal message: %s", err.Error()), false, false)
+		return
+	}
+	jclient.wswriteMutex.Lock()
+	defer jclient.wswriteMutex.Unlock()
+	if err := jclient.wsconn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("error while writing error message to ws client: %s", err.Error()), false, false)
+		return
+	}
+	metrics.WebsocketSentBytes.Add(float64(len(jmsgBytes)), metrics.SourceJournald)
+}
+
+// 读取command的stderr输出并写入日志，stderr读取结束或超时后返回
+func (jclient *JournaldClient) readStderr() []string {
+	readStderrTimeout, cancel := context.WithTimeout(JournaldCtx, jclient.timeout)
+	defer cancel()
+
+	lines := []string{}
+	for {
+		select {
+		case <-readStderrTimeout.Done():
+			global.ERManager.ErrorTransmit("journald", "warn", errors.New("read stderr timeout, kill journalctl process"), false, false)
+			return lines
+		case stderrLine, isOpen := <-jclient.errCh:
+			// readFromStderr退出时发送nil
+			if !isOpen || stderrLine == nil {
+				return lines
+			}
+			if line := strings.TrimRight(string(stderrLine), "\n"); line != "" {
+				global.ERManager.ErrorTransmit("journald", "error", errors.New(line), false, false)
+				lines = append(lines, line)
+			}
+		}
+	}
+}
+
+// 丢弃上一次查询未读取的stderr输出
+func (jclient *JournaldClient) discardStderr() {
+	for {
+		select {
+		case _, isOpen := <-jclient.errCh:
+			if !isOpen {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
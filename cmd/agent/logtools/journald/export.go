@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 10:12:37 2026 +0800
+ */
+package journald
+
+import (
+	"bytes"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+// 导出结果每段的大小
+const exportChunkSize = 64 * 1024
+
+/*
+按时间顺序编码查询范围内的全部日志，每积累exportChunkSize发送一段
+
+dataCh写满时等待客户端读取，agent不缓存全部导出结果；最后发送done为true的一段
+*/
+func (jclient *JournaldClient) serveExport(_encoder public.ExportEncoder) {
+	defer jclient.wg.Done()
+
+	source := jclient.newPageSource(jclient.options)
+	defer source.close()
+
+	entries := 0
+	buf := &bytes.Buffer{}
+	// 编码失败或客户端断开时停止遍历
+	err := source.each(jclient.CancelC, func(_raw_entry map[string]interface{}) error {
+		if err := _encoder.Encode(buf, _raw_entry); err != nil {
+			return err
+		}
+		entries++
+		if buf.Len() >= exportChunkSize {
+			if !jclient.sendStdoutData(&public.StdoutData{Type: public.ExportData, Data: &public.ExportChunk{Data: buf.Bytes()}}) {
+				return jclient.CancelC.Err()
+			}
+			buf = &bytes.Buffer{}
+		}
+		return nil
+	})
+	if jclient.CancelC.Err() != nil {
+		global.ERManager.ErrorTransmit("journald", "warn", errors.New("jclient.serveExport() exit, cancelctx canceled"), false, false)
+		return
+	}
+	if err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "fail to export journal entries"), false, false)
+		jclient.sendError(public.ErrCodeQueryFailed, err, nil)
+	}
+	if buf.Len() > 0 && err == nil {
+		if !jclient.sendStdoutData(&public.StdoutData{Type: public.ExportData, Data: &public.ExportChunk{Data: buf.Bytes()}}) {
+			return
+		}
+	}
+	jclient.sendStdoutData(&public.StdoutData{Type: public.ExportData, Data: &public.ExportChunk{Done: true, Entries: entries}})
+}
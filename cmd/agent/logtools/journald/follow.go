@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Tue Oct 20 09:26:37 2026 +0800
+ */
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+// 后台持续读取时单条日志的最大长度
+const maxFollowEntryBytes = 16 * 1024 * 1024
+
+// 实时查询断线重连时客户端发送的游标
+func checkAfterCursor(_options *public.JournalctlOptions) error {
+	if _options.Notail || _options.AfterCursor == "" {
+		return nil
+	}
+	if _, err := sdjournal.ParseCursor(_options.AfterCursor); err != nil {
+		return errors.Wrapf(err, "invalid after_cursor %q", _options.AfterCursor)
+	}
+	return nil
+}
+
+// 实时查询的journalctl参数：断线重连时从游标之后返回全部日志，否则先返回最近的若干条
+func followOptions(_options *public.JournalctlOptions) []string {
+	if _options.AfterCursor != "" && checkAfterCursor(_options) == nil {
+		// --follow默认只返回最近10条，--no-tail使--after-cursor之后的日志全部返回
+		return []string{"--follow", "--no-tail", "--after-cursor=" + _options.AfterCursor}
+	}
+	return []string{"--follow"}
+}
+
+/*
+将读取位置移动到_cursor对应的日志之后，Next返回此后满足过滤条件的日志
+
+cursor对应的日志已被轮转删除时从游标中的时间之后继续
+*/
+func seekAfterCursor(_journal *sdjournal.Journal, _cursor string) error {
+	if err := _journal.SeekCursor(_cursor); err != nil {
+		return err
+	}
+	entry, err := _journal.Next()
+	if err == io.EOF {
+		return _journal.SeekCursor(_cursor)
+	}
+	if err != nil {
+		return err
+	}
+	if !sdjournal.TestCursor(entry, _cursor) {
+		// 第一条日志不是cursor对应的日志，重新定位使Next再次返回该日志
+		return _journal.SeekCursor(_cursor)
+	}
+	return nil
+}
+
+// 校验后台持续读取journal日志（如syslog转发）的查询条件
+func CheckFollowOptions(_options *public.JournalctlOptions) error {
+	if _, err := public.UserUID(_options.User); err != nil {
+		return err
+	}
+	if _options.Severity != "" {
+		if _, err := parsePriorityRange(_options.Severity); err != nil {
+			return err
+		}
+	}
+	if err := checkFieldMatches(_options.Matches); err != nil {
+		return err
+	}
+	if err := checkBoot(_options.Boot); err != nil {
+		return err
+	}
+	if _, err := newGrepMatcher(_options); err != nil {
+		return err
+	}
+	_, err := newQueryFilter(_options)
+	return err
+}
+
+// journal中最后一条日志的游标，journal为空时返回空字符串
+func TailCursor(_ctx context.Context) (string, error) {
+	args := append([]string{}, PageLogDefaultOptions...)
+	cmd := exec.CommandContext(_ctx, "journalctl", append(args, "--lines=1")...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	metrics.JournalctlStarted()
+	output, err := cmd.Output()
+	if err != nil {
+		metrics.JournalctlFailed(err, cmd.ProcessState == nil)
+		return "", errors.Errorf("err while running journalctl: %s, %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return "", nil
+	}
+	raw_entry := map[string]interface{}{}
+	if err := json.Unmarshal(output, &raw_entry); err != nil {
+		return "", errors.Errorf("fail to unmarshal Journald JSON: %s", err)
+	}
+	return entryCursor(raw_entry), nil
+}
+
+/*
+按查询条件持续读取journal日志，since、until及分页参数无效
+
+_after为空时从journal的第一条日志开始，否则从_after对应的日志之后开始；只读取新写入的日志时先通过TailCursor获取游标
+（journalctl --follow --lines=0与字段匹配同时使用时会漏掉第一条新日志）；
+
+_fn返回错误或_ctx取消时结束读取，journalctl退出时返回错误
+*/
+func FollowEntries(_ctx context.Context, _options *public.JournalctlOptions, _after string, _fn func(map[string]interface{}) error) error {
+	if err := CheckFollowOptions(_options); err != nil {
+		return err
+	}
+	if _after != "" {
+		if _, err := sdjournal.ParseCursor(_after); err != nil {
+			return errors.Wrapf(err, "invalid cursor %q", _after)
+		}
+	}
+	grep, _ := newGrepMatcher(_options)
+	query, _ := newQueryFilter(_options)
+	options := *_options
+	options.Notail = false
+
+	args := append([]string{}, FollowLogDefaultOptions...)
+	args = append(args, "--follow", "--no-tail")
+	if _after != "" {
+		args = append(args, "--after-cursor="+_after)
+	}
+	args = append(args, assembleMatches(&options)...)
+
+	ctx, cancel := context.WithCancel(_ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Errorf("cannot get stdout pipe: %s", err)
+	}
+	metrics.JournalctlStarted()
+	if err := cmd.Start(); err != nil {
+		metrics.JournalctlFailed(err, true)
+		return errors.Errorf("cannot start journalctl: %s", err)
+	}
+
+	var fn_err error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxFollowEntryBytes)
+	for scanner.Scan() {
+		raw_entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw_entry); err != nil {
+			continue
+		}
+		// journalctl不支持--grep时在agent端过滤
+		if grep != nil && !journalctlGrepSupported() && !grep.matchRaw(raw_entry) {
+			continue
+		}
+		if query != nil && !query.matchRaw(raw_entry) {
+			continue
+		}
+		if fn_err = _fn(raw_entry); fn_err != nil {
+			break
+		}
+	}
+	cancel()
+	wait_err := cmd.Wait()
+	switch {
+	case fn_err != nil:
+		return fn_err
+	case _ctx.Err() != nil:
+		return nil
+	case scanner.Err() != nil:
+		return errors.Errorf("fail to read journalctl output: %s", scanner.Err())
+	case wait_err != nil:
+		metrics.JournalctlFailed(wait_err, false)
+		return errors.Errorf("err while running journalctl: %s, %s", wait_err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return errors.New("journalctl exited")
+}
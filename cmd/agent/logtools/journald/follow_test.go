@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 12:52:08 2026 +0800
+ */
+package journald
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestFollowOptions(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+	tests := []struct {
+		name    string
+		options public.JournalctlOptions
+		ok      bool
+		args    []string
+	}{
+		{"tail", public.JournalctlOptions{}, true, []string{"--follow"}},
+		{"after cursor", public.JournalctlOptions{AfterCursor: cursors["3"]}, true, []string{"--follow", "--no-tail", "--after-cursor=" + cursors["3"]}},
+		{"invalid cursor", public.JournalctlOptions{AfterCursor: "x"}, false, []string{"--follow"}},
+		// 非实时查询时忽略after_cursor
+		{"notail", public.JournalctlOptions{Notail: true, AfterCursor: "x"}, true, nil},
+	}
+	for _, tt := range tests {
+		if err := checkAfterCursor(&tt.options); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+		if tt.options.Notail {
+			continue
+		}
+		if got := followOptions(&tt.options); !reflect.DeepEqual(got, tt.args) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.args)
+		}
+	}
+}
+
+// 断线重连后从游标之后的第一条日志继续
+func TestSeekAfterCursor(t *testing.T) {
+	journal, err := sdjournal.OpenDirs(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	raw_entries := fixtureRawEntries(t)
+	for i, raw_entry := range raw_entries {
+		cursor := raw_entry["__CURSOR"].(string)
+		if err := seekAfterCursor(journal, cursor); err != nil {
+			t.Fatalf("%s: %s", cursor, err)
+		}
+		entry, err := journal.Next()
+		if i == len(raw_entries)-1 {
+			if err != io.EOF {
+				t.Errorf("after last entry: got %v, %v", entry, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", cursor, err)
+		}
+		if want := raw_entries[i+1]["__CURSOR"].(string); entry.Cursor() != want {
+			t.Errorf("after %s: got %s, want %s", cursor, entry.Cursor(), want)
+		}
+	}
+}
+
+func TestSeekAfterCursorFilter(t *testing.T) {
+	journal, err := sdjournal.OpenDirs(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	realtimes, cursors := fixtureEntries(t)
+
+	// 只返回游标之后满足过滤条件的日志
+	journal.SetFilter(sdjournal.FieldMatch{Field: "PRIORITY", Value: "4"})
+	if err := seekAfterCursor(journal, cursors["2"]); err != nil {
+		t.Fatal(err)
+	}
+	seqs := []string{}
+	for {
+		entry, err := journal.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, entry.Fields["FIXTURE_SEQ"])
+	}
+	if want := []string{"4", "6"}; !reflect.DeepEqual(seqs, want) {
+		t.Errorf("got %v, want %v", seqs, want)
+	}
+
+	// 游标对应的日志已不存在时从游标中的时间之后继续
+	journal.SetFilter(sdjournal.FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: "fixture"})
+	removed := "s=00000000000000000000000000000001;i=1;b=00000000000000000000000000000001;m=1;t=" + strconv.FormatUint(realtimes["3"]+1, 16) + ";x=1"
+	if err := seekAfterCursor(journal, removed); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := journal.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.Fields["FIXTURE_SEQ"]; got != "4" {
+		t.Errorf("removed cursor: got entry %q, want 4", got)
+	}
+}
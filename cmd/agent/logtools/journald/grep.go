@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 17:02:44 2026 +0800
+ */
+package journald
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+var (
+	grepSupportedOnce sync.Once
+	grepSupported     bool
+)
+
+// journalctl --grep依赖编译时的pcre2支持（systemd >= 237），首次使用时检测
+func journalctlGrepSupported() bool {
+	grepSupportedOnce.Do(func() {
+		cmd := exec.Command("journalctl", "--quiet", "--no-pager", "--lines=0", "--grep=^", "--case-sensitive=true")
+		if err := cmd.Run(); err != nil {
+			global.ERManager.ErrorTransmit("journald", "warn", errors.Errorf("journalctl --grep unsupported, filter messages in agent: %s", err), false, false)
+			return
+		}
+		grepSupported = true
+	})
+	return grepSupported
+}
+
+// 按MESSAGE内容搜索日志，同时实现sdjournal.Filter
+type grepMatcher struct {
+	// 传递给journalctl --grep的表达式
+	pattern    string
+	ignoreCase bool
+	re         *regexp.Regexp
+}
+
+var _ sdjournal.Filter = (*grepMatcher)(nil)
+
+// 查询条件中未设置grep时返回nil
+func newGrepMatcher(_options *public.JournalctlOptions) (*grepMatcher, error) {
+	if _options.Grep == "" {
+		return nil, nil
+	}
+	g := &grepMatcher{
+		pattern:    _options.Grep,
+		ignoreCase: _options.GrepIgnoreCase,
+	}
+	if !_options.GrepRegex {
+		g.pattern = regexp.QuoteMeta(_options.Grep)
+	}
+	expr := g.pattern
+	if g.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Errorf("invalid grep pattern %q: %s", _options.Grep, err)
+	}
+	g.re = re
+	return g, nil
+}
+
+func (g *grepMatcher) args() []string {
+	return []string{"--grep", g.pattern, "--case-sensitive=" + strconv.FormatBool(!g.ignoreCase)}
+}
+
+func (g *grepMatcher) Match(_e *sdjournal.Entry) bool {
+	return g.re.MatchString(_e.Fields["MESSAGE"])
+}
+
+func (g *grepMatcher) matchRaw(_raw_entry map[string]interface{}) bool {
+	message, _ := _raw_entry["MESSAGE"].(string)
+	return g.re.MatchString(message)
+}
+
+// MESSAGE中所有匹配位置，[起始, 结束)均为字符偏移，供前端高亮
+func (g *grepMatcher) offsets(_message string) [][2]int {
+	offsets := [][2]int{}
+	for _, loc := range g.re.FindAllStringIndex(_message, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		start := utf8.RuneCountInString(_message[:loc[0]])
+		offsets = append(offsets, [2]int{start, start + utf8.RuneCountInString(_message[loc[0]:loc[1]])})
+	}
+	return offsets
+}
+
+// 为返回的日志条目添加匹配位置
+func (g *grepMatcher) markEntry(_entry map[string]interface{}) {
+	message, _ := _entry["message"].(string)
+	_entry["matches"] = g.offsets(message)
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 12:04:17 2026 +0800
+ */
+package journald
+
+import (
+	"reflect"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestNewGrepMatcher(t *testing.T) {
+	g, err := newGrepMatcher(&public.JournalctlOptions{})
+	if err != nil || g != nil {
+		t.Fatalf("empty grep: got %v, %v", g, err)
+	}
+
+	tests := []struct {
+		name    string
+		options public.JournalctlOptions
+		args    []string
+		match   []string
+		nomatch []string
+	}{
+		{
+			name:    "literal",
+			options: public.JournalctlOptions{Grep: "a.b"},
+			args:    []string{"--grep", `a\.b`, "--case-sensitive=true"},
+			match:   []string{"xa.by"},
+			nomatch: []string{"axb", "A.B"},
+		},
+		{
+			name:    "regex",
+			options: public.JournalctlOptions{Grep: "^a.b$", GrepRegex: true},
+			args:    []string{"--grep", "^a.b$", "--case-sensitive=true"},
+			match:   []string{"axb", "a.b"},
+			nomatch: []string{"xaxb", "A.B"},
+		},
+		{
+			name:    "ignore case",
+			options: public.JournalctlOptions{Grep: "Error", GrepIgnoreCase: true},
+			args:    []string{"--grep", "Error", "--case-sensitive=false"},
+			match:   []string{"ERROR", "some error"},
+			nomatch: []string{"err"},
+		},
+	}
+	for _, tt := range tests {
+		g, err := newGrepMatcher(&tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := g.args(); !reflect.DeepEqual(got, tt.args) {
+			t.Errorf("%s: got args %q, want %q", tt.name, got, tt.args)
+		}
+		for _, message := range tt.match {
+			if !g.Match(&sdjournal.Entry{Fields: map[string]string{"MESSAGE": message}}) || !g.matchRaw(map[string]interface{}{"MESSAGE": message}) {
+				t.Errorf("%s: %q not matched", tt.name, message)
+			}
+		}
+		for _, message := range tt.nomatch {
+			if g.Match(&sdjournal.Entry{Fields: map[string]string{"MESSAGE": message}}) || g.matchRaw(map[string]interface{}{"MESSAGE": message}) {
+				t.Errorf("%s: %q matched", tt.name, message)
+			}
+		}
+	}
+
+	if _, err := newGrepMatcher(&public.JournalctlOptions{Grep: "(", GrepRegex: true}); err == nil {
+		t.Error("invalid regex: expected error")
+	}
+	// 非正则表达式模式下特殊字符按字面匹配
+	if _, err := newGrepMatcher(&public.JournalctlOptions{Grep: "("}); err != nil {
+		t.Errorf("literal: %s", err)
+	}
+	// 没有MESSAGE字段时不匹配
+	if g, _ := newGrepMatcher(&public.JournalctlOptions{Grep: "a"}); g.matchRaw(map[string]interface{}{}) {
+		t.Error("missing MESSAGE matched")
+	}
+}
+
+func TestGrepOffsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		options public.JournalctlOptions
+		message string
+		want    [][2]int
+	}{
+		{"none", public.JournalctlOptions{Grep: "x"}, "abc", [][2]int{}},
+		{"all", public.JournalctlOptions{Grep: "ab"}, "ab ab", [][2]int{{0, 2}, {3, 5}}},
+		{"ignore case", public.JournalctlOptions{Grep: "ab", GrepIgnoreCase: true}, "xAbaB", [][2]int{{1, 3}, {3, 5}}},
+		// 偏移量按字符而非字节计算
+		{"multibyte", public.JournalctlOptions{Grep: "错误"}, "日志错误：磁盘错误", [][2]int{{2, 4}, {7, 9}}},
+		{"multibyte match", public.JournalctlOptions{Grep: "é+", GrepRegex: true}, "aéébé", [][2]int{{1, 3}, {4, 5}}},
+		// 空匹配不返回
+		{"empty match", public.JournalctlOptions{Grep: "x*", GrepRegex: true}, "axxb", [][2]int{{1, 3}}},
+	}
+	for _, tt := range tests {
+		g, err := newGrepMatcher(&tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := g.offsets(tt.message); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGrepMarkEntry(t *testing.T) {
+	g, err := newGrepMatcher(&public.JournalctlOptions{Grep: "disk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := map[string]interface{}{"message": "disk full on disk0"}
+	g.markEntry(entry)
+	if got, want := entry["matches"], [][2]int{{0, 4}, {13, 17}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// 没有message时matches为空列表
+	entry = map[string]interface{}{}
+	g.markEntry(entry)
+	if got, want := entry["matches"], [][2]int{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("no message: got %v, want %v", got, want)
+	}
+}
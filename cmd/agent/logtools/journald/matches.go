@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 17:48:09 2026 +0800
+ */
+package journald
+
+import (
+	"regexp"
+	"strings"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+// 单次查询允许的字段匹配条件总数
+const maxFieldMatches = 64
+
+// journal字段名只能包含大写字母、数字和下划线，且不能以数字开头
+var fieldNameRegexp = regexp.MustCompile(`^[A-Z_][A-Z0-9_]{0,63}$`)
+
+// 校验字段匹配条件，避免向journalctl传入字段匹配以外的参数
+func checkFieldMatches(_matches [][]public.FieldMatch) error {
+	count := 0
+	for _, group := range _matches {
+		for _, m := range group {
+			if !fieldNameRegexp.MatchString(m.Field) {
+				return errors.Errorf("invalid journal field name: %q", m.Field)
+			}
+			// "__"开头的为__CURSOR等journal内部字段，不能用于匹配
+			if strings.HasPrefix(m.Field, "__") {
+				return errors.Errorf("journal field %s cannot be matched", m.Field)
+			}
+			if strings.ContainsRune(m.Value, '\n') {
+				return errors.Errorf("value of journal field %s contains newline", m.Field)
+			}
+			count++
+		}
+	}
+	if count > maxFieldMatches {
+		return errors.Errorf("too many field matches: %d > %d", count, maxFieldMatches)
+	}
+	return nil
+}
+
+/*
+生成journalctl的FIELD=VALUE参数
+
+_fixed: 所有组共同的匹配条件（_TRANSPORT、_UID），在每个组中重复以保持AND语义
+
+_matches: 组之间以"+"分隔，表示OR
+*/
+func fieldMatchArgs(_fixed []string, _matches [][]public.FieldMatch) []string {
+	args := []string{}
+	for _, group := range _matches {
+		if len(group) == 0 {
+			continue
+		}
+		if len(args) != 0 {
+			args = append(args, "+")
+		}
+		args = append(args, _fixed...)
+		for _, m := range group {
+			args = append(args, m.Field+"="+m.Value)
+		}
+	}
+	if len(args) == 0 {
+		return _fixed
+	}
+	return args
+}
+
+// 与journalctl语义一致的过滤器，没有匹配条件时返回nil
+func fieldMatchFilter(_matches [][]public.FieldMatch) sdjournal.Filter {
+	groups := sdjournal.Or{}
+	for _, group := range _matches {
+		if len(group) == 0 {
+			continue
+		}
+		fields := []string{}
+		values := map[string][]string{}
+		for _, m := range group {
+			if _, ok := values[m.Field]; !ok {
+				fields = append(fields, m.Field)
+			}
+			values[m.Field] = append(values[m.Field], m.Value)
+		}
+		filter := sdjournal.And{}
+		for _, field := range fields {
+			filter = append(filter, sdjournal.FieldIn(field, values[field]...))
+		}
+		groups = append(groups, filter)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	return groups
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 12:13:06 2026 +0800
+ */
+package journald
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestCheckFieldMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches [][]public.FieldMatch
+		ok      bool
+	}{
+		{"empty", nil, true},
+		{"valid", [][]public.FieldMatch{{{Field: "_SYSTEMD_UNIT", Value: "a.service"}}, {{Field: "PRIORITY", Value: ""}}}, true},
+		{"lower case", [][]public.FieldMatch{{{Field: "priority", Value: "3"}}}, false},
+		{"leading digit", [][]public.FieldMatch{{{Field: "1FIELD", Value: "x"}}}, false},
+		{"option", [][]public.FieldMatch{{{Field: "--since", Value: "x"}}}, false},
+		{"internal", [][]public.FieldMatch{{{Field: "__CURSOR", Value: "x"}}}, false},
+		{"newline", [][]public.FieldMatch{{{Field: "MESSAGE", Value: "a\nb"}}}, false},
+		{"too long", [][]public.FieldMatch{{{Field: strings.Repeat("A", 65), Value: "x"}}}, false},
+	}
+	for _, tt := range tests {
+		if err := checkFieldMatches(tt.matches); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+
+	matches := [][]public.FieldMatch{}
+	for i := 0; i < maxFieldMatches; i++ {
+		matches = append(matches, []public.FieldMatch{{Field: "PRIORITY", Value: "3"}})
+	}
+	if err := checkFieldMatches(matches); err != nil {
+		t.Errorf("%d matches: %s", maxFieldMatches, err)
+	}
+	matches[0] = append(matches[0], public.FieldMatch{Field: "PRIORITY", Value: "4"})
+	if err := checkFieldMatches(matches); err == nil {
+		t.Errorf("%d matches: expected error", maxFieldMatches+1)
+	}
+}
+
+func TestFieldMatchArgs(t *testing.T) {
+	fixed := []string{"_TRANSPORT=kernel"}
+	tests := []struct {
+		name    string
+		fixed   []string
+		matches [][]public.FieldMatch
+		want    []string
+	}{
+		{"no matches", fixed, nil, fixed},
+		{"empty groups", fixed, [][]public.FieldMatch{{}, {}}, fixed},
+		{"no fixed", nil, [][]public.FieldMatch{{{Field: "PRIORITY", Value: "3"}}}, []string{"PRIORITY=3"}},
+		{
+			name:    "single group",
+			fixed:   fixed,
+			matches: [][]public.FieldMatch{{{Field: "PRIORITY", Value: "3"}, {Field: "PRIORITY", Value: "4"}, {Field: "_PID", Value: "1"}}},
+			want:    []string{"_TRANSPORT=kernel", "PRIORITY=3", "PRIORITY=4", "_PID=1"},
+		},
+		{
+			// 组之间以"+"分隔，每组重复共同条件，跳过空组
+			name:    "groups",
+			fixed:   fixed,
+			matches: [][]public.FieldMatch{{{Field: "PRIORITY", Value: "3"}}, {}, {{Field: "_PID", Value: "1"}}},
+			want:    []string{"_TRANSPORT=kernel", "PRIORITY=3", "+", "_TRANSPORT=kernel", "_PID=1"},
+		},
+		{"value with equal sign", nil, [][]public.FieldMatch{{{Field: "MESSAGE", Value: "a=b"}}}, []string{"MESSAGE=a=b"}},
+	}
+	for _, tt := range tests {
+		if got := fieldMatchArgs(tt.fixed, tt.matches); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 与journalctl一致：同名字段之间为OR，不同字段之间为AND，组之间为OR
+func TestFieldMatchFilter(t *testing.T) {
+	if f := fieldMatchFilter(nil); f != nil {
+		t.Errorf("no matches: got %#v", f)
+	}
+	if f := fieldMatchFilter([][]public.FieldMatch{{}}); f != nil {
+		t.Errorf("empty group: got %#v", f)
+	}
+
+	filter := fieldMatchFilter([][]public.FieldMatch{
+		{{Field: "PRIORITY", Value: "3"}, {Field: "_PID", Value: "1"}, {Field: "PRIORITY", Value: "4"}},
+		{},
+		{{Field: "SYSLOG_IDENTIFIER", Value: "kernel"}},
+	})
+	tests := []struct {
+		name   string
+		fields map[string]string
+		// 出现多次的字段
+		repeated map[string][]string
+		want     bool
+	}{
+		{"first value", map[string]string{"PRIORITY": "3", "_PID": "1"}, nil, true},
+		{"second value", map[string]string{"PRIORITY": "4", "_PID": "1"}, nil, true},
+		{"other value", map[string]string{"PRIORITY": "5", "_PID": "1"}, nil, false},
+		{"missing field", map[string]string{"PRIORITY": "3"}, nil, false},
+		{"second group", map[string]string{"SYSLOG_IDENTIFIER": "kernel"}, nil, true},
+		{"none", map[string]string{"SYSLOG_IDENTIFIER": "systemd"}, nil, false},
+		{"repeated field", map[string]string{"PRIORITY": "5", "_PID": "1"}, map[string][]string{"PRIORITY": {"5", "4"}}, true},
+	}
+	for _, tt := range tests {
+		e := &sdjournal.Entry{Fields: tt.fields, Repeated: tt.repeated}
+		if got := filter.Match(e); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
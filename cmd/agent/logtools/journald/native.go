@@ -0,0 +1,290 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package journald
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+// journal读取方式
+const (
+	ExecReader   = "exec"   // 调用journalctl
+	NativeReader = "native" // 直接解析journal文件
+)
+
+// 实时模式下首次返回的日志条数，与journalctl --follow保持一致
+const followInitEntries = 10
+
+var priorityNames = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"warning": 4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+/*
+打开本机journal并根据查询条件设置过滤器
+
+journal文件不可读、包含无法解析的压缩格式或查询条件不受支持时返回error，由调用方回退至journalctl
+*/
+func openNativeJournal(_options *public.JournalctlOptions) (*sdjournal.Journal, error) {
+	filter, err := nativeFilter(_options)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := sdjournal.OpenLocal()
+	if err != nil {
+		return nil, err
+	}
+	if !journal.Supported() {
+		journal.Close()
+		return nil, errors.Wrap(sdjournal.ErrUnsupportedCompression, "native journal reader")
+	}
+	if _options.Boot != "" {
+		boot_id, err := resolveBoot(_options.Boot, journal)
+		if err != nil {
+			journal.Close()
+			return nil, err
+		}
+		filter = sdjournal.And{filter, sdjournal.BootMatch(boot_id)}
+	}
+	journal.SetFilter(filter)
+	return journal, nil
+}
+
+// 与journalctl对--unit、--identifier、--priority等参数的处理保持一致
+func nativeFilter(_options *public.JournalctlOptions) (sdjournal.Filter, error) {
+	filter := sdjournal.And{}
+	if _options.Unit != "" {
+		unit := _options.Unit
+		if !strings.Contains(unit, ".") {
+			unit += ".service"
+		}
+		filter = append(filter, sdjournal.Or{
+			sdjournal.FieldMatch{Field: "_SYSTEMD_UNIT", Value: unit},
+			sdjournal.And{
+				sdjournal.FieldMatch{Field: "MESSAGE_ID", Value: "fc2e22bc6ee647b6b90729ab34a250b1"},
+				sdjournal.FieldMatch{Field: "_UID", Value: "0"},
+				sdjournal.FieldMatch{Field: "COREDUMP_UNIT", Value: unit},
+			},
+			sdjournal.And{
+				sdjournal.FieldMatch{Field: "_PID", Value: "1"},
+				sdjournal.FieldMatch{Field: "UNIT", Value: unit},
+			},
+			sdjournal.And{
+				sdjournal.FieldMatch{Field: "_UID", Value: "0"},
+				sdjournal.FieldMatch{Field: "OBJECT_SYSTEMD_UNIT", Value: unit},
+			},
+		})
+	}
+	if _options.Identifier != "" {
+		filter = append(filter, sdjournal.FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: _options.Identifier})
+	}
+	if _options.Severity != "" {
+		priorities, err := parsePriorityRange(_options.Severity)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, sdjournal.FieldIn("PRIORITY", priorities...))
+	}
+	if _options.Transport != "" {
+		filter = append(filter, sdjournal.FieldMatch{Field: "_TRANSPORT", Value: _options.Transport})
+	}
+	if _options.User != "" {
+		uid, err := public.UserUID(_options.User)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, sdjournal.FieldMatch{Field: "_UID", Value: uid})
+	}
+	if err := checkFieldMatches(_options.Matches); err != nil {
+		return nil, err
+	}
+	if matches := fieldMatchFilter(queryFieldMatches(_options)); matches != nil {
+		filter = append(filter, matches)
+	}
+	grep, err := newGrepMatcher(_options)
+	if err != nil {
+		return nil, err
+	}
+	if grep != nil {
+		filter = append(filter, grep)
+	}
+	query, err := newQueryFilter(_options)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		filter = append(filter, query)
+	}
+	return filter, nil
+}
+
+// --priority支持单个等级或"FROM..TO"范围，单个等级表示0..N
+func parsePriorityRange(_severity string) ([]string, error) {
+	parse := func(_s string) (int, error) {
+		if p, ok := priorityNames[_s]; ok {
+			return p, nil
+		}
+		p, err := strconv.Atoi(_s)
+		if err != nil || p < 0 || p > 7 {
+			return 0, errors.Errorf("invalid priority: %s", _s)
+		}
+		return p, nil
+	}
+
+	from, to := 0, 0
+	var err error
+	if r := strings.SplitN(_severity, "..", 2); len(r) == 2 {
+		if from, err = parse(r[0]); err != nil {
+			return nil, err
+		}
+		if to, err = parse(r[1]); err != nil {
+			return nil, err
+		}
+		if from > to {
+			from, to = to, from
+		}
+	} else if to, err = parse(_severity); err != nil {
+		return nil, err
+	}
+
+	priorities := []string{}
+	for p := from; p <= to; p++ {
+		priorities = append(priorities, strconv.Itoa(p))
+	}
+	return priorities, nil
+}
+
+func entryJSONLine(_e *sdjournal.Entry) (string, error) {
+	bytes, err := json.Marshal(_e.JSONMap())
+	if err != nil {
+		return "", errors.Errorf("fail to marshal journal entry: %s", err.Error())
+	}
+	return string(bytes) + "\n", nil
+}
+
+// 查询失败时通知前端
+func (jclient *JournaldClient) sendAbnormal() {
+	if jclient.options != nil && jclient.options.Notail {
+		jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: (*public.PageData)(nil)})
+		return
+	}
+	jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: "abnormal"})
+}
+
+func (jclient *JournaldClient) sendStdoutData(_data *public.StdoutData) bool {
+	select {
+	case <-jclient.CancelC.Done():
+		return false
+	case jclient.dataCh <- _data:
+		return true
+	}
+}
+
+// 使用sdjournal实时读取日志条目，输出格式与journalctl --output=json一致
+func (jclient *JournaldClient) readFromJournal(_journal *sdjournal.Journal) {
+	defer jclient.wg.Done()
+	defer _journal.Close()
+
+	jclient.followJournal(_journal)
+}
+
+func (jclient *JournaldClient) followJournal(_journal *sdjournal.Journal) {
+	if after_cursor := jclient.options.AfterCursor; after_cursor != "" {
+		// 断线重连：从游标对应的日志之后继续返回
+		if err := seekAfterCursor(_journal, after_cursor); err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "jclient.followJournal() exit: "), false, false)
+			jclient.sendError(public.ErrCodeJournalRead, err, nil)
+			jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: "abnormal"})
+			return
+		}
+	} else if !jclient.sendFollowTail(_journal) {
+		return
+	}
+
+	for {
+		select {
+		case <-jclient.CancelC.Done():
+			global.ERManager.ErrorTransmit("journald", "warn", errors.New("jclient.followJournal() exit, cancelctx canceled"), false, false)
+			return
+		default:
+		}
+
+		entry, err := _journal.Next()
+		if err == io.EOF {
+			select {
+			case <-jclient.CancelC.Done():
+				continue
+			case <-time.After(global.JournalPollInterval):
+			}
+			if err := _journal.Refresh(); err != nil {
+				global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+			}
+			continue
+		}
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "jclient.followJournal() exit: "), false, false)
+			jclient.sendError(public.ErrCodeJournalRead, err, nil)
+			jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: "abnormal"})
+			return
+		}
+		line, err := entryJSONLine(entry)
+		if err != nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+			continue
+		}
+		if !jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: line}) {
+			return
+		}
+	}
+}
+
+// 先返回最近的若干条日志，再将读取位置移动到最后一条日志之后，返回false时停止读取
+func (jclient *JournaldClient) sendFollowTail(_journal *sdjournal.Journal) bool {
+	init_entries := []*sdjournal.Entry{}
+	_journal.SeekTail()
+	for len(init_entries) < followInitEntries {
+		entry, err := _journal.Previous()
+		if err != nil {
+			if err != io.EOF {
+				global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+			}
+			break
+		}
+		init_entries = append(init_entries, entry)
+	}
+	for i := len(init_entries) - 1; i >= 0; i-- {
+		line, err := entryJSONLine(init_entries[i])
+		if err != nil {
+			continue
+		}
+		if !jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: line}) {
+			return false
+		}
+	}
+
+	// 将读取位置移动到最后一条日志（不论是否满足过滤条件）之后
+	if err := _journal.SeekLastEntry(); err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+	}
+	return true
+}
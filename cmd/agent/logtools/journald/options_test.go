@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 09:14:22 2026 +0800
+ */
+package journald
+
+import (
+	"strings"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestCheckQueryOptions(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+	tests := []struct {
+		name    string
+		options *public.JournalctlOptions
+		err     string
+	}{
+		{"nil", nil, "joptions is required"},
+		{"empty", &public.JournalctlOptions{}, ""},
+		{"valid", &public.JournalctlOptions{
+			Grep: "time(out)?", GrepRegex: true, Query: "unit:nginx AND priority<=err", Boot: "-1", Fields: []string{"_PID"},
+			Matches: [][]public.FieldMatch{{{Field: "_COMM", Value: "nginx"}}}, AfterCursor: cursors["1"],
+		}, ""},
+		{"user", &public.JournalctlOptions{User: "root:0"}, ""},
+		{"user without uid", &public.JournalctlOptions{User: "root"}, "user field in options is invalid"},
+		{"user with empty uid", &public.JournalctlOptions{User: "root:"}, "user field in options is invalid"},
+		{"user with extra colon", &public.JournalctlOptions{User: "a:1:2"}, "user field in options is invalid"},
+		{"invalid grep", &public.JournalctlOptions{Grep: "(", GrepRegex: true}, "grep"},
+		{"invalid match field", &public.JournalctlOptions{Matches: [][]public.FieldMatch{{{Field: "comm", Value: "x"}}}}, "invalid journal field name"},
+		{"internal match field", &public.JournalctlOptions{Matches: [][]public.FieldMatch{{{Field: "__CURSOR", Value: "x"}}}}, "cannot be matched"},
+		{"match value with newline", &public.JournalctlOptions{Matches: [][]public.FieldMatch{{{Field: "_COMM", Value: "a\nb"}}}}, "contains newline"},
+		{"invalid query", &public.JournalctlOptions{Query: "unit:"}, "query syntax error"},
+		{"invalid boot", &public.JournalctlOptions{Boot: "--all"}, "invalid boot"},
+		{"invalid field", &public.JournalctlOptions{Fields: []string{"-o"}}, "invalid journal field name"},
+		{"invalid after cursor", &public.JournalctlOptions{AfterCursor: "x"}, "invalid after_cursor"},
+		// 分页查询不使用after_cursor
+		{"after cursor when paging", &public.JournalctlOptions{Notail: true, AfterCursor: "x"}, ""},
+		{"relative time range", &public.JournalctlOptions{Notail: true, Since: "yesterday", Until: "now"}, "unsupported time format"},
+	}
+	for _, tt := range tests {
+		_, _, err := checkQueryOptions(tt.options)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,670 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 16:20:37 2026 +0800
+ */
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPageSize = 20
+	// 按From分页时每次跳过的日志条数，限制单次读取占用的内存
+	pageSkipBatch = 500
+	// 缓存的分页位置游标数量上限
+	maxPageMarks = 1024
+)
+
+var PageLogDefaultOptions = []string{"--quiet", "--utc", "--output=json", "--no-pager"}
+
+/*
+分页数据源，每次只读取一页日志，不缓存整个查询结果
+
+fetch: 读取_cursor之后（_forward）或之前的至多_limit条日志，_cursor为空时从查询范围的第一条（或最后一条）开始，结果按时间升序排列
+
+count: 统计查询范围内的日志总数
+
+each: 按时间顺序遍历查询范围内的全部日志，_fn返回错误时停止遍历并返回该错误
+*/
+type pageSource interface {
+	fetch(_ctx context.Context, _cursor string, _forward bool, _limit int) ([]map[string]interface{}, error)
+	count(_ctx context.Context) (int, error)
+	each(_ctx context.Context, _fn func(map[string]interface{}) error) error
+	close()
+}
+
+// 查询范围内前from条日志以cursor对应的日志结束
+type pageMark struct {
+	from   int
+	cursor string
+}
+
+type pager struct {
+	source pageSource
+	reqCh  chan *public.JournalctlOptions
+	grep   *grepMatcher
+
+	// 按From分页时已读取过的位置及游标，按位置升序；跳转页面时从不超过From的最近位置继续读取
+	marks []pageMark
+
+	totalMutex sync.RWMutex
+	// 总数统计完成前为-1
+	total int
+}
+
+func newPager(_source pageSource, _grep *grepMatcher) *pager {
+	return &pager{
+		source: _source,
+		reqCh:  make(chan *public.JournalctlOptions, 10),
+		grep:   _grep,
+		total:  -1,
+	}
+}
+
+func (p *pager) setTotal(_total int) {
+	p.totalMutex.Lock()
+	defer p.totalMutex.Unlock()
+	p.total = _total
+}
+
+func (p *pager) getTotal() int {
+	p.totalMutex.RLock()
+	defer p.totalMutex.RUnlock()
+	return p.total
+}
+
+func (p *pager) page(_ctx context.Context, _options *public.JournalctlOptions) (*public.PageData, error) {
+	size := _options.Size
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	forward := _options.Direction != public.PageBackward
+
+	from := -1
+	var raw_entries []map[string]interface{}
+	var err error
+	switch {
+	case _options.Cursor != "" || !forward:
+		raw_entries, err = p.source.fetch(_ctx, _options.Cursor, forward, size+1)
+	default:
+		from = _options.From
+		if from < 0 {
+			from = 0
+		}
+		mark := p.nearestMark(from)
+		cursor, skip := mark.cursor, from-mark.from
+		for skip > 0 {
+			limit := skip
+			if limit > pageSkipBatch {
+				limit = pageSkipBatch
+			}
+			skipped, err := p.source.fetch(_ctx, cursor, true, limit)
+			if err != nil {
+				return nil, err
+			}
+			if len(skipped) == 0 {
+				break
+			}
+			cursor = entryCursor(skipped[len(skipped)-1])
+			skip -= len(skipped)
+			p.addMark(from-skip, cursor)
+			if len(skipped) < limit {
+				break
+			}
+		}
+		if skip == 0 {
+			raw_entries, err = p.source.fetch(_ctx, cursor, true, size+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(raw_entries) > size
+	if more {
+		if forward {
+			raw_entries = raw_entries[:size]
+		} else {
+			raw_entries = raw_entries[len(raw_entries)-size:]
+		}
+	}
+
+	page := &public.PageData{
+		Hits: make([]map[string]interface{}, 0, len(raw_entries)),
+		More: more,
+	}
+	for _, raw_entry := range raw_entries {
+		entry := generateEntry(raw_entry)
+		addExtraFields(entry, raw_entry, _options.Fields)
+		if p.grep != nil {
+			p.grep.markEntry(entry)
+		}
+		page.Hits = append(page.Hits, entry)
+	}
+	if len(raw_entries) > 0 {
+		page.FirstCursor = entryCursor(raw_entries[0])
+		page.LastCursor = entryCursor(raw_entries[len(raw_entries)-1])
+		if from >= 0 {
+			p.addMark(from+len(raw_entries), page.LastCursor)
+		}
+	}
+
+	page.Total = p.getTotal()
+	if page.Total < 0 {
+		// 总数尚未统计完成时返回已知的下限
+		page.Estimated = true
+		page.Total = len(page.Hits)
+		if from > 0 {
+			page.Total += from
+		}
+		if more {
+			page.Total++
+		}
+	}
+	return page, nil
+}
+
+// 不超过_from的最近位置，没有时为查询范围的起点
+func (p *pager) nearestMark(_from int) pageMark {
+	i := sort.Search(len(p.marks), func(_i int) bool { return p.marks[_i].from > _from })
+	if i == 0 {
+		return pageMark{}
+	}
+	return p.marks[i-1]
+}
+
+// 超出上限时隔一个移除，保留最后一个位置
+func (p *pager) addMark(_from int, _cursor string) {
+	if _from <= 0 || _cursor == "" {
+		return
+	}
+	i := sort.Search(len(p.marks), func(_i int) bool { return p.marks[_i].from >= _from })
+	if i < len(p.marks) && p.marks[i].from == _from {
+		p.marks[i].cursor = _cursor
+		return
+	}
+	p.marks = append(p.marks, pageMark{})
+	copy(p.marks[i+1:], p.marks[i:])
+	p.marks[i] = pageMark{from: _from, cursor: _cursor}
+	if len(p.marks) > maxPageMarks {
+		kept := p.marks[:0]
+		for j := (len(p.marks) - 1) % 2; j < len(p.marks); j += 2 {
+			kept = append(kept, p.marks[j])
+		}
+		p.marks = kept
+	}
+}
+
+func entryCursor(_raw_entry map[string]interface{}) string {
+	cursor, _ := _raw_entry["__CURSOR"].(string)
+	return cursor
+}
+
+// 根据reader配置创建分页数据源，native不可用时回退至journalctl
+func (jclient *JournaldClient) newPageSource(_options *public.JournalctlOptions) pageSource {
+	if jclient.Reader == NativeReader {
+		source, err := newNativePageSource(_options)
+		if err == nil {
+			return source
+		}
+		global.ERManager.ErrorTransmit("journald", "warn", errors.Wrap(err, "native journal reader unavailable, fall back to journalctl"), false, false)
+	}
+	return newExecPageSource(_options)
+}
+
+// 查询条件已由resetQuery校验
+func newExecPageSource(_options *public.JournalctlOptions) *execPageSource {
+	source := &execPageSource{args: assembleMatches(_options)}
+	source.cursorArgs = source.args
+	if _options.Notail && _options.Since != "" && _options.Until != "" {
+		source.since, _ = public.ParseJournalTime(_options.Since)
+		source.until, _ = public.ParseJournalTime(_options.Until)
+		options := *_options
+		options.Since, options.Until = "", ""
+		source.cursorArgs = assembleMatches(&options)
+	}
+	if grep, _ := newGrepMatcher(_options); grep != nil && !journalctlGrepSupported() {
+		source.grep = grep
+	}
+	source.query, _ = newQueryFilter(_options)
+	return source
+}
+
+// 分页查询从游标继续读取时在agent端按时间范围过滤，--since/--until须能够解析
+func checkTimeRange(_options *public.JournalctlOptions) error {
+	if !_options.Notail || _options.Since == "" || _options.Until == "" {
+		return nil
+	}
+	if _, err := public.ParseJournalTime(_options.Since); err != nil {
+		return err
+	}
+	_, err := public.ParseJournalTime(_options.Until)
+	return err
+}
+
+// 按请求顺序返回分页结果
+func (jclient *JournaldClient) servePages(_pager *pager) {
+	defer jclient.wg.Done()
+	defer _pager.source.close()
+
+	for {
+		select {
+		case <-jclient.CancelC.Done():
+			global.ERManager.ErrorTransmit("journald", "warn", errors.New("jclient.servePages() exit, cancelctx canceled"), false, false)
+			return
+		case options := <-_pager.reqCh:
+			page, err := _pager.page(jclient.CancelC, options)
+			if err != nil {
+				if jclient.CancelC.Err() != nil {
+					return
+				}
+				global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "fail to query page"), false, false)
+				jclient.sendError(public.ErrCodeQueryFailed, err, nil)
+				page = nil
+			}
+			if !jclient.sendStdoutData(&public.StdoutData{Type: public.LogEntryData, Data: page}) {
+				return
+			}
+		}
+	}
+}
+
+// 后台统计日志总数，统计完成后的分页结果返回准确的Total
+func (jclient *JournaldClient) countPageTotal(_pager *pager) {
+	defer jclient.wg.Done()
+
+	total, err := _pager.source.count(jclient.CancelC)
+	if err != nil {
+		if jclient.CancelC.Err() == nil {
+			global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, "fail to count journal entries"), false, false)
+		}
+		return
+	}
+	_pager.setTotal(total)
+}
+
+type execPageSource struct {
+	// 查询条件对应的journalctl参数
+	args []string
+	// 从游标继续读取时的journalctl参数，不含--since/--until：journalctl不允许与--after-cursor同时使用
+	cursorArgs []string
+	// 从游标继续读取时在agent端判断的时间范围，微秒时间戳，0表示不限制
+	since uint64
+	until uint64
+	// journalctl不支持--grep时在agent端过滤
+	grep *grepMatcher
+	// 查询语句在agent端过滤
+	query *queryFilter
+}
+
+func (s *execPageSource) fetch(_ctx context.Context, _cursor string, _forward bool, _limit int) ([]map[string]interface{}, error) {
+	args := append([]string{}, PageLogDefaultOptions...)
+	if _cursor != "" {
+		args = append(args, s.cursorArgs...)
+		args = append(args, "--after-cursor", _cursor)
+	} else {
+		args = append(args, s.args...)
+	}
+	if !_forward {
+		args = append(args, "--reverse")
+	}
+
+	ctx, cancel := context.WithCancel(_ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Errorf("cannot get stdout pipe: %s", err)
+	}
+	metrics.JournalctlStarted()
+	if err := cmd.Start(); err != nil {
+		metrics.JournalctlFailed(err, true)
+		return nil, errors.Errorf("cannot start journalctl: %s", err)
+	}
+
+	raw_entries := []map[string]interface{}{}
+	out_of_range := false
+	reader := bufio.NewReader(stdout)
+	for len(raw_entries) < _limit {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			raw_entry := map[string]interface{}{}
+			if err := json.Unmarshal(line, &raw_entry); err != nil {
+				global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to unmarshal Journald JSON: %s; raw data: %s", err, line), false, false)
+			} else if position := s.rangePosition(raw_entry); _cursor != "" && position != 0 {
+				// 游标可能在时间范围之外：跳过到达范围之前的日志，离开范围后结束
+				if (position > 0) == _forward {
+					out_of_range = true
+					break
+				}
+			} else if s.match(raw_entry) {
+				raw_entries = append(raw_entries, raw_entry)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// 已读取到足够的日志或超出时间范围时结束journalctl进程
+	stopped := len(raw_entries) >= _limit || out_of_range
+	cancel()
+	if err := cmd.Wait(); err != nil && !stopped && _ctx.Err() == nil {
+		metrics.JournalctlFailed(err, false)
+		return nil, errors.Errorf("err while running journalctl: %s, %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	if !_forward {
+		reverseEntries(raw_entries)
+	}
+	return raw_entries, nil
+}
+
+func (s *execPageSource) count(_ctx context.Context) (int, error) {
+	args := append([]string{}, PageLogDefaultOptions...)
+	args = append(args, s.args...)
+	cmd := exec.CommandContext(_ctx, "journalctl", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	counter := &lineCounter{source: s}
+	cmd.Stdout = counter
+	metrics.JournalctlStarted()
+	if err := cmd.Run(); err != nil {
+		if _ctx.Err() == nil {
+			metrics.JournalctlFailed(err, cmd.ProcessState == nil)
+		}
+		return 0, errors.Errorf("err while running journalctl: %s, %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return counter.lines, nil
+}
+
+func (s *execPageSource) each(_ctx context.Context, _fn func(map[string]interface{}) error) error {
+	args := append([]string{}, PageLogDefaultOptions...)
+	args = append(args, s.args...)
+	ctx, cancel := context.WithCancel(_ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Errorf("cannot get stdout pipe: %s", err)
+	}
+	metrics.JournalctlStarted()
+	if err := cmd.Start(); err != nil {
+		metrics.JournalctlFailed(err, true)
+		return errors.Errorf("cannot start journalctl: %s", err)
+	}
+
+	var fn_err error
+	reader := bufio.NewReader(stdout)
+	for fn_err == nil {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			raw_entry := map[string]interface{}{}
+			if err := json.Unmarshal(line, &raw_entry); err == nil && s.match(raw_entry) {
+				fn_err = _fn(raw_entry)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if fn_err != nil {
+		// 提前结束时终止journalctl进程
+		cancel()
+		cmd.Wait()
+		return fn_err
+	}
+	if err := cmd.Wait(); err != nil {
+		if _ctx.Err() == nil {
+			metrics.JournalctlFailed(err, false)
+		}
+		return errors.Errorf("err while running journalctl: %s, %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+func (s *execPageSource) close() {}
+
+func (s *execPageSource) rangePosition(_raw_entry map[string]interface{}) int {
+	if s.since == 0 && s.until == 0 {
+		return 0
+	}
+	realtime_str, _ := _raw_entry["__REALTIME_TIMESTAMP"].(string)
+	realtime, err := strconv.ParseUint(realtime_str, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return rangePosition(realtime, s.since, s.until)
+}
+
+// 日志时间相对查询范围的位置：-1早于_since，1晚于_until，0在范围内；0表示不限制
+func rangePosition(_realtime, _since, _until uint64) int {
+	switch {
+	case _since != 0 && _realtime < _since:
+		return -1
+	case _until != 0 && _realtime > _until:
+		return 1
+	}
+	return 0
+}
+
+// 需要在agent端过滤时判断日志是否满足查询条件
+func (s *execPageSource) filtered() bool {
+	return s.grep != nil || s.query != nil
+}
+
+func (s *execPageSource) match(_raw_entry map[string]interface{}) bool {
+	if s.grep != nil && !s.grep.matchRaw(_raw_entry) {
+		return false
+	}
+	return s.query == nil || s.query.matchRaw(_raw_entry)
+}
+
+// journalctl --output=json每行一条日志
+type lineCounter struct {
+	lines int
+
+	// 需要在agent端过滤时缓存未读完的行
+	source  *execPageSource
+	partial []byte
+}
+
+func (c *lineCounter) Write(_p []byte) (int, error) {
+	if !c.source.filtered() {
+		c.lines += bytes.Count(_p, []byte{'\n'})
+		return len(_p), nil
+	}
+
+	data := _p
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			c.partial = append(c.partial, data...)
+			break
+		}
+		line := data[:i]
+		if len(c.partial) > 0 {
+			line = append(c.partial, line...)
+		}
+		raw_entry := map[string]interface{}{}
+		if err := json.Unmarshal(line, &raw_entry); err == nil && c.source.match(raw_entry) {
+			c.lines++
+		}
+		c.partial = c.partial[:0]
+		data = data[i+1:]
+	}
+	return len(_p), nil
+}
+
+type nativePageSource struct {
+	options *public.JournalctlOptions
+	journal *sdjournal.Journal
+
+	// 微秒时间戳，0表示不限制
+	since uint64
+	until uint64
+}
+
+func newNativePageSource(_options *public.JournalctlOptions) (*nativePageSource, error) {
+	s := &nativePageSource{options: _options}
+	if _options.Since != "" && _options.Until != "" {
+		var err error
+		if s.since, err = public.ParseJournalTime(_options.Since); err != nil {
+			return nil, err
+		}
+		if s.until, err = public.ParseJournalTime(_options.Until); err != nil {
+			return nil, err
+		}
+	}
+	journal, err := openNativeJournal(_options)
+	if err != nil {
+		return nil, err
+	}
+	s.journal = journal
+	return s, nil
+}
+
+// 将读取位置移动到查询范围的起点（或终点）
+func (s *nativePageSource) seek(_journal *sdjournal.Journal, _forward bool) {
+	switch {
+	case _forward && s.since != 0:
+		_journal.SeekRealtime(s.since)
+	case _forward:
+		_journal.SeekHead()
+	case s.until != 0:
+		_journal.SeekRealtime(s.until + 1)
+	default:
+		_journal.SeekTail()
+	}
+}
+
+func (s *nativePageSource) fetch(_ctx context.Context, _cursor string, _forward bool, _limit int) ([]map[string]interface{}, error) {
+	if _cursor != "" {
+		if err := s.journal.SeekCursor(_cursor); err != nil {
+			return nil, err
+		}
+	} else {
+		s.seek(s.journal, _forward)
+	}
+
+	raw_entries := []map[string]interface{}{}
+	for len(raw_entries) < _limit {
+		if err := _ctx.Err(); err != nil {
+			return nil, err
+		}
+		var entry *sdjournal.Entry
+		var err error
+		if _forward {
+			entry, err = s.journal.Next()
+		} else {
+			entry, err = s.journal.Previous()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _cursor != "" && sdjournal.TestCursor(entry, _cursor) {
+			continue
+		}
+		if position := rangePosition(entry.Realtime, s.since, s.until); position != 0 {
+			if (position > 0) == _forward {
+				break
+			}
+			continue
+		}
+		raw_entries = append(raw_entries, entry.JSONMap())
+	}
+
+	if !_forward {
+		reverseEntries(raw_entries)
+	}
+	return raw_entries, nil
+}
+
+// 使用单独的journal实例统计，不影响分页读取位置
+func (s *nativePageSource) count(_ctx context.Context) (int, error) {
+	journal, err := openNativeJournal(s.options)
+	if err != nil {
+		return 0, err
+	}
+	defer journal.Close()
+
+	s.seek(journal, true)
+	total := 0
+	for {
+		if total%pageSkipBatch == 0 {
+			if err := _ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
+		entry, err := journal.Next()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if s.until != 0 && entry.Realtime > s.until {
+			return total, nil
+		}
+		total++
+	}
+}
+
+func (s *nativePageSource) each(_ctx context.Context, _fn func(map[string]interface{}) error) error {
+	s.seek(s.journal, true)
+	for i := 0; ; i++ {
+		if i%pageSkipBatch == 0 {
+			if err := _ctx.Err(); err != nil {
+				return err
+			}
+		}
+		entry, err := s.journal.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if s.until != 0 && entry.Realtime > s.until {
+			return nil
+		}
+		if err := _fn(entry.JSONMap()); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *nativePageSource) close() {
+	s.journal.Close()
+}
+
+func reverseEntries(_entries []map[string]interface{}) {
+	for i, j := 0, len(_entries)-1; i < j; i, j = i+1, j-1 {
+		_entries[i], _entries[j] = _entries[j], _entries[i]
+	}
+}
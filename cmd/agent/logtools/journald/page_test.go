@@ -0,0 +1,378 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 11:03:52 2026 +0800
+ */
+package journald
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+// sdjournal的测试文件，FIXTURE_SEQ为1-6的日志依次间隔1.1秒写入，见sdjournal/journal_test.go
+var fixtureDir = filepath.Join("sdjournal", "testdata", "plain")
+
+// 测试文件中的全部日志，即journalctl --output=json的输出
+func fixtureRawEntries(t *testing.T) []map[string]interface{} {
+	f, err := os.Open(fixtureDir + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	raw_entries := []map[string]interface{}{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		raw_entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw_entry); err != nil {
+			t.Fatal(err)
+		}
+		raw_entries = append(raw_entries, raw_entry)
+	}
+	return raw_entries
+}
+
+// 按FIXTURE_SEQ返回测试文件中日志的__REALTIME_TIMESTAMP和__CURSOR
+func fixtureEntries(t *testing.T) (map[string]uint64, map[string]string) {
+	realtimes, cursors := map[string]uint64{}, map[string]string{}
+	for _, raw_entry := range fixtureRawEntries(t) {
+		seq, ok := raw_entry["FIXTURE_SEQ"].(string)
+		if !ok {
+			continue
+		}
+		realtimes[seq], _ = strconv.ParseUint(raw_entry["__REALTIME_TIMESTAMP"].(string), 10, 64)
+		cursors[seq] = raw_entry["__CURSOR"].(string)
+	}
+	return realtimes, cursors
+}
+
+// 时间范围为FIXTURE_SEQ 2-5
+func fixtureTimeRange(t *testing.T) *public.JournalctlOptions {
+	return fixtureSeqRange(t, "2", "5")
+}
+
+// 时间范围为FIXTURE_SEQ _first至_last：起止时间取整到秒，相邻日志间隔超过1秒
+func fixtureSeqRange(t *testing.T, _first, _last string) *public.JournalctlOptions {
+	realtimes, _ := fixtureEntries(t)
+	format := func(_usec uint64) string {
+		return time.UnixMicro(int64(_usec)).Truncate(time.Second).Local().Format("2006-01-02 15:04:05")
+	}
+	return &public.JournalctlOptions{
+		Notail:     true,
+		Identifier: "fixture",
+		Since:      format(realtimes[_first]),
+		Until:      format(realtimes[_last] + uint64(time.Second/time.Microsecond)),
+	}
+}
+
+// 读取测试文件的journalctl分页数据源
+func fixtureSource(t *testing.T, _options *public.JournalctlOptions) *execPageSource {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		t.Skip("journalctl not found")
+	}
+	if err := checkTimeRange(_options); err != nil {
+		t.Fatal(err)
+	}
+	source := newExecPageSource(_options)
+	dir := "--directory=" + fixtureDir
+	source.args = append([]string{dir}, source.args...)
+	source.cursorArgs = append([]string{dir}, source.cursorArgs...)
+	return source
+}
+
+func hitSeqs(_page *public.PageData) []string {
+	seqs := []string{}
+	for _, hit := range _page.Hits {
+		message, _ := hit["message"].(string)
+		seqs = append(seqs, message[len("entry "):])
+	}
+	return seqs
+}
+
+func rawSeqs(_raw_entries []map[string]interface{}) []string {
+	seqs := []string{}
+	for _, raw_entry := range _raw_entries {
+		seq, _ := raw_entry["FIXTURE_SEQ"].(string)
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+func TestExecPageSinceUntil(t *testing.T) {
+	options := fixtureTimeRange(t)
+	source := fixtureSource(t, options)
+	ctx := context.Background()
+
+	total, err := source.count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Fatalf("count: got %d, want 4", total)
+	}
+
+	t.Run("forward by cursor", func(t *testing.T) {
+		p := newPager(source, nil)
+		seqs := []string{}
+		request := *options
+		request.Size = 1
+		for i := 0; ; i++ {
+			page, err := p.page(ctx, &request)
+			if err != nil {
+				t.Fatalf("page %d: %s", i+1, err)
+			}
+			seqs = append(seqs, hitSeqs(page)...)
+			if !page.More {
+				break
+			}
+			request.Cursor = page.LastCursor
+		}
+		if want := []string{"2", "3", "4", "5"}; !reflect.DeepEqual(seqs, want) {
+			t.Errorf("got %v, want %v", seqs, want)
+		}
+	})
+
+	t.Run("forward by from", func(t *testing.T) {
+		tests := []struct {
+			from int
+			want []string
+			more bool
+		}{
+			{0, []string{"2", "3"}, true},
+			{1, []string{"3", "4"}, true},
+			{2, []string{"4", "5"}, false},
+			{3, []string{"5"}, false},
+			{4, []string{}, false},
+		}
+		for _, tt := range tests {
+			// 每次使用新的pager，不从上一页的游标继续，覆盖跳过From条日志的路径
+			p := newPager(source, nil)
+			request := *options
+			request.Size, request.From = 2, tt.from
+			page, err := p.page(ctx, &request)
+			if err != nil {
+				t.Fatalf("from %d: %s", tt.from, err)
+			}
+			if got := hitSeqs(page); !reflect.DeepEqual(got, tt.want) || page.More != tt.more {
+				t.Errorf("from %d: got %v more=%v, want %v more=%v", tt.from, got, page.More, tt.want, tt.more)
+			}
+		}
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		p := newPager(source, nil)
+		request := *options
+		request.Size, request.Direction = 2, public.PageBackward
+		page, err := p.page(ctx, &request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hitSeqs(page); !reflect.DeepEqual(got, []string{"4", "5"}) || !page.More {
+			t.Fatalf("last page: got %v more=%v", got, page.More)
+		}
+
+		request.Cursor = page.FirstCursor
+		page, err = p.page(ctx, &request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hitSeqs(page); !reflect.DeepEqual(got, []string{"2", "3"}) || page.More {
+			t.Fatalf("previous page: got %v more=%v", got, page.More)
+		}
+	})
+}
+
+// 内存中的分页数据源，记录每次fetch的起始游标
+type memoryPageSource struct {
+	entries []map[string]interface{}
+	fetches []string
+}
+
+func newMemoryPageSource(_n int) *memoryPageSource {
+	s := &memoryPageSource{}
+	for i := 1; i <= _n; i++ {
+		s.entries = append(s.entries, map[string]interface{}{
+			"__REALTIME_TIMESTAMP": strconv.Itoa(1792800000000000 + i),
+			"__CURSOR":             "c" + strconv.Itoa(i),
+			"MESSAGE":              "entry " + strconv.Itoa(i),
+		})
+	}
+	return s
+}
+
+func (s *memoryPageSource) fetch(_ctx context.Context, _cursor string, _forward bool, _limit int) ([]map[string]interface{}, error) {
+	s.fetches = append(s.fetches, _cursor)
+	start := 0
+	if _cursor != "" {
+		n, _ := strconv.Atoi(_cursor[1:])
+		start = n
+	}
+	end := start + _limit
+	if end > len(s.entries) {
+		end = len(s.entries)
+	}
+	return s.entries[start:end], nil
+}
+
+func (s *memoryPageSource) count(_ctx context.Context) (int, error) {
+	return len(s.entries), nil
+}
+
+func (s *memoryPageSource) each(_ctx context.Context, _fn func(map[string]interface{}) error) error {
+	for _, raw_entry := range s.entries {
+		if err := _fn(raw_entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryPageSource) close() {}
+
+// 按From跳转时从已访问过的最近位置继续读取
+func TestPagerMarks(t *testing.T) {
+	source := newMemoryPageSource(2000)
+	p := newPager(source, nil)
+	page := func(_from int) []string {
+		source.fetches = nil
+		data, err := p.page(context.Background(), &public.JournalctlOptions{Notail: true, Size: 10, From: _from})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hitSeqs(data)
+	}
+
+	tests := []struct {
+		from    int
+		first   string
+		fetches []string
+	}{
+		// 跳过1200条：每批pageSkipBatch条
+		{1200, "1201", []string{"", "c500", "c1000", "c1200"}},
+		// 下一页从上一页结束的位置继续
+		{1210, "1211", []string{"c1210"}},
+		// 从跳过时经过的位置继续
+		{1005, "1006", []string{"c1000", "c1005"}},
+		{600, "601", []string{"c500", "c600"}},
+		{1215, "1216", []string{"c1210", "c1215"}},
+		{3, "4", []string{"", "c3"}},
+	}
+	for _, tt := range tests {
+		got := page(tt.from)
+		if len(got) == 0 || got[0] != tt.first {
+			t.Errorf("from %d: got %v, want first entry %s", tt.from, got, tt.first)
+		}
+		if !reflect.DeepEqual(source.fetches, tt.fetches) {
+			t.Errorf("from %d: fetched from %q, want %q", tt.from, source.fetches, tt.fetches)
+		}
+	}
+}
+
+func TestPagerAddMarkLimit(t *testing.T) {
+	p := newPager(newMemoryPageSource(0), nil)
+	for i := 1; i <= maxPageMarks*3; i++ {
+		p.addMark(i, "c"+strconv.Itoa(i))
+	}
+	if len(p.marks) > maxPageMarks {
+		t.Fatalf("got %d marks, want at most %d", len(p.marks), maxPageMarks)
+	}
+	if last := p.marks[len(p.marks)-1]; last.from != maxPageMarks*3 {
+		t.Errorf("last mark: got %d, want %d", last.from, maxPageMarks*3)
+	}
+	for i := 1; i < len(p.marks); i++ {
+		if p.marks[i-1].from >= p.marks[i].from {
+			t.Fatalf("marks not sorted at %d: %d >= %d", i, p.marks[i-1].from, p.marks[i].from)
+		}
+	}
+	if mark := p.nearestMark(0); mark.from != 0 || mark.cursor != "" {
+		t.Errorf("nearest mark of 0: got %+v", mark)
+	}
+	if mark := p.nearestMark(maxPageMarks * 2); mark.from > maxPageMarks*2 || mark.cursor != "c"+strconv.Itoa(mark.from) {
+		t.Errorf("nearest mark of %d: got %+v", maxPageMarks*2, mark)
+	}
+}
+
+func TestExecFetchOutOfRangeCursor(t *testing.T) {
+	_, cursors := fixtureEntries(t)
+
+	tests := []struct {
+		first, last string
+		cursor      string
+		forward     bool
+		want        []string
+	}{
+		{"2", "5", cursors["1"], true, []string{"2", "3", "4", "5"}},
+		{"2", "5", cursors["5"], true, []string{}},
+		{"2", "5", cursors["6"], false, []string{"2", "3", "4", "5"}},
+		{"2", "5", cursors["2"], false, []string{}},
+		// 游标与时间范围之间还有日志
+		{"3", "4", cursors["1"], true, []string{"3", "4"}},
+		{"3", "4", cursors["6"], false, []string{"3", "4"}},
+		{"4", "4", cursors["1"], false, []string{}},
+		{"3", "3", cursors["6"], true, []string{}},
+	}
+	for _, tt := range tests {
+		source := fixtureSource(t, fixtureSeqRange(t, tt.first, tt.last))
+		raw_entries, err := source.fetch(context.Background(), tt.cursor, tt.forward, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rawSeqs(raw_entries); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("range %s-%s fetch after %s forward=%v: got %v, want %v", tt.first, tt.last, tt.cursor, tt.forward, got, tt.want)
+		}
+	}
+}
+
+// _fn返回错误时停止遍历
+func TestExecEachAbort(t *testing.T) {
+	source := fixtureSource(t, fixtureTimeRange(t))
+	stop := errors.New("stop")
+	seqs := []string{}
+	err := source.each(context.Background(), func(_raw_entry map[string]interface{}) error {
+		seqs = append(seqs, rawSeqs([]map[string]interface{}{_raw_entry})...)
+		if len(seqs) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("got error %v, want %v", err, stop)
+	}
+	if want := []string{"2", "3"}; !reflect.DeepEqual(seqs, want) {
+		t.Errorf("got %v, want %v", seqs, want)
+	}
+}
+
+func TestCheckTimeRange(t *testing.T) {
+	tests := []struct {
+		options *public.JournalctlOptions
+		ok      bool
+	}{
+		{&public.JournalctlOptions{Notail: true, Since: "2026-10-01 00:00:00", Until: "2026-10-02"}, true},
+		{&public.JournalctlOptions{Notail: true, Since: "yesterday", Until: "2026-10-02"}, false},
+		{&public.JournalctlOptions{Notail: true, Since: "2026-10-01", Until: "now"}, false},
+		// 只有一端或实时查询时不使用--since/--until
+		{&public.JournalctlOptions{Notail: true, Since: "yesterday"}, true},
+		{&public.JournalctlOptions{Since: "yesterday", Until: "now"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkTimeRange(tt.options); (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v", tt.options, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 17:02:19 2026 +0800
+ */
+package journald
+
+import (
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+/*
+按查询语句过滤日志，同时实现sdjournal.Filter
+
+查询语句中可以下推的条件已作为字段匹配条件交由journalctl过滤（见queryFieldMatches），其余条件在agent端判断
+*/
+type queryFilter struct {
+	query *public.Query
+}
+
+var _ sdjournal.Filter = (*queryFilter)(nil)
+
+// 查询条件中未设置query时返回nil
+func newQueryFilter(_options *public.JournalctlOptions) (*queryFilter, error) {
+	if _options.Query == "" {
+		return nil, nil
+	}
+	query, err := public.ParseQuery(_options.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &queryFilter{query: query}, nil
+}
+
+func (q *queryFilter) Match(_e *sdjournal.Entry) bool {
+	return q.query.Match(func(_field string) (string, bool) {
+		value, ok := _e.Fields[_field]
+		return value, ok
+	})
+}
+
+// journalctl --output=json中二进制字段为数组，按字段不存在处理
+func (q *queryFilter) matchRaw(_raw_entry map[string]interface{}) bool {
+	return q.query.Match(func(_field string) (string, bool) {
+		value, ok := _raw_entry[_field].(string)
+		return value, ok
+	})
+}
+
+/*
+查询条件中的字段匹配条件与查询语句中可以下推的条件取AND
+
+组内已有的字段及_TRANSPORT、_UID不再添加查询语句中的条件，避免journalctl按OR扩大查询范围；
+查询语句不合法或条件总数超出上限时只返回_options.Matches
+*/
+func queryFieldMatches(_options *public.JournalctlOptions) [][]public.FieldMatch {
+	if _options.Query == "" {
+		return _options.Matches
+	}
+	query, err := public.ParseQuery(_options.Query)
+	if err != nil {
+		return _options.Matches
+	}
+	pushdown := query.FieldMatches()
+	if len(pushdown) == 0 {
+		return _options.Matches
+	}
+	groups := _options.Matches
+	if len(groups) == 0 {
+		groups = [][]public.FieldMatch{{}}
+	}
+
+	matches := [][]public.FieldMatch{}
+	for _, group := range groups {
+		fields := map[string]bool{"_TRANSPORT": true, "_UID": true}
+		for _, m := range group {
+			fields[m.Field] = true
+		}
+		for _, extra := range pushdown {
+			merged := append([]public.FieldMatch{}, group...)
+			for _, m := range extra {
+				if !fields[m.Field] {
+					merged = append(merged, m)
+				}
+			}
+			// 组为空表示不限制，与其他组取OR时须保留
+			if len(merged) == 0 {
+				return _options.Matches
+			}
+			matches = append(matches, merged)
+		}
+	}
+	if countMatches(matches) > maxFieldMatches {
+		return _options.Matches
+	}
+	return matches
+}
+
+func countMatches(_matches [][]public.FieldMatch) int {
+	count := 0
+	for _, group := range _matches {
+		count += len(group)
+	}
+	return count
+}
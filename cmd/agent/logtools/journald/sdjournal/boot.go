@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 18:31:52 2026 +0800
+ */
+package sdjournal
+
+import (
+	"sort"
+)
+
+// 一次启动期间写入的日志范围
+type Boot struct {
+	ID ID128
+	// 第一条、最后一条日志的realtime，微秒
+	First uint64
+	Last  uint64
+}
+
+// 与journalctl --list-boots一致，按第一条日志的时间排序，不受过滤器影响
+func (j *Journal) Boots() ([]Boot, error) {
+	boots := map[ID128]*Boot{}
+	add := func(_id ID128, _first, _last uint64) {
+		b, ok := boots[_id]
+		if !ok {
+			boots[_id] = &Boot{ID: _id, First: _first, Last: _last}
+			return
+		}
+		if _first < b.First {
+			b.First = _first
+		}
+		if _last > b.Last {
+			b.Last = _last
+		}
+	}
+	for _, jf := range j.files {
+		file_boots, err := jf.boots()
+		if err == nil {
+			for _, b := range file_boots {
+				add(b.ID, b.First, b.Last)
+			}
+			continue
+		}
+		// hash table不可用时逐个读取entry
+		for _, offset := range jf.entryOffsets {
+			eh, err := jf.entryHead(offset)
+			if err != nil {
+				return nil, err
+			}
+			add(eh.bootID, eh.realtime, eh.realtime)
+		}
+	}
+
+	list := make([]Boot, 0, len(boots))
+	for _, b := range boots {
+		list = append(list, *b)
+	}
+	sort.Slice(list, func(i, k int) bool {
+		return list[i].First < list[k].First
+	})
+	return list, nil
+}
+
+// 只返回指定启动期间的日志
+type BootMatch ID128
+
+func (b BootMatch) Match(_e *Entry) bool {
+	return _e.BootID == ID128(b)
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+type Entry struct {
+	// 同名字段出现多次时为第一个值
+	Fields map[string]string
+	// 出现多次的字段的全部取值，按entry中的顺序排列
+	Repeated map[string][]string
+
+	Realtime  uint64
+	Monotonic uint64
+	Seqnum    uint64
+	SeqnumID  ID128
+	BootID    ID128
+
+	xorHash uint64
+}
+
+// 与sd_journal_get_cursor格式一致
+func (e *Entry) Cursor() string {
+	return Cursor{
+		SeqnumID:  e.SeqnumID,
+		Seqnum:    e.Seqnum,
+		BootID:    e.BootID,
+		Monotonic: e.Monotonic,
+		Realtime:  e.Realtime,
+		XorHash:   e.xorHash,
+	}.String()
+}
+
+// 字段的全部取值
+func (e *Entry) Values(_field string) []string {
+	if values, ok := e.Repeated[_field]; ok {
+		return values
+	}
+	if value, ok := e.Fields[_field]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+func (e *Entry) addField(_field, _value string) {
+	first, ok := e.Fields[_field]
+	if !ok {
+		e.Fields[_field] = _value
+		return
+	}
+	if e.Repeated == nil {
+		e.Repeated = map[string][]string{}
+	}
+	if _, ok := e.Repeated[_field]; !ok {
+		e.Repeated[_field] = []string{first}
+	}
+	e.Repeated[_field] = append(e.Repeated[_field], _value)
+}
+
+/*
+生成与journalctl --output=json --all一致的字段
+
+不可打印的值为字节数组，同名字段出现多次时为全部取值组成的数组
+*/
+func (e *Entry) JSONMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		m[k] = jsonValue(v)
+	}
+	for k, values := range e.Repeated {
+		array := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			array = append(array, jsonValue(v))
+		}
+		m[k] = array
+	}
+	m["__CURSOR"] = e.Cursor()
+	m["__REALTIME_TIMESTAMP"] = strconv.FormatUint(e.Realtime, 10)
+	m["__MONOTONIC_TIMESTAMP"] = strconv.FormatUint(e.Monotonic, 10)
+	m["_BOOT_ID"] = e.BootID.String()
+	return m
+}
+
+// 字节数组的元素为float64，与journalctl输出经json解码后的类型一致
+func jsonValue(_value string) interface{} {
+	if printable(_value) {
+		return _value
+	}
+	array := make([]interface{}, 0, len(_value))
+	for i := 0; i < len(_value); i++ {
+		array = append(array, float64(_value[i]))
+	}
+	return array
+}
+
+// 与systemd utf8_is_printable一致：合法的utf8，且除\t、\n外不含C0、C1控制字符及DEL
+func printable(_value string) bool {
+	if !utf8.ValidString(_value) {
+		return false
+	}
+	for _, r := range _value {
+		if (r < ' ' && r != '\t' && r != '\n') || (r >= 0x7f && r <= 0x9f) {
+			return false
+		}
+	}
+	return true
+}
+
+type Cursor struct {
+	SeqnumID  ID128
+	Seqnum    uint64
+	BootID    ID128
+	Monotonic uint64
+	Realtime  uint64
+	XorHash   uint64
+
+	hasSeqnum   bool
+	hasRealtime bool
+}
+
+func (c Cursor) String() string {
+	return fmt.Sprintf("s=%s;i=%x;b=%s;m=%x;t=%x;x=%x", c.SeqnumID, c.Seqnum, c.BootID, c.Monotonic, c.Realtime, c.XorHash)
+}
+
+func ParseCursor(_cursor string) (*Cursor, error) {
+	c := &Cursor{}
+	for _, item := range strings.Split(_cursor, ";") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || len(kv[0]) != 1 {
+			return nil, errors.Errorf("invalid cursor: %s", _cursor)
+		}
+		var err error
+		switch kv[0] {
+		case "s":
+			c.SeqnumID, err = ParseID128(kv[1])
+		case "i":
+			c.Seqnum, err = strconv.ParseUint(kv[1], 16, 64)
+			c.hasSeqnum = true
+		case "b":
+			c.BootID, err = ParseID128(kv[1])
+		case "m":
+			c.Monotonic, err = strconv.ParseUint(kv[1], 16, 64)
+		case "t":
+			c.Realtime, err = strconv.ParseUint(kv[1], 16, 64)
+			c.hasRealtime = true
+		case "x":
+			c.XorHash, err = strconv.ParseUint(kv[1], 16, 64)
+		}
+		if err != nil {
+			return nil, errors.Errorf("invalid cursor: %s: %s", _cursor, err.Error())
+		}
+	}
+	if !c.hasSeqnum && !c.hasRealtime {
+		return nil, errors.Errorf("invalid cursor: %s", _cursor)
+	}
+	return c, nil
+}
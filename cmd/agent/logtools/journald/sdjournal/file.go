@@ -0,0 +1,299 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// 单个journal文件，只读mmap映射
+type File struct {
+	Path string
+
+	f    *os.File
+	info os.FileInfo
+	data []byte
+
+	header *fileHeader
+
+	// 按seqnum顺序排列的entry object偏移量
+	entryOffsets []uint64
+
+	// entry array链表的读取进度，用于增量刷新
+	lastArrayOffset uint64
+	lastArrayRead   int
+
+	// 当前过滤条件的候选entry，过滤条件变化时清空
+	index *filterIndex
+}
+
+func OpenFile(_path string) (*File, error) {
+	f, err := os.Open(_path)
+	if err != nil {
+		return nil, errors.Errorf("fail to open journal file %s: %s", _path, err.Error())
+	}
+	jf := &File{
+		Path: _path,
+		f:    f,
+	}
+	if err := jf.remap(); err != nil {
+		jf.Close()
+		return nil, err
+	}
+	if err := jf.loadEntryOffsets(); err != nil {
+		jf.Close()
+		return nil, err
+	}
+	return jf, nil
+}
+
+func (jf *File) remap() error {
+	info, err := jf.f.Stat()
+	if err != nil {
+		return errors.Errorf("fail to stat journal file %s: %s", jf.Path, err.Error())
+	}
+	if info.Size() < headerMinSize {
+		return errors.Wrapf(ErrInvalidFile, "%s: file too small", jf.Path)
+	}
+	if jf.data != nil && int64(len(jf.data)) == info.Size() {
+		// journald预分配文件空间，文件大小不变时header仍可能更新
+		header, err := parseHeader(jf.data)
+		if err != nil {
+			return errors.Wrap(err, jf.Path)
+		}
+		jf.header = header
+		return nil
+	}
+
+	data, err := syscall.Mmap(int(jf.f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return errors.Errorf("fail to mmap journal file %s: %s", jf.Path, err.Error())
+	}
+	header, err := parseHeader(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return errors.Wrap(err, jf.Path)
+	}
+	if jf.data != nil {
+		syscall.Munmap(jf.data)
+	}
+	jf.data = data
+	jf.info = info
+	jf.header = header
+	return nil
+}
+
+// 文件发生增长时重新映射，并读取新增的entry
+func (jf *File) Refresh() error {
+	if err := jf.remap(); err != nil {
+		return err
+	}
+	if err := jf.loadEntryOffsets(); err != nil {
+		return err
+	}
+	jf.extendIndex()
+	return nil
+}
+
+func (jf *File) loadEntryOffsets() error {
+	offset := jf.lastArrayOffset
+	skip := jf.lastArrayRead
+	if offset == 0 {
+		offset = jf.header.entryArrayOffset
+		skip = 0
+	}
+
+	itemSize := 8
+	if jf.header.compact() {
+		itemSize = 4
+	}
+
+	for offset != 0 {
+		otype, _, size, err := jf.objectHeader(offset)
+		if err != nil {
+			return err
+		}
+		if otype != objectEntryArray || size < entryArrayHeadSize {
+			return errors.Wrapf(ErrInvalidFile, "%s: bad entry array object at %d", jf.Path, offset)
+		}
+		next := le64(jf.data, int(offset)+16)
+		n := int(size-entryArrayHeadSize) / itemSize
+
+		read := skip
+		for i := skip; i < n; i++ {
+			pos := int(offset) + entryArrayHeadSize + i*itemSize
+			var item uint64
+			if itemSize == 4 {
+				item = uint64(le32(jf.data, pos))
+			} else {
+				item = le64(jf.data, pos)
+			}
+			if item == 0 {
+				break
+			}
+			jf.entryOffsets = append(jf.entryOffsets, item)
+			read = i + 1
+		}
+
+		jf.lastArrayOffset = offset
+		jf.lastArrayRead = read
+		if read < n || next == 0 {
+			break
+		}
+		offset = next
+		skip = 0
+	}
+	return nil
+}
+
+func (jf *File) objectHeader(_offset uint64) (uint8, uint8, uint64, error) {
+	if _offset%8 != 0 || _offset+objectHeaderSize > uint64(len(jf.data)) {
+		return 0, 0, 0, errors.Wrapf(ErrInvalidFile, "%s: object offset out of range: %d", jf.Path, _offset)
+	}
+	otype := jf.data[_offset]
+	flags := jf.data[_offset+1]
+	size := le64(jf.data, int(_offset)+8)
+	if size < objectHeaderSize || _offset+size > uint64(len(jf.data)) {
+		return 0, 0, 0, errors.Wrapf(ErrInvalidFile, "%s: object size out of range: %d@%d", jf.Path, size, _offset)
+	}
+	return otype, flags, size, nil
+}
+
+// entry object的头部信息，不读取data
+type entryHead struct {
+	offset    uint64
+	seqnum    uint64
+	realtime  uint64
+	monotonic uint64
+	bootID    ID128
+	xorHash   uint64
+}
+
+func (jf *File) entryHead(_offset uint64) (*entryHead, error) {
+	otype, _, size, err := jf.objectHeader(_offset)
+	if err != nil {
+		return nil, err
+	}
+	if otype != objectEntry || size < entryObjectHeadSize {
+		return nil, errors.Wrapf(ErrInvalidFile, "%s: bad entry object at %d", jf.Path, _offset)
+	}
+	o := int(_offset)
+	eh := &entryHead{
+		offset:    _offset,
+		seqnum:    le64(jf.data, o+16),
+		realtime:  le64(jf.data, o+24),
+		monotonic: le64(jf.data, o+32),
+		xorHash:   le64(jf.data, o+56),
+	}
+	copy(eh.bootID[:], jf.data[o+40:o+56])
+	return eh, nil
+}
+
+// 读取entry全部字段
+func (jf *File) readEntry(_eh *entryHead) (*Entry, error) {
+	_, _, size, err := jf.objectHeader(_eh.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	itemSize := uint64(16)
+	if jf.header.compact() {
+		itemSize = 4
+	}
+	n := (size - entryObjectHeadSize) / itemSize
+
+	e := &Entry{
+		Fields:    make(map[string]string, n),
+		Realtime:  _eh.realtime,
+		Monotonic: _eh.monotonic,
+		Seqnum:    _eh.seqnum,
+		SeqnumID:  jf.header.seqnumID,
+		BootID:    _eh.bootID,
+		xorHash:   _eh.xorHash,
+	}
+	for i := uint64(0); i < n; i++ {
+		pos := int(_eh.offset + entryObjectHeadSize + i*itemSize)
+		var dataOffset uint64
+		if itemSize == 4 {
+			dataOffset = uint64(le32(jf.data, pos))
+		} else {
+			dataOffset = le64(jf.data, pos)
+		}
+		payload, err := jf.dataPayload(dataOffset)
+		if err != nil {
+			return nil, err
+		}
+		idx := bytes.IndexByte(payload, '=')
+		if idx <= 0 {
+			continue
+		}
+		e.addField(string(payload[:idx]), string(payload[idx+1:]))
+	}
+	return e, nil
+}
+
+func (jf *File) dataPayload(_offset uint64) ([]byte, error) {
+	otype, flags, size, err := jf.objectHeader(_offset)
+	if err != nil {
+		return nil, err
+	}
+	payloadOffset := uint64(dataPayloadOffset)
+	if jf.header.compact() {
+		payloadOffset = dataPayloadOffsetCompact
+	}
+	if otype != objectData || size < payloadOffset {
+		return nil, errors.Wrapf(ErrInvalidFile, "%s: bad data object at %d", jf.Path, _offset)
+	}
+	payload := jf.data[_offset+payloadOffset : _offset+size]
+
+	switch {
+	case flags&objectCompressedLZ4 != 0:
+		return decompressLZ4(payload)
+	case flags&objectCompressedZSTD != 0:
+		return decompressZSTD(payload)
+	case flags&objectCompressedXZ != 0:
+		return nil, errors.Wrapf(ErrUnsupportedCompression, "%s: data object at %d, flags %#x", jf.Path, _offset, flags)
+	}
+	return payload, nil
+}
+
+/*
+返回_offsets中第一个满足_after的entry下标，不存在时返回len(_offsets)
+
+要求_after对entry下标单调：某个下标满足时，其后的下标均满足
+*/
+func (jf *File) search(_offsets []uint64, _after func(*entryHead) bool) (int, error) {
+	var searchErr error
+	idx := sort.Search(len(_offsets), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		eh, err := jf.entryHead(_offsets[i])
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return _after(eh)
+	})
+	return idx, searchErr
+}
+
+func (jf *File) Close() {
+	if jf.data != nil {
+		syscall.Munmap(jf.data)
+		jf.data = nil
+	}
+	if jf.f != nil {
+		jf.f.Close()
+	}
+}
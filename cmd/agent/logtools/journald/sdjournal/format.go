@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+/*
+systemd journal文件格式，参考：
+https://systemd.io/JOURNAL_FILE_FORMAT/
+
+所有整数均为小端序，对象按8字节对齐
+*/
+
+const signature = "LPKSHHRH"
+
+// header incompatible_flags
+const (
+	incompatibleCompressedXZ   uint32 = 1 << 0
+	incompatibleCompressedLZ4  uint32 = 1 << 1
+	incompatibleKeyedHash      uint32 = 1 << 2
+	incompatibleCompressedZSTD uint32 = 1 << 3
+	incompatibleCompact        uint32 = 1 << 4
+
+	incompatibleSupported = incompatibleCompressedXZ | incompatibleCompressedLZ4 | incompatibleKeyedHash |
+		incompatibleCompressedZSTD | incompatibleCompact
+)
+
+// object type
+const (
+	objectUnused uint8 = iota
+	objectData
+	objectField
+	objectEntry
+	objectDataHashTable
+	objectFieldHashTable
+	objectEntryArray
+	objectTag
+)
+
+// object flags
+const (
+	objectCompressedXZ   uint8 = 1 << 0
+	objectCompressedLZ4  uint8 = 1 << 1
+	objectCompressedZSTD uint8 = 1 << 2
+)
+
+const (
+	headerMinSize       = 208
+	objectHeaderSize    = 16
+	entryObjectHeadSize = 64
+	entryArrayHeadSize  = 24
+	hashItemSize        = 16
+	fieldPayloadOffset  = 40
+	dataPayloadOffset   = 64
+	// compact模式下data object在n_entries之后多出tail_entry_array_offset、tail_entry_array_n_entries两个le32字段
+	dataPayloadOffsetCompact = 72
+)
+
+var (
+	ErrInvalidFile            = errors.New("invalid journal file")
+	ErrUnsupportedCompression = errors.New("unsupported journal compression")
+)
+
+type ID128 [16]byte
+
+func (id ID128) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func ParseID128(_s string) (ID128, error) {
+	var id ID128
+	b, err := hex.DecodeString(_s)
+	if err != nil || len(b) != 16 {
+		return id, errors.Errorf("invalid 128bit id: %s", _s)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+type fileHeader struct {
+	compatibleFlags   uint32
+	incompatibleFlags uint32
+	state             uint8
+	fileID            ID128
+	machineID         ID128
+	seqnumID          ID128
+	headerSize        uint64
+	arenaSize         uint64
+	// hash table的items位置及字节数
+	dataHashTableOffset  uint64
+	dataHashTableSize    uint64
+	fieldHashTableOffset uint64
+	fieldHashTableSize   uint64
+	nObjects             uint64
+	nEntries             uint64
+	tailEntrySeqnum      uint64
+	headEntrySeqnum      uint64
+	entryArrayOffset     uint64
+	headEntryRealtime    uint64
+	tailEntryRealtime    uint64
+}
+
+func parseHeader(_b []byte) (*fileHeader, error) {
+	if len(_b) < headerMinSize || string(_b[:8]) != signature {
+		return nil, errors.Wrap(ErrInvalidFile, "bad signature")
+	}
+	h := &fileHeader{
+		compatibleFlags:      le32(_b, 8),
+		incompatibleFlags:    le32(_b, 12),
+		state:                _b[16],
+		headerSize:           le64(_b, 88),
+		arenaSize:            le64(_b, 96),
+		dataHashTableOffset:  le64(_b, 104),
+		dataHashTableSize:    le64(_b, 112),
+		fieldHashTableOffset: le64(_b, 120),
+		fieldHashTableSize:   le64(_b, 128),
+		nObjects:             le64(_b, 144),
+		nEntries:             le64(_b, 152),
+		tailEntrySeqnum:      le64(_b, 160),
+		headEntrySeqnum:      le64(_b, 168),
+		entryArrayOffset:     le64(_b, 176),
+		headEntryRealtime:    le64(_b, 184),
+		tailEntryRealtime:    le64(_b, 192),
+	}
+	copy(h.fileID[:], _b[24:40])
+	copy(h.machineID[:], _b[40:56])
+	copy(h.seqnumID[:], _b[72:88])
+
+	if h.incompatibleFlags&^incompatibleSupported != 0 {
+		return nil, errors.Wrapf(ErrInvalidFile, "unknown incompatible flags: %#x", h.incompatibleFlags)
+	}
+	if h.headerSize < headerMinSize {
+		return nil, errors.Wrapf(ErrInvalidFile, "header size too small: %d", h.headerSize)
+	}
+	return h, nil
+}
+
+func (h *fileHeader) compact() bool {
+	return h.incompatibleFlags&incompatibleCompact != 0
+}
+
+// 是否可能包含当前无法解压的对象
+func (h *fileHeader) unsupportedCompression() bool {
+	return h.incompatibleFlags&incompatibleCompressedXZ != 0
+}
+
+func le32(_b []byte, _off int) uint32 {
+	return binary.LittleEndian.Uint32(_b[_off : _off+4])
+}
+
+func le64(_b []byte, _off int) uint64 {
+	return binary.LittleEndian.Uint64(_b[_off : _off+8])
+}
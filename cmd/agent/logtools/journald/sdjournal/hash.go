@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 10:06:18 2026 +0800
+ */
+package sdjournal
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+/*
+data、field hash table使用的哈希函数，与journal_file_hash_data一致：
+
+header包含keyed_hash标志时为以file_id为密钥的siphash24，否则为jenkins_hash64（lookup3的hashlittle2）
+*/
+func (jf *File) hash(_data []byte) uint64 {
+	if jf.header.incompatibleFlags&incompatibleKeyedHash != 0 {
+		return siphash24(_data, jf.header.fileID)
+	}
+	return jenkinsHash64(_data)
+}
+
+func siphash24(_data []byte, _key ID128) uint64 {
+	k0 := binary.LittleEndian.Uint64(_key[0:8])
+	k1 := binary.LittleEndian.Uint64(_key[8:16])
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	data := _data
+	for ; len(data) >= 8; data = data[8:] {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	// 最后不足8字节的部分，最高字节为总长度
+	var tail [8]byte
+	copy(tail[:], data)
+	tail[7] = byte(len(_data))
+	m := binary.LittleEndian.Uint64(tail[:])
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// 与systemd的jenkins_hash64一致：hashlittle2的两个结果c、b组成64位
+func jenkinsHash64(_data []byte) uint64 {
+	c, b := hashlittle2(_data, 0, 0)
+	return uint64(c)<<32 | uint64(b)
+}
+
+func hashlittle2(_data []byte, _pc, _pb uint32) (uint32, uint32) {
+	a := 0xdeadbeef + uint32(len(_data)) + _pc
+	b, c := a, a+_pb
+
+	data := _data
+	for ; len(data) > 12; data = data[12:] {
+		a += binary.LittleEndian.Uint32(data[0:4])
+		b += binary.LittleEndian.Uint32(data[4:8])
+		c += binary.LittleEndian.Uint32(data[8:12])
+		// mix
+		a -= c
+		a ^= bits.RotateLeft32(c, 4)
+		c += b
+		b -= a
+		b ^= bits.RotateLeft32(a, 6)
+		a += c
+		c -= b
+		c ^= bits.RotateLeft32(b, 8)
+		b += a
+		a -= c
+		a ^= bits.RotateLeft32(c, 16)
+		c += b
+		b -= a
+		b ^= bits.RotateLeft32(a, 19)
+		a += c
+		c -= b
+		c ^= bits.RotateLeft32(b, 4)
+		b += a
+	}
+	if len(data) == 0 {
+		return c, b
+	}
+	// 最后1-12字节按小端补零
+	var tail [12]byte
+	copy(tail[:], data)
+	a += binary.LittleEndian.Uint32(tail[0:4])
+	b += binary.LittleEndian.Uint32(tail[4:8])
+	c += binary.LittleEndian.Uint32(tail[8:12])
+	// final
+	c ^= b
+	c -= bits.RotateLeft32(b, 14)
+	a ^= c
+	a -= bits.RotateLeft32(c, 11)
+	b ^= a
+	b -= bits.RotateLeft32(a, 25)
+	c ^= b
+	c -= bits.RotateLeft32(b, 16)
+	a ^= c
+	a -= bits.RotateLeft32(c, 4)
+	b ^= a
+	b -= bits.RotateLeft32(a, 14)
+	c ^= b
+	c -= bits.RotateLeft32(b, 24)
+	return c, b
+}
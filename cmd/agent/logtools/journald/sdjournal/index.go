@@ -0,0 +1,334 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 10:31:47 2026 +0800
+ */
+package sdjournal
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+/*
+通过data hash table查找FIELD=VALUE对应的data object，沿data object的entry array得到引用它的entry，
+不必读取文件中的每个entry
+
+hash table或对象不合法时返回错误，由调用方回退至逐个entry检查过滤条件
+*/
+
+// 过滤条件在单个文件中的候选entry
+type filterIndex struct {
+	// 无法通过hash table计算时检查全部entry
+	full bool
+	// 候选entry偏移量，升序；可能多于满足条件的entry，读取后仍检查过滤条件
+	offsets []uint64
+	// 已处理的entryOffsets数量，之后新增的entry全部作为候选
+	indexed int
+}
+
+// 候选entry的偏移量，升序
+func (jf *File) candidates(_filter Filter) []uint64 {
+	if _filter == nil {
+		return jf.entryOffsets
+	}
+	if jf.index == nil {
+		jf.index = jf.buildIndex(_filter)
+	}
+	if jf.index.full {
+		return jf.entryOffsets
+	}
+	return jf.index.offsets
+}
+
+func (jf *File) buildIndex(_filter Filter) *filterIndex {
+	offsets, err := jf.resolve(_filter)
+	if err != nil || offsets == nil {
+		return &filterIndex{full: true}
+	}
+	// 只保留已读取的entry，此后写入的entry在Refresh时加入
+	indexed := len(jf.entryOffsets)
+	if indexed > 0 {
+		last := jf.entryOffsets[indexed-1]
+		offsets = offsets[:sort.Search(len(offsets), func(i int) bool { return offsets[i] > last })]
+	} else {
+		offsets = offsets[:0]
+	}
+	return &filterIndex{offsets: offsets, indexed: indexed}
+}
+
+// Refresh后新增的entry作为候选
+func (jf *File) extendIndex() {
+	if jf.index == nil || jf.index.full {
+		return
+	}
+	jf.index.offsets = append(jf.index.offsets, jf.entryOffsets[jf.index.indexed:]...)
+	jf.index.indexed = len(jf.entryOffsets)
+}
+
+/*
+满足过滤条件的entry偏移量的超集，升序；无法计算时返回nil
+
+FieldMatch、BootMatch通过hash table查找，And取可计算部分的交集，Or要求每一项均可计算
+*/
+func (jf *File) resolve(_filter Filter) ([]uint64, error) {
+	switch f := _filter.(type) {
+	case FieldMatch:
+		data_offset, err := jf.findData([]byte(f.Field + "=" + f.Value))
+		if err != nil || data_offset == 0 {
+			return []uint64{}, err
+		}
+		return jf.dataEntries(data_offset)
+	case BootMatch:
+		return jf.resolve(FieldMatch{Field: "_BOOT_ID", Value: ID128(f).String()})
+	case And:
+		var result []uint64
+		for _, sub := range f {
+			offsets, err := jf.resolve(sub)
+			if err != nil {
+				return nil, err
+			}
+			if offsets == nil {
+				continue
+			}
+			if result == nil {
+				result = offsets
+			} else {
+				result = intersectOffsets(result, offsets)
+			}
+		}
+		return result, nil
+	case Or:
+		if len(f) == 0 {
+			return nil, nil
+		}
+		result := []uint64{}
+		for _, sub := range f {
+			offsets, err := jf.resolve(sub)
+			if err != nil || offsets == nil {
+				return nil, err
+			}
+			result = unionOffsets(result, offsets)
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
+// hash table中_hash所在链表的第一个对象
+func (jf *File) hashTableHead(_table_offset, _table_size, _hash uint64) (uint64, error) {
+	n := _table_size / hashItemSize
+	if _table_offset == 0 || n == 0 || _table_offset+_table_size > uint64(len(jf.data)) {
+		return 0, errors.Wrapf(ErrInvalidFile, "%s: bad hash table at %d", jf.Path, _table_offset)
+	}
+	return le64(jf.data, int(_table_offset+(_hash%n)*hashItemSize)), nil
+}
+
+// FIELD=VALUE对应的data object，不存在时返回0
+func (jf *File) findData(_payload []byte) (uint64, error) {
+	h := jf.hash(_payload)
+	offset, err := jf.hashTableHead(jf.header.dataHashTableOffset, jf.header.dataHashTableSize, h)
+	if err != nil {
+		return 0, err
+	}
+	for i := uint64(0); offset != 0; i++ {
+		if i > jf.header.nObjects {
+			return 0, errors.Wrapf(ErrInvalidFile, "%s: data hash chain loop", jf.Path)
+		}
+		otype, _, size, err := jf.objectHeader(offset)
+		if err != nil {
+			return 0, err
+		}
+		if otype != objectData || size < dataPayloadOffset {
+			return 0, errors.Wrapf(ErrInvalidFile, "%s: bad data object at %d", jf.Path, offset)
+		}
+		if le64(jf.data, int(offset)+16) == h {
+			payload, err := jf.dataPayload(offset)
+			if err != nil {
+				return 0, err
+			}
+			if bytes.Equal(payload, _payload) {
+				return offset, nil
+			}
+		}
+		offset = le64(jf.data, int(offset)+24)
+	}
+	return 0, nil
+}
+
+// 字段名对应的field object，不存在时返回0
+func (jf *File) findField(_field []byte) (uint64, error) {
+	h := jf.hash(_field)
+	offset, err := jf.hashTableHead(jf.header.fieldHashTableOffset, jf.header.fieldHashTableSize, h)
+	if err != nil {
+		return 0, err
+	}
+	for i := uint64(0); offset != 0; i++ {
+		if i > jf.header.nObjects {
+			return 0, errors.Wrapf(ErrInvalidFile, "%s: field hash chain loop", jf.Path)
+		}
+		otype, _, size, err := jf.objectHeader(offset)
+		if err != nil {
+			return 0, err
+		}
+		if otype != objectField || size < fieldPayloadOffset {
+			return 0, errors.Wrapf(ErrInvalidFile, "%s: bad field object at %d", jf.Path, offset)
+		}
+		if le64(jf.data, int(offset)+16) == h && bytes.Equal(jf.data[offset+fieldPayloadOffset:offset+size], _field) {
+			return offset, nil
+		}
+		offset = le64(jf.data, int(offset)+24)
+	}
+	return 0, nil
+}
+
+// 引用data object的entry偏移量，升序
+func (jf *File) dataEntries(_offset uint64) ([]uint64, error) {
+	o := int(_offset)
+	first := le64(jf.data, o+40)
+	n := le64(jf.data, o+56)
+	if first == 0 || n == 0 {
+		return []uint64{}, nil
+	}
+	offsets, err := jf.readEntryArray(le64(jf.data, o+48), n-1)
+	if err != nil {
+		return nil, err
+	}
+	offsets = append(offsets, first)
+	sort.Slice(offsets, func(i, k int) bool { return offsets[i] < offsets[k] })
+	// 同一entry可能多次引用相同的data object
+	result := offsets[:0]
+	for i, offset := range offsets {
+		if i == 0 || offset != offsets[i-1] {
+			result = append(result, offset)
+		}
+	}
+	return result, nil
+}
+
+// 沿entry array链表读取至多_n个entry偏移量
+func (jf *File) readEntryArray(_offset uint64, _n uint64) ([]uint64, error) {
+	itemSize := 8
+	if jf.header.compact() {
+		itemSize = 4
+	}
+	offsets := []uint64{}
+	for offset := _offset; offset != 0 && uint64(len(offsets)) < _n; {
+		otype, _, size, err := jf.objectHeader(offset)
+		if err != nil {
+			return nil, err
+		}
+		if otype != objectEntryArray || size < entryArrayHeadSize {
+			return nil, errors.Wrapf(ErrInvalidFile, "%s: bad entry array object at %d", jf.Path, offset)
+		}
+		n := int(size-entryArrayHeadSize) / itemSize
+		for i := 0; i < n && uint64(len(offsets)) < _n; i++ {
+			pos := int(offset) + entryArrayHeadSize + i*itemSize
+			var item uint64
+			if itemSize == 4 {
+				item = uint64(le32(jf.data, pos))
+			} else {
+				item = le64(jf.data, pos)
+			}
+			if item == 0 {
+				return offsets, nil
+			}
+			offsets = append(offsets, item)
+		}
+		next := le64(jf.data, int(offset)+16)
+		// 后追加的entry array位于文件更靠后的位置
+		if next != 0 && next <= offset {
+			return nil, errors.Wrapf(ErrInvalidFile, "%s: entry array loop at %d", jf.Path, offset)
+		}
+		offset = next
+	}
+	return offsets, nil
+}
+
+/*
+通过_BOOT_ID字段的data object得到文件中每次启动的第一条、最后一条entry
+
+文件中没有_BOOT_ID字段时返回空列表
+*/
+func (jf *File) boots() ([]Boot, error) {
+	field_offset, err := jf.findField([]byte("_BOOT_ID"))
+	if err != nil || field_offset == 0 {
+		return nil, err
+	}
+	boots := []Boot{}
+	data_offset := le64(jf.data, int(field_offset)+32)
+	for i := uint64(0); data_offset != 0; i++ {
+		if i > jf.header.nObjects {
+			return nil, errors.Wrapf(ErrInvalidFile, "%s: field data chain loop", jf.Path)
+		}
+		payload, err := jf.dataPayload(data_offset)
+		if err != nil {
+			return nil, err
+		}
+		id, err := ParseID128(string(bytes.TrimPrefix(payload, []byte("_BOOT_ID="))))
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidFile, "%s: bad _BOOT_ID data at %d", jf.Path, data_offset)
+		}
+		offsets, err := jf.dataEntries(data_offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(offsets) > 0 {
+			first, err := jf.entryHead(offsets[0])
+			if err != nil {
+				return nil, err
+			}
+			last, err := jf.entryHead(offsets[len(offsets)-1])
+			if err != nil {
+				return nil, err
+			}
+			boots = append(boots, Boot{ID: id, First: first.realtime, Last: last.realtime})
+		}
+		data_offset = le64(jf.data, int(data_offset)+32)
+	}
+	return boots, nil
+}
+
+// 两个升序列表的交集
+func intersectOffsets(_a, _b []uint64) []uint64 {
+	result := []uint64{}
+	for i, k := 0, 0; i < len(_a) && k < len(_b); {
+		switch {
+		case _a[i] < _b[k]:
+			i++
+		case _a[i] > _b[k]:
+			k++
+		default:
+			result = append(result, _a[i])
+			i++
+			k++
+		}
+	}
+	return result
+}
+
+// 两个升序列表的并集
+func unionOffsets(_a, _b []uint64) []uint64 {
+	result := make([]uint64, 0, len(_a)+len(_b))
+	i, k := 0, 0
+	for i < len(_a) && k < len(_b) {
+		switch {
+		case _a[i] < _b[k]:
+			result = append(result, _a[i])
+			i++
+		case _a[i] > _b[k]:
+			result = append(result, _b[k])
+			k++
+		default:
+			result = append(result, _a[i])
+			i++
+			k++
+		}
+	}
+	result = append(result, _a[i:]...)
+	return append(result, _b[k:]...)
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 11:02:39 2026 +0800
+ */
+package sdjournal
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// lookup3.c及SipHash论文中的测试向量
+func TestHash(t *testing.T) {
+	jenkins := []struct {
+		data   string
+		pc, pb uint32
+		c, b   uint32
+	}{
+		{"", 0, 0, 0xdeadbeef, 0xdeadbeef},
+		{"", 0, 0xdeadbeef, 0xbd5b7dde, 0xdeadbeef},
+		{"", 0xdeadbeef, 0xdeadbeef, 0x9c093ccd, 0xbd5b7dde},
+		{"Four score and seven years ago", 0, 0, 0x17770551, 0xce7226e6},
+		{"Four score and seven years ago", 0, 1, 0xe3607cae, 0xbd371de4},
+		{"Four score and seven years ago", 1, 0, 0xcd628161, 0x6cbea4b3},
+	}
+	for _, tt := range jenkins {
+		if c, b := hashlittle2([]byte(tt.data), tt.pc, tt.pb); c != tt.c || b != tt.b {
+			t.Errorf("hashlittle2(%q, %#x, %#x): got %#x %#x, want %#x %#x", tt.data, tt.pc, tt.pb, c, b, tt.c, tt.b)
+		}
+	}
+
+	var key ID128
+	for i := range key {
+		key[i] = byte(i)
+	}
+	message := []byte{}
+	for i := 0; i < 15; i++ {
+		message = append(message, byte(i))
+	}
+	if got := siphash24(nil, key); got != 0x726fdb47dd0e0e31 {
+		t.Errorf("siphash24 of empty message: got %#x", got)
+	}
+	if got := siphash24(message, key); got != 0xa129ca6149be45e5 {
+		t.Errorf("siphash24 of 15 bytes: got %#x", got)
+	}
+}
+
+// 过滤条件通过hash table得到的候选entry与逐个检查的结果一致
+func TestFilterIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		// 候选entry即为满足条件的entry
+		exact bool
+	}{
+		{"field", FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: "fixture"}, true},
+		{"repeated later value", FieldMatch{Field: "TAG", Value: "three"}, true},
+		{"binary", FieldMatch{Field: "BINARY", Value: "a\x00b\x7f"}, true},
+		{"compressed", FieldMatch{Field: "BIG", Value: strings.Repeat("x", 520)}, true},
+		{"missing", FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: "missing"}, true},
+		{"or", FieldIn("FIXTURE_SEQ", "1", "3", "5"), true},
+		{"and", And{FieldMatch{Field: "PRIORITY", Value: "4"}, FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: "fixture"}}, true},
+		{"and not", And{FieldMatch{Field: "SYSLOG_IDENTIFIER", Value: "fixture"}, Not{Filter: FieldMatch{Field: "PRIORITY", Value: "4"}}}, false},
+	}
+	for _, name := range fixtures {
+		j := openFixture(t, name)
+		for _, tt := range tests {
+			for _, jf := range j.files {
+				index := jf.buildIndex(tt.filter)
+				if index.full {
+					t.Errorf("%s/%s: %s not resolved by hash table", name, tt.name, jf.Path)
+					continue
+				}
+				matched := []uint64{}
+				for _, offset := range jf.entryOffsets {
+					eh, err := jf.entryHead(offset)
+					if err != nil {
+						t.Fatal(err)
+					}
+					e, err := jf.readEntry(eh)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if tt.filter.Match(e) {
+						matched = append(matched, offset)
+					}
+				}
+				if tt.exact && !reflect.DeepEqual(index.offsets, matched) {
+					t.Errorf("%s/%s: got candidates %v, want %v", name, tt.name, index.offsets, matched)
+				}
+				if !tt.exact && !reflect.DeepEqual(intersectOffsets(index.offsets, matched), matched) {
+					t.Errorf("%s/%s: candidates %v do not contain %v", name, tt.name, index.offsets, matched)
+				}
+			}
+		}
+	}
+}
+
+// 无法通过hash table计算的过滤条件检查全部entry
+func TestFilterIndexFull(t *testing.T) {
+	j := openFixture(t, "plain")
+	jf := j.files[0]
+	for _, filter := range []Filter{
+		Not{Filter: FieldMatch{Field: "PRIORITY", Value: "4"}},
+		Or{},
+		Or{FieldMatch{Field: "PRIORITY", Value: "4"}, Not{Filter: FieldMatch{Field: "PRIORITY", Value: "4"}}},
+		And{Not{Filter: FieldMatch{Field: "PRIORITY", Value: "4"}}},
+	} {
+		if index := jf.buildIndex(filter); !index.full {
+			t.Errorf("%#v: got candidates %v", filter, index.offsets)
+		}
+	}
+}
+
+// 通过_BOOT_ID得到的启动列表与逐个读取entry一致
+func TestBootsIndex(t *testing.T) {
+	for _, name := range fixtures {
+		j := openFixture(t, name)
+		for _, jf := range j.files {
+			boots, err := jf.boots()
+			if err != nil {
+				t.Fatalf("%s: %s", jf.Path, err)
+			}
+			scanned := map[ID128]*Boot{}
+			for _, offset := range jf.entryOffsets {
+				eh, err := jf.entryHead(offset)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b, ok := scanned[eh.bootID]; ok {
+					b.Last = eh.realtime
+				} else {
+					scanned[eh.bootID] = &Boot{ID: eh.bootID, First: eh.realtime, Last: eh.realtime}
+				}
+			}
+			want := []Boot{}
+			for _, b := range scanned {
+				want = append(want, *b)
+			}
+			sort.Slice(want, func(i, k int) bool { return want[i].First < want[k].First })
+			sort.Slice(boots, func(i, k int) bool { return boots[i].First < boots[k].First })
+			if len(boots) == 0 || !reflect.DeepEqual(boots, want) {
+				t.Errorf("%s: got %+v, want %+v", jf.Path, boots, want)
+			}
+		}
+	}
+}
+
+// 过滤条件变化及文件刷新后候选entry随之更新
+func TestFilterIndexRefresh(t *testing.T) {
+	j := openFixture(t, "plain")
+	j.SetFilter(FieldMatch{Field: "FIXTURE_SEQ", Value: "2"})
+	j.SeekHead()
+	if got := len(readAll(t, j, true)); got != 1 {
+		t.Fatalf("got %d entries, want 1", got)
+	}
+	j.SetFilter(FieldIn("FIXTURE_SEQ", "2", "4"))
+	j.SeekHead()
+	if got := len(readAll(t, j, true)); got != 2 {
+		t.Fatalf("got %d entries after SetFilter, want 2", got)
+	}
+	if err := j.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	for _, jf := range j.files {
+		if jf.index == nil || jf.index.full || jf.index.indexed != len(jf.entryOffsets) {
+			t.Errorf("%s: index not kept after refresh: %+v", jf.Path, jf.index)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+var DefaultJournalDirs = []string{"/var/log/journal", "/run/log/journal"}
+
+type locationType int
+
+const (
+	locHead locationType = iota
+	locTail
+	locRealtime
+	locEntry
+)
+
+// 读取位置，Next/Previous返回位置之后/之前的entry
+type location struct {
+	typ locationType
+
+	realtime  uint64
+	seqnumID  ID128
+	seqnum    uint64
+	monotonic uint64
+	bootID    ID128
+	xorHash   uint64
+
+	// locEntry时Next/Previous是否可以返回该位置的entry本身
+	inclusive bool
+}
+
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// 多个journal文件按时间顺序合并读取
+type Journal struct {
+	dirs []string
+
+	files    []*File
+	fileKeys map[fileKey]struct{}
+
+	filter Filter
+
+	loc location
+}
+
+// 打开本机journal目录（<dir>/<machine-id>/*.journal）
+func OpenLocal() (*Journal, error) {
+	dirs := []string{}
+	machineID, err := os.ReadFile("/etc/machine-id")
+	for _, d := range DefaultJournalDirs {
+		if err == nil {
+			dirs = append(dirs, filepath.Join(d, strings.TrimSpace(string(machineID))))
+		} else {
+			dirs = append(dirs, d)
+		}
+	}
+	return OpenDirs(dirs...)
+}
+
+func OpenDirs(_dirs ...string) (*Journal, error) {
+	j := &Journal{
+		dirs:     _dirs,
+		fileKeys: make(map[fileKey]struct{}),
+	}
+	if err := j.scan(); err != nil {
+		j.Close()
+		return nil, err
+	}
+	if len(j.files) == 0 {
+		j.Close()
+		return nil, errors.Errorf("no journal files found in %v", _dirs)
+	}
+	return j, nil
+}
+
+func (j *Journal) scan() error {
+	for _, dir := range j.dirs {
+		paths := []string{}
+		err := filepath.Walk(dir, func(_path string, _info os.FileInfo, _err error) error {
+			if _err != nil {
+				if os.IsNotExist(_err) || os.IsPermission(_err) {
+					return nil
+				}
+				return _err
+			}
+			// "~"结尾的为journald启动时发现未正常关闭而重命名的文件
+			if _info.Mode().IsRegular() && (strings.HasSuffix(_path, ".journal") || strings.HasSuffix(_path, ".journal~")) {
+				paths = append(paths, _path)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Errorf("fail to scan journal dir %s: %s", dir, err.Error())
+		}
+		for _, p := range paths {
+			if err := j.addFile(p); err != nil {
+				// 损坏或无权限的文件跳过，与journalctl行为一致
+				continue
+			}
+		}
+	}
+	return nil
+}
+
+func (j *Journal) addFile(_path string) error {
+	info, err := os.Stat(_path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.Errorf("fail to get inode: %s", _path)
+	}
+	key := fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+	if _, ok := j.fileKeys[key]; ok {
+		return nil
+	}
+	jf, err := OpenFile(_path)
+	if err != nil {
+		return err
+	}
+	j.fileKeys[key] = struct{}{}
+	j.files = append(j.files, jf)
+	return nil
+}
+
+// 读取已打开文件新增的entry，并加入新创建的journal文件（如轮转后的system.journal）
+func (j *Journal) Refresh() error {
+	for _, jf := range j.files {
+		if err := jf.Refresh(); err != nil {
+			return err
+		}
+	}
+	return j.scan()
+}
+
+// 是否所有文件都不包含无法解压的对象
+func (j *Journal) Supported() bool {
+	for _, jf := range j.files {
+		if jf.header.unsupportedCompression() {
+			return false
+		}
+	}
+	return true
+}
+
+func (j *Journal) SetFilter(_filter Filter) {
+	j.filter = _filter
+	for _, jf := range j.files {
+		jf.index = nil
+	}
+}
+
+func (j *Journal) SeekHead() {
+	j.loc = location{typ: locHead}
+}
+
+func (j *Journal) SeekTail() {
+	j.loc = location{typ: locTail}
+}
+
+// Next返回第一个时间不早于_usec的entry
+func (j *Journal) SeekRealtime(_usec uint64) {
+	j.loc = location{typ: locRealtime, realtime: _usec}
+}
+
+// Next/Previous返回的第一个entry为cursor对应的entry（若存在）
+func (j *Journal) SeekCursor(_cursor string) error {
+	c, err := ParseCursor(_cursor)
+	if err != nil {
+		return err
+	}
+	j.loc = location{
+		typ:       locEntry,
+		realtime:  c.Realtime,
+		seqnumID:  c.SeqnumID,
+		seqnum:    c.Seqnum,
+		monotonic: c.Monotonic,
+		bootID:    c.BootID,
+		xorHash:   c.XorHash,
+		inclusive: true,
+	}
+	return nil
+}
+
+// 与sd_journal_test_cursor一致：当前位置是否为cursor对应的entry
+func TestCursor(_e *Entry, _cursor string) bool {
+	c, err := ParseCursor(_cursor)
+	if err != nil {
+		return false
+	}
+	return _e.SeqnumID == c.SeqnumID && _e.Seqnum == c.Seqnum && _e.BootID == c.BootID && _e.Realtime == c.Realtime
+}
+
+func (j *Journal) Next() (*Entry, error) {
+	return j.step(true)
+}
+
+func (j *Journal) Previous() (*Entry, error) {
+	return j.step(false)
+}
+
+func (j *Journal) step(_forward bool) (*Entry, error) {
+	for {
+		var best *File
+		var bestHead *entryHead
+		for _, jf := range j.files {
+			eh, err := j.candidate(jf, _forward)
+			if err != nil {
+				return nil, err
+			}
+			if eh == nil {
+				continue
+			}
+			if best == nil {
+				best, bestHead = jf, eh
+				continue
+			}
+			c := compareEntry(eh, jf.header.seqnumID, bestHead, best.header.seqnumID)
+			if (_forward && c < 0) || (!_forward && c > 0) {
+				best, bestHead = jf, eh
+			}
+		}
+		if best == nil {
+			return nil, io.EOF
+		}
+
+		j.loc = location{
+			typ:       locEntry,
+			realtime:  bestHead.realtime,
+			seqnumID:  best.header.seqnumID,
+			seqnum:    bestHead.seqnum,
+			monotonic: bestHead.monotonic,
+			bootID:    bestHead.bootID,
+			xorHash:   bestHead.xorHash,
+		}
+
+		e, err := best.readEntry(bestHead)
+		if err != nil {
+			return nil, err
+		}
+		if j.filter == nil || j.filter.Match(e) {
+			return e, nil
+		}
+	}
+}
+
+// 文件中位于当前读取位置之后（或之前）的第一个entry
+func (j *Journal) candidate(_jf *File, _forward bool) (*entryHead, error) {
+	offsets := _jf.candidates(j.filter)
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+	sid := _jf.header.seqnumID
+	idx, err := _jf.search(offsets, func(_eh *entryHead) bool {
+		c := j.compareLocation(_eh, sid)
+		if _forward == j.loc.inclusive {
+			// forward且inclusive、backward且非inclusive：第一个>=loc的entry
+			return c >= 0
+		}
+		return c > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !_forward {
+		idx--
+	}
+	if idx < 0 || idx >= len(offsets) {
+		return nil, nil
+	}
+	return _jf.entryHead(offsets[idx])
+}
+
+// entry相对于当前读取位置的先后
+func (j *Journal) compareLocation(_eh *entryHead, _sid ID128) int {
+	switch j.loc.typ {
+	case locHead:
+		return 1
+	case locTail:
+		return -1
+	case locRealtime:
+		if _eh.realtime >= j.loc.realtime {
+			return 1
+		}
+		return -1
+	}
+	loc := &entryHead{
+		realtime:  j.loc.realtime,
+		seqnum:    j.loc.seqnum,
+		monotonic: j.loc.monotonic,
+		bootID:    j.loc.bootID,
+		xorHash:   j.loc.xorHash,
+	}
+	return compareEntry(_eh, _sid, loc, j.loc.seqnumID)
+}
+
+/*
+与sd-journal的compare_entry_order一致：同一seqnum_id的entry按seqnum排序，seqnum相同（文件未正常关闭时可能出现）或seqnum_id不同时，
+依次按同一次启动的monotonic、realtime、xor_hash排序
+
+journald对同一次运行写入的所有文件使用同一个seqnum_id
+*/
+func compareEntry(_a *entryHead, _asid ID128, _b *entryHead, _bsid ID128) int {
+	if _asid == _bsid {
+		if c := compareUint(_a.seqnum, _b.seqnum); c != 0 {
+			return c
+		}
+	}
+	if _a.bootID == _b.bootID {
+		if c := compareUint(_a.monotonic, _b.monotonic); c != 0 {
+			return c
+		}
+	}
+	if c := compareUint(_a.realtime, _b.realtime); c != 0 {
+		return c
+	}
+	return compareUint(_a.xorHash, _b.xorHash)
+}
+
+func compareUint(_a, _b uint64) int {
+	switch {
+	case _a < _b:
+		return -1
+	case _a > _b:
+		return 1
+	}
+	return 0
+}
+
+// 已打开的journal文件路径
+func (j *Journal) Files() []string {
+	paths := make([]string, 0, len(j.files))
+	for _, jf := range j.files {
+		paths = append(paths, jf.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (j *Journal) Close() {
+	for _, jf := range j.files {
+		jf.Close()
+	}
+	j.files = nil
+}
+
+/*
+定位到最后一条entry，忽略过滤条件
+
+Next将返回此后新写入的entry；journal为空时定位到开头
+*/
+func (j *Journal) SeekLastEntry() error {
+	filter := j.filter
+	j.filter = nil
+	defer func() { j.filter = filter }()
+
+	j.SeekTail()
+	if _, err := j.Previous(); err != nil {
+		if err == io.EOF {
+			j.SeekHead()
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// journal中lz4压缩的data payload：le64原始长度 + lz4 block
+func decompressLZ4(_src []byte) ([]byte, error) {
+	if len(_src) < 8 {
+		return nil, errors.New("lz4 payload too short")
+	}
+	size := binary.LittleEndian.Uint64(_src[:8])
+	if size > 768*1024*1024 {
+		return nil, errors.Errorf("lz4 payload too large: %d", size)
+	}
+	return lz4DecodeBlock(_src[8:], int(size))
+}
+
+func lz4DecodeBlock(_src []byte, _size int) ([]byte, error) {
+	dst := make([]byte, 0, _size)
+	i := 0
+	for i < len(_src) {
+		token := _src[i]
+		i++
+
+		// literals
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			for {
+				if i >= len(_src) {
+					return nil, errors.New("lz4: truncated literal length")
+				}
+				b := _src[i]
+				i++
+				litLen += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if i+litLen > len(_src) || len(dst)+litLen > _size {
+			return nil, errors.New("lz4: literal out of range")
+		}
+		dst = append(dst, _src[i:i+litLen]...)
+		i += litLen
+		if i == len(_src) {
+			// 最后一个序列只有literals
+			break
+		}
+
+		// match
+		if i+2 > len(_src) {
+			return nil, errors.New("lz4: truncated match offset")
+		}
+		offset := int(_src[i]) | int(_src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errors.New("lz4: invalid match offset")
+		}
+		matchLen := int(token & 0x0f)
+		if matchLen == 15 {
+			for {
+				if i >= len(_src) {
+					return nil, errors.New("lz4: truncated match length")
+				}
+				b := _src[i]
+				i++
+				matchLen += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		matchLen += 4
+		if len(dst)+matchLen > _size {
+			return nil, errors.New("lz4: match out of range")
+		}
+		// 匹配区间可能与输出重叠，逐字节复制
+		start := len(dst) - offset
+		for k := 0; k < matchLen; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+	if len(dst) != _size {
+		return nil, errors.Errorf("lz4: size mismatch: %d != %d", len(dst), _size)
+	}
+	return dst, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+// entry过滤条件
+type Filter interface {
+	Match(_e *Entry) bool
+}
+
+// FIELD=VALUE
+type FieldMatch struct {
+	Field string
+	Value string
+}
+
+// 同名字段出现多次时任意一个值相等即可，与journalctl一致
+func (fm FieldMatch) Match(_e *Entry) bool {
+	for _, v := range _e.Values(fm.Field) {
+		if v == fm.Value {
+			return true
+		}
+	}
+	return false
+}
+
+type And []Filter
+
+func (a And) Match(_e *Entry) bool {
+	for _, f := range a {
+		if !f.Match(_e) {
+			return false
+		}
+	}
+	return true
+}
+
+type Or []Filter
+
+func (o Or) Match(_e *Entry) bool {
+	for _, f := range o {
+		if f.Match(_e) {
+			return true
+		}
+	}
+	return len(o) == 0
+}
+
+type Not struct {
+	Filter Filter
+}
+
+func (n Not) Match(_e *Entry) bool {
+	return !n.Filter.Match(_e)
+}
+
+// 同一字段多个取值，任意一个匹配即可
+func FieldIn(_field string, _values ...string) Filter {
+	o := Or{}
+	for _, v := range _values {
+		o = append(o, FieldMatch{Field: _field, Value: v})
+	}
+	return o
+}
@@ -0,0 +1,25 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 14:05:21 2026 +0800
+ */
+package sdjournal
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// 无状态解码器，可并发使用
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(768*1024*1024))
+
+// journal中zstd压缩的data payload为完整的zstd frame
+func decompressZSTD(_src []byte) ([]byte, error) {
+	dst, err := zstdDecoder.DecodeAll(_src, nil)
+	if err != nil {
+		return nil, errors.Errorf("zstd: %s", err.Error())
+	}
+	return dst, nil
+}
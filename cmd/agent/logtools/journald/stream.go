@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Tue Oct 20 14:47:05 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"fmt"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 单个连接上同时运行的子查询数上限
+	maxStreams = 16
+	// 单个子查询待处理的消息数上限
+	maxStreamInbox = 16
+)
+
+/*
+将指定stream的消息交由对应的子查询处理，子查询不存在时创建
+
+子查询与连接本身的查询相互独立，可以同时运行多个实时查询、分页查询及服务单元列表等请求；
+每个子查询在自身的goroutine中处理消息，读取连接消息的goroutine不等待，结束子查询的请求不会被阻塞
+*/
+func (jclient *JournaldClient) dispatchStream(_jmsg *public.JMessage) {
+	if _jmsg.Type == public.CancelStreamMsg {
+		jclient.cancelStream(_jmsg.Stream)
+		return
+	}
+	stream, err := jclient.openStream(_jmsg.Stream)
+	if err != nil {
+		global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, false)
+		jclient.writeError(_jmsg.Stream, &public.ErrorInfo{Code: public.ErrCodeTooManyStreams, Message: err.Error()})
+		return
+	}
+	select {
+	case stream.inbox <- _jmsg:
+	default:
+		err := errors.Errorf("too many pending messages on stream %s, drop message type %d", _jmsg.Stream, _jmsg.Type)
+		global.ERManager.ErrorTransmit("journald", "warn", err, false, false)
+		jclient.writeError(_jmsg.Stream, &public.ErrorInfo{Code: public.ErrCodeStreamBusy, Message: err.Error()})
+	}
+}
+
+func (jclient *JournaldClient) openStream(_stream string) (*JournaldClient, error) {
+	jclient.streamsMutex.Lock()
+	defer jclient.streamsMutex.Unlock()
+	if stream, ok := jclient.streams[_stream]; ok {
+		return stream, nil
+	}
+	if len(jclient.streams) >= maxStreams {
+		return nil, errors.Errorf("too many streams on journald client %s, drop stream %s", jclient.ID, _stream)
+	}
+	stream := CreateJournaldClient(jclient.wsconn, jclient.timeout)
+	stream.ID = fmt.Sprintf("%s/%s", jclient.ID, _stream)
+	stream.Reader = jclient.Reader
+	stream.Active = true
+	stream.stream = _stream
+	stream.wswriteMutex = jclient.wswriteMutex
+	// 子查询结束时取消正在运行的查询
+	stream.CancelF()
+	stream.parentCtx, stream.streamStop = context.WithCancel(JournaldCtx)
+	stream.CancelC, stream.CancelF = context.WithCancel(stream.parentCtx)
+	stream.inbox = make(chan *public.JMessage, maxStreamInbox)
+	stream.streamDone = make(chan struct{})
+	jclient.streams[_stream] = stream
+	go stream.serveStream()
+	return stream, nil
+}
+
+// 依次处理子查询的消息，子查询结束后释放查询资源
+func (jclient *JournaldClient) serveStream() {
+	defer close(jclient.streamDone)
+
+	for {
+		select {
+		case <-jclient.parentCtx.Done():
+			jclient.Active = false
+			jclient.Close(false, false, false)
+			return
+		case jmsg := <-jclient.inbox:
+			// 子查询已结束时不再处理剩余的消息
+			if jclient.parentCtx.Err() != nil {
+				continue
+			}
+			jclient.handleMessage(jmsg)
+		}
+	}
+}
+
+// 结束子查询，不等待查询资源释放
+func (jclient *JournaldClient) cancelStream(_stream string) {
+	jclient.streamsMutex.Lock()
+	stream, ok := jclient.streams[_stream]
+	delete(jclient.streams, _stream)
+	jclient.streamsMutex.Unlock()
+	if !ok {
+		return
+	}
+	stream.streamStop()
+}
+
+// 连接关闭时结束全部子查询，等待查询资源释放
+func (jclient *JournaldClient) closeStreams() {
+	jclient.streamsMutex.Lock()
+	streams := jclient.streams
+	jclient.streams = make(map[string]*JournaldClient)
+	jclient.streamsMutex.Unlock()
+
+	for _, stream := range streams {
+		stream.streamStop()
+	}
+	for _, stream := range streams {
+		<-stream.streamDone
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 13:06:44 2026 +0800
+ */
+package journald
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/resourcemanage"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/gorilla/websocket"
+)
+
+// 子查询结束时Close固定等待1秒
+const streamCloseTimeout = 5 * time.Second
+
+// 丢弃查询过程中的日志
+func discardErrors() {
+	if global.ERManager != nil {
+		return
+	}
+	erm := &resourcemanage.ErrorReleaseManagement{ErrChan: make(chan error, 64)}
+	go func() {
+		for range erm.ErrChan {
+		}
+	}()
+	global.ERManager = erm
+}
+
+// 返回agent端的查询及客户端连接
+func newStreamTestClient(t *testing.T) (*JournaldClient, *websocket.Conn) {
+	discardErrors()
+	connCh := make(chan *websocket.Conn, 1)
+	upgrader := &websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(_w http.ResponseWriter, _r *http.Request) {
+		conn, err := upgrader.Upgrade(_w, _r, nil)
+		if err != nil {
+			return
+		}
+		connCh <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	agent_conn := <-connCh
+	t.Cleanup(func() { agent_conn.Close() })
+
+	jclient := CreateJournaldClient(agent_conn, time.Second)
+	jclient.ID = "test"
+	jclient.Active = true
+	return jclient, conn
+}
+
+// 读取agent返回的错误消息
+func readStreamError(t *testing.T, _conn *websocket.Conn) (string, *public.ErrorInfo) {
+	_conn.SetReadDeadline(time.Now().Add(streamCloseTimeout))
+	jmsg := &public.JMessage{}
+	if err := _conn.ReadJSON(jmsg); err != nil {
+		t.Fatal(err)
+	}
+	if jmsg.Type != public.ErrorMsg {
+		t.Fatalf("got message type %d, want %d", jmsg.Type, public.ErrorMsg)
+	}
+	data, _ := json.Marshal(jmsg.Data)
+	info := &public.ErrorInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		t.Fatal(err)
+	}
+	return jmsg.Stream, info
+}
+
+func waitStreamDone(t *testing.T, _stream *JournaldClient) {
+	select {
+	case <-_stream.streamDone:
+	case <-time.After(streamCloseTimeout):
+		t.Fatalf("stream %s not closed", _stream.ID)
+	}
+}
+
+func TestOpenStream(t *testing.T) {
+	jclient, _ := newStreamTestClient(t)
+	defer jclient.closeStreams()
+
+	a, err := jclient.openStream("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := jclient.openStream("a"); again != a {
+		t.Error("stream a opened twice")
+	}
+	if a.ID != "test/a" || a.stream != "a" || a.wsconn != jclient.wsconn || a.wswriteMutex != jclient.wswriteMutex {
+		t.Errorf("stream a: got id %s stream %s", a.ID, a.stream)
+	}
+
+	for i := 1; i < maxStreams; i++ {
+		if _, err := jclient.openStream(strconv.Itoa(i)); err != nil {
+			t.Fatalf("stream %d: %s", i, err)
+		}
+	}
+	if _, err := jclient.openStream("full"); err == nil {
+		t.Errorf("%d streams: expected error", maxStreams+1)
+	}
+	// 已存在的子查询不受数量限制
+	if _, err := jclient.openStream("a"); err != nil {
+		t.Errorf("existing stream: %s", err)
+	}
+}
+
+// 消息按stream交由对应的子查询处理，返回的消息带有相同的stream
+func TestDispatchStream(t *testing.T) {
+	jclient, conn := newStreamTestClient(t)
+	defer jclient.closeStreams()
+
+	for _, stream := range []string{"a", "b"} {
+		jclient.dispatchStream(&public.JMessage{Type: public.UpdateOptionsMsg, Stream: stream})
+		got, info := readStreamError(t, conn)
+		if got != stream || info.Code != public.ErrCodeInvalidOptions {
+			t.Errorf("stream %s: got stream %q code %s", stream, got, info.Code)
+		}
+	}
+
+	for i := 2; i < maxStreams; i++ {
+		if _, err := jclient.openStream(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jclient.dispatchStream(&public.JMessage{Type: public.UpdateOptionsMsg, Stream: "full"})
+	if got, info := readStreamError(t, conn); got != "full" || info.Code != public.ErrCodeTooManyStreams {
+		t.Errorf("too many streams: got stream %q code %s", got, info.Code)
+	}
+}
+
+// 结束单个子查询不影响其他子查询及连接本身的查询
+func TestCancelStream(t *testing.T) {
+	jclient, _ := newStreamTestClient(t)
+	a, _ := jclient.openStream("a")
+	b, _ := jclient.openStream("b")
+
+	jclient.dispatchStream(&public.JMessage{Type: public.CancelStreamMsg, Stream: "a"})
+	waitStreamDone(t, a)
+	if a.CancelC.Err() == nil {
+		t.Error("stream a query not canceled")
+	}
+	jclient.streamsMutex.Lock()
+	_, ok := jclient.streams["a"]
+	jclient.streamsMutex.Unlock()
+	if ok {
+		t.Error("stream a not removed")
+	}
+	// 不存在的子查询
+	jclient.dispatchStream(&public.JMessage{Type: public.CancelStreamMsg, Stream: "a"})
+
+	if b.parentCtx.Err() != nil || jclient.CancelC.Err() != nil {
+		t.Error("other queries canceled")
+	}
+	// 同名的子查询重新创建
+	if again, _ := jclient.openStream("a"); again == a {
+		t.Error("canceled stream reused")
+	}
+
+	jclient.closeStreams()
+	if b.parentCtx.Err() == nil {
+		t.Error("stream b not canceled")
+	}
+	waitStreamDone(t, b)
+	if len(jclient.streams) != 0 {
+		t.Errorf("streams left: %d", len(jclient.streams))
+	}
+	if jclient.CancelC.Err() != nil {
+		t.Error("client query canceled by closeStreams")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 20:27:41 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 未指定时间段宽度时，时间段数量不超过autoTimelineBuckets
+	autoTimelineBuckets = 60
+	maxTimelineBuckets  = 1000
+)
+
+// 自动选择时间段宽度时的候选值
+var timelineIntervals = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// 按时间段统计since至until之间满足查询条件的日志条数
+func (jclient *JournaldClient) timeline(_ctx context.Context, _options *public.JournalctlOptions) (*public.Timeline, error) {
+	if _options.Since == "" || _options.Until == "" {
+		return nil, errors.New("since and until are required for timeline")
+	}
+	since, err := public.ParseJournalTime(_options.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := public.ParseJournalTime(_options.Until)
+	if err != nil {
+		return nil, err
+	}
+	if since >= until {
+		return nil, errors.Errorf("since %s is not before until %s", _options.Since, _options.Until)
+	}
+	switch _options.SplitBy {
+	case "", public.SplitByUnit, public.SplitByPriority:
+	default:
+		return nil, errors.Errorf("unsupported split_by: %s", _options.SplitBy)
+	}
+	if err := checkFieldMatches(_options.Matches); err != nil {
+		return nil, err
+	}
+	if err := checkBoot(_options.Boot); err != nil {
+		return nil, err
+	}
+	if _, err := newGrepMatcher(_options); err != nil {
+		return nil, err
+	}
+	if _, err := newQueryFilter(_options); err != nil {
+		return nil, err
+	}
+
+	counter, err := newTimelineCounter(since, until, _options.Interval, _options.SplitBy)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := *_options
+	scope.Notail = true
+	source := jclient.newPageSource(&scope)
+	defer source.close()
+	err = source.each(_ctx, func(_raw_entry map[string]interface{}) error {
+		counter.add(_raw_entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counter.timeline, nil
+}
+
+// 按时间段累计日志条数，时间均为微秒
+type timelineCounter struct {
+	timeline *public.Timeline
+
+	since, until, interval uint64
+	splitBy                string
+}
+
+// _interval: 时间段宽度（秒），不大于0时根据时间范围自动选择
+func newTimelineCounter(_since, _until uint64, _interval int, _split_by string) (*timelineCounter, error) {
+	span := _until - _since
+	interval := uint64(_interval) * uint64(time.Second/time.Microsecond)
+	if _interval <= 0 {
+		interval = uint64(timelineIntervals[len(timelineIntervals)-1] / time.Microsecond)
+		for _, d := range timelineIntervals {
+			if span/uint64(d/time.Microsecond) < autoTimelineBuckets {
+				interval = uint64(d / time.Microsecond)
+				break
+			}
+		}
+	}
+	count := int((span + interval - 1) / interval)
+	if count > maxTimelineBuckets {
+		return nil, errors.Errorf("too many timeline buckets: %d > %d", count, maxTimelineBuckets)
+	}
+
+	timeline := &public.Timeline{
+		Since:    int64(_since / 1000),
+		Until:    int64(_until / 1000),
+		Interval: int64(interval / 1000),
+		Buckets:  make([]public.TimelineBucket, count),
+	}
+	for i := range timeline.Buckets {
+		timeline.Buckets[i].Timestamp = int64((_since + uint64(i)*interval) / 1000)
+		if _split_by != "" {
+			timeline.Buckets[i].Groups = map[string]int{}
+		}
+	}
+	return &timelineCounter{
+		timeline: timeline,
+		since:    _since,
+		until:    _until,
+		interval: interval,
+		splitBy:  _split_by,
+	}, nil
+}
+
+// 时间范围之外的日志不计入，恰好为until的日志计入最后一个时间段
+func (c *timelineCounter) add(_raw_entry map[string]interface{}) {
+	realtime_str, _ := _raw_entry["__REALTIME_TIMESTAMP"].(string)
+	realtime, err := strconv.ParseUint(realtime_str, 10, 64)
+	if err != nil || realtime < c.since || realtime > c.until {
+		return
+	}
+	index := int((realtime - c.since) / c.interval)
+	if index >= len(c.timeline.Buckets) {
+		index = len(c.timeline.Buckets) - 1
+	}
+	bucket := &c.timeline.Buckets[index]
+	bucket.Count++
+	if c.splitBy != "" {
+		bucket.Groups[timelineGroup(_raw_entry, c.splitBy)]++
+	}
+}
+
+// 日志所属的unit或priority，没有unit的内核、审计等日志按_TRANSPORT分组
+func timelineGroup(_raw_entry map[string]interface{}, _split_by string) string {
+	field, fallback := "_SYSTEMD_UNIT", "_TRANSPORT"
+	if _split_by == public.SplitByPriority {
+		field, fallback = "PRIORITY", ""
+	}
+	if value, ok := _raw_entry[field].(string); ok && value != "" {
+		return value
+	}
+	if value, ok := _raw_entry[fallback].(string); ok && value != "" {
+		return value
+	}
+	return "-"
+}
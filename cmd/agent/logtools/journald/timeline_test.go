@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 12:37:15 2026 +0800
+ */
+package journald
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+const usecPerSecond = uint64(time.Second / time.Microsecond)
+
+func TestTimelineInterval(t *testing.T) {
+	since := uint64(1700000000) * usecPerSecond
+	tests := []struct {
+		name     string
+		span     time.Duration
+		interval int
+		// 秒
+		want  int64
+		count int
+	}{
+		{"seconds", 30 * time.Second, 0, 1, 30},
+		{"auto limit", 60 * time.Second, 0, 5, 12},
+		{"minutes", 2 * time.Hour, 0, 5 * 60, 24},
+		{"hours", 10 * 24 * time.Hour, 0, 6 * 3600, 40},
+		{"days", 30 * 24 * time.Hour, 0, 24 * 3600, 30},
+		// 超过最大候选值时按天划分
+		{"largest", 100 * 24 * time.Hour, 0, 24 * 3600, 100},
+		// 最后一个时间段可以不足interval
+		{"explicit", 20 * time.Second, 7, 7, 3},
+		{"sub second span", 500 * time.Millisecond, 0, 1, 1},
+	}
+	for _, tt := range tests {
+		c, err := newTimelineCounter(since, since+uint64(tt.span/time.Microsecond), tt.interval, "")
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if c.timeline.Interval != tt.want*1000 || len(c.timeline.Buckets) != tt.count {
+			t.Errorf("%s: got interval %dms with %d buckets, want %ds with %d", tt.name, c.timeline.Interval, len(c.timeline.Buckets), tt.want, tt.count)
+		}
+		for i, bucket := range c.timeline.Buckets {
+			if want := int64(since/1000) + int64(i)*tt.want*1000; bucket.Timestamp != want {
+				t.Errorf("%s: bucket %d timestamp %d, want %d", tt.name, i, bucket.Timestamp, want)
+			}
+		}
+	}
+
+	if _, err := newTimelineCounter(since, since+(maxTimelineBuckets+1)*usecPerSecond, 1, ""); err == nil {
+		t.Error("too many buckets: expected error")
+	}
+	if _, err := newTimelineCounter(since, since+maxTimelineBuckets*usecPerSecond, 1, ""); err != nil {
+		t.Errorf("%d buckets: %s", maxTimelineBuckets, err)
+	}
+}
+
+func timelineTestEntry(_realtime uint64, _fields ...string) map[string]interface{} {
+	raw_entry := map[string]interface{}{"__REALTIME_TIMESTAMP": strconv.FormatUint(_realtime, 10)}
+	for i := 0; i+1 < len(_fields); i += 2 {
+		raw_entry[_fields[i]] = _fields[i+1]
+	}
+	return raw_entry
+}
+
+func timelineCounts(_timeline *public.Timeline) []int {
+	counts := []int{}
+	for _, bucket := range _timeline.Buckets {
+		counts = append(counts, bucket.Count)
+	}
+	return counts
+}
+
+func TestTimelineCounterAdd(t *testing.T) {
+	since := uint64(1700000000) * usecPerSecond
+	until := since + 5*usecPerSecond
+	c, err := newTimelineCounter(since, until, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw_entry := range []map[string]interface{}{
+		timelineTestEntry(since),
+		timelineTestEntry(since + usecPerSecond - 1),
+		timelineTestEntry(since + usecPerSecond),
+		timelineTestEntry(since + 3*usecPerSecond + usecPerSecond/2),
+		// 恰好为until的日志计入最后一个时间段
+		timelineTestEntry(until),
+		// 时间范围之外或时间戳不合法的日志不计入
+		timelineTestEntry(since - 1),
+		timelineTestEntry(until + 1),
+		{"__REALTIME_TIMESTAMP": "x"},
+		{},
+	} {
+		c.add(raw_entry)
+	}
+	if got, want := timelineCounts(c.timeline), []int{2, 1, 0, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if c.timeline.Buckets[0].Groups != nil {
+		t.Errorf("groups without split_by: %v", c.timeline.Buckets[0].Groups)
+	}
+}
+
+// 没有unit的日志按_TRANSPORT分组，priority没有替代字段
+func TestTimelineGroups(t *testing.T) {
+	since := uint64(1700000000) * usecPerSecond
+	entries := []map[string]interface{}{
+		timelineTestEntry(since, "_SYSTEMD_UNIT", "sshd.service", "PRIORITY", "6", "_TRANSPORT", "syslog"),
+		timelineTestEntry(since, "_SYSTEMD_UNIT", "sshd.service", "PRIORITY", "3"),
+		timelineTestEntry(since, "_TRANSPORT", "kernel", "PRIORITY", "3"),
+		timelineTestEntry(since, "_SYSTEMD_UNIT", "", "_TRANSPORT", "audit"),
+		timelineTestEntry(since),
+	}
+	tests := []struct {
+		splitBy string
+		want    map[string]int
+	}{
+		{public.SplitByUnit, map[string]int{"sshd.service": 2, "kernel": 1, "audit": 1, "-": 1}},
+		{public.SplitByPriority, map[string]int{"6": 1, "3": 2, "-": 2}},
+	}
+	for _, tt := range tests {
+		c, err := newTimelineCounter(since, since+usecPerSecond, 0, tt.splitBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, raw_entry := range entries {
+			c.add(raw_entry)
+		}
+		bucket := c.timeline.Buckets[0]
+		if bucket.Count != len(entries) || !reflect.DeepEqual(bucket.Groups, tt.want) {
+			t.Errorf("%s: got %d %v, want %d %v", tt.splitBy, bucket.Count, bucket.Groups, len(entries), tt.want)
+		}
+	}
+}
+
+// 查询条件不合法时在读取日志前返回错误
+func TestTimelineOptions(t *testing.T) {
+	jclient := &JournaldClient{}
+	for _, options := range []*public.JournalctlOptions{
+		{Until: "2024-01-01 00:00:00"},
+		{Since: "2024-01-01 00:00:00"},
+		{Since: "2024-01-01 00:00:00", Until: "2024-01-01 00:00:00"},
+		{Since: "2024-01-02 00:00:00", Until: "2024-01-01 00:00:00"},
+		{Since: "x", Until: "2024-01-01 00:00:00"},
+		{Since: "2024-01-01 00:00:00", Until: "2024-01-01 01:00:00", SplitBy: "host"},
+		{Since: "2024-01-01 00:00:00", Until: "2024-01-01 01:00:00", Interval: 1, SplitBy: public.SplitByUnit},
+	} {
+		if _, err := jclient.timeline(context.Background(), options); err == nil {
+			t.Errorf("%+v: expected error", options)
+		}
+	}
+}
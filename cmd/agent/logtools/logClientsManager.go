@@ -9,13 +9,12 @@ package logtools
 
 import (
 	"fmt"
-	"os/exec"
 	"sync"
 	"time"
 
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/filetail"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald"
-	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
 	"github.com/pkg/errors"
 )
 
@@ -23,30 +22,28 @@ var LogCollector *LogClientManagement
 
 const (
 	JournaldLogClientType int = iota
+	FileLogClientType
 )
 
 type LogClientManagement struct {
 	// key: web client id
 	journaldClients map[string]*journald.JournaldClient
+	fileClients     map[string]*filetail.FileClient
 
 	// 终止采集组件状态检测
 	heartbeatDone chan struct{}
 
-	// 终止周期性采集日志时间轴数据
-	timelineDone chan struct{}
-
 	once sync.Once
 }
 
 func CreateLogClientsManager() {
 	LogCollector = &LogClientManagement{
 		journaldClients: make(map[string]*journald.JournaldClient),
+		fileClients:     make(map[string]*filetail.FileClient),
 		heartbeatDone:   make(chan struct{}),
-		timelineDone:    make(chan struct{}),
 	}
 
 	go LogCollector.heartbeatDetect()
-	// go LogCollector.logTimeline()
 }
 
 func (lcm *LogClientManagement) Add(_type int, _id string, _c interface{}) error {
@@ -57,6 +54,12 @@ func (lcm *LogClientManagement) Add(_type int, _id string, _c interface{}) error
 			return fmt.Errorf("fail to add log collect client: %+v", _c)
 		}
 		lcm.journaldClients[_id] = jc
+	case FileLogClientType:
+		fc, ok := _c.(*filetail.FileClient)
+		if !ok {
+			return fmt.Errorf("fail to add log collect client: %+v", _c)
+		}
+		lcm.fileClients[_id] = fc
 	}
 	return nil
 }
@@ -68,6 +71,11 @@ func (lcm *LogClientManagement) Get(_type int, _id string) (interface{}, bool) {
 		if ok {
 			return jc, ok
 		}
+	case FileLogClientType:
+		fc, ok := lcm.fileClients[_id]
+		if ok {
+			return fc, ok
+		}
 	}
 	return nil, false
 }
@@ -76,6 +84,8 @@ func (lcm *LogClientManagement) Delete(_type int, _id string) {
 	switch _type {
 	case JournaldLogClientType:
 		delete(lcm.journaldClients, _id)
+	case FileLogClientType:
+		delete(lcm.fileClients, _id)
 	}
 }
 
@@ -83,6 +93,8 @@ func (lcm *LogClientManagement) ReturnLogClients(_type int) interface{} {
 	switch _type {
 	case JournaldLogClientType:
 		return lcm.journaldClients
+	case FileLogClientType:
+		return lcm.fileClients
 	}
 	return nil
 }
@@ -99,43 +111,14 @@ func (lcm *LogClientManagement) heartbeatDetect() {
 					lcm.Delete(JournaldLogClientType, _id)
 				}
 			}
-		}
-	}
-}
-
-func (lcm *LogClientManagement) LogTimeline() error {
-	for {
-		select {
-		case <-lcm.timelineDone:
-			return fmt.Errorf("timeline done channel closed")
-		case t := <-time.After(60 * time.Second):
-			if len(lcm.journaldClients) == 0 {
-				return fmt.Errorf("no active journald clients")
-			}
-
-			tmpjclient := journald.CreateJournaldClient(nil, global.ReadCmdStderrTimeout)
-			cmd := exec.Command("systemctl", journald.UnitListDefaultOptions...)
-			tmpjclient.ProcessData(cmd, public.UnitData)
-			go tmpjclient.WriteMessageToClient()
-
-			entry_count := make(map[string]string)
-			for _, _unit := range tmpjclient.UnitsMap["systemd"] {
-				since := t.Local().Format("2024-01-02 15:04:05")
-				until := t.Add(-60 * time.Second).Local().Format("2024-01-02 15:04:05")
-				cmd = exec.Command("/bin/bash", "-c", "journalctl", "--quiet", "--no-pager", "--unit", _unit+".service", "--since", since, "--until", until, "|", "wc", "-l")
-				outputBytes, err := cmd.CombinedOutput()
-				if err != nil {
-					return fmt.Errorf("log timeline cmd error: %s, output: %v", err.Error(), string(outputBytes))
+			for _id, _fc := range lcm.fileClients {
+				if !_fc.Active {
+					global.ERManager.ErrorTransmit("logtools", "info", errors.Errorf("remove file web client: %s", _id), false, false)
+					lcm.Delete(FileLogClientType, _id)
 				}
-				entry_count[_unit] = string(outputBytes)
 			}
-
-			fmt.Printf("\033[32m>>>\033[0m entry count: %+v\n", entry_count)
-
-			tmpjclient.Close(false, true, false)
 		}
 	}
-
 }
 
 func (lcm *LogClientManagement) CloseAll() {
@@ -143,10 +126,14 @@ func (lcm *LogClientManagement) CloseAll() {
 		close(lcm.heartbeatDone)
 	})
 
-	// lcm.timelineDoneCh <- struct{}{}
 	for id, jc := range lcm.journaldClients {
 		global.ERManager.ErrorTransmit("logtools", "info", errors.Errorf("shutdown journald client: %s", id), false, false)
 		jc.CloseReadMsgCh <- struct{}{}
 		jc.Close(true, true, false)
 	}
+	for id, fc := range lcm.fileClients {
+		global.ERManager.ErrorTransmit("logtools", "info", errors.Errorf("shutdown file client: %s", id), false, false)
+		fc.CloseReadMsgCh <- struct{}{}
+		fc.Close(true)
+	}
 }
@@ -8,12 +8,15 @@
 package main
 
 import (
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/archive"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logger"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/resourcemanage"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/signal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/syslog"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/webserver"
 	sdklogger "gitee.com/openeuler/PilotGo/sdk/logger"
 )
@@ -48,6 +51,25 @@ func main() {
 	 */
 	global.InitOSName()
 
+	/*
+		journal日志上传至logs server归档
+	*/
+	if err := archive.StartShipper(); err != nil {
+		sdklogger.Fatal(err.Error())
+	}
+
+	/*
+		journal日志转发至syslog服务器
+	*/
+	if err := syslog.Start(); err != nil {
+		sdklogger.Fatal(err.Error())
+	}
+
+	/*
+		统计本机journal日志条数
+	*/
+	metrics.StartJournalCounter()
+
 	/*
 		init web server
 	*/
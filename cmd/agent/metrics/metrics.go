@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 10:03:26 2026 +0800
+ */
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	// journalctl退出后重新统计的间隔
+	restartInterval = 10 * time.Second
+	// 单条日志的最大长度
+	maxEntryBytes = 16 * 1024 * 1024
+	// JournalEntries中unit标签的最大取值数，超过后新的服务单元计入UnitOther
+	maxUnitLabels = 256
+)
+
+var (
+	JournalEntries = public.NewCounterVec("logs_agent_journal_entries_total",
+		"Journal entries written on this host since the agent started, by systemd unit and priority. Transient units are collapsed and units beyond the limit are counted as \"other\".", "unit", "priority")
+
+	JournaldSessions = public.NewGaugeVec("logs_agent_journald_sessions",
+		"Active journald websocket sessions.")
+
+	JournalctlSpawns = public.NewCounterVec("logs_agent_journalctl_spawns_total",
+		"journalctl processes started.")
+	JournalctlFailures = public.NewCounterVec("logs_agent_journalctl_failures_total",
+		"journalctl processes that failed to start or exited with an error.", "reason")
+
+	WebsocketSentBytes = public.NewCounterVec("logs_agent_websocket_sent_bytes_total",
+		"Bytes of messages sent to websocket clients, by log source.", "source")
+
+	SyslogForwarded = public.NewCounterVec("logs_agent_syslog_forwarded_total",
+		"Journal entries forwarded to syslog servers, by target.", "target")
+	SyslogFailures = public.NewCounterVec("logs_agent_syslog_failures_total",
+		"Failures to connect or send to syslog servers, by target.", "target")
+)
+
+// journalctl失败原因
+const (
+	FailureStart = "start"
+	FailureExit  = "exit"
+)
+
+// JournalEntries的unit标签：超过maxUnitLabels后新出现的服务单元
+const UnitOther = "other"
+
+// websocket连接的日志来源
+const (
+	SourceJournald = "journald"
+	SourceFile     = "file"
+)
+
+/*
+合并临时创建的服务单元，避免unit标签的取值无限增长：
+
+登录会话session-<id>.scope、systemd-run创建的run-<id>.service等名称中的id替换为*，
+模板实例<name>@<instance>.<type>替换为<name>@*.<type>，容器运行时为每个容器创建的<runtime>-<id>.scope替换为<runtime>-*.scope
+*/
+var transientUnits = []struct {
+	prefix string
+	suffix string
+}{
+	{"session-", ".scope"},
+	{"run-", ""},
+	{"docker-", ".scope"},
+	{"libpod-", ".scope"},
+	{"crio-", ".scope"},
+	{"cri-containerd-", ".scope"},
+}
+
+// 已计数的unit标签，只在统计journal日志的goroutine中访问
+var unitLabels = map[string]bool{}
+
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// 记录journalctl进程的启动
+func JournalctlStarted() {
+	JournalctlSpawns.Inc()
+}
+
+/*
+记录journalctl进程的失败，_err为nil时不记录
+
+_start_failed: 进程未能启动；否则为进程退出时的错误
+*/
+func JournalctlFailed(_err error, _start_failed bool) {
+	if _err == nil {
+		return
+	}
+	if _start_failed {
+		JournalctlFailures.Inc(FailureStart)
+		return
+	}
+	JournalctlFailures.Inc(FailureExit)
+}
+
+// 开启指标时统计本机journal日志条数，未开启时不统计
+func StartJournalCounter() {
+	if !Enabled() || !conf.Global_Config.Metrics.JournalEntries {
+		return
+	}
+	global.ERManager.Wg.Add(1)
+	go func() {
+		defer global.ERManager.Wg.Done()
+		for {
+			if err := countJournal(global.ERManager.GoCancelCtx); err != nil {
+				global.ERManager.ErrorTransmit("metrics", "error", errors.Wrap(err, "journal entry counter interrupted"), false, false)
+			}
+			select {
+			case <-global.ERManager.GoCancelCtx.Done():
+				return
+			case <-time.After(restartInterval):
+			}
+		}
+	}()
+}
+
+func Enabled() bool {
+	return conf.Global_Config.Metrics != nil && conf.Global_Config.Metrics.Enabled
+}
+
+// 实时读取新写入的journal日志，按服务单元及优先级计数
+func countJournal(_ctx context.Context) error {
+	cmd := exec.CommandContext(_ctx, "journalctl", "--quiet", "--follow", "--lines=0", "--output=json",
+		"--output-fields=_SYSTEMD_UNIT,PRIORITY")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Errorf("fail to get journalctl stdout: %s", err.Error())
+	}
+	JournalctlStarted()
+	if err := cmd.Start(); err != nil {
+		JournalctlFailed(err, true)
+		return errors.Errorf("fail to start journalctl: %s", err.Error())
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+	for scanner.Scan() {
+		fields := struct {
+			Unit     string `json:"_SYSTEMD_UNIT"`
+			Priority string `json:"PRIORITY"`
+		}{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			continue
+		}
+		JournalEntries.Inc(unitLabel(fields.Unit), priorityName(fields.Priority))
+	}
+	err = cmd.Wait()
+	if _ctx.Err() != nil {
+		return nil
+	}
+	JournalctlFailed(err, false)
+	if err != nil {
+		return errors.Errorf("journalctl exited: %s", err.Error())
+	}
+	return errors.New("journalctl exited")
+}
+
+func priorityName(_priority string) string {
+	if p, err := strconv.Atoi(_priority); err == nil && p >= 0 && p < len(priorityNames) {
+		return priorityNames[p]
+	}
+	return _priority
+}
+
+// 返回日志所属服务单元对应的unit标签
+func unitLabel(_unit string) string {
+	label := collapseUnit(_unit)
+	if unitLabels[label] {
+		return label
+	}
+	if len(unitLabels) >= maxUnitLabels {
+		return UnitOther
+	}
+	unitLabels[label] = true
+	return label
+}
+
+func collapseUnit(_unit string) string {
+	dot := strings.LastIndexByte(_unit, '.')
+	if dot <= 0 {
+		return _unit
+	}
+	name, suffix := _unit[:dot], _unit[dot:]
+	if at := strings.IndexByte(name, '@'); at >= 0 {
+		return name[:at+1] + "*" + suffix
+	}
+	for _, t := range transientUnits {
+		if strings.HasPrefix(name, t.prefix) && len(name) > len(t.prefix) && (t.suffix == "" || t.suffix == suffix) {
+			return t.prefix + "*" + suffix
+		}
+	}
+	return _unit
+}
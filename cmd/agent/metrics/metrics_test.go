@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 17:21:14 2026 +0800
+ */
+package metrics
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCollapseUnit(t *testing.T) {
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{"sshd.service", "sshd.service"},
+		{"", ""},
+		{"session-12.scope", "session-*.scope"},
+		{"session-c3.scope", "session-*.scope"},
+		{"run-r1b2c3.service", "run-*.service"},
+		{"run-u27.scope", "run-*.scope"},
+		{"user@1000.service", "user@*.service"},
+		{"sshd@3-10.0.0.1:22-10.0.0.2:51234.service", "sshd@*.service"},
+		{"docker-0123456789abcdef.scope", "docker-*.scope"},
+		// 只合并容器的scope，同名前缀的服务不变
+		{"docker-registry.service", "docker-registry.service"},
+		{"session-.scope", "session-.scope"},
+		{"init.scope", "init.scope"},
+	}
+	for _, tt := range tests {
+		if got := collapseUnit(tt.unit); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestUnitLabelLimit(t *testing.T) {
+	unitLabels = map[string]bool{}
+	defer func() { unitLabels = map[string]bool{} }()
+
+	for i := 0; i < maxUnitLabels; i++ {
+		unit := "u" + strconv.Itoa(i) + ".service"
+		if got := unitLabel(unit); got != unit {
+			t.Fatalf("%s: got %q", unit, got)
+		}
+	}
+	if got := unitLabel("new.service"); got != UnitOther {
+		t.Errorf("unit beyond the limit: got %q, want %q", got, UnitOther)
+	}
+	// 已有的标签不受限制影响
+	if got := unitLabel("u0.service"); got != "u0.service" {
+		t.Errorf("existing unit: got %q", got)
+	}
+	if len(unitLabels) != maxUnitLabels {
+		t.Errorf("got %d unit labels, want %d", len(unitLabels), maxUnitLabels)
+	}
+}
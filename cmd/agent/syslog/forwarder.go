@@ -0,0 +1,396 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 18:15:08 2026 +0800
+ */
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald/sdjournal"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls"
+)
+
+const (
+	// 连接或发送失败后重新连接的间隔
+	retryInterval = 10 * time.Second
+	dialTimeout   = 10 * time.Second
+	writeTimeout  = 10 * time.Second
+	// 单次写入连接的最大消息数，每批写入后保存游标
+	maxBatchMessages = 256
+	// udp单个报文的最大长度，超出时截断
+	maxUDPMessageBytes = 8192
+)
+
+// 转发目标名称用作游标文件名
+var targetNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+/*
+将满足条件的journal日志持续转发至一个syslog服务器
+
+日志按批写入连接，每批写入后立即将最后一条日志的游标写入state_dir，重连或重启后从游标之后继续；
+没有游标时从启动后新写入的日志开始。
+
+投递语义为至少一次：写入连接后、保存游标前进程退出或保存失败时，重启后再次发送这一批日志。
+tcp、tls只保证日志已写入连接，连接断开时已写入但未被服务器接收的日志会丢失；udp不保证送达
+*/
+type forwarder struct {
+	name       string
+	protocol   string
+	addr       string
+	facility   int
+	hostname   string
+	options    *public.JournalctlOptions
+	tlsConfig  *tls.Config
+	cursorFile string
+
+	conn net.Conn
+
+	// 已发送的最后一条日志及已写入文件的游标
+	cursor string
+	saved  string
+}
+
+// 待发送的一条syslog消息及对应日志的游标
+type message struct {
+	data   []byte
+	cursor string
+}
+
+// 未开启转发时不转发；配置不合法时返回错误
+func Start() error {
+	syslogconf := conf.Global_Config.Syslog
+	if syslogconf == nil || !syslogconf.Enabled {
+		return nil
+	}
+	if syslogconf.StateDir == "" {
+		return errors.New("syslog state_dir is required when syslog forwarding is enabled")
+	}
+	if len(syslogconf.Targets) == 0 {
+		return errors.New("no syslog targets configured")
+	}
+	if err := os.MkdirAll(syslogconf.StateDir, 0700); err != nil {
+		return errors.Errorf("fail to create syslog state directory: %s", err.Error())
+	}
+
+	forwarders := []*forwarder{}
+	names := map[string]bool{}
+	for _, target := range syslogconf.Targets {
+		f, err := newForwarder(syslogconf.StateDir, target)
+		if err != nil {
+			return err
+		}
+		if names[f.name] {
+			return errors.Errorf("duplicate syslog target name: %s", f.name)
+		}
+		names[f.name] = true
+		forwarders = append(forwarders, f)
+	}
+	for _, f := range forwarders {
+		global.ERManager.Wg.Add(1)
+		go f.run()
+		global.ERManager.ErrorTransmit("syslog", "info", errors.Errorf("syslog forwarder %s started: %s://%s", f.name, f.protocol, f.addr), false, false)
+	}
+	return nil
+}
+
+func newForwarder(_state_dir string, _target *conf.SyslogTarget) (*forwarder, error) {
+	if !targetNameRegexp.MatchString(_target.Name) {
+		return nil, errors.Errorf("invalid syslog target name %q: only letters, digits, '_', '.' and '-' are allowed", _target.Name)
+	}
+	f := &forwarder{
+		name:       _target.Name,
+		protocol:   strings.ToLower(_target.Protocol),
+		addr:       _target.Addr,
+		options:    filterOptions(_target.Filter),
+		cursorFile: filepath.Join(_state_dir, _target.Name+".cursor"),
+	}
+	switch f.protocol {
+	case ProtocolUDP, ProtocolTCP, ProtocolTLS:
+	default:
+		return nil, errors.Errorf("syslog target %s: unsupported protocol %q, expected udp, tcp or tls", f.name, _target.Protocol)
+	}
+	host, _, err := net.SplitHostPort(f.addr)
+	if err != nil {
+		return nil, errors.Errorf("syslog target %s: invalid addr %q: %s", f.name, f.addr, err.Error())
+	}
+	if f.facility, err = parseFacility(_target.Facility); err != nil {
+		return nil, errors.Wrapf(err, "syslog target %s", f.name)
+	}
+	if err := journald.CheckFollowOptions(f.options); err != nil {
+		return nil, errors.Wrapf(err, "syslog target %s: invalid filter", f.name)
+	}
+	if f.protocol == ProtocolTLS {
+		if f.tlsConfig, err = tlsConfig(_target, host); err != nil {
+			return nil, errors.Wrapf(err, "syslog target %s", f.name)
+		}
+	}
+	// 日志中没有_HOSTNAME时使用本机主机名
+	f.hostname, _ = os.Hostname()
+
+	cursor, err := os.ReadFile(f.cursorFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Errorf("fail to read %s: %s", f.cursorFile, err.Error())
+	}
+	f.cursor = strings.TrimSpace(string(cursor))
+	// 游标文件损坏时不从头或从最新日志转发，避免重复或遗漏，由管理员确认后删除该文件
+	if _, err := sdjournal.ParseCursor(f.cursor); f.cursor != "" && err != nil {
+		return nil, errors.Errorf("syslog target %s: invalid cursor in %s, remove the file to forward new entries only: %s", f.name, f.cursorFile, err.Error())
+	}
+	f.saved = f.cursor
+	return f, nil
+}
+
+func filterOptions(_filter *conf.SyslogFilter) *public.JournalctlOptions {
+	if _filter == nil {
+		return &public.JournalctlOptions{}
+	}
+	return &public.JournalctlOptions{
+		Unit:           _filter.Unit,
+		Identifier:     _filter.Identifier,
+		Severity:       _filter.Priority,
+		Transport:      _filter.Transport,
+		User:           _filter.User,
+		Grep:           _filter.Grep,
+		GrepRegex:      _filter.GrepRegex,
+		GrepIgnoreCase: _filter.GrepIgnoreCase,
+		Matches:        _filter.Matches,
+		Query:          _filter.Query,
+	}
+}
+
+func tlsConfig(_target *conf.SyslogTarget, _server_name string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: _server_name, MinVersion: tls.VersionTLS12}
+	if _target.CAFile != "" {
+		ca, err := global.FileReadBytes(_target.CAFile)
+		if err != nil {
+			return nil, errors.Errorf("fail to read ca file %s: %s", _target.CAFile, err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no valid certificate in ca file %s", _target.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if _target.CertFile != "" || _target.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(_target.CertFile, _target.KeyFile)
+		if err != nil {
+			return nil, errors.Errorf("fail to load client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (f *forwarder) run() {
+	defer global.ERManager.Wg.Done()
+
+	for {
+		err := f.forward(global.ERManager.GoCancelCtx)
+		f.disconnect()
+		if err != nil {
+			metrics.SyslogFailures.Inc(f.name)
+			global.ERManager.ErrorTransmit("syslog", "error", errors.Wrapf(err, "syslog forwarding to %s interrupted", f.name), false, false)
+		}
+		select {
+		case <-global.ERManager.GoCancelCtx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+/*
+连接syslog服务器并从游标之后持续转发，连接、发送失败或游标无法保存时返回
+
+读取journal与写入连接在不同的goroutine中进行，写入期间读取的日志在下一批中一起写入
+*/
+func (f *forwarder) forward(_ctx context.Context) error {
+	if err := f.connect(_ctx); err != nil {
+		return err
+	}
+
+	if f.cursor == "" {
+		// 首次转发从当前最后一条日志之后开始，立即保存游标，重启后不遗漏此后的日志
+		tail, err := journald.TailCursor(_ctx)
+		if err != nil {
+			return err
+		}
+		f.cursor = tail
+		if err := f.saveCursor(); err != nil {
+			return err
+		}
+	}
+	cursor := f.cursor
+
+	ctx, cancel := context.WithCancel(_ctx)
+	queue := make(chan *message, maxBatchMessages)
+	write_err := make(chan error, 1)
+	go func() {
+		write_err <- f.writeBatches(ctx, queue)
+	}()
+
+	err := journald.FollowEntries(ctx, f.options, cursor, func(_raw_entry map[string]interface{}) error {
+		msg := &message{data: formatMessage(_raw_entry, f.facility, f.hostname)}
+		msg.cursor, _ = _raw_entry["__CURSOR"].(string)
+		select {
+		case queue <- msg:
+			return nil
+		case err := <-write_err:
+			// writeBatches已退出，保留错误供forward返回
+			write_err <- err
+			if err == nil {
+				err = errors.New("syslog writer exited")
+			}
+			return err
+		}
+	})
+	cancel()
+	if werr := <-write_err; werr != nil {
+		return werr
+	}
+	if _ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+/*
+依次将队列中的消息按批写入连接，每批写入后保存游标
+
+_ctx结束时返回nil，队列中未写入的日志在重新转发时从游标之后再次读取
+*/
+func (f *forwarder) writeBatches(_ctx context.Context, _queue chan *message) error {
+	for {
+		batch := make([]*message, 0, maxBatchMessages)
+		select {
+		case <-_ctx.Done():
+			return nil
+		case msg := <-_queue:
+			batch = append(batch, msg)
+		}
+	collect:
+		for len(batch) < maxBatchMessages {
+			select {
+			case msg := <-_queue:
+				batch = append(batch, msg)
+			default:
+				break collect
+			}
+		}
+
+		if err := f.write(batch); err != nil {
+			return err
+		}
+		for _, msg := range batch {
+			if msg.cursor != "" {
+				f.cursor = msg.cursor
+			}
+		}
+		if err := f.saveCursor(); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *forwarder) connect(_ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	switch f.protocol {
+	case ProtocolTLS:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: f.tlsConfig}).DialContext(_ctx, "tcp", f.addr)
+	default:
+		conn, err = dialer.DialContext(_ctx, f.protocol, f.addr)
+	}
+	if err != nil {
+		return errors.Errorf("fail to connect to %s://%s: %s", f.protocol, f.addr, err.Error())
+	}
+	f.conn = conn
+	if f.protocol != ProtocolUDP {
+		// 服务器不发送数据，读取失败表示连接已断开，关闭连接使后续发送失败
+		go func() {
+			io.Copy(io.Discard, conn)
+			conn.Close()
+		}()
+	}
+	return nil
+}
+
+func (f *forwarder) disconnect() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
+
+// udp每个报文一条消息；tcp、tls按RFC 6587以"长度 消息"分帧，一批消息一次写入
+func (f *forwarder) write(_batch []*message) error {
+	f.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if f.protocol == ProtocolUDP {
+		for _, msg := range _batch {
+			data := msg.data
+			if len(data) > maxUDPMessageBytes {
+				data = data[:maxUDPMessageBytes]
+			}
+			if _, err := f.conn.Write(data); err != nil {
+				return errors.Errorf("fail to send to %s://%s: %s", f.protocol, f.addr, err.Error())
+			}
+			metrics.SyslogForwarded.Inc(f.name)
+		}
+		return nil
+	}
+
+	buf := []byte{}
+	for _, msg := range _batch {
+		buf = strconv.AppendInt(buf, int64(len(msg.data)), 10)
+		buf = append(buf, ' ')
+		buf = append(buf, msg.data...)
+	}
+	if _, err := f.conn.Write(buf); err != nil {
+		return errors.Errorf("fail to send to %s://%s: %s", f.protocol, f.addr, err.Error())
+	}
+	for range _batch {
+		metrics.SyslogForwarded.Inc(f.name)
+	}
+	return nil
+}
+
+// 游标有变化时写入文件
+func (f *forwarder) saveCursor() error {
+	if f.cursor == f.saved {
+		return nil
+	}
+	if err := os.WriteFile(f.cursorFile+".tmp", []byte(f.cursor+"\n"), 0600); err != nil {
+		return errors.Errorf("fail to write %s: %s", f.cursorFile, err.Error())
+	}
+	if err := os.Rename(f.cursorFile+".tmp", f.cursorFile); err != nil {
+		return errors.Errorf("fail to write %s: %s", f.cursorFile, err.Error())
+	}
+	f.saved = f.cursor
+	return nil
+}
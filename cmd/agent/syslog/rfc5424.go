@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 17:46:52 2026 +0800
+ */
+package syslog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RFC 5424中HEADER各字段的最大长度
+const (
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+	maxProcIDLen   = 128
+	maxMsgIDLen    = 32
+)
+
+const (
+	// 日志中没有SYSLOG_FACILITY、PRIORITY时使用user、info
+	defaultFacility = 1
+	defaultSeverity = 6
+
+	timestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var facilityNames = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// facility名称或0-23，为空时返回-1，表示使用日志的SYSLOG_FACILITY
+func parseFacility(_facility string) (int, error) {
+	if _facility == "" {
+		return -1, nil
+	}
+	if facility, ok := facilityNames[strings.ToLower(_facility)]; ok {
+		return facility, nil
+	}
+	if facility, err := strconv.Atoi(_facility); err == nil && facility >= 0 && facility <= 23 {
+		return facility, nil
+	}
+	return 0, errors.Errorf("invalid syslog facility: %s", _facility)
+}
+
+/*
+将journalctl --output=json的一条日志转换为RFC 5424格式的syslog消息，不含传输层的分帧
+
+HOSTNAME、APP-NAME、PROCID、MSGID分别取自_HOSTNAME、SYSLOG_IDENTIFIER（_COMM）、SYSLOG_PID（_PID）、MESSAGE_ID，不含STRUCTURED-DATA
+
+_facility小于0时使用日志的SYSLOG_FACILITY
+*/
+func formatMessage(_raw_entry map[string]interface{}, _facility int, _hostname string) []byte {
+	facility := _facility
+	if facility < 0 {
+		facility = intField(_raw_entry, "SYSLOG_FACILITY", defaultFacility, 23)
+	}
+	severity := intField(_raw_entry, "PRIORITY", defaultSeverity, 7)
+
+	timestamp := "-"
+	if usec, err := strconv.ParseInt(stringField(_raw_entry, "__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		timestamp = time.UnixMicro(usec).UTC().Format(timestampLayout)
+	}
+	hostname := stringField(_raw_entry, "_HOSTNAME")
+	if hostname == "" {
+		hostname = _hostname
+	}
+	app_name := stringField(_raw_entry, "SYSLOG_IDENTIFIER")
+	if app_name == "" {
+		app_name = stringField(_raw_entry, "_COMM")
+	}
+	proc_id := stringField(_raw_entry, "SYSLOG_PID")
+	if proc_id == "" {
+		proc_id = stringField(_raw_entry, "_PID")
+	}
+
+	msg := &bytes.Buffer{}
+	msg.WriteString("<" + strconv.Itoa(facility*8+severity) + ">1 ")
+	msg.WriteString(timestamp + " ")
+	msg.WriteString(headerField(hostname, maxHostnameLen) + " ")
+	msg.WriteString(headerField(app_name, maxAppNameLen) + " ")
+	msg.WriteString(headerField(proc_id, maxProcIDLen) + " ")
+	msg.WriteString(headerField(stringField(_raw_entry, "MESSAGE_ID"), maxMsgIDLen) + " ")
+	// STRUCTURED-DATA
+	msg.WriteString("-")
+	if message := stringField(_raw_entry, "MESSAGE"); message != "" {
+		msg.WriteString(" " + message)
+	}
+	return msg.Bytes()
+}
+
+// HEADER字段只能包含可打印的ASCII字符（不含空格），为空时为"-"
+func headerField(_value string, _max_len int) string {
+	if _value == "" {
+		return "-"
+	}
+	field := []byte(_value)
+	if len(field) > _max_len {
+		field = field[:_max_len]
+	}
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	return string(field)
+}
+
+// 二进制字段在journalctl --output=json中为字节数组
+func stringField(_raw_entry map[string]interface{}, _field string) string {
+	switch value := _raw_entry[_field].(type) {
+	case string:
+		return value
+	case []interface{}:
+		data := make([]byte, 0, len(value))
+		for _, b := range value {
+			n, ok := b.(float64)
+			if !ok {
+				return ""
+			}
+			data = append(data, byte(n))
+		}
+		return string(data)
+	}
+	return ""
+}
+
+func intField(_raw_entry map[string]interface{}, _field string, _default, _max int) int {
+	if n, err := strconv.Atoi(stringField(_raw_entry, _field)); err == nil && n >= 0 && n <= _max {
+		return n
+	}
+	return _default
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 09:52:16 2026 +0800
+ */
+package syslog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw_entry map[string]interface{}
+		facility  int
+		want      string
+	}{
+		{
+			"all fields",
+			map[string]interface{}{
+				"__REALTIME_TIMESTAMP": "1792800000123456",
+				"_HOSTNAME":            "node1",
+				"SYSLOG_IDENTIFIER":    "sshd",
+				"SYSLOG_PID":           "1234",
+				"MESSAGE_ID":           "ID47",
+				"SYSLOG_FACILITY":      "4",
+				"PRIORITY":             "3",
+				"MESSAGE":              "Accepted publickey",
+			},
+			-1,
+			"<35>1 2026-10-24T00:00:00.123456Z node1 sshd 1234 ID47 - Accepted publickey",
+		},
+		{
+			// 配置的facility优先于日志的SYSLOG_FACILITY
+			"configured facility",
+			map[string]interface{}{"SYSLOG_FACILITY": "4", "PRIORITY": "0", "MESSAGE": "m"},
+			23,
+			"<184>1 - fallback - - - - m",
+		},
+		{
+			// 缺少或不合法的SYSLOG_FACILITY、PRIORITY使用user、info
+			"default pri",
+			map[string]interface{}{"SYSLOG_FACILITY": "24", "PRIORITY": "x", "MESSAGE": "m"},
+			-1,
+			"<14>1 - fallback - - - - m",
+		},
+		{
+			// SYSLOG_IDENTIFIER、SYSLOG_PID为空时使用_COMM、_PID，没有MESSAGE时省略MSG
+			"fallback fields",
+			map[string]interface{}{"_COMM": "cron", "_PID": "42", "PRIORITY": "6"},
+			-1,
+			"<14>1 - fallback cron 42 - -",
+		},
+		{
+			// HEADER字段中的空格及非ASCII字符替换为"_"，超长时截断
+			"header escaping",
+			map[string]interface{}{
+				"_HOSTNAME":         "host name",
+				"SYSLOG_IDENTIFIER": "app\tnamé",
+				"MESSAGE_ID":        strings.Repeat("m", 40),
+				"MESSAGE":           "message with spaces\tand tabs",
+			},
+			-1,
+			"<14>1 - host_name app_nam__ - " + strings.Repeat("m", 32) + " - message with spaces\tand tabs",
+		},
+		{
+			// 二进制字段为字节数组
+			"binary message",
+			map[string]interface{}{"MESSAGE": []interface{}{float64('o'), float64('k')}},
+			-1,
+			"<14>1 - fallback - - - - ok",
+		},
+	}
+	for _, tt := range tests {
+		if got := string(formatMessage(tt.raw_entry, tt.facility, "fallback")); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderField(t *testing.T) {
+	tests := []struct {
+		value   string
+		max_len int
+		want    string
+	}{
+		{"", 48, "-"},
+		{"sshd", 48, "sshd"},
+		{"a b\nc\x7f", 48, "a_b_c_"},
+		{"abcdef", 4, "abcd"},
+		// 按字节截断，不完整的多字节字符同样替换
+		{"aé", 2, "a_"},
+	}
+	for _, tt := range tests {
+		if got := headerField(tt.value, tt.max_len); got != tt.want {
+			t.Errorf("headerField(%q, %d): got %q, want %q", tt.value, tt.max_len, got, tt.want)
+		}
+	}
+}
+
+func TestParseFacility(t *testing.T) {
+	tests := []struct {
+		facility string
+		want     int
+		ok       bool
+	}{
+		{"", -1, true},
+		{"local0", 16, true},
+		{"AUTHPRIV", 10, true},
+		{"23", 23, true},
+		{"24", 0, false},
+		{"-1", 0, false},
+		{"unknown", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseFacility(tt.facility)
+		if (err == nil) != tt.ok || (err == nil && got != tt.want) {
+			t.Errorf("parseFacility(%q): got %d, %v", tt.facility, got, err)
+		}
+	}
+}
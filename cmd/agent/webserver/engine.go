@@ -12,21 +12,42 @@ import (
 
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
 	"github.com/pkg/errors"
 )
 
 func InitWebserver() {
 	http.HandleFunc("/ws/entry", entryHandle)
+	if metrics.Enabled() {
+		http.Handle("/metrics", public.MetricsHandler(conf.Global_Config.Metrics.Token))
+	}
+
+	server := &http.Server{Addr: conf.Global_Config.Logs.Addr}
+	if conf.Global_Config.Logs.CAFile != "" {
+		if !conf.Global_Config.Logs.Https_enabled {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.New("ca_file requires https_enabled"), true, false)
+			return
+		}
+		tlsconf, err := clientAuthTLSConfig(conf.Global_Config.Logs.CAFile)
+		if err != nil {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, " "), true, false)
+			return
+		}
+		server.TLSConfig = tlsconf
+	} else {
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.New("ca_file is empty, connections to /ws/entry are not authenticated"), false, false)
+	}
 
 	go func() {
 		global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("WebSocket server started on %s", conf.Global_Config.Logs.Addr), false, false)
 		if conf.Global_Config.Logs.Https_enabled {
-			if err := http.ListenAndServeTLS(conf.Global_Config.Logs.Addr, conf.Global_Config.Logs.CertFile, conf.Global_Config.Logs.KeyFile, nil); err != nil {
+			if err := server.ListenAndServeTLS(conf.Global_Config.Logs.CertFile, conf.Global_Config.Logs.KeyFile); err != nil {
 				global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("Error starting server: %s", err), true, false)
 				return
 			}
 		} else {
-			if err := http.ListenAndServe(conf.Global_Config.Logs.Addr, nil); err != nil {
+			if err := server.ListenAndServe(); err != nil {
 				global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("Error starting server: %s", err), true, false)
 				return
 			}
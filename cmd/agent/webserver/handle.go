@@ -11,10 +11,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/filetail"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/logtools/journald"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/metrics"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 )
@@ -41,10 +45,25 @@ func entryHandle(_w http.ResponseWriter, _r *http.Request) {
 	}
 	defer conn.Close()
 
+	// 未通过认证的连接以关闭原因通知对端
+	if reason := checkClientCert(_r); reason != "" {
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject ws client %s: %s", _r.RemoteAddr, reason), false, false)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+		return
+	}
+
 	global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("connected to ws client: %s", strings.Split(_r.Header.Get("X-Forwarded-For"), ",")[0]), false, false)
 
+	if _r.Header.Get("logSource") == "file" {
+		fileHandle(_w, _r, conn)
+		return
+	}
+
 	jclient := journald.CreateJournaldClient(conn, global.ReadCmdStderrTimeout)
 	jclient.ID = _r.Header.Get("clientId")
+	if conf.Global_Config.Journal != nil && conf.Global_Config.Journal.Reader != "" {
+		jclient.Reader = conf.Global_Config.Journal.Reader
+	}
 
 	jclient.Active = true
 	if logtools.LogCollector == nil {
@@ -57,5 +76,30 @@ func entryHandle(_w http.ResponseWriter, _r *http.Request) {
 		_w.Write([]byte(err.Error()))
 		return
 	}
+	metrics.JournaldSessions.Inc()
+	defer metrics.JournaldSessions.Dec()
 	jclient.ReadMessageFromClient()
 }
+
+// 文本日志文件采集
+func fileHandle(_w http.ResponseWriter, _r *http.Request, _conn *websocket.Conn) {
+	allow_list := []string{}
+	if conf.Global_Config.Filetail != nil {
+		allow_list = conf.Global_Config.Filetail.Allow_list
+	}
+	fclient := filetail.CreateFileClient(_conn, allow_list, global.FilePollInterval)
+	fclient.ID = _r.Header.Get("clientId")
+
+	fclient.Active = true
+	if logtools.LogCollector == nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.New("logcollector is nil"), false, false)
+		_w.Write([]byte("logcollector is nil"))
+		return
+	}
+	if err := logtools.LogCollector.Add(logtools.FileLogClientType, fclient.ID, fclient); err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.New(err.Error()), false, false)
+		_w.Write([]byte(err.Error()))
+		return
+	}
+	fclient.ReadMessageFromClient()
+}
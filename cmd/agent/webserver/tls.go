@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 22:14:09 2026 +0800
+ */
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/agent/global"
+	"github.com/pkg/errors"
+)
+
+// 校验客户端证书的tls配置：未提供证书时允许完成握手，由checkClientCert以websocket关闭原因拒绝连接
+func clientAuthTLSConfig(_ca_file string) (*tls.Config, error) {
+	ca, err := global.FileReadBytes(_ca_file)
+	if err != nil {
+		return nil, errors.Errorf("fail to read ca file %s: %s", _ca_file, err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("no valid certificate in ca file %s", _ca_file)
+	}
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// 开启mTLS时检查连接方是否提供了有效的客户端证书，返回拒绝连接的原因
+func checkClientCert(_r *http.Request) string {
+	if conf.Global_Config.Logs.CAFile == "" {
+		return ""
+	}
+	if _r.TLS == nil {
+		return "unauthenticated: https required"
+	}
+	if len(_r.TLS.VerifiedChains) == 0 {
+		return "unauthenticated: client certificate required"
+	}
+	return ""
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 16:40:15 2026 +0800
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	apiPrefix         = "/plugin/logs/api"
+	ndjsonContentType = "application/x-ndjson"
+	// 单行日志的最大长度
+	maxLineBytes = 16 * 1024 * 1024
+)
+
+// 凭据文件，命令行参数及环境变量LOGSCTL_SERVER、LOGSCTL_TOKEN优先
+type credentials struct {
+	Server   string `yaml:"server"`
+	Token    string `yaml:"token"`
+	CAFile   string `yaml:"ca_file"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+// 各子命令共用的连接参数
+type connOptions struct {
+	server          string
+	token           string
+	credentialsFile string
+	caFile          string
+	insecure        bool
+	timeout         time.Duration
+}
+
+func (o *connOptions) register(_fs *flag.FlagSet) {
+	_fs.StringVar(&o.server, "server", "", "logs server address, e.g. http://127.0.0.1:9996")
+	_fs.StringVar(&o.token, "token", "", "PilotGo token")
+	_fs.StringVar(&o.credentialsFile, "credentials", defaultCredentialsFile(), "credentials file with server, token, ca_file and insecure")
+	_fs.StringVar(&o.caFile, "ca-file", "", "CA certificate of the logs server")
+	_fs.BoolVar(&o.insecure, "insecure", false, "skip verifying the logs server certificate")
+	_fs.DurationVar(&o.timeout, "timeout", 60*time.Second, "timeout of non-streaming requests")
+}
+
+func defaultCredentialsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "logsctl", "credentials.yaml")
+}
+
+// logs server的http客户端
+type client struct {
+	server  string
+	token   string
+	timeout time.Duration
+	http    *http.Client
+}
+
+// 合并命令行参数、环境变量及凭据文件
+func newClient(_o *connOptions) (*client, error) {
+	creds := &credentials{}
+	if _o.credentialsFile != "" {
+		creds_bytes, err := os.ReadFile(_o.credentialsFile)
+		if err != nil && !(os.IsNotExist(err) && _o.credentialsFile == defaultCredentialsFile()) {
+			return nil, errors.Errorf("fail to read credentials file: %s", err.Error())
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(creds_bytes, creds); err != nil {
+				return nil, errors.Errorf("invalid credentials file %s: %s", _o.credentialsFile, err.Error())
+			}
+		}
+	}
+	server := firstNonEmpty(_o.server, os.Getenv("LOGSCTL_SERVER"), creds.Server)
+	if server == "" {
+		return nil, errors.New("logs server address is required: -server, LOGSCTL_SERVER or credentials file")
+	}
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	c := &client{
+		server:  strings.TrimSuffix(server, "/"),
+		token:   firstNonEmpty(_o.token, os.Getenv("LOGSCTL_TOKEN"), creds.Token),
+		timeout: _o.timeout,
+		http:    &http.Client{},
+	}
+
+	tls_config := &tls.Config{InsecureSkipVerify: _o.insecure || creds.Insecure}
+	if ca_file := firstNonEmpty(_o.caFile, creds.CAFile); ca_file != "" {
+		ca, err := os.ReadFile(ca_file)
+		if err != nil {
+			return nil, errors.Errorf("fail to read ca file %s: %s", ca_file, err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no valid certificate in ca file %s", ca_file)
+		}
+		tls_config.RootCAs = pool
+	}
+	c.http.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tls_config}
+	return c, nil
+}
+
+func firstNonEmpty(_values ...string) string {
+	for _, v := range _values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// logs server接口的返回格式
+type serverResponse struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+	Msg  string          `json:"msg"`
+}
+
+// 查询失败，info为logs server或agent返回的错误码
+type apiError struct {
+	msg  string
+	info *public.ErrorInfo
+}
+
+func (e *apiError) Error() string {
+	if e.info != nil && e.info.Code != "" {
+		msg := e.info.Code + ": " + e.info.Message
+		if len(e.info.Stderr) > 0 {
+			msg += "\n" + strings.Join(e.info.Stderr, "\n")
+		}
+		return msg
+	}
+	return e.msg
+}
+
+func (c *client) newRequest(_ctx context.Context, _method, _path string, _query url.Values, _body interface{}) (*http.Request, error) {
+	u := c.server + apiPrefix + _path
+	if len(_query) > 0 {
+		u += "?" + _query.Encode()
+	}
+	var body io.Reader
+	if _body != nil {
+		bytes_body, err := json.Marshal(_body)
+		if err != nil {
+			return nil, errors.Errorf("fail to marshal request: %s", err.Error())
+		}
+		body = bytes.NewReader(bytes_body)
+	}
+	req, err := http.NewRequestWithContext(_ctx, _method, u, body)
+	if err != nil {
+		return nil, errors.Errorf("fail to create request: %s", err.Error())
+	}
+	if _body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// 发送请求并将返回的data解析至_out
+func (c *client) call(_ctx context.Context, _method, _path string, _query url.Values, _body, _out interface{}) error {
+	ctx, cancel := context.WithTimeout(_ctx, c.timeout)
+	defer cancel()
+	req, err := c.newRequest(ctx, _method, _path, _query, _body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errors.Errorf("request to %s failed: %s", req.URL.Path, err.Error())
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, _out)
+}
+
+func decodeResponse(_resp *http.Response, _out interface{}) error {
+	resp_bytes, err := io.ReadAll(_resp.Body)
+	if err != nil {
+		return errors.Errorf("fail to read response: %s", err.Error())
+	}
+	server_resp := &serverResponse{}
+	if err := json.Unmarshal(resp_bytes, server_resp); err != nil {
+		return errors.Errorf("invalid response(%d): %s", _resp.StatusCode, strings.TrimSpace(string(resp_bytes)))
+	}
+	if server_resp.Code != http.StatusOK {
+		api_err := &apiError{msg: server_resp.Msg}
+		json.Unmarshal(server_resp.Data, &api_err.info)
+		return api_err
+	}
+	if _out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(server_resp.Data, _out); err != nil {
+		return errors.Errorf("fail to unmarshal response: %s", err.Error())
+	}
+	return nil
+}
+
+/*
+下载接口返回的文件，_open根据logs server给出的文件名返回写入位置
+
+logs server开始返回数据后出现的错误通过X-Export-Error trailer返回
+*/
+func (c *client) download(_ctx context.Context, _path string, _body interface{}, _open func(_filename string) (io.WriteCloser, error)) error {
+	req, err := c.newRequest(_ctx, http.MethodPost, _path, nil, _body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errors.Errorf("request to %s failed: %s", req.URL.Path, err.Error())
+	}
+	defer resp.Body.Close()
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if err != nil {
+		return decodeResponse(resp, nil)
+	}
+	w, err := _open(params["filename"])
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return errors.Errorf("fail to read response: %s", err.Error())
+	}
+	if msg := resp.Trailer.Get("X-Export-Error"); msg != "" {
+		return errors.Errorf("export incomplete: %s", msg)
+	}
+	return nil
+}
+
+/*
+以ndjson格式发送查询，每收到一行调用一次_fn，_fn返回false时结束
+
+logs server在开始返回日志后出现的错误以{"error":ErrorInfo}作为最后一行返回
+*/
+func (c *client) stream(_ctx context.Context, _path string, _query url.Values, _body interface{}, _fn func(json.RawMessage) bool) error {
+	query := url.Values{}
+	for k, v := range _query {
+		query[k] = v
+	}
+	query.Set("format", "ndjson")
+	req, err := c.newRequest(_ctx, http.MethodPost, _path, query, _body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", ndjsonContentType)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if _ctx.Err() != nil {
+			return nil
+		}
+		return errors.Errorf("request to %s failed: %s", req.URL.Path, err.Error())
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), ndjsonContentType) {
+		return decodeResponse(resp, nil)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte(`{"error":`)) {
+			stream_err := &struct {
+				Error *public.ErrorInfo `json:"error"`
+			}{}
+			if err := json.Unmarshal(line, stream_err); err == nil && stream_err.Error != nil {
+				return &apiError{info: stream_err.Error}
+			}
+		}
+		if !_fn(append(json.RawMessage{}, line...)) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil && _ctx.Err() == nil {
+		return errors.Errorf("fail to read response: %s", err.Error())
+	}
+	return nil
+}
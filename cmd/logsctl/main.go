@@ -0,0 +1,402 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 16:22:09 2026 +0800
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const usage = `logsctl: query logs through the PilotGo logs server
+
+Usage:
+  logsctl machines [flags]            list machines with a reachable logs agent
+  logsctl units    -uuid|-ip [flags]  list systemd services of a machine
+  logsctl query    -uuid|-ip [flags]  query logs of a machine, -f to follow
+  logsctl export   -uuid|-ip [flags]  download all matching logs as a file
+
+Run "logsctl <command> -h" for the flags of a command.
+Connection flags may also be set in the credentials file (default ~/.config/logsctl/credentials.yaml):
+  server: http://127.0.0.1:9996
+  token: <PilotGo token>
+  ca_file: /path/to/ca.pem
+  insecure: false
+`
+
+type command func(_ctx context.Context, _args []string) error
+
+var commands = map[string]command{
+	"machines": machinesCommand,
+	"units":    unitsCommand,
+	"query":    queryCommand,
+	"export":   exportCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "-help" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "logsctl: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(_name, _args_usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(_name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: logsctl %s %s\n", _name, _args_usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// 可以重复指定的参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(_value string) error {
+	*l = append(*l, _value)
+	return nil
+}
+
+func machinesCommand(_ctx context.Context, _args []string) error {
+	conn := &connOptions{}
+	fs := newFlagSet("machines", "[flags]")
+	conn.register(fs)
+	output := fs.String("o", outputHuman, "output format: human, json or ndjson")
+	fs.Parse(_args)
+
+	p, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(conn)
+	if err != nil {
+		return err
+	}
+	ips := []string{}
+	if err := c.call(_ctx, http.MethodGet, "/ip_list", nil, nil, &ips); err != nil {
+		return err
+	}
+	defer p.flush()
+	if p.format == outputNDJSON {
+		for _, ip := range ips {
+			p.value(ip, nil)
+		}
+		return nil
+	}
+	p.value(ips, func() {
+		for _, ip := range ips {
+			fmt.Fprintln(p.writer, ip)
+		}
+	})
+	return nil
+}
+
+func unitsCommand(_ctx context.Context, _args []string) error {
+	conn := &connOptions{}
+	fs := newFlagSet("units", "-uuid <uuid>|-ip <ip> [flags]")
+	conn.register(fs)
+	uuid := fs.String("uuid", "", "machine uuid")
+	ip := fs.String("ip", "", "machine ip")
+	output := fs.String("o", outputHuman, "output format: human, json or ndjson")
+	fs.Parse(_args)
+
+	p, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	if *uuid == "" && *ip == "" {
+		return errors.New("-uuid or -ip is required")
+	}
+	c, err := newClient(conn)
+	if err != nil {
+		return err
+	}
+	units := map[string][]string{}
+	if err := c.call(_ctx, http.MethodGet, "/units", url.Values{"uuid": {*uuid}, "ip": {*ip}}, nil, &units); err != nil {
+		return err
+	}
+
+	kinds := []string{}
+	for kind := range units {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	defer p.flush()
+	if p.format == outputNDJSON {
+		for _, kind := range kinds {
+			for _, name := range units[kind] {
+				p.value(map[string]string{"kind": kind, "name": name}, nil)
+			}
+		}
+		return nil
+	}
+	p.value(units, func() {
+		for _, kind := range kinds {
+			for _, name := range units[kind] {
+				fmt.Fprintf(p.writer, "%s\t%s\n", kind, name)
+			}
+		}
+	})
+	return nil
+}
+
+/*
+注册query及export共用的查询条件参数
+
+返回的函数在解析参数后调用，将-match及-fields写入_options
+*/
+func registerFilters(_fs *flag.FlagSet, _options *public.JournalctlOptions) func() error {
+	_fs.StringVar(&_options.Since, "since", "", "show entries not older than the date, e.g. \"2026-10-01 00:00:00\"")
+	_fs.StringVar(&_options.Until, "until", "", "show entries not newer than the date")
+	_fs.StringVar(&_options.Unit, "unit", "", "systemd unit")
+	_fs.StringVar(&_options.Identifier, "identifier", "", "syslog identifier")
+	_fs.StringVar(&_options.Severity, "priority", "", "maximum priority, e.g. warning or 4")
+	_fs.StringVar(&_options.Transport, "transport", "", "journal transport, e.g. kernel or syslog")
+	_fs.StringVar(&_options.User, "user", "", "user, e.g. root:0")
+	_fs.StringVar(&_options.Boot, "boot", "", "boot offset (0, -1, ...) or boot id")
+	_fs.StringVar(&_options.File, "file", "", "text log file, with -source file")
+	_fs.StringVar(&_options.Grep, "grep", "", "filter MESSAGE by substring")
+	_fs.BoolVar(&_options.GrepRegex, "regex", false, "-grep is a regular expression")
+	_fs.BoolVar(&_options.GrepIgnoreCase, "ignore-case", false, "-grep ignores case")
+	matches := &stringList{}
+	_fs.Var(matches, "match", "FIELD=VALUE journal field match, may be repeated: different fields are ANDed, the same field is ORed")
+	fields := _fs.String("fields", "", "comma separated extra fields, e.g. _PID,_HOSTNAME")
+	_fs.StringVar(&_options.Query, "q", "", "query, e.g. 'unit:nginx AND priority<=warning AND msg~\"timeout\" AND NOT _COMM=curl'")
+
+	return func() error {
+		if len(*matches) > 0 {
+			group := []public.FieldMatch{}
+			for _, m := range *matches {
+				field, value, ok := strings.Cut(m, "=")
+				if !ok || field == "" {
+					return errors.Errorf("invalid -match %q, want FIELD=VALUE", m)
+				}
+				group = append(group, public.FieldMatch{Field: field, Value: value})
+			}
+			_options.Matches = [][]public.FieldMatch{group}
+		}
+		if *fields != "" {
+			_options.Fields = strings.Split(*fields, ",")
+		}
+		return public.CheckQuery(_options)
+	}
+}
+
+// 与logs server http查询接口的请求一致
+type queryRequest struct {
+	UUID    string                    `json:"uuid"`
+	IP      string                    `json:"ip"`
+	Options *public.JournalctlOptions `json:"options"`
+	Follow  bool                      `json:"follow"`
+	Limit   int                       `json:"limit"`
+}
+
+type queryResult struct {
+	UUID     string           `json:"uuid"`
+	IP       string           `json:"ip"`
+	Archived bool             `json:"archived"`
+	Page     *public.PageData `json:"page"`
+}
+
+func queryCommand(_ctx context.Context, _args []string) error {
+	conn := &connOptions{}
+	fs := newFlagSet("query", "-uuid <uuid>|-ip <ip> [flags]")
+	conn.register(fs)
+	req := &queryRequest{Options: &public.JournalctlOptions{}}
+	options := req.Options
+	fs.StringVar(&req.UUID, "uuid", "", "machine uuid")
+	fs.StringVar(&req.IP, "ip", "", "machine ip")
+	fs.BoolVar(&req.Follow, "f", false, "follow new entries until interrupted")
+	fs.IntVar(&req.Limit, "limit", 0, "stop after this many entries with -all or -f, 0 for no limit")
+	all := fs.Bool("all", false, "page through all matching entries")
+	output := fs.String("o", outputHuman, "output format: human, json or ndjson")
+	source := fs.String("source", "", "log source: empty for journal, file for text log files")
+
+	filters := registerFilters(fs, options)
+	fs.IntVar(&options.Size, "size", 0, "entries per page, 0 for the server default")
+	fs.IntVar(&options.From, "from", 0, "offset of the first entry")
+	fs.StringVar(&options.Cursor, "cursor", "", "page from this cursor instead of -from")
+	fs.StringVar(&options.Direction, "direction", "", "page direction: forward (default) or backward")
+	fs.StringVar(&options.AfterCursor, "after-cursor", "", "with -f, resume after this cursor")
+	fs.Parse(_args)
+
+	p, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	if req.UUID == "" && req.IP == "" {
+		return errors.New("-uuid or -ip is required")
+	}
+	if err := filters(); err != nil {
+		return err
+	}
+	c, err := newClient(conn)
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *source != "" {
+		query.Set("source", *source)
+	}
+
+	defer p.flush()
+	if req.Follow || *all {
+		return c.stream(_ctx, "/query", query, req, func(_line json.RawMessage) bool {
+			p.entry(_line)
+			p.flush()
+			return true
+		})
+	}
+
+	result := &queryResult{}
+	if err := c.call(_ctx, http.MethodPost, "/query", query, req, result); err != nil {
+		return err
+	}
+	if p.format == outputJSON {
+		p.value(result, nil)
+		return nil
+	}
+	if result.Page == nil {
+		return nil
+	}
+	for _, hit := range result.Page.Hits {
+		raw, err := json.Marshal(hit)
+		if err != nil {
+			continue
+		}
+		p.entry(raw)
+	}
+	if p.format == outputHuman {
+		p.flush()
+		printPageSummary(result, options)
+	}
+	return nil
+}
+
+// human格式在stderr中提示总数及翻页参数
+func printPageSummary(_result *queryResult, _options *public.JournalctlOptions) {
+	page := _result.Page
+	total := fmt.Sprint(page.Total)
+	if page.Estimated {
+		total = ">=" + total
+	}
+	source := ""
+	if _result.Archived {
+		source = " (archive)"
+	}
+	fmt.Fprintf(os.Stderr, "-- %d of %s entries from %s%s\n", len(page.Hits), total, _result.IP, source)
+	if !page.More {
+		return
+	}
+	if _options.Direction == public.PageBackward {
+		fmt.Fprintf(os.Stderr, "-- older entries: -direction backward -cursor '%s'\n", page.FirstCursor)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "-- more entries: -cursor '%s'\n", page.LastCursor)
+}
+
+// 与logs server导出接口的请求一致
+type exportRequest struct {
+	UUID    string                    `json:"uuid"`
+	IP      string                    `json:"ip"`
+	Format  string                    `json:"format"`
+	Gzip    bool                      `json:"gzip"`
+	Options *public.JournalctlOptions `json:"options"`
+}
+
+func exportCommand(_ctx context.Context, _args []string) error {
+	conn := &connOptions{}
+	fs := newFlagSet("export", "-uuid <uuid>|-ip <ip> [flags]")
+	conn.register(fs)
+	req := &exportRequest{Options: &public.JournalctlOptions{}}
+	fs.StringVar(&req.UUID, "uuid", "", "machine uuid")
+	fs.StringVar(&req.IP, "ip", "", "machine ip")
+	fs.StringVar(&req.Format, "format", public.ExportNDJSON, "file format: ndjson, csv, short-iso or export (journal export format)")
+	fs.BoolVar(&req.Gzip, "gzip", false, "gzip the file")
+	output := fs.String("o", "", "output file, - for stdout, default the file name given by the server")
+	filters := registerFilters(fs, req.Options)
+	fs.Parse(_args)
+
+	if req.UUID == "" && req.IP == "" {
+		return errors.New("-uuid or -ip is required")
+	}
+	if err := filters(); err != nil {
+		return err
+	}
+	c, err := newClient(conn)
+	if err != nil {
+		return err
+	}
+
+	filename := ""
+	err = c.download(_ctx, "/export", req, func(_filename string) (io.WriteCloser, error) {
+		if *output == "-" {
+			return nopWriteCloser{os.Stdout}, nil
+		}
+		filename = *output
+		if filename == "" {
+			filename = filepath.Base(_filename)
+		}
+		if filename == "" || filename == "." || filename == "/" {
+			return nil, errors.New("no file name given by the server, use -o")
+		}
+		f, err := os.Create(filename)
+		if err != nil {
+			return nil, errors.Errorf("fail to create output file: %s", err.Error())
+		}
+		return f, nil
+	})
+	if err != nil {
+		if _ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	if filename != "" {
+		fmt.Fprintf(os.Stderr, "-- exported to %s\n", filename)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
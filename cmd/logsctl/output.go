@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 17:05:48 2026 +0800
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 输出格式
+const (
+	outputHuman  = "human"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// 日志条目中由human格式单独展示的字段
+var entryBaseFields = map[string]bool{
+	"cursor":     true,
+	"timestamp":  true,
+	"level":      true,
+	"targetname": true,
+	"message":    true,
+	"host":       true,
+	"fields":     true,
+}
+
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+type printer struct {
+	format string
+	writer *bufio.Writer
+}
+
+func newPrinter(_format string) (*printer, error) {
+	switch _format {
+	case outputHuman, outputJSON, outputNDJSON:
+	default:
+		return nil, errors.Errorf("unknown output format: %s", _format)
+	}
+	return &printer{format: _format, writer: bufio.NewWriter(os.Stdout)}, nil
+}
+
+func (p *printer) flush() {
+	p.writer.Flush()
+}
+
+// json格式输出整个结果，其他格式由_human输出
+func (p *printer) value(_v interface{}, _human func()) {
+	if p.format == outputHuman {
+		_human()
+		return
+	}
+	var bytes []byte
+	if p.format == outputJSON {
+		bytes, _ = json.MarshalIndent(_v, "", "  ")
+	} else {
+		bytes, _ = json.Marshal(_v)
+	}
+	p.writer.Write(append(bytes, '\n'))
+}
+
+// 输出一条日志：json及ndjson格式原样输出一行
+func (p *printer) entry(_raw json.RawMessage) {
+	if p.format != outputHuman {
+		p.writer.Write(append(_raw, '\n'))
+		return
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(_raw, &entry); err != nil {
+		p.writer.Write(append(_raw, '\n'))
+		return
+	}
+	p.writer.WriteString(formatEntry(entry))
+	p.writer.WriteByte('\n')
+}
+
+// 时间 级别 来源: 消息 额外字段
+func formatEntry(_entry map[string]interface{}) string {
+	var b strings.Builder
+	if ms, err := strconv.ParseInt(stringField(_entry, "timestamp"), 10, 64); err == nil {
+		b.WriteString(time.UnixMilli(ms).Format("2006-01-02 15:04:05.000"))
+	} else {
+		b.WriteString("-")
+	}
+	b.WriteByte(' ')
+	level := stringField(_entry, "level")
+	if i, err := strconv.Atoi(level); err == nil && i >= 0 && i < len(priorityNames) {
+		level = priorityNames[i]
+	}
+	fmt.Fprintf(&b, "%-7s ", level)
+	if host := stringField(_entry, "host"); host != "" {
+		b.WriteString(host + " ")
+	}
+	if target := stringField(_entry, "targetname"); target != "" {
+		b.WriteString(target + ": ")
+	}
+	b.WriteString(stringField(_entry, "message"))
+
+	// 查询时指定的额外字段及其他未知字段
+	extra := map[string]interface{}{}
+	if fields, ok := _entry["fields"].(map[string]interface{}); ok {
+		for k, v := range fields {
+			extra[k] = v
+		}
+	}
+	for k, v := range _entry {
+		if !entryBaseFields[k] {
+			extra[k] = v
+		}
+	}
+	keys := []string{}
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, extra[k])
+	}
+	return b.String()
+}
+
+func stringField(_entry map[string]interface{}, _key string) string {
+	switch v := _entry[_key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
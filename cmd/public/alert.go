@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 14:36:08 2026 +0800
+ */
+package public
+
+// 告警状态
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertRuleMsg的data：window秒内满足查询条件的日志多于threshold条时触发告警
+type AlertCondition struct {
+	Threshold int `json:"threshold"`
+	Window    int `json:"window"`
+}
+
+/*
+AlertData的data：告警状态
+
+agent开始评估后先返回一次当前状态，此后只在状态变化时返回
+*/
+type AlertEvent struct {
+	State string `json:"state"`
+	// 时间窗口内满足查询条件的日志条数，至多为threshold+1
+	Count int `json:"count"`
+	// 毫秒时间戳
+	Timestamp int64 `json:"timestamp"`
+	// 最近一条满足查询条件的日志的MESSAGE
+	Message string `json:"message,omitempty"`
+}
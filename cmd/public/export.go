@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 09:31:26 2026 +0800
+ */
+package public
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 导出格式
+const (
+	ExportNDJSON   = "ndjson"    // 每行一条journalctl --output=json格式的日志
+	ExportCSV      = "csv"       // 常用字段及joptions.fields
+	ExportShortISO = "short-iso" // 与journalctl --output=short-iso一致
+	ExportJournal  = "export"    // journal export format，可以使用systemd-journal-remote导入
+)
+
+// ExportData的data：导出结果的一段，最后一段done为true并带有导出的日志条数
+type ExportChunk struct {
+	Data    []byte `json:"data,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Entries int    `json:"entries,omitempty"`
+}
+
+// 将journalctl --output=json格式的日志逐条编码为导出格式
+type ExportEncoder interface {
+	Encode(_w io.Writer, _raw_entry map[string]interface{}) error
+}
+
+func NewExportEncoder(_format string, _fields []string) (ExportEncoder, error) {
+	switch _format {
+	case ExportNDJSON:
+		return ndjsonEncoder{}, nil
+	case ExportCSV:
+		return &csvEncoder{fields: _fields}, nil
+	case ExportShortISO:
+		return shortISOEncoder{}, nil
+	case ExportJournal:
+		return journalExportEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", _format)
+}
+
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) Encode(_w io.Writer, _raw_entry map[string]interface{}) error {
+	bytes, err := json.Marshal(_raw_entry)
+	if err != nil {
+		return err
+	}
+	_, err = _w.Write(append(bytes, '\n'))
+	return err
+}
+
+// csv的固定列，joptions.fields中的字段依次追加在后面
+var csvColumns = []string{"time", "hostname", "priority", "unit", "identifier", "pid", "message", "cursor"}
+
+type csvEncoder struct {
+	fields []string
+	header bool
+}
+
+func (e *csvEncoder) Encode(_w io.Writer, _raw_entry map[string]interface{}) error {
+	writer := csv.NewWriter(_w)
+	if !e.header {
+		e.header = true
+		if err := writer.Write(append(append([]string{}, csvColumns...), e.fields...)); err != nil {
+			return err
+		}
+	}
+	record := []string{
+		entryTime(_raw_entry).Format(time.RFC3339Nano),
+		ExportFieldValue(_raw_entry, "_HOSTNAME"),
+		ExportFieldValue(_raw_entry, "PRIORITY"),
+		ExportFieldValue(_raw_entry, "_SYSTEMD_UNIT"),
+		entryIdentifier(_raw_entry),
+		ExportFieldValue(_raw_entry, "_PID"),
+		ExportFieldValue(_raw_entry, "MESSAGE"),
+		ExportFieldValue(_raw_entry, "__CURSOR"),
+	}
+	for _, field := range e.fields {
+		record = append(record, ExportFieldValue(_raw_entry, field))
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type shortISOEncoder struct{}
+
+// 时间 主机 标识[pid]: 消息，多行消息的后续行与第一行消息对齐
+func (shortISOEncoder) Encode(_w io.Writer, _raw_entry map[string]interface{}) error {
+	prefix := entryTime(_raw_entry).Format("2006-01-02T15:04:05-0700")
+	if hostname := ExportFieldValue(_raw_entry, "_HOSTNAME"); hostname != "" {
+		prefix += " " + hostname
+	}
+	prefix += " " + entryIdentifier(_raw_entry)
+	pid := ExportFieldValue(_raw_entry, "_PID")
+	if pid == "" {
+		pid = ExportFieldValue(_raw_entry, "SYSLOG_PID")
+	}
+	if pid != "" {
+		prefix += "[" + pid + "]"
+	}
+	prefix += ": "
+	message := strings.TrimRight(ExportFieldValue(_raw_entry, "MESSAGE"), "\n")
+	message = strings.ReplaceAll(message, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+	_, err := io.WriteString(_w, prefix+message+"\n")
+	return err
+}
+
+// 地址字段在前，与journalctl --output=export一致
+var journalAddressFields = []string{"__CURSOR", "__REALTIME_TIMESTAMP", "__MONOTONIC_TIMESTAMP", "_BOOT_ID"}
+
+type journalExportEncoder struct{}
+
+/*
+每个字段一行KEY=value，包含换行、不可打印字符或不是有效UTF-8的值按二进制格式写入：
+KEY\n + 64位小端序长度 + 值 + \n；日志之间以空行分隔
+*/
+func (journalExportEncoder) Encode(_w io.Writer, _raw_entry map[string]interface{}) error {
+	buf := &bytes.Buffer{}
+	keys := []string{}
+	for key := range _raw_entry {
+		if !isAddressField(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range append(append([]string{}, journalAddressFields...), keys...) {
+		for _, value := range fieldValues(_raw_entry[key]) {
+			if isAddressField(key) || isPrintable(value) && bytes.IndexByte(value, '\n') < 0 {
+				buf.WriteString(key + "=")
+				buf.Write(value)
+				buf.WriteByte('\n')
+				continue
+			}
+			buf.WriteString(key + "\n")
+			binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+			buf.Write(value)
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteByte('\n')
+	_, err := _w.Write(buf.Bytes())
+	return err
+}
+
+func isAddressField(_key string) bool {
+	for _, field := range journalAddressFields {
+		if field == _key {
+			return true
+		}
+	}
+	return false
+}
+
+// 与systemd的utf8_is_printable一致：有效的UTF-8，且不含\t、\n以外的C0控制字符及0x7f-0x9f
+func isPrintable(_value []byte) bool {
+	if !utf8.Valid(_value) {
+		return false
+	}
+	for _, r := range string(_value) {
+		if r < ' ' && r != '\t' && r != '\n' || r >= 0x7f && r <= 0x9f {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+journalctl --output=json中字段的全部取值：
+
+字符串为单个值，数字数组为二进制值，数组中包含字符串或数字数组时为同一字段的多个值
+*/
+func fieldValues(_value interface{}) [][]byte {
+	switch v := _value.(type) {
+	case nil:
+		return nil
+	case string:
+		return [][]byte{[]byte(v)}
+	case []interface{}:
+		if len(v) > 0 {
+			if _, ok := v[0].(float64); ok {
+				value := make([]byte, 0, len(v))
+				for _, b := range v {
+					n, _ := b.(float64)
+					value = append(value, byte(n))
+				}
+				return [][]byte{value}
+			}
+		}
+		values := [][]byte{}
+		for _, item := range v {
+			values = append(values, fieldValues(item)...)
+		}
+		return values
+	default:
+		return [][]byte{[]byte(fmt.Sprint(v))}
+	}
+}
+
+// 字段的取值，多个值以换行连接
+func ExportFieldValue(_raw_entry map[string]interface{}, _key string) string {
+	values := fieldValues(_raw_entry[_key])
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, string(value))
+	}
+	return strings.Join(strs, "\n")
+}
+
+func entryTime(_raw_entry map[string]interface{}) time.Time {
+	realtime, err := strconv.ParseInt(ExportFieldValue(_raw_entry, "__REALTIME_TIMESTAMP"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMicro(realtime)
+}
+
+func entryIdentifier(_raw_entry map[string]interface{}) string {
+	for _, key := range []string{"SYSLOG_IDENTIFIER", "_COMM"} {
+		if value := ExportFieldValue(_raw_entry, key); value != "" {
+			return value
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 18:36:52 2026 +0800
+ */
+package public
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRealtime = time.Date(2026, 10, 1, 8, 0, 0, 123456000, time.UTC)
+
+// journalctl --output=json格式的日志：多行消息、引号及逗号、二进制值、重复字段
+func testEntries() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"__CURSOR":              "s=1;i=1",
+			"__REALTIME_TIMESTAMP":  "1790841600123456",
+			"__MONOTONIC_TIMESTAMP": "1000",
+			"_BOOT_ID":              "b1",
+			"_HOSTNAME":             "node1",
+			"PRIORITY":              "3",
+			"_SYSTEMD_UNIT":         "nginx.service",
+			"SYSLOG_IDENTIFIER":     "nginx",
+			"_PID":                  "42",
+			"MESSAGE":               "first line, \"quoted\"\nsecond line\n",
+			"TAG":                   []interface{}{"one", "two"},
+			"BINARY":                []interface{}{float64(0), float64(255), float64(10)},
+		},
+		{
+			"__CURSOR":              "s=1;i=2",
+			"__REALTIME_TIMESTAMP":  "1790841601000000",
+			"__MONOTONIC_TIMESTAMP": "2000",
+			"_BOOT_ID":              "b1",
+			"_COMM":                 "kworker",
+			"PRIORITY":              "6",
+			"MESSAGE":               "tab\there 日志",
+			"DEL":                   "a\x7fb",
+			"C1":                    "a\u0085b",
+		},
+	}
+}
+
+func encodeAll(t *testing.T, _format string, _fields []string) []byte {
+	encoder, err := NewExportEncoder(_format, _fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	for _, raw_entry := range testEntries() {
+		if err := encoder.Encode(buf, raw_entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestExportNDJSON(t *testing.T) {
+	scanner := bufio.NewScanner(bytes.NewReader(encodeAll(t, ExportNDJSON, nil)))
+	entries := []map[string]interface{}{}
+	for scanner.Scan() {
+		raw_entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw_entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, raw_entry)
+	}
+	if want := testEntries(); !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v, want %v", entries, want)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(encodeAll(t, ExportCSV, []string{"TAG", "MISSING"}))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"time", "hostname", "priority", "unit", "identifier", "pid", "message", "cursor", "TAG", "MISSING"},
+		{testRealtime.Local().Format(time.RFC3339Nano), "node1", "3", "nginx.service", "nginx", "42", "first line, \"quoted\"\nsecond line\n", "s=1;i=1", "one\ntwo", ""},
+		{testRealtime.Truncate(time.Second).Add(time.Second).Local().Format(time.RFC3339Nano), "", "6", "", "kworker", "", "tab\there 日志", "s=1;i=2", "", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
+
+func TestExportShortISO(t *testing.T) {
+	first := testRealtime.Local().Format("2006-01-02T15:04:05-0700") + " node1 nginx[42]: "
+	second := testRealtime.Add(time.Second).Local().Format("2006-01-02T15:04:05-0700") + " kworker: "
+	want := first + "first line, \"quoted\"\n" +
+		strings.Repeat(" ", len(first)) + "second line\n" +
+		second + "tab\there 日志\n"
+	if got := string(encodeAll(t, ExportShortISO, nil)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// 按journal export format解析，返回各日志的字段及取值，binary记录按二进制格式写入的字段
+func readJournalExport(t *testing.T, _data []byte) ([]map[string][]string, map[string]bool) {
+	reader := bufio.NewReader(bytes.NewReader(_data))
+	entries := []map[string][]string{}
+	binaries := map[string]bool{}
+	entry := map[string][]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil {
+			t.Fatalf("truncated export: %q", line)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			entries = append(entries, entry)
+			entry = map[string][]string{}
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			entry[key] = append(entry[key], value)
+			continue
+		}
+		size := uint64(0)
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			t.Fatal(err)
+		}
+		value := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			t.Fatal(err)
+		}
+		if value[size] != '\n' {
+			t.Fatalf("binary field %s is not terminated by a newline", line)
+		}
+		entry[line] = append(entry[line], string(value[:size]))
+		binaries[line] = true
+	}
+	if len(entry) != 0 {
+		t.Fatal("export does not end with an empty line")
+	}
+	return entries, binaries
+}
+
+func TestExportJournal(t *testing.T) {
+	data := encodeAll(t, ExportJournal, nil)
+	if !bytes.HasPrefix(data, []byte("__CURSOR=s=1;i=1\n__REALTIME_TIMESTAMP=1790841600123456\n__MONOTONIC_TIMESTAMP=1000\n_BOOT_ID=b1\n")) {
+		t.Errorf("address fields are not written first: %q", data[:80])
+	}
+	entries, binaries := readJournalExport(t, data)
+
+	want := []map[string][]string{}
+	for _, raw_entry := range testEntries() {
+		entry := map[string][]string{}
+		for key, value := range raw_entry {
+			for _, v := range fieldValues(value) {
+				entry[key] = append(entry[key], string(v))
+			}
+		}
+		want = append(want, entry)
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %q, want %q", entries, want)
+	}
+	// 包含换行、0x7f、C1控制字符及不是有效UTF-8的值按二进制格式写入
+	wantBinaries := map[string]bool{"MESSAGE": true, "BINARY": true, "DEL": true, "C1": true}
+	if !reflect.DeepEqual(binaries, wantBinaries) {
+		t.Errorf("binary fields: got %v, want %v", binaries, wantBinaries)
+	}
+}
+
+func TestIsPrintable(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"plain text", true},
+		{"tab\tand\nnewline", true},
+		{"日志", true},
+		{"", true},
+		{"bell\a", false},
+		{"nul\x00", false},
+		{"del\x7f", false},
+		{"c1\u0085", false},
+		{"c1 end\u009f", false},
+		{"after c1 ", true},
+		{"invalid \xff", false},
+		{"truncated \xe6\x97", false},
+	}
+	for _, tt := range tests {
+		if got := isPrintable([]byte(tt.value)); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 19:48:27 2026 +0800
+ */
+package public
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// since、until中以UTC表示的时间，如2006-01-02 15:04:05 UTC
+const JournalTimeLayoutUTC = "2006-01-02 15:04:05 UTC"
+
+/*
+解析since、until，返回微秒时间戳，空值返回0
+
+支持journalctl的以下格式：YYYY-MM-DD[ HH:MM[:SS]]（本地时间，带" UTC"后缀时为UTC）、RFC 3339及@秒级时间戳（可以带小数）
+*/
+func ParseJournalTime(_t string) (uint64, error) {
+	if _t == "" {
+		return 0, nil
+	}
+	if strings.HasPrefix(_t, "@") {
+		return parseEpoch(_t)
+	}
+	location, value := time.Local, _t
+	if v, ok := strings.CutSuffix(_t, " UTC"); ok {
+		location, value = time.UTC, v
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return uint64(t.UnixMicro()), nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, _t); err == nil {
+		return uint64(t.UnixMicro()), nil
+	}
+	return 0, fmt.Errorf("unsupported time format: %s", _t)
+}
+
+// @秒[.小数]，小数部分精确到微秒
+func parseEpoch(_t string) (uint64, error) {
+	seconds, fraction, _ := strings.Cut(_t[1:], ".")
+	sec, err := strconv.ParseUint(seconds, 10, 64)
+	if err != nil || sec > uint64(1<<63-1)/1000000 {
+		return 0, fmt.Errorf("unsupported time format: %s", _t)
+	}
+	usec := uint64(0)
+	for i, c := range fraction {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("unsupported time format: %s", _t)
+		}
+		if i < 6 {
+			usec = usec*10 + uint64(c-'0')
+		}
+	}
+	for i := len(fraction); i < 6; i++ {
+		usec *= 10
+	}
+	return sec*1000000 + usec, nil
+}
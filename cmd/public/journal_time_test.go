@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 20:02:45 2026 +0800
+ */
+package public
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJournalTime(t *testing.T) {
+	utc := time.Date(2026, 10, 1, 8, 30, 15, 0, time.UTC)
+	local := time.Date(2026, 10, 1, 8, 30, 15, 0, time.Local)
+	usec := func(_t time.Time) uint64 { return uint64(_t.UnixMicro()) }
+
+	tests := []struct {
+		value string
+		want  uint64
+		ok    bool
+	}{
+		{"", 0, true},
+		{"2026-10-01 08:30:15", usec(local), true},
+		{"2026-10-01 08:30", usec(local.Add(-15 * time.Second)), true},
+		{"2026-10-01", usec(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)), true},
+		{"2026-10-01 08:30:15 UTC", usec(utc), true},
+		{"2026-10-01 UTC", usec(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)), true},
+		{"2026-10-01T16:30:15+08:00", usec(utc), true},
+		{"@1790843415", usec(utc), true},
+		{"@1790843415.5", usec(utc) + 500000, true},
+		{"@1790843415.1234567", usec(utc) + 123456, true},
+		{"@0", 0, true},
+		{"@", 0, false},
+		{"@-1", 0, false},
+		{"@1.2.3", 0, false},
+		{"@1e9", 0, false},
+		{"2026-10-01 08:30:15 CST", 0, false},
+		{"2026-10-01 08:30:15UTC", 0, false},
+		{"yesterday", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseJournalTime(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%q: got %d, error %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -7,6 +7,8 @@
  */
 package public
 
+import "encoding/json"
+
 type JournalctlOptions struct {
 	Since      string `json:"since"`
 	Until      string `json:"until"`
@@ -18,12 +20,67 @@ type JournalctlOptions struct {
 	User       string `json:"user"` // root:0
 	From       int    `json:"from"`
 	Size       int    `json:"size"`
+	File       string `json:"file"`      // 文本日志文件路径，仅文件采集客户端使用
+	Cursor     string `json:"cursor"`    // 分页游标（__CURSOR），不为空时忽略From
+	Direction  string `json:"direction"` // 分页方向：forward（默认）、backward
+	// 按MESSAGE内容搜索，默认为子串匹配
+	Grep           string `json:"grep"`
+	GrepRegex      bool   `json:"grep_regex"`       // grep为正则表达式
+	GrepIgnoreCase bool   `json:"grep_ignore_case"` // 忽略大小写
+	// 日志字段匹配条件：组内不同字段为AND、相同字段为OR，组之间为OR，与其他查询条件为AND
+	Matches [][]FieldMatch `json:"matches"`
+	// 查询语句（语法见Query），与其他查询条件为AND
+	Query string `json:"query"`
+	// 启动序号（0为本次启动，-1为上一次启动）或boot ID，为空时不限制
+	Boot string `json:"boot"`
+	// 日志条目中额外返回的字段，如_PID、_HOSTNAME、CODE_FILE
+	Fields []string `json:"fields"`
+	// 上下文查询：cursor前后的日志条数及查询范围
+	Before       int    `json:"before"`
+	After        int    `json:"after"`
+	ContextScope string `json:"context_scope"`
+	// 时间轴统计：时间段宽度（秒，为0时自动选择）及分组方式
+	Interval int    `json:"interval"`
+	SplitBy  string `json:"split_by"`
+	// 实时查询断线重连：从断线前收到的最后一条日志（StdoutData.Cursor）之后继续返回，不再返回最近的日志
+	AfterCursor string `json:"after_cursor,omitempty"`
+	// 多主机实时查询断线重连：各主机（UUID）断线前收到的最后一条日志的游标
+	AfterCursors map[string]string `json:"after_cursors,omitempty"`
+}
+
+// 时间轴分组方式
+const (
+	SplitByUnit     = "unit"
+	SplitByPriority = "priority"
+)
+
+// 上下文查询范围
+const (
+	ContextScopeAll   = "all"   // 整个journal（默认）
+	ContextScopeBoot  = "boot"  // 与cursor对应日志属于同一次启动
+	ContextScopeQuery = "query" // 使用joptions中的查询条件
+)
+
+// journal字段匹配条件，如_PID=1、_COMM=sshd
+type FieldMatch struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
 }
 
+// 分页方向
+const (
+	PageForward  = "forward"  // cursor之后的日志，cursor为空时从第一条开始
+	PageBackward = "backward" // cursor之前的日志，cursor为空时从最后一条开始
+)
+
 type JMessage struct {
 	Type     int                `json:"type"`
 	JOptions *JournalctlOptions `json:"joptions"`
 	Data     interface{}        `json:"data"`
+	// 多主机查询时消息所属主机的UUID：客户端发送时指定目标主机，为空时发往全部主机
+	Host string `json:"host,omitempty"`
+	// 单主机查询时同一连接上的多个并发查询：由客户端指定，agent返回的消息带有相同的stream；为空时为连接本身的查询
+	Stream string `json:"stream,omitempty"`
 }
 
 // 客户端与logs agent之间websocket通信的消息类型
@@ -35,22 +92,128 @@ const (
 	DataMsg
 	UpdatePageMsg
 	DialFailedMsg
+	BootListMsg
+	EntryDetailMsg      // 根据joptions.cursor查询单条日志的全部字段
+	ContextMsg          // 根据joptions.cursor、before、after查询日志上下文
+	TimelineMsg         // 按时间段统计since至until之间的日志条数
+	HostsMsg            // 多主机查询：客户端发送的第一条消息，data为HostSelector
+	PermissionDeniedMsg // 查询未通过权限检查，data为拒绝原因
+	CancelStreamMsg     // 结束jmsg.stream对应的查询，连接及其他查询不受影响
+	ErrorMsg            // 查询失败，data为ErrorInfo
+	ExportMsg           // 导出joptions的全部查询结果，data为导出格式
+	AlertRuleMsg        // 实时评估告警规则，joptions为规则的查询条件，data为AlertCondition
+)
+
+// ErrorMsg的data：agent端查询失败或logs server无法连接agent的原因
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// journalctl等命令的错误输出
+	Stderr []string `json:"stderr,omitempty"`
+}
+
+// ErrorInfo的错误码
+const (
+	ErrCodeInvalidOptions   = "invalid_options"     // 查询条件不合法
+	ErrCodeCommandFailed    = "command_failed"      // journalctl、systemctl无法执行或非零退出
+	ErrCodeJournalRead      = "journal_read_failed" // 读取journal或日志文件失败
+	ErrCodeDecodeFailed     = "decode_failed"       // 无法解析journalctl的输出
+	ErrCodeQueryFailed      = "query_failed"        // 分页、详情、上下文、时间轴等查询失败
+	ErrCodeUnsupported      = "unsupported_message" // 不支持的消息类型
+	ErrCodeTooManyStreams   = "too_many_streams"    // 同一连接上的stream数超出上限
+	ErrCodeStreamBusy       = "stream_busy"         // stream上待处理的消息数超出上限
+	ErrCodeDialFailed       = "dial_failed"         // logs server无法连接agent
+	ErrCodeHandshakeFailed  = "handshake_failed"    // logs server与agent的tls或websocket握手失败
+	ErrCodePermissionDenied = "permission_denied"   // 查询未通过权限检查，仅http查询接口使用
 )
 
+// 多主机查询的目标主机，MachineUUIDs与BatchID对应的主机取并集
+type HostSelector struct {
+	MachineUUIDs []string `json:"uuids"`
+	BatchID      string   `json:"batch_id"`
+}
+
+// 多主机查询已建立连接的主机，日志条目中以host、uuid字段标识所属主机
+type HostInfo struct {
+	UUID string `json:"uuid"`
+	IP   string `json:"ip"`
+	// agent不可达，查询结果来自logs server归档
+	Archived bool `json:"archived,omitempty"`
+}
+
+// 单主机查询时ConnectedMsg的data，表示agent不可达，查询结果来自logs server归档
+const ArchiveSource = "archive"
+
+// agent上传至logs server归档的一批journal日志，entries为journalctl --output=json的输出
+type ArchiveBatch struct {
+	// 上一批最后一条日志的游标，与logs server记录的不一致时拒绝，agent从logs server记录的游标重新上传
+	After   string            `json:"after"`
+	Entries []json.RawMessage `json:"entries"`
+}
+
+// logs server已归档的最后一条日志的游标，为空时agent从journal的第一条日志开始上传
+type ArchiveCursor struct {
+	Cursor string `json:"cursor"`
+}
+
 type StdoutDataType int
 
 type StdoutData struct {
 	Type StdoutDataType `json:"type"`
 	Data interface{}    `json:"data"`
+	// 实时查询：已发送的最后一条日志的游标，重连时作为joptions.after_cursor
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // shell命令stdout数据类型
 const (
 	LogEntryData StdoutDataType = iota
 	UnitData
+	BootData
+	EntryDetailData
+	ContextEntryData
+	TimelineData
+	ExportData
+	AlertData
 )
 
 type PageData struct {
 	Total int                      `json:"total"`
 	Hits  []map[string]interface{} `json:"hits"`
+	// Total为估算值，总数统计完成后的分页结果中返回准确值
+	Estimated bool `json:"estimated,omitempty"`
+	// 当前页第一条和最后一条日志的游标，用于向前、向后翻页
+	FirstCursor string `json:"first_cursor,omitempty"`
+	LastCursor  string `json:"last_cursor,omitempty"`
+	// 分页方向上是否还有更多日志
+	More bool `json:"more"`
+}
+
+// 与journalctl --list-boots一致，时间戳单位为毫秒
+type BootInfo struct {
+	Index          int    `json:"index"`
+	BootID         string `json:"boot_id"`
+	FirstTimestamp int64  `json:"first_timestamp"`
+	LastTimestamp  int64  `json:"last_timestamp"`
+}
+
+// 上下文查询结果，Anchor为cursor对应日志在Hits中的下标，不存在时为-1
+type EntryContext struct {
+	Anchor int                      `json:"anchor"`
+	Hits   []map[string]interface{} `json:"hits"`
+}
+
+// 日志时间轴，时间戳及时间段宽度单位均为毫秒
+type Timeline struct {
+	Since    int64            `json:"since"`
+	Until    int64            `json:"until"`
+	Interval int64            `json:"interval"`
+	Buckets  []TimelineBucket `json:"buckets"`
+}
+
+type TimelineBucket struct {
+	Timestamp int64 `json:"timestamp"`
+	Count     int   `json:"count"`
+	// 按unit或priority分组的条数
+	Groups map[string]int `json:"groups,omitempty"`
 }
@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 09:12:05 2026 +0800
+ */
+package public
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prometheus文本格式的Content-Type
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// 代理延迟等以秒为单位的直方图的默认分桶
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+/*
+以Prometheus文本格式输出的指标，在包初始化时通过NewCounterVec等创建并注册
+
+同一进程内指标名称不能重复
+*/
+type metricCollector interface {
+	metricName() string
+	write(_w *bufio.Writer)
+}
+
+var (
+	metricsMutex sync.Mutex
+	collectors   = map[string]metricCollector{}
+	startTime    = time.Now()
+)
+
+func registerMetric(_c metricCollector) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	if _, ok := collectors[_c.metricName()]; ok {
+		panic("duplicate metric: " + _c.metricName())
+	}
+	collectors[_c.metricName()] = _c
+}
+
+// 一组标签值相同的样本
+type metricSeries struct {
+	labelValues []string
+	value       float64
+	// 直方图：各分桶的计数（非累计）、总和及总数
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// counter、gauge、histogram共用的按标签值保存样本的结构
+type metricVec struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*metricSeries
+}
+
+func newMetricVec(_name, _help, _kind string, _buckets []float64, _labels []string) *metricVec {
+	v := &metricVec{
+		name:    _name,
+		help:    _help,
+		kind:    _kind,
+		labels:  _labels,
+		buckets: _buckets,
+		series:  map[string]*metricSeries{},
+	}
+	// 没有标签的指标在首次更新前输出0
+	if len(_labels) == 0 {
+		v.get(nil)
+	}
+	registerMetric(v)
+	return v
+}
+
+func (v *metricVec) metricName() string {
+	return v.name
+}
+
+// 调用方需持有mutex
+func (v *metricVec) get(_label_values []string) *metricSeries {
+	if len(_label_values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", v.name, len(v.labels), len(_label_values)))
+	}
+	key := strings.Join(_label_values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: append([]string{}, _label_values...)}
+		if v.buckets != nil {
+			s.buckets = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *metricVec) write(_w *bufio.Writer) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	fmt.Fprintf(_w, "# HELP %s %s\n", v.name, escapeMetricHelp(v.help))
+	fmt.Fprintf(_w, "# TYPE %s %s\n", v.name, v.kind)
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		if v.kind != "histogram" {
+			writeSample(_w, v.name, v.labels, s.labelValues, "", s.value)
+			continue
+		}
+		cumulative := uint64(0)
+		for i, bound := range v.buckets {
+			cumulative += s.buckets[i]
+			writeSample(_w, v.name+"_bucket", v.labels, s.labelValues, formatMetricValue(bound), float64(cumulative))
+		}
+		writeSample(_w, v.name+"_bucket", v.labels, s.labelValues, "+Inf", float64(s.count))
+		writeSample(_w, v.name+"_sum", v.labels, s.labelValues, "", s.sum)
+		writeSample(_w, v.name+"_count", v.labels, s.labelValues, "", float64(s.count))
+	}
+}
+
+// 只增不减的计数
+type CounterVec struct {
+	vec *metricVec
+}
+
+func NewCounterVec(_name, _help string, _labels ...string) *CounterVec {
+	return &CounterVec{vec: newMetricVec(_name, _help, "counter", nil, _labels)}
+}
+
+func (c *CounterVec) Inc(_label_values ...string) {
+	c.Add(1, _label_values...)
+}
+
+// _delta小于0时忽略
+func (c *CounterVec) Add(_delta float64, _label_values ...string) {
+	if _delta < 0 {
+		return
+	}
+	c.vec.mutex.Lock()
+	defer c.vec.mutex.Unlock()
+	c.vec.get(_label_values).value += _delta
+}
+
+// 可增可减的当前值
+type GaugeVec struct {
+	vec *metricVec
+}
+
+func NewGaugeVec(_name, _help string, _labels ...string) *GaugeVec {
+	return &GaugeVec{vec: newMetricVec(_name, _help, "gauge", nil, _labels)}
+}
+
+func (g *GaugeVec) Inc(_label_values ...string) {
+	g.Add(1, _label_values...)
+}
+
+func (g *GaugeVec) Dec(_label_values ...string) {
+	g.Add(-1, _label_values...)
+}
+
+func (g *GaugeVec) Add(_delta float64, _label_values ...string) {
+	g.vec.mutex.Lock()
+	defer g.vec.mutex.Unlock()
+	g.vec.get(_label_values).value += _delta
+}
+
+func (g *GaugeVec) Set(_value float64, _label_values ...string) {
+	g.vec.mutex.Lock()
+	defer g.vec.mutex.Unlock()
+	g.vec.get(_label_values).value = _value
+}
+
+// 按分桶统计观测值的分布，_buckets为升序的分桶上限
+type HistogramVec struct {
+	vec *metricVec
+}
+
+func NewHistogramVec(_name, _help string, _buckets []float64, _labels ...string) *HistogramVec {
+	return &HistogramVec{vec: newMetricVec(_name, _help, "histogram", _buckets, _labels)}
+}
+
+func (h *HistogramVec) Observe(_value float64, _label_values ...string) {
+	h.vec.mutex.Lock()
+	defer h.vec.mutex.Unlock()
+	s := h.vec.get(_label_values)
+	if i := sort.SearchFloat64s(h.vec.buckets, _value); i < len(s.buckets) {
+		s.buckets[i]++
+	}
+	s.sum += _value
+	s.count++
+}
+
+// 输出时调用fn获取当前值的gauge
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func NewGaugeFunc(_name, _help string, _fn func() float64) {
+	registerMetric(&gaugeFunc{name: _name, help: _help, fn: _fn})
+}
+
+func (g *gaugeFunc) metricName() string {
+	return g.name
+}
+
+func (g *gaugeFunc) write(_w *bufio.Writer) {
+	fmt.Fprintf(_w, "# HELP %s %s\n", g.name, escapeMetricHelp(g.help))
+	fmt.Fprintf(_w, "# TYPE %s gauge\n", g.name)
+	writeSample(_w, g.name, nil, nil, "", g.fn())
+}
+
+func init() {
+	NewGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+		return float64(startTime.UnixNano()) / 1e9
+	})
+	NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	NewGaugeFunc("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", func() float64 {
+		stats := runtime.MemStats{}
+		runtime.ReadMemStats(&stats)
+		return float64(stats.HeapAlloc)
+	})
+}
+
+/*
+以Prometheus文本格式输出全部指标
+
+_token不为空时要求请求携带Authorization: Bearer <_token>
+*/
+func MetricsHandler(_token string) http.Handler {
+	return http.HandlerFunc(func(_w http.ResponseWriter, _r *http.Request) {
+		if _token != "" && subtle.ConstantTimeCompare([]byte(_r.Header.Get("Authorization")), []byte("Bearer "+_token)) != 1 {
+			http.Error(_w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		metricsMutex.Lock()
+		names := make([]string, 0, len(collectors))
+		for name := range collectors {
+			names = append(names, name)
+		}
+		metricsMutex.Unlock()
+		sort.Strings(names)
+
+		_w.Header().Set("Content-Type", MetricsContentType)
+		w := bufio.NewWriter(_w)
+		for _, name := range names {
+			metricsMutex.Lock()
+			c := collectors[name]
+			metricsMutex.Unlock()
+			c.write(w)
+		}
+		w.Flush()
+	})
+}
+
+func writeSample(_w *bufio.Writer, _name string, _labels, _label_values []string, _le string, _value float64) {
+	_w.WriteString(_name)
+	if len(_labels) > 0 || _le != "" {
+		_w.WriteByte('{')
+		for i, label := range _labels {
+			if i > 0 {
+				_w.WriteByte(',')
+			}
+			fmt.Fprintf(_w, "%s=\"%s\"", label, escapeLabelValue(_label_values[i]))
+		}
+		if _le != "" {
+			if len(_labels) > 0 {
+				_w.WriteByte(',')
+			}
+			fmt.Fprintf(_w, "le=\"%s\"", _le)
+		}
+		_w.WriteByte('}')
+	}
+	_w.WriteByte(' ')
+	_w.WriteString(formatMetricValue(_value))
+	_w.WriteByte('\n')
+}
+
+func formatMetricValue(_value float64) string {
+	switch {
+	case math.IsInf(_value, 1):
+		return "+Inf"
+	case math.IsInf(_value, -1):
+		return "-Inf"
+	case math.IsNaN(_value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(_value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeMetricHelp(_help string) string {
+	return helpEscaper.Replace(_help)
+}
+
+func escapeLabelValue(_value string) string {
+	return labelEscaper.Replace(_value)
+}
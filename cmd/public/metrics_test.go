@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 17:34:48 2026 +0800
+ */
+package public
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 输出一个指标的文本格式，不含其他已注册的指标
+func writeMetric(_c metricCollector) []string {
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	_c.write(w)
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestCounterLabelEscaping(t *testing.T) {
+	c := NewCounterVec("test_escape_total", "Help with \\ and\nnewline.", "path", "source")
+	c.Inc(`C:\logs`, "a\"b\nc")
+	c.Add(2, "plain", "x")
+	c.Add(-1, "plain", "x")
+
+	want := []string{
+		`# HELP test_escape_total Help with \\ and\nnewline.`,
+		`# TYPE test_escape_total counter`,
+		`test_escape_total{path="C:\\logs",source="a\"b\nc"} 1`,
+		`test_escape_total{path="plain",source="x"} 2`,
+	}
+	if got := writeMetric(c.vec); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 0.5, 1}, "host")
+	// 等于上限的观测值计入该分桶，超过最大上限的只计入+Inf
+	for _, v := range []float64{0.05, 0.1, 0.3, 1, 2, 7} {
+		h.Observe(v, "a")
+	}
+
+	want := []string{
+		`# HELP test_latency_seconds Latency.`,
+		`# TYPE test_latency_seconds histogram`,
+		`test_latency_seconds_bucket{host="a",le="0.1"} 2`,
+		`test_latency_seconds_bucket{host="a",le="0.5"} 3`,
+		`test_latency_seconds_bucket{host="a",le="1"} 4`,
+		`test_latency_seconds_bucket{host="a",le="+Inf"} 6`,
+		`test_latency_seconds_sum{host="a"} 10.45`,
+		`test_latency_seconds_count{host="a"} 6`,
+	}
+	if got := writeMetric(h.vec); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUnlabeledMetrics(t *testing.T) {
+	g := NewGaugeVec("test_sessions", "Sessions.")
+	want := []string{"# HELP test_sessions Sessions.", "# TYPE test_sessions gauge", "test_sessions 0"}
+	if got := writeMetric(g.vec); !reflect.DeepEqual(got, want) {
+		t.Errorf("before update: got %v", got)
+	}
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	if got := writeMetric(g.vec); got[2] != "test_sessions 1" {
+		t.Errorf("after update: got %q", got[2])
+	}
+
+	h := NewHistogramVec("test_empty_seconds", "Empty.", []float64{1})
+	want = []string{
+		"# HELP test_empty_seconds Empty.",
+		"# TYPE test_empty_seconds histogram",
+		`test_empty_seconds_bucket{le="1"} 0`,
+		`test_empty_seconds_bucket{le="+Inf"} 0`,
+		"test_empty_seconds_sum 0",
+		"test_empty_seconds_count 0",
+	}
+	if got := writeMetric(h.vec); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMetricsHandlerToken(t *testing.T) {
+	tests := []struct {
+		token  string
+		header string
+		status int
+	}{
+		{"", "", http.StatusOK},
+		{"s3cret", "Bearer s3cret", http.StatusOK},
+		{"s3cret", "", http.StatusUnauthorized},
+		{"s3cret", "Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", "s3cret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		MetricsHandler(tt.token).ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("token %q header %q: got status %d, want %d", tt.token, tt.header, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK {
+			if ct := w.Header().Get("Content-Type"); ct != MetricsContentType {
+				t.Errorf("got Content-Type %q", ct)
+			}
+			if !strings.Contains(w.Body.String(), "# TYPE go_goroutines gauge\n") {
+				t.Errorf("registered metrics missing from output")
+			}
+		}
+	}
+}
@@ -0,0 +1,570 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 16:20:37 2026 +0800
+ */
+package public
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+日志查询语句，如：unit:nginx AND priority<=warning AND msg~"timeout" AND NOT _COMM=curl
+
+语法：
+
+	query := or
+	or    := and ("OR" and)*
+	and   := unary (["AND"] unary)*     相邻的条件之间省略AND时同样为AND
+	unary := "NOT" unary | "(" or ")" | term
+	term  := FIELD OP VALUE
+
+OP：":"与"="为等于，"!="为不等于，"~"、"!~"为正则表达式匹配、不匹配，"<"、"<="、">"、">="按数值比较
+
+FIELD为journal字段名（如_COMM、_PID）或别名：unit、priority、msg（message）、identifier、transport、pid、comm、host
+
+VALUE为不含空白及括号的字符串，或以双引号括起、支持\"和\\转义的字符串；priority的值可以为等级名称或0-7
+
+关键字AND、OR、NOT不区分大小写
+*/
+type Query struct {
+	text string
+	root queryNode
+}
+
+// 查询语句语法错误，Position为出错位置（从1开始的字符序号）
+type QuerySyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("query syntax error at position %d: %s", e.Position, e.Message)
+}
+
+// 查询语句中可以使用的字段别名
+var queryFieldAliases = map[string]string{
+	"unit":       "_SYSTEMD_UNIT",
+	"priority":   "PRIORITY",
+	"msg":        "MESSAGE",
+	"message":    "MESSAGE",
+	"identifier": "SYSLOG_IDENTIFIER",
+	"transport":  "_TRANSPORT",
+	"pid":        "_PID",
+	"comm":       "_COMM",
+	"host":       "_HOSTNAME",
+}
+
+var (
+	queryFieldRegexp = regexp.MustCompile(`^[A-Z_][A-Z0-9_]{0,63}$`)
+
+	queryPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// 下推至journalctl的字段匹配条件总数上限，与agent允许的字段匹配条件数一致
+const maxQueryFieldMatches = 64
+
+// 解析查询语句，语法错误时返回*QuerySyntaxError
+func ParseQuery(_text string) (*Query, error) {
+	p := &queryParser{input: _text}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf(p.pos, "empty query")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		if p.input[p.pos] == ')' {
+			return nil, p.errorf(p.pos, "unexpected \")\"")
+		}
+		return nil, p.errorf(p.pos, "unexpected %q", p.input[p.pos:])
+	}
+	return &Query{text: _text, root: root}, nil
+}
+
+// 校验查询条件中的用户及查询语句
+func CheckQuery(_options *JournalctlOptions) error {
+	if _options == nil {
+		return nil
+	}
+	if _, err := UserUID(_options.User); err != nil {
+		return err
+	}
+	if _options.Query == "" {
+		return nil
+	}
+	_, err := ParseQuery(_options.Query)
+	return err
+}
+
+// 查询条件中user（用户名:UID）的UID，未设置user时返回空字符串
+func UserUID(_user string) (string, error) {
+	if _user == "" {
+		return "", nil
+	}
+	user_split := strings.Split(_user, ":")
+	if len(user_split) != 2 || user_split[1] == "" {
+		return "", fmt.Errorf("user field in options is invalid: %s", _user)
+	}
+	return user_split[1], nil
+}
+
+func (q *Query) String() string {
+	return q.text
+}
+
+/*
+判断日志是否满足查询条件
+
+_field返回日志中字段的值，字段不存在时返回false
+*/
+func (q *Query) Match(_field func(string) (string, bool)) bool {
+	return q.root.match(_field)
+}
+
+/*
+可以交由journalctl过滤的字段匹配条件，语义与JournalctlOptions.Matches一致
+
+结果是查询条件的必要条件，满足的日志仍需通过Match判断；无法下推时返回nil
+*/
+func (q *Query) FieldMatches() [][]FieldMatch {
+	matches, _ := q.root.pushdown()
+	return matches
+}
+
+type queryNode interface {
+	match(_field func(string) (string, bool)) bool
+	// 返回的匹配条件为nil时不限制
+	pushdown() ([][]FieldMatch, bool)
+}
+
+type queryAnd struct {
+	left, right queryNode
+}
+
+func (n *queryAnd) match(_field func(string) (string, bool)) bool {
+	return n.left.match(_field) && n.right.match(_field)
+}
+
+// 两侧均可下推时按组做笛卡尔积，超出上限时只保留条件较少的一侧
+func (n *queryAnd) pushdown() ([][]FieldMatch, bool) {
+	left, lok := n.left.pushdown()
+	right, rok := n.right.pushdown()
+	switch {
+	case !lok:
+		return right, rok
+	case !rok:
+		return left, lok
+	}
+	matches := [][]FieldMatch{}
+	for _, l := range left {
+		for _, r := range right {
+			group := append(append([]FieldMatch{}, l...), r...)
+			matches = append(matches, group)
+		}
+	}
+	if countFieldMatches(matches) <= maxQueryFieldMatches {
+		return matches, true
+	}
+	if countFieldMatches(left) <= countFieldMatches(right) {
+		return left, true
+	}
+	return right, true
+}
+
+type queryOr struct {
+	left, right queryNode
+}
+
+func (n *queryOr) match(_field func(string) (string, bool)) bool {
+	return n.left.match(_field) || n.right.match(_field)
+}
+
+func (n *queryOr) pushdown() ([][]FieldMatch, bool) {
+	left, lok := n.left.pushdown()
+	right, rok := n.right.pushdown()
+	if !lok || !rok {
+		return nil, false
+	}
+	matches := append(append([][]FieldMatch{}, left...), right...)
+	if countFieldMatches(matches) > maxQueryFieldMatches {
+		return nil, false
+	}
+	return matches, true
+}
+
+type queryNot struct {
+	node queryNode
+}
+
+func (n *queryNot) match(_field func(string) (string, bool)) bool {
+	return !n.node.match(_field)
+}
+
+func (n *queryNot) pushdown() ([][]FieldMatch, bool) {
+	return nil, false
+}
+
+// 比较运算符
+const (
+	queryOpEqual        = "="
+	queryOpNotEqual     = "!="
+	queryOpRegexp       = "~"
+	queryOpNotRegexp    = "!~"
+	queryOpLess         = "<"
+	queryOpLessEqual    = "<="
+	queryOpGreater      = ">"
+	queryOpGreaterEqual = ">="
+)
+
+type queryTerm struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+	// 数值比较的右侧
+	number float64
+}
+
+func (t *queryTerm) match(_field func(string) (string, bool)) bool {
+	value, ok := _field(t.field)
+	switch t.op {
+	case queryOpEqual:
+		return ok && value == t.value
+	case queryOpNotEqual:
+		return !ok || value != t.value
+	case queryOpRegexp:
+		return ok && t.re.MatchString(value)
+	case queryOpNotRegexp:
+		return !ok || !t.re.MatchString(value)
+	}
+	if !ok {
+		return false
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false
+	}
+	switch t.op {
+	case queryOpLess:
+		return number < t.number
+	case queryOpLessEqual:
+		return number <= t.number
+	case queryOpGreater:
+		return number > t.number
+	default:
+		return number >= t.number
+	}
+}
+
+// 等于及PRIORITY的比较可以下推，同一字段的多个值在组内为OR
+func (t *queryTerm) pushdown() ([][]FieldMatch, bool) {
+	switch {
+	case t.op == queryOpEqual:
+		return [][]FieldMatch{{{Field: t.field, Value: t.value}}}, true
+	case t.field == "PRIORITY" && t.op != queryOpNotEqual && t.op != queryOpRegexp && t.op != queryOpNotRegexp:
+		group := []FieldMatch{}
+		for p := range queryPriorities {
+			if t.match(func(string) (string, bool) { return strconv.Itoa(p), true }) {
+				group = append(group, FieldMatch{Field: "PRIORITY", Value: strconv.Itoa(p)})
+			}
+		}
+		// 不可能满足的条件仍需交由journalctl过滤，以无法匹配的值代替
+		if len(group) == 0 {
+			group = append(group, FieldMatch{Field: "PRIORITY", Value: "-1"})
+		}
+		return [][]FieldMatch{group}, true
+	}
+	return nil, false
+}
+
+func countFieldMatches(_matches [][]FieldMatch) int {
+	count := 0
+	for _, group := range _matches {
+		count += len(group)
+	}
+	return count
+}
+
+type queryParser struct {
+	input string
+	pos   int
+}
+
+func (p *queryParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+// _offset为字节偏移，错误中的位置为字符序号
+func (p *queryParser) errorf(_offset int, _format string, _args ...interface{}) error {
+	return &QuerySyntaxError{
+		Position: utf8.RuneCountInString(p.input[:_offset]) + 1,
+		Message:  fmt.Sprintf(_format, _args...),
+	}
+}
+
+// _offset处的完整字符，用于错误信息
+func (p *queryParser) runeAt(_offset int) string {
+	_, size := utf8.DecodeRuneInString(p.input[_offset:])
+	return p.input[_offset : _offset+size]
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.eof() && isQuerySpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func isQuerySpace(_c byte) bool {
+	return _c == ' ' || _c == '\t' || _c == '\n' || _c == '\r'
+}
+
+func isQueryWordChar(_c byte) bool {
+	return _c == '_' || _c >= '0' && _c <= '9' || _c >= 'a' && _c <= 'z' || _c >= 'A' && _c <= 'Z'
+}
+
+// 当前位置为关键字_keyword时跳过并返回true，关键字后须为空白、括号或结尾
+func (p *queryParser) keyword(_keyword string) bool {
+	end := p.pos + len(_keyword)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], _keyword) {
+		return false
+	}
+	if end < len(p.input) && isQueryWordChar(p.input[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.keyword("OR") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left: left, right: right}
+	}
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.eof() || p.input[p.pos] == ')' {
+			return left, nil
+		}
+		start := p.pos
+		if p.keyword("OR") {
+			p.pos = start
+			return left, nil
+		}
+		p.keyword("AND")
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left: left, right: right}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf(p.pos, "unexpected end of query, expected a condition")
+	}
+	if p.keyword("NOT") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{node: node}, nil
+	}
+	if p.input[p.pos] == '(' {
+		open := p.pos
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.eof() || p.input[p.pos] != ')' {
+			return nil, p.errorf(open, "unclosed \"(\"")
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	start := p.pos
+	for !p.eof() && isQueryWordChar(p.input[p.pos]) {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	if name == "" {
+		return nil, p.errorf(start, "expected a field name, got %q", p.runeAt(start))
+	}
+	for _, keyword := range []string{"AND", "OR", "NOT"} {
+		if strings.EqualFold(name, keyword) {
+			return nil, p.errorf(start, "unexpected %s, expected a condition", keyword)
+		}
+	}
+	field, err := p.resolveField(start, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	opStart := p.pos
+	op := ""
+	for _, candidate := range []string{"!=", "!~", "<=", ">=", ":", "=", "~", "<", ">"} {
+		if strings.HasPrefix(p.input[p.pos:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		if p.eof() {
+			return nil, p.errorf(p.pos, "unexpected end of query, expected an operator after %s", name)
+		}
+		return nil, p.errorf(p.pos, "expected an operator (: = != ~ !~ < <= > >=) after %s", name)
+	}
+	p.pos += len(op)
+	if op == ":" {
+		op = queryOpEqual
+	}
+
+	p.skipSpace()
+	valueStart := p.pos
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return p.newTerm(field, op, value, opStart, valueStart)
+}
+
+func (p *queryParser) resolveField(_offset int, _name string) (string, error) {
+	if field, ok := queryFieldAliases[strings.ToLower(_name)]; ok {
+		return field, nil
+	}
+	if !queryFieldRegexp.MatchString(_name) {
+		return "", p.errorf(_offset, "unknown field %s, use a journal field name such as _COMM or one of unit, priority, msg, identifier, transport, pid, comm, host", _name)
+	}
+	if strings.HasPrefix(_name, "__") {
+		return "", p.errorf(_offset, "journal field %s cannot be matched", _name)
+	}
+	return _name, nil
+}
+
+// 带引号的值支持\"和\\转义，不带引号的值到空白或括号为止
+func (p *queryParser) parseValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf(p.pos, "unexpected end of query, expected a value")
+	}
+	if p.input[p.pos] != '"' {
+		start := p.pos
+		for !p.eof() && !isQuerySpace(p.input[p.pos]) && p.input[p.pos] != '(' && p.input[p.pos] != ')' && p.input[p.pos] != '"' {
+			p.pos++
+		}
+		if p.pos == start {
+			return "", p.errorf(start, "expected a value, got %q", p.runeAt(start))
+		}
+		return p.input[start:p.pos], nil
+	}
+
+	open := p.pos
+	p.pos++
+	value := strings.Builder{}
+	for !p.eof() {
+		c := p.input[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return value.String(), nil
+		case '\\':
+			if p.pos+1 < len(p.input) && (p.input[p.pos+1] == '"' || p.input[p.pos+1] == '\\') {
+				value.WriteByte(p.input[p.pos+1])
+				p.pos += 2
+				continue
+			}
+		}
+		value.WriteByte(c)
+		p.pos++
+	}
+	return "", p.errorf(open, "unterminated string")
+}
+
+func (p *queryParser) newTerm(_field, _op, _value string, _op_offset, _value_offset int) (queryNode, error) {
+	t := &queryTerm{field: _field, op: _op, value: _value}
+	if strings.ContainsRune(_value, '\n') {
+		return nil, p.errorf(_value_offset, "value contains newline")
+	}
+	switch _op {
+	case queryOpRegexp, queryOpNotRegexp:
+		re, err := regexp.Compile(_value)
+		if err != nil {
+			return nil, p.errorf(_value_offset, "invalid regular expression: %s", err.Error())
+		}
+		t.re = re
+		return t, nil
+	}
+
+	switch _field {
+	case "PRIORITY":
+		priority, ok := parseQueryPriority(_value)
+		if !ok {
+			return nil, p.errorf(_value_offset, "invalid priority %q, expected one of %s or 0-7", _value, strings.Join(queryPriorities, ", "))
+		}
+		t.value = strconv.Itoa(priority)
+	case "_SYSTEMD_UNIT":
+		// 与journalctl --unit一致，未指定类型时为service
+		if !strings.Contains(_value, ".") {
+			t.value = _value + ".service"
+		}
+	}
+
+	switch _op {
+	case queryOpEqual, queryOpNotEqual:
+		return t, nil
+	}
+	number, err := strconv.ParseFloat(t.value, 64)
+	if err != nil {
+		return nil, p.errorf(_op_offset, "operator %s requires a numeric value, got %q", _op, _value)
+	}
+	t.number = number
+	return t, nil
+}
+
+func parseQueryPriority(_value string) (int, bool) {
+	for i, name := range queryPriorities {
+		if strings.EqualFold(_value, name) {
+			return i, true
+		}
+	}
+	if p, err := strconv.Atoi(_value); err == nil && p >= 0 && p < len(queryPriorities) {
+		return p, true
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 18:10:37 2026 +0800
+ */
+package public
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestQueryMatch(t *testing.T) {
+	entry := map[string]string{
+		"_SYSTEMD_UNIT": "nginx.service",
+		"PRIORITY":      "4",
+		"MESSAGE":       `upstream "timeout"`,
+		"_COMM":         "nginx",
+		"_PID":          "123",
+	}
+	field := func(_name string) (string, bool) {
+		value, ok := entry[_name]
+		return value, ok
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"unit:nginx", true},
+		{"unit:nginx.service", true},
+		{"unit:nginx AND priority<=warning", true},
+		{"priority<warning", false},
+		{"priority:4", true},
+		{`msg~"time(out)?"`, true},
+		{"msg!~timeout", false},
+		{`msg="upstream \"timeout\""`, true},
+		{"NOT _COMM=curl", true},
+		{"_COMM=curl OR pid>100", true},
+		{"(_COMM=curl OR _COMM=nginx) priority:4", true},
+		{"_COMM=curl OR _COMM=nginx AND priority:0", false},
+		{"unit:nginx and not comm:curl", true},
+		{"MISSING!=x", true},
+		{"MISSING=x", false},
+		{"pid>122 pid<124", true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: %s", tt.query, err)
+			continue
+		}
+		if got := q.Match(field); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQuerySyntaxError(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+		message  string
+	}{
+		{"", 1, "empty query"},
+		{"unit:", 6, "unexpected end of query, expected a value"},
+		{"unit nginx", 6, "expected an operator"},
+		{"(unit:nginx", 1, `unclosed "("`},
+		{"unit:nginx)", 11, `unexpected ")"`},
+		{"unit:nginx OR", 14, "unexpected end of query, expected a condition"},
+		{"and unit:nginx", 1, "unexpected AND"},
+		{"priority:loud", 10, "invalid priority"},
+		{"pid>abc", 4, "requires a numeric value"},
+		{`msg~"("`, 5, "invalid regular expression"},
+		{`msg:"abc`, 5, "unterminated string"},
+		{"msg=(x)", 5, `expected a value, got "("`},
+		{"__CURSOR=x", 1, "cannot be matched"},
+		{"foo:x", 1, "unknown field foo"},
+		// 位置为字符序号，错误信息中为完整的字符
+		{"日志:x", 1, `expected a field name, got "日"`},
+		{`msg:"日志" AND ünit:x`, 14, `expected a field name, got "ü"`},
+		{"msg:日志 OR", 10, "unexpected end of query, expected a condition"},
+		{"msg:日志)", 7, `unexpected ")"`},
+		{"\xffx:1", 1, `expected a field name, got "\xff"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		syntax_err := &QuerySyntaxError{}
+		if !errors.As(err, &syntax_err) {
+			t.Errorf("%q: got error %v, want a syntax error", tt.query, err)
+			continue
+		}
+		if syntax_err.Position != tt.position || !strings.Contains(syntax_err.Message, tt.message) {
+			t.Errorf("%q: got %q at %d, want %q at %d", tt.query, syntax_err.Message, syntax_err.Position, tt.message, tt.position)
+		}
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	tests := []struct {
+		options *JournalctlOptions
+		ok      bool
+	}{
+		{nil, true},
+		{&JournalctlOptions{}, true},
+		{&JournalctlOptions{User: "root:0", Query: "unit:nginx"}, true},
+		{&JournalctlOptions{User: "root"}, false},
+		{&JournalctlOptions{User: "root:"}, false},
+		{&JournalctlOptions{User: ":0:1"}, false},
+		{&JournalctlOptions{Query: "unit:"}, false},
+	}
+	for _, tt := range tests {
+		if err := CheckQuery(tt.options); (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v", tt.options, err)
+		}
+	}
+	if uid, err := UserUID("admin:1000"); err != nil || uid != "1000" {
+		t.Errorf("got uid %q, error %v", uid, err)
+	}
+}
+
+// n个同一字段的条件以OR连接
+func orQuery(_field string, _n int) string {
+	terms := []string{}
+	for i := 0; i < _n; i++ {
+		terms = append(terms, _field+"="+strconv.Itoa(i))
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+func orGroups(_field string, _n int) [][]FieldMatch {
+	groups := [][]FieldMatch{}
+	for i := 0; i < _n; i++ {
+		groups = append(groups, []FieldMatch{{Field: _field, Value: strconv.Itoa(i)}})
+	}
+	return groups
+}
+
+func andGroups(_left, _right [][]FieldMatch) [][]FieldMatch {
+	groups := [][]FieldMatch{}
+	for _, l := range _left {
+		for _, r := range _right {
+			groups = append(groups, append(append([]FieldMatch{}, l...), r...))
+		}
+	}
+	return groups
+}
+
+func TestQueryFieldMatches(t *testing.T) {
+	unit := func(_name string) FieldMatch { return FieldMatch{Field: "_SYSTEMD_UNIT", Value: _name + ".service"} }
+	comm := func(_name string) FieldMatch { return FieldMatch{Field: "_COMM", Value: _name} }
+	priority := func(_p int) FieldMatch { return FieldMatch{Field: "PRIORITY", Value: strconv.Itoa(_p)} }
+
+	tests := []struct {
+		query string
+		want  [][]FieldMatch
+	}{
+		{"unit:a", [][]FieldMatch{{unit("a")}}},
+		{"unit:a OR unit:b", [][]FieldMatch{{unit("a")}, {unit("b")}}},
+		{"unit:a _COMM=x", [][]FieldMatch{{unit("a"), comm("x")}}},
+		{"(unit:a OR unit:b) AND (_COMM=x OR _COMM=y)", [][]FieldMatch{
+			{unit("a"), comm("x")}, {unit("a"), comm("y")}, {unit("b"), comm("x")}, {unit("b"), comm("y")},
+		}},
+		// PRIORITY的比较展开为满足条件的等级
+		{"priority<=err", [][]FieldMatch{{priority(0), priority(1), priority(2), priority(3)}}},
+		{"priority>notice AND unit:a", [][]FieldMatch{{priority(6), priority(7), unit("a")}}},
+		{"priority>debug", [][]FieldMatch{{{Field: "PRIORITY", Value: "-1"}}}},
+		{"priority!=err", nil},
+		// NOT、不等于及正则表达式不下推，AND只保留可以下推的一侧
+		{"NOT unit:a", nil},
+		{"unit:a AND NOT _COMM=x", [][]FieldMatch{{unit("a")}}},
+		{"NOT _COMM=x AND msg~err", nil},
+		{"unit:a OR NOT _COMM=x", nil},
+		{"unit:a OR msg~err", nil},
+		{"_COMM!=x", nil},
+		// 笛卡尔积不超过上限：4×8组，每组2个条件
+		{orQuery("_PID", 4) + " AND " + orQuery("_UID", 8), andGroups(orGroups("_PID", 4), orGroups("_UID", 8))},
+		// 超出上限时保留条件较少的一侧
+		{orQuery("_PID", 10) + " AND " + orQuery("_UID", 9), orGroups("_UID", 9)},
+		{orQuery("_PID", 9) + " AND " + orQuery("_UID", 10), orGroups("_PID", 9)},
+		// OR超出上限时不下推
+		{orQuery("_PID", maxQueryFieldMatches+1), nil},
+		{orQuery("_PID", maxQueryFieldMatches), orGroups("_PID", maxQueryFieldMatches)},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.query, err)
+		}
+		if got := q.FieldMatches(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
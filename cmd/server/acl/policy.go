@@ -0,0 +1,366 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 23:20:48 2026 +0800
+ */
+package acl
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"github.com/pkg/errors"
+)
+
+// 与journalctl --priority一致的优先级名称
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// 不带后缀的服务单元名称由journalctl补全为.service
+var unitSuffixes = []string{".service", ".socket", ".target", ".device", ".mount", ".automount", ".swap", ".timer", ".path", ".slice", ".scope"}
+
+// 检查权限控制配置
+func Init() error {
+	if !Enabled() {
+		return nil
+	}
+	aclconf := conf.Global_Config.ACL
+	if aclconf.JwtSecret == "" {
+		return errors.New("acl jwt_secret is required when acl is enabled")
+	}
+	for i, p := range aclconf.Policies {
+		if len(p.Users) == 0 {
+			return errors.Errorf("acl policy %d: users is empty", i)
+		}
+		if p.Priority != "" {
+			if _, err := parsePriority(p.Priority); err != nil {
+				return errors.Errorf("acl policy %d: %s", i, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// 用户对单个主机生效的策略
+type Grant struct {
+	User    string
+	Machine *common.MachineNode
+
+	policies []*conf.ACLPolicy
+}
+
+// 检查用户能否查看主机的日志，未开启权限控制时返回nil
+func Authorize(_user string, _machine *common.MachineNode) (*Grant, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	grant := &Grant{User: _user, Machine: _machine}
+	for _, p := range conf.Global_Config.ACL.Policies {
+		if matchUser(p, _user) && matchMachine(p, _machine) {
+			grant.policies = append(grant.policies, p)
+		}
+	}
+	if len(grant.policies) == 0 {
+		return nil, errors.Errorf("permission denied: user %s may not read logs of %s", _user, _machine.IP)
+	}
+	return grant, nil
+}
+
+// 检查请求用户能否查询审计日志及管理告警规则，未开启权限控制时不限制
+func CheckAdmin(_r *http.Request) error {
+	if !Enabled() {
+		return nil
+	}
+	user, err := ResolveUser(_r)
+	if err != nil {
+		return err
+	}
+	if !contains(conf.Global_Config.ACL.Admins, user) {
+		return errors.Errorf("permission denied: user %s is not an administrator of logs plugin", user)
+	}
+	return nil
+}
+
+// 是否为权限检查失败的错误
+func IsPermissionDenied(_err error) bool {
+	return _err != nil && strings.HasPrefix(errors.Cause(_err).Error(), "permission denied")
+}
+
+// 根据agent地址查找机器
+func MachineByAddr(_addr string) (*common.MachineNode, error) {
+	ip, _, err := net.SplitHostPort(_addr)
+	if err != nil {
+		ip = _addr
+	}
+	machines, err := pluginclient.Global_Client.MachineList()
+	if err != nil {
+		return nil, errors.Errorf("fail to get machine list: %s", err.Error())
+	}
+	for _, m := range machines {
+		if m.IP == ip {
+			return m, nil
+		}
+	}
+	return nil, errors.Errorf("permission denied: unknown machine %s", ip)
+}
+
+// 根据UUID查找机器
+func MachineByUUID(_uuid string) (*common.MachineNode, error) {
+	machines, err := pluginclient.Global_Client.MachineList()
+	if err != nil {
+		return nil, errors.Errorf("fail to get machine list: %s", err.Error())
+	}
+	for _, m := range machines {
+		if m.UUID == _uuid {
+			return m, nil
+		}
+	}
+	return nil, errors.Errorf("permission denied: unknown machine %s", _uuid)
+}
+
+// 检查发往agent的请求，满足任意一条策略时允许，grant为nil时不限制
+func (g *Grant) Check(_jmsg *public.JMessage, _log_source string) error {
+	if g == nil {
+		return nil
+	}
+	var reason error
+	for _, p := range g.policies {
+		err := checkPolicy(p, _jmsg, _log_source)
+		if err == nil {
+			return nil
+		}
+		if reason == nil {
+			reason = err
+		}
+	}
+	return errors.Errorf("permission denied: %s", reason.Error())
+}
+
+func checkPolicy(_p *conf.ACLPolicy, _jmsg *public.JMessage, _log_source string) error {
+	switch _jmsg.Type {
+	case public.UpdateOptionsMsg, public.TimelineMsg, public.AlertRuleMsg:
+		// 查询条件为空的请求不合法，不交由agent处理
+		if _jmsg.JOptions == nil {
+			return errors.New("joptions is required")
+		}
+		if _log_source == "file" {
+			return checkFile(_p, _jmsg.JOptions.File)
+		}
+		return checkOptions(_p, _jmsg.JOptions)
+	case public.ExportMsg:
+		// agent导出时joptions为空等同于不限制查询条件
+		options := _jmsg.JOptions
+		if options == nil {
+			options = &public.JournalctlOptions{}
+		}
+		if _log_source == "file" {
+			return checkFile(_p, options.File)
+		}
+		return checkOptions(_p, options)
+	case public.EntryDetailMsg, public.ContextMsg:
+		// 单条日志详情及上下文不受查询条件限制
+		if restricted(_p) {
+			return errors.New("entry detail and context are not available when units, transports or priority are restricted")
+		}
+	}
+	return nil
+}
+
+// 策略是否限制了journal的查询范围
+func restricted(_p *conf.ACLPolicy) bool {
+	return len(_p.Units) > 0 || len(_p.Transports) > 0 || _p.Priority != ""
+}
+
+func checkOptions(_p *conf.ACLPolicy, _options *public.JournalctlOptions) error {
+	if len(_p.Units) > 0 {
+		units := []string{_options.Unit}
+		if _options.Unit == "" {
+			var ok bool
+			if units, ok = groupValues(_options.Matches, "_SYSTEMD_UNIT"); !ok {
+				return errors.Errorf("unit must be one of %v", _p.Units)
+			}
+		}
+		for _, unit := range units {
+			if !matchUnit(_p.Units, unit) {
+				return errors.Errorf("unit %s is not allowed", unit)
+			}
+		}
+	}
+
+	if len(_p.Transports) > 0 {
+		transports := []string{_options.Transport}
+		if _options.Transport == "" {
+			var ok bool
+			if transports, ok = groupValues(_options.Matches, "_TRANSPORT"); !ok {
+				return errors.Errorf("transport must be one of %v", _p.Transports)
+			}
+		}
+		for _, transport := range transports {
+			if !contains(_p.Transports, transport) {
+				return errors.Errorf("transport %s is not allowed", transport)
+			}
+		}
+	}
+
+	if _p.Priority != "" {
+		limit, err := parsePriority(_p.Priority)
+		if err != nil {
+			return err
+		}
+		priorities := []string{_options.Severity}
+		if _options.Severity == "" {
+			var ok bool
+			if priorities, ok = groupValues(_options.Matches, "PRIORITY"); !ok {
+				return errors.Errorf("priority must be specified, at most %s", _p.Priority)
+			}
+		}
+		for _, priority := range priorities {
+			upper, err := priorityUpperBound(priority)
+			if err != nil {
+				return err
+			}
+			if upper > limit {
+				return errors.Errorf("priority %s exceeds %s", priority, _p.Priority)
+			}
+		}
+	}
+	return nil
+}
+
+func checkFile(_p *conf.ACLPolicy, _file string) error {
+	if len(_p.Files) == 0 {
+		if restricted(_p) {
+			return errors.New("log files are not available when units, transports or priority are restricted")
+		}
+		return nil
+	}
+	if !path.IsAbs(_file) {
+		return errors.Errorf("file path must be absolute: %s", _file)
+	}
+	cleaned := path.Clean(_file)
+	for _, pattern := range _p.Files {
+		if ok, err := path.Match(pattern, cleaned); err == nil && ok {
+			return nil
+		}
+	}
+	return errors.Errorf("file %s is not allowed", cleaned)
+}
+
+// 每个匹配组都包含该字段时返回全部取值，否则该字段不受限制
+func groupValues(_matches [][]public.FieldMatch, _field string) ([]string, bool) {
+	if len(_matches) == 0 {
+		return nil, false
+	}
+	values := []string{}
+	for _, group := range _matches {
+		found := false
+		for _, m := range group {
+			if m.Field == _field {
+				values = append(values, m.Value)
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+func matchUser(_p *conf.ACLPolicy, _user string) bool {
+	return contains(_p.Users, "*") || contains(_p.Users, _user)
+}
+
+func matchMachine(_p *conf.ACLPolicy, _machine *common.MachineNode) bool {
+	if len(_p.Machines) == 0 && len(_p.Departments) == 0 {
+		return true
+	}
+	return contains(_p.Machines, "*") || contains(_p.Machines, _machine.UUID) || contains(_p.Machines, _machine.IP) ||
+		contains(_p.Departments, _machine.Department)
+}
+
+// 查询中的单元名称含通配符时只允许与策略完全一致
+func matchUnit(_patterns []string, _unit string) bool {
+	unit := normalizeUnit(_unit)
+	for _, pattern := range _patterns {
+		pattern = normalizeUnit(pattern)
+		if strings.ContainsAny(unit, "*?[") {
+			if unit == pattern {
+				return true
+			}
+			continue
+		}
+		if ok, err := path.Match(pattern, unit); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeUnit(_unit string) string {
+	if strings.ContainsAny(_unit, "*?[") {
+		return _unit
+	}
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(_unit, suffix) {
+			return _unit
+		}
+	}
+	return _unit + ".service"
+}
+
+// 优先级名称或数字
+func parsePriority(_priority string) (int, error) {
+	for i, name := range priorityNames {
+		if _priority == name {
+			return i, nil
+		}
+	}
+	if p, err := strconv.Atoi(_priority); err == nil && p >= 0 && p < len(priorityNames) {
+		return p, nil
+	}
+	return 0, errors.Errorf("invalid priority: %s", _priority)
+}
+
+// journalctl --priority的取值可以是单个优先级或FROM..TO范围，返回最低严重程度
+func priorityUpperBound(_priority string) (int, error) {
+	from, to, is_range := strings.Cut(_priority, "..")
+	if !is_range {
+		return parsePriority(_priority)
+	}
+	upper := len(priorityNames) - 1
+	if to != "" {
+		p, err := parsePriority(to)
+		if err != nil {
+			return 0, err
+		}
+		upper = p
+	}
+	if from != "" {
+		p, err := parsePriority(from)
+		if err != nil {
+			return 0, err
+		}
+		if p > upper {
+			upper = p
+		}
+	}
+	return upper, nil
+}
+
+func contains(_list []string, _s string) bool {
+	for _, s := range _list {
+		if s == _s {
+			return true
+		}
+	}
+	return false
+}
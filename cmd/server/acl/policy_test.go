@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 19:31:40 2026 +0800
+ */
+package acl
+
+import (
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+)
+
+var (
+	testWeb   = &common.MachineNode{UUID: "uuid-1", IP: "10.0.0.1", Department: "dev"}
+	testOps   = &common.MachineNode{UUID: "uuid-2", IP: "10.0.0.2", Department: "ops"}
+	testSpare = &common.MachineNode{UUID: "uuid-3", IP: "10.0.0.3", Department: "dev"}
+)
+
+/*
+alice：10.0.0.1上nginx、app-*服务warning及以上的日志，或kernel日志；
+bob：ops部门主机的全部journal日志及/var/log/*.log；任意用户：uuid-3的全部日志
+*/
+func setTestPolicies(t *testing.T) {
+	setACL(t, &conf.ACLConf{
+		Enabled:   true,
+		JwtSecret: testSecret,
+		Policies: []*conf.ACLPolicy{
+			{Users: []string{"alice"}, Machines: []string{"10.0.0.1"}, Units: []string{"nginx", "app-*"}, Priority: "warning"},
+			{Users: []string{"alice"}, Machines: []string{"uuid-1"}, Transports: []string{"kernel"}},
+			{Users: []string{"bob"}, Departments: []string{"ops"}, Files: []string{"/var/log/*.log"}},
+			{Users: []string{"*"}, Machines: []string{"uuid-3"}},
+		},
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	setTestPolicies(t)
+	tests := []struct {
+		user     string
+		machine  *common.MachineNode
+		policies int
+	}{
+		{"alice", testWeb, 2},
+		{"alice", testOps, 0},
+		{"bob", testOps, 1},
+		{"bob", testWeb, 0},
+		{"carol", testSpare, 1},
+		{"alice", testSpare, 1},
+		{"carol", testWeb, 0},
+	}
+	for _, tt := range tests {
+		grant, err := Authorize(tt.user, tt.machine)
+		if tt.policies == 0 {
+			if err == nil || !IsPermissionDenied(err) {
+				t.Errorf("%s on %s: got error %v, want permission denied", tt.user, tt.machine.IP, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %s: %s", tt.user, tt.machine.IP, err)
+			continue
+		}
+		if len(grant.policies) != tt.policies {
+			t.Errorf("%s on %s: got %d policies, want %d", tt.user, tt.machine.IP, len(grant.policies), tt.policies)
+		}
+	}
+
+	setACL(t, &conf.ACLConf{})
+	if grant, err := Authorize("anyone", testWeb); grant != nil || err != nil {
+		t.Errorf("acl disabled: got grant %v, error %v", grant, err)
+	}
+}
+
+func TestGrantCheck(t *testing.T) {
+	setTestPolicies(t)
+	grants := map[string]*Grant{}
+	for user, machine := range map[string]*common.MachineNode{"alice": testWeb, "bob": testOps, "carol": testSpare} {
+		grant, err := Authorize(user, machine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		grants[user] = grant
+	}
+	options := func(_unit, _severity string) *public.JournalctlOptions {
+		return &public.JournalctlOptions{Unit: _unit, Severity: _severity}
+	}
+	unitMatches := func(_groups ...[]public.FieldMatch) *public.JournalctlOptions {
+		return &public.JournalctlOptions{Matches: _groups}
+	}
+	unit := func(_name string) public.FieldMatch { return public.FieldMatch{Field: "_SYSTEMD_UNIT", Value: _name} }
+	priority := func(_p string) public.FieldMatch { return public.FieldMatch{Field: "PRIORITY", Value: _p} }
+
+	tests := []struct {
+		name    string
+		user    string
+		jmsg    *public.JMessage
+		source  string
+		allowed bool
+	}{
+		// 服务单元及优先级
+		{"unit and priority", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "err")}, "journald", true},
+		{"unit with suffix", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx.service", "warning")}, "journald", true},
+		{"unit pattern", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("app-web", "3")}, "journald", true},
+		{"same wildcard as policy", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("app-*", "err")}, "journald", true},
+		{"broader wildcard", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("*", "err")}, "journald", false},
+		{"other unit", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("sshd", "err")}, "journald", false},
+		{"no unit", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("", "err")}, "journald", false},
+		{"lower priority", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "info")}, "journald", false},
+		{"no priority", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "")}, "journald", false},
+		{"priority range", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "emerg..warning")}, "journald", true},
+		{"priority range to info", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "err..info")}, "journald", false},
+		{"open priority range", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("nginx", "3..")}, "journald", false},
+		{"field matches", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: unitMatches(
+			[]public.FieldMatch{unit("nginx.service"), priority("3")}, []public.FieldMatch{unit("app-db.service"), priority("4")})}, "journald", true},
+		{"field match group without unit", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: unitMatches(
+			[]public.FieldMatch{unit("nginx.service"), priority("3")}, []public.FieldMatch{priority("3")})}, "journald", false},
+		// 满足任意一条策略即可
+		{"second policy", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{Transport: "kernel"}}, "journald", true},
+		// 查询条件为空的请求不合法，不受策略限制的用户同样拒绝
+		{"no options", "alice", &public.JMessage{Type: public.UpdateOptionsMsg}, "journald", false},
+		{"no options unrestricted", "carol", &public.JMessage{Type: public.UpdateOptionsMsg}, "journald", false},
+		{"timeline without options", "carol", &public.JMessage{Type: public.TimelineMsg}, "journald", false},
+		{"alert rule without options", "carol", &public.JMessage{Type: public.AlertRuleMsg}, "file", false},
+		// 各消息类型
+		{"timeline", "alice", &public.JMessage{Type: public.TimelineMsg, JOptions: options("sshd", "err")}, "journald", false},
+		{"alert rule", "alice", &public.JMessage{Type: public.AlertRuleMsg, JOptions: options("sshd", "err")}, "journald", false},
+		{"alert rule allowed", "alice", &public.JMessage{Type: public.AlertRuleMsg, JOptions: options("nginx", "err")}, "journald", true},
+		{"export without options", "alice", &public.JMessage{Type: public.ExportMsg, Data: public.ExportNDJSON}, "journald", false},
+		{"export", "alice", &public.JMessage{Type: public.ExportMsg, JOptions: options("nginx", "crit")}, "journald", true},
+		{"entry detail", "alice", &public.JMessage{Type: public.EntryDetailMsg, JOptions: &public.JournalctlOptions{Cursor: "s=1"}}, "journald", false},
+		{"context", "alice", &public.JMessage{Type: public.ContextMsg, JOptions: &public.JournalctlOptions{Cursor: "s=1"}}, "journald", false},
+		{"unit list", "alice", &public.JMessage{Type: public.UnitListMsg}, "journald", true},
+		{"boot list", "alice", &public.JMessage{Type: public.BootListMsg}, "journald", true},
+		{"unrestricted entry detail", "bob", &public.JMessage{Type: public.EntryDetailMsg, JOptions: &public.JournalctlOptions{Cursor: "s=1"}}, "journald", true},
+		{"unrestricted journal", "bob", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: options("", "")}, "journald", true},
+		// 文本日志文件
+		{"file", "bob", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "/var/log/app.log"}}, "file", true},
+		{"file traversal", "bob", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "/var/log/../../etc/shadow"}}, "file", false},
+		{"relative file", "bob", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "var/log/app.log"}}, "file", false},
+		{"file in subdirectory", "bob", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "/var/log/nginx/access.log"}}, "file", false},
+		{"file export", "bob", &public.JMessage{Type: public.ExportMsg, JOptions: &public.JournalctlOptions{File: "/var/log/app.log"}}, "file", true},
+		{"file export without options", "bob", &public.JMessage{Type: public.ExportMsg}, "file", false},
+		{"file restricted by units", "alice", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "/var/log/app.log"}}, "file", false},
+		{"file unrestricted", "carol", &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &public.JournalctlOptions{File: "/etc/passwd"}}, "file", true},
+	}
+	for _, tt := range tests {
+		err := grants[tt.user].Check(tt.jmsg, tt.source)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if !tt.allowed && !IsPermissionDenied(err) {
+			t.Errorf("%s: got error %v, want permission denied", tt.name, err)
+		}
+	}
+
+	// 未开启权限控制时grant为nil
+	var grant *Grant
+	if err := grant.Check(&public.JMessage{Type: public.EntryDetailMsg}, "journald"); err != nil {
+		t.Errorf("nil grant: %s", err)
+	}
+}
+
+func TestInitPolicies(t *testing.T) {
+	tests := []struct {
+		aclconf *conf.ACLConf
+		ok      bool
+	}{
+		{&conf.ACLConf{}, true},
+		{&conf.ACLConf{Enabled: true, JwtSecret: testSecret, Policies: []*conf.ACLPolicy{{Users: []string{"a"}, Priority: "err"}}}, true},
+		{&conf.ACLConf{Enabled: true, Policies: []*conf.ACLPolicy{{Users: []string{"a"}}}}, false},
+		{&conf.ACLConf{Enabled: true, JwtSecret: testSecret, Policies: []*conf.ACLPolicy{{Priority: "err"}}}, false},
+		{&conf.ACLConf{Enabled: true, JwtSecret: testSecret, Policies: []*conf.ACLPolicy{{Users: []string{"a"}, Priority: "loud"}}}, false},
+	}
+	for i, tt := range tests {
+		setACL(t, tt.aclconf)
+		if err := Init(); (err == nil) != tt.ok {
+			t.Errorf("config %d: got error %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 23:02:15 2026 +0800
+ */
+package acl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTokenCookie   = "Admin-Token"
+	defaultUsernameClaim = "UserName"
+)
+
+func Enabled() bool {
+	return conf.Global_Config != nil && conf.Global_Config.ACL != nil && conf.Global_Config.ACL.Enabled
+}
+
+// 根据请求携带的PilotGo token解析用户名，未开启权限控制时返回空字符串
+func ResolveUser(_r *http.Request) (string, error) {
+	if !Enabled() {
+		return "", nil
+	}
+	aclconf := conf.Global_Config.ACL
+	if aclconf.JwtSecret == "" {
+		return "", errors.New("acl jwt_secret is not configured")
+	}
+
+	token := _r.URL.Query().Get("token")
+	if auth := _r.Header.Get("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token == "" {
+		cookie_name := aclconf.TokenCookie
+		if cookie_name == "" {
+			cookie_name = defaultTokenCookie
+		}
+		if cookie, err := _r.Cookie(cookie_name); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		return "", errors.New("unauthenticated: token required")
+	}
+
+	claims, err := parseToken(token, aclconf.JwtSecret)
+	if err != nil {
+		return "", errors.Errorf("unauthenticated: %s", err.Error())
+	}
+	claim := aclconf.UsernameClaim
+	if claim == "" {
+		claim = defaultUsernameClaim
+	}
+	username, ok := claims[claim].(string)
+	if !ok || username == "" {
+		return "", errors.Errorf("unauthenticated: token has no %s", claim)
+	}
+	return username, nil
+}
+
+// 校验HS256签名及过期时间，返回token中的claims
+func parseToken(_token, _secret string) (map[string]interface{}, error) {
+	parts := strings.Split(_token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" {
+		return nil, errors.Errorf("unsupported token algorithm: %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, []byte(_secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, errors.New("token expired")
+	}
+	return claims, nil
+}
+
+func decodeSegment(_segment string, _v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(_segment, "="))
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, _v); err != nil {
+		return errors.Errorf("malformed token: %s", err.Error())
+	}
+	return nil
+}
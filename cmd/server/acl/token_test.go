@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 19:05:14 2026 +0800
+ */
+package acl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+)
+
+const testSecret = "test-secret"
+
+func encodeSegment(t *testing.T, _v interface{}) string {
+	data, err := json.Marshal(_v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// 以_hash计算HMAC签名，_alg为header中声明的算法
+func signToken(t *testing.T, _alg string, _hash func() hash.Hash, _claims map[string]interface{}, _secret string) string {
+	unsigned := encodeSegment(t, map[string]string{"alg": _alg, "typ": "JWT"}) + "." + encodeSegment(t, _claims)
+	mac := hmac.New(_hash, []byte(_secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 设置权限控制配置，测试结束后恢复
+func setACL(t *testing.T, _aclconf *conf.ACLConf) {
+	old := conf.Global_Config
+	conf.Global_Config = &conf.ServerConfig{ACL: _aclconf}
+	t.Cleanup(func() { conf.Global_Config = old })
+}
+
+func TestParseToken(t *testing.T) {
+	claims := map[string]interface{}{"UserName": "alice"}
+	valid := signToken(t, "HS256", sha256.New, claims, testSecret)
+	parts := strings.Split(valid, ".")
+	expired := map[string]interface{}{"UserName": "alice", "exp": time.Now().Add(-time.Minute).Unix()}
+	unexpired := map[string]interface{}{"UserName": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"valid", valid, ""},
+		{"not expired", signToken(t, "HS256", sha256.New, unexpired, testSecret), ""},
+		{"wrong secret", signToken(t, "HS256", sha256.New, claims, "other"), "invalid token signature"},
+		{"tampered claims", parts[0] + "." + encodeSegment(t, map[string]interface{}{"UserName": "root"}) + "." + parts[2], "invalid token signature"},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:10], "invalid token signature"},
+		{"empty signature", parts[0] + "." + parts[1] + ".", "invalid token signature"},
+		// 只接受HS256，不能通过header更换算法
+		{"alg none", encodeSegment(t, map[string]string{"alg": "none"}) + "." + parts[1] + ".", "unsupported token algorithm: none"},
+		{"alg HS512", signToken(t, "HS512", sha512.New, claims, testSecret), "unsupported token algorithm: HS512"},
+		{"alg RS256", signToken(t, "RS256", sha256.New, claims, testSecret), "unsupported token algorithm: RS256"},
+		{"alg lowercase", signToken(t, "hs256", sha256.New, claims, testSecret), "unsupported token algorithm: hs256"},
+		{"HS256 header with HS512 signature", signToken(t, "HS256", sha512.New, claims, testSecret), "invalid token signature"},
+		{"expired", signToken(t, "HS256", sha256.New, expired, testSecret), "token expired"},
+		{"two segments", parts[0] + "." + parts[1], "malformed token"},
+		{"bad base64 header", "!!!." + parts[1] + "." + parts[2], "malformed token"},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".***", "malformed token signature"},
+		{"bad claims json", signTokenRaw(t, "not json"), "malformed token"},
+	}
+	for _, tt := range tests {
+		got, err := parseToken(tt.token, testSecret)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+			} else if got["UserName"] != "alice" {
+				t.Errorf("%s: got claims %v", tt.name, got)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// 签名正确、claims不是json的token
+func signTokenRaw(t *testing.T, _claims string) string {
+	unsigned := encodeSegment(t, map[string]string{"alg": "HS256"}) + "." + base64.RawURLEncoding.EncodeToString([]byte(_claims))
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestResolveUser(t *testing.T) {
+	token := signToken(t, "HS256", sha256.New, map[string]interface{}{"UserName": "alice", "name": "bob"}, testSecret)
+	request := func(_query, _header string, _cookie *http.Cookie) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/ws"+_query, nil)
+		if _header != "" {
+			r.Header.Set("Authorization", _header)
+		}
+		if _cookie != nil {
+			r.AddCookie(_cookie)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		aclconf *conf.ACLConf
+		r       *http.Request
+		user    string
+		ok      bool
+	}{
+		{"disabled", &conf.ACLConf{}, request("", "", nil), "", true},
+		{"query", &conf.ACLConf{Enabled: true, JwtSecret: testSecret}, request("?token="+token, "", nil), "alice", true},
+		{"bearer", &conf.ACLConf{Enabled: true, JwtSecret: testSecret}, request("", "Bearer "+token, nil), "alice", true},
+		{"default cookie", &conf.ACLConf{Enabled: true, JwtSecret: testSecret}, request("", "", &http.Cookie{Name: "Admin-Token", Value: token}), "alice", true},
+		{"custom cookie", &conf.ACLConf{Enabled: true, JwtSecret: testSecret, TokenCookie: "t"}, request("", "", &http.Cookie{Name: "t", Value: token}), "alice", true},
+		{"other cookie", &conf.ACLConf{Enabled: true, JwtSecret: testSecret, TokenCookie: "t"}, request("", "", &http.Cookie{Name: "Admin-Token", Value: token}), "", false},
+		{"custom claim", &conf.ACLConf{Enabled: true, JwtSecret: testSecret, UsernameClaim: "name"}, request("", "Bearer "+token, nil), "bob", true},
+		{"missing claim", &conf.ACLConf{Enabled: true, JwtSecret: testSecret, UsernameClaim: "sub"}, request("", "Bearer "+token, nil), "", false},
+		{"no token", &conf.ACLConf{Enabled: true, JwtSecret: testSecret}, request("", "", nil), "", false},
+		{"basic auth", &conf.ACLConf{Enabled: true, JwtSecret: testSecret}, request("", "Basic "+token, nil), "", false},
+		{"no secret", &conf.ACLConf{Enabled: true}, request("?token="+token, "", nil), "", false},
+		{"wrong secret", &conf.ACLConf{Enabled: true, JwtSecret: "other"}, request("?token="+token, "", nil), "", false},
+	}
+	for _, tt := range tests {
+		setACL(t, tt.aclconf)
+		user, err := ResolveUser(tt.r)
+		if (err == nil) != tt.ok || user != tt.user {
+			t.Errorf("%s: got %q, error %v", tt.name, user, err)
+		}
+	}
+}
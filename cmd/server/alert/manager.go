@@ -0,0 +1,274 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 16:21:37 2026 +0800
+ */
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver/proxy"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 同步机器列表的周期，规则变化时立即同步
+	syncPeriod = time.Minute
+	// 与agent断开后重新连接的等待时间，连续失败时逐渐增加
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+var manager *alertManager
+
+/*
+为每条启用的规则在每台目标机器上维持一个agent会话，
+
+agent评估规则并在状态变化时返回AlertEvent，logs server记录状态并发送通知
+*/
+type alertManager struct {
+	store *ruleStore
+	sinks map[string]sink
+
+	ctx    context.Context
+	syncCh chan struct{}
+
+	mutex sync.Mutex
+	// key: 规则ID + "/" + 机器UUID
+	watchers map[string]*watcher
+}
+
+type watcher struct {
+	rule    *Rule
+	machine *common.MachineNode
+	cancel  context.CancelFunc
+}
+
+// 加载告警规则并开始评估，未开启时不评估
+func Init() error {
+	alertconf := config()
+	if alertconf == nil || !alertconf.Enabled {
+		return nil
+	}
+	if alertconf.Path == "" {
+		return errors.New("alert path is required when alert is enabled")
+	}
+	s, err := loadStore(alertconf.Path)
+	if err != nil {
+		return err
+	}
+	manager = &alertManager{
+		store:    s,
+		sinks:    newSinks(alertconf),
+		ctx:      global.RootCtx,
+		syncCh:   make(chan struct{}, 1),
+		watchers: map[string]*watcher{},
+	}
+	global.ERManager.Wg.Add(1)
+	go manager.run()
+	return nil
+}
+
+func Enabled() bool {
+	return manager != nil
+}
+
+func ListRules() []*Rule {
+	return manager.store.list()
+}
+
+func GetRule(_id string) (*Rule, error) {
+	return manager.store.get(_id)
+}
+
+func CreateRule(_rule *Rule, _creator string) error {
+	_rule.Creator = _creator
+	if err := manager.store.create(_rule); err != nil {
+		return err
+	}
+	manager.requestSync()
+	return nil
+}
+
+func UpdateRule(_id string, _rule *Rule) error {
+	if err := manager.store.update(_id, _rule); err != nil {
+		return err
+	}
+	manager.requestSync()
+	return nil
+}
+
+func DeleteRule(_id string) error {
+	if err := manager.store.delete(_id); err != nil {
+		return err
+	}
+	manager.requestSync()
+	return nil
+}
+
+// 告警状态，_rule_id为空时返回全部规则的状态
+func ListStates(_rule_id string) []*HostState {
+	return manager.store.listStates(_rule_id)
+}
+
+func (m *alertManager) requestSync() {
+	select {
+	case m.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *alertManager) run() {
+	defer global.ERManager.Wg.Done()
+
+	ticker := time.NewTicker(syncPeriod)
+	defer ticker.Stop()
+	for {
+		m.sync()
+		select {
+		case <-m.ctx.Done():
+			m.mutex.Lock()
+			for key, w := range m.watchers {
+				w.cancel()
+				delete(m.watchers, key)
+			}
+			m.mutex.Unlock()
+			return
+		case <-ticker.C:
+		case <-m.syncCh:
+		}
+	}
+}
+
+// 根据规则及机器列表启动或停止agent会话，规则修改后重新启动
+func (m *alertManager) sync() {
+	rules := m.store.list()
+	machines, err := pluginclient.Global_Client.MachineList()
+	if err != nil {
+		global.ERManager.ErrorTransmit("alert", "error", errors.Errorf("fail to get machine list: %s", err.Error()), false, false)
+		return
+	}
+
+	wanted := map[string]*watcher{}
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		for _, machine := range machines {
+			if matchMachine(r, machine) {
+				wanted[stateKey(r.ID, machine.UUID)] = &watcher{rule: r, machine: machine}
+			}
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key, w := range m.watchers {
+		if want, ok := wanted[key]; !ok || want.rule.Updated != w.rule.Updated || want.machine.IP != w.machine.IP {
+			w.cancel()
+			delete(m.watchers, key)
+		}
+	}
+	for key, w := range wanted {
+		if _, ok := m.watchers[key]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(m.ctx)
+		w.cancel = cancel
+		m.watchers[key] = w
+		go m.watch(ctx, w)
+	}
+}
+
+func matchMachine(_rule *Rule, _machine *common.MachineNode) bool {
+	if len(_rule.Machines) == 0 {
+		return true
+	}
+	for _, id := range _rule.Machines {
+		if id == _machine.UUID || id == _machine.IP {
+			return true
+		}
+	}
+	return false
+}
+
+// 维持规则在一台机器上的agent会话，断开后等待一段时间重新连接
+func (m *alertManager) watch(_ctx context.Context, _w *watcher) {
+	delay := minRetryDelay
+	for {
+		start := time.Now()
+		err := m.evaluate(_ctx, _w)
+		if _ctx.Err() != nil {
+			return
+		}
+		global.ERManager.ErrorTransmit("alert", "warn", errors.Wrapf(err, "alert rule %s on %s", _w.rule.Name, _w.machine.IP), false, false)
+		if time.Since(start) > maxRetryDelay {
+			delay = minRetryDelay
+		}
+		select {
+		case <-_ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// 发送规则并处理agent返回的告警状态，会话结束时返回原因
+func (m *alertManager) evaluate(_ctx context.Context, _w *watcher) error {
+	ctx, cancel := context.WithCancel(_ctx)
+	defer cancel()
+	session, err := proxy.DialAgentService(ctx, _w.machine, fmt.Sprintf("alert-%s-%x", _w.rule.ID, time.Now().UnixNano()))
+	if err != nil {
+		return err
+	}
+	err = session.Send(&public.JMessage{
+		Type:     public.AlertRuleMsg,
+		JOptions: _w.rule.Options,
+		Data:     &public.AlertCondition{Threshold: _w.rule.Threshold, Window: _w.rule.Window},
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		data, err := session.Read()
+		if err != nil {
+			return err
+		}
+		if data.Type != public.AlertData {
+			continue
+		}
+		event := &public.AlertEvent{}
+		if err := json.Unmarshal(data.Data, event); err != nil {
+			return errors.Errorf("fail to unmarshal alert event: %s", err.Error())
+		}
+		m.handleEvent(_w, event)
+	}
+}
+
+// 记录告警状态，状态变化时发送通知
+func (m *alertManager) handleEvent(_w *watcher, _event *public.AlertEvent) {
+	previous, changed, err := m.store.transition(_w.rule, _w.machine.UUID, _w.machine.IP, _event)
+	if err != nil {
+		global.ERManager.ErrorTransmit("alert", "error", errors.Wrap(err, "fail to save alert state"), false, false)
+	}
+	if !changed {
+		return
+	}
+	global.ERManager.ErrorTransmit("alert", "info", errors.Errorf("alert rule %s on %s: %s, %d entries in %ds", _w.rule.Name, _w.machine.IP, _event.State, _event.Count, _w.rule.Window), false, false)
+	n := newNotification(_w.rule, _w.machine, _event, previous)
+	go m.notify(_w.rule, n)
+}
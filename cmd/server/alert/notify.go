@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 16:58:12 2026 +0800
+ */
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"github.com/pkg/errors"
+)
+
+// 发送webhook通知的超时时间
+const webhookTimeout = 10 * time.Second
+
+// 告警触发或恢复时发送的通知
+type Notification struct {
+	RuleID    string `json:"rule_id"`
+	RuleName  string `json:"rule_name"`
+	UUID      string `json:"uuid"`
+	IP        string `json:"ip"`
+	State     string `json:"state"`
+	Count     int    `json:"count"`
+	Threshold int    `json:"threshold"`
+	Window    int    `json:"window"`
+	// 毫秒时间戳；恢复通知中fired_at为告警触发的时间
+	Timestamp int64  `json:"timestamp"`
+	FiredAt   int64  `json:"fired_at,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func newNotification(_rule *Rule, _machine *common.MachineNode, _event *public.AlertEvent, _previous *HostState) *Notification {
+	n := &Notification{
+		RuleID:    _rule.ID,
+		RuleName:  _rule.Name,
+		UUID:      _machine.UUID,
+		IP:        _machine.IP,
+		State:     _event.State,
+		Count:     _event.Count,
+		Threshold: _rule.Threshold,
+		Window:    _rule.Window,
+		Timestamp: _event.Timestamp,
+		Message:   _event.Message,
+	}
+	if _event.State == public.AlertResolved && _previous != nil {
+		n.FiredAt = _previous.Since
+	}
+	return n
+}
+
+func (n *Notification) subject() string {
+	return fmt.Sprintf("[PilotGo logs] %s: %s on %s", strings.ToUpper(n.State), n.RuleName, n.IP)
+}
+
+func (n *Notification) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rule: %s (%s)\n", n.RuleName, n.RuleID)
+	fmt.Fprintf(&b, "machine: %s (%s)\n", n.IP, n.UUID)
+	fmt.Fprintf(&b, "state: %s at %s\n", n.State, time.UnixMilli(n.Timestamp).Format(time.RFC3339))
+	if n.FiredAt != 0 {
+		fmt.Fprintf(&b, "fired at: %s\n", time.UnixMilli(n.FiredAt).Format(time.RFC3339))
+	}
+	// agent只保留超过threshold所需的日志，告警时的条数是下限
+	if n.State == public.AlertFiring {
+		fmt.Fprintf(&b, "count: more than %d entries in %ds\n", n.Threshold, n.Window)
+	} else {
+		fmt.Fprintf(&b, "count: %d entries in %ds, threshold %d\n", n.Count, n.Window, n.Threshold)
+	}
+	if n.Message != "" {
+		fmt.Fprintf(&b, "last message: %s\n", n.Message)
+	}
+	return b.String()
+}
+
+// 通知方式
+type sink interface {
+	send(_n *Notification) error
+}
+
+// 已配置的通知方式
+func newSinks(_conf *conf.AlertConf) map[string]sink {
+	sinks := map[string]sink{}
+	if _conf.Webhook != nil && _conf.Webhook.URL != "" {
+		sinks[SinkWebhook] = &webhookSink{conf: _conf.Webhook, client: &http.Client{Timeout: webhookTimeout}}
+	}
+	if _conf.SMTP != nil && _conf.SMTP.Addr != "" {
+		sinks[SinkSMTP] = &smtpSink{conf: _conf.SMTP}
+	}
+	if _conf.PilotGo != nil && _conf.PilotGo.Enabled {
+		sinks[SinkPilotGo] = &pilotgoSink{conf: _conf.PilotGo}
+	}
+	return sinks
+}
+
+// 通过规则指定的通知方式发送，未指定时使用全部已配置的通知方式
+func (m *alertManager) notify(_rule *Rule, _n *Notification) {
+	names := _rule.Sinks
+	if len(names) == 0 {
+		for name := range m.sinks {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		s, ok := m.sinks[name]
+		if !ok {
+			global.ERManager.ErrorTransmit("alert", "warn", errors.Errorf("alert sink %s of rule %s is not configured", name, _rule.Name), false, false)
+			continue
+		}
+		if err := s.send(_n); err != nil {
+			global.ERManager.ErrorTransmit("alert", "error", errors.Wrapf(err, "fail to send alert of rule %s via %s", _rule.Name, name), false, false)
+		}
+	}
+}
+
+type webhookSink struct {
+	conf   *conf.AlertWebhookConf
+	client *http.Client
+}
+
+// 以json格式POST通知
+func (s *webhookSink) send(_n *Notification) error {
+	body, err := json.Marshal(_n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type smtpSink struct {
+	conf *conf.AlertSMTPConf
+}
+
+// 以纯文本邮件发送通知，服务器支持时使用STARTTLS
+func (s *smtpSink) send(_n *Notification) error {
+	if len(s.conf.To) == 0 {
+		return errors.New("no smtp recipients")
+	}
+	var auth smtp.Auth
+	if s.conf.Username != "" {
+		host, _, err := net.SplitHostPort(s.conf.Addr)
+		if err != nil {
+			return errors.Errorf("invalid smtp addr %s: %s", s.conf.Addr, err.Error())
+		}
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.conf.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.conf.To, ", "))
+	// 规则名称可能包含非ASCII字符，按RFC 2047编码，同时避免换行注入邮件头
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", _n.subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(_n.text(), "\n", "\r\n"))
+	return smtp.SendMail(s.conf.Addr, auth, s.conf.From, s.conf.To, msg.Bytes())
+}
+
+type pilotgoSink struct {
+	conf *conf.AlertPilotGoConf
+}
+
+// 通过PilotGo事件总线发布通知，消息内容为json格式的Notification
+func (s *pilotgoSink) send(_n *Notification) error {
+	data, err := json.Marshal(_n)
+	if err != nil {
+		return err
+	}
+	return pluginclient.Global_Client.PublishEvent(common.EventMessage{
+		MessageType: s.conf.MessageType,
+		MessageData: string(data),
+	})
+}
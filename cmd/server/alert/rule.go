@@ -0,0 +1,334 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 15:48:20 2026 +0800
+ */
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"github.com/pkg/errors"
+)
+
+const (
+	rulesFile  = "rules.json"
+	statesFile = "states.json"
+	// 告警时间窗口的上限，与agent一致，单位秒
+	maxWindow = 24 * 60 * 60
+)
+
+// 通知方式
+const (
+	SinkWebhook = "webhook"
+	SinkSMTP    = "smtp"
+	SinkPilotGo = "pilotgo"
+)
+
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+/*
+告警规则：每台主机window秒内满足options的日志多于threshold条时触发告警，
+不再满足时恢复；触发及恢复时通过sinks发送通知
+*/
+type Rule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// 机器UUID或IP，为空时对全部机器生效
+	Machines  []string                  `json:"machines"`
+	Options   *public.JournalctlOptions `json:"options"`
+	Threshold int                       `json:"threshold"`
+	Window    int                       `json:"window"`
+	// 为空时使用全部已配置的通知方式
+	Sinks []string `json:"sinks"`
+
+	Creator string `json:"creator"`
+	// 毫秒时间戳
+	Created int64 `json:"created"`
+	Updated int64 `json:"updated"`
+}
+
+// 规则在一台主机上的告警状态，重启后据此判断状态是否变化，避免重复通知
+type HostState struct {
+	RuleID string `json:"rule_id"`
+	UUID   string `json:"uuid"`
+	IP     string `json:"ip"`
+	State  string `json:"state"`
+	Count  int    `json:"count"`
+	// 进入当前状态的时间，毫秒
+	Since   int64  `json:"since"`
+	Message string `json:"message,omitempty"`
+}
+
+type ruleStore struct {
+	path string
+
+	mutex sync.Mutex
+	rules map[string]*Rule
+	// key: 规则ID + "/" + 机器UUID
+	states map[string]*HostState
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+	// 规则名称用于邮件标题等单行文本
+	if strings.IndexFunc(r.Name, unicode.IsControl) >= 0 {
+		return errors.Errorf("rule name contains control characters: %q", r.Name)
+	}
+	if r.Options == nil {
+		return errors.New("rule options are required")
+	}
+	if err := public.CheckQuery(r.Options); err != nil {
+		return err
+	}
+	if r.Threshold < 0 {
+		return errors.Errorf("invalid threshold: %d", r.Threshold)
+	}
+	if r.Window <= 0 || r.Window > maxWindow {
+		return errors.Errorf("window must be between 1 and %d seconds: %d", maxWindow, r.Window)
+	}
+	for _, sink := range r.Sinks {
+		switch sink {
+		case SinkWebhook, SinkSMTP, SinkPilotGo:
+		default:
+			return errors.Errorf("unknown sink: %s", sink)
+		}
+	}
+	// 告警规则只支持journal日志的实时查询
+	r.Options.Notail = false
+	r.Options.AfterCursor = ""
+	r.Options.File = ""
+	return nil
+}
+
+func loadStore(_path string) (*ruleStore, error) {
+	s := &ruleStore{path: _path, rules: map[string]*Rule{}, states: map[string]*HostState{}}
+	if err := os.MkdirAll(_path, 0700); err != nil {
+		return nil, errors.Errorf("fail to create alert directory: %s", err.Error())
+	}
+	rules := []*Rule{}
+	if err := readJSON(filepath.Join(_path, rulesFile), &rules); err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		s.rules[r.ID] = r
+	}
+	states := []*HostState{}
+	if err := readJSON(filepath.Join(_path, statesFile), &states); err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if _, ok := s.rules[state.RuleID]; ok {
+			s.states[stateKey(state.RuleID, state.UUID)] = state
+		}
+	}
+	return s, nil
+}
+
+func stateKey(_rule_id, _uuid string) string {
+	return _rule_id + "/" + _uuid
+}
+
+// 按创建时间排序的全部规则
+func (s *ruleStore) list() []*Rule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rules := make([]*Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		copied := *r
+		rules = append(rules, &copied)
+	}
+	sort.Slice(rules, func(_i, _j int) bool { return rules[_i].Created < rules[_j].Created })
+	return rules
+}
+
+func (s *ruleStore) get(_id string) (*Rule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r, ok := s.rules[_id]
+	if !ok {
+		return nil, ErrRuleNotFound
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (s *ruleStore) create(_rule *Rule) error {
+	if err := _rule.validate(); err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return errors.Errorf("fail to generate rule id: %s", err.Error())
+	}
+	_rule.ID = hex.EncodeToString(id)
+	_rule.Created = time.Now().UnixMilli()
+	_rule.Updated = _rule.Created
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rules[_rule.ID] = _rule
+	return s.saveRules()
+}
+
+func (s *ruleStore) update(_id string, _rule *Rule) error {
+	if err := _rule.validate(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	old, ok := s.rules[_id]
+	if !ok {
+		return ErrRuleNotFound
+	}
+	_rule.ID = _id
+	_rule.Creator = old.Creator
+	_rule.Created = old.Created
+	_rule.Updated = time.Now().UnixMilli()
+	s.rules[_id] = _rule
+	// 查询条件变化后重新评估，原有的告警状态不再有效
+	s.deleteStates(_id)
+	if err := s.saveStates(); err != nil {
+		return err
+	}
+	return s.saveRules()
+}
+
+func (s *ruleStore) delete(_id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.rules[_id]; !ok {
+		return ErrRuleNotFound
+	}
+	delete(s.rules, _id)
+	s.deleteStates(_id)
+	if err := s.saveStates(); err != nil {
+		return err
+	}
+	return s.saveRules()
+}
+
+func (s *ruleStore) deleteStates(_rule_id string) {
+	for key, state := range s.states {
+		if state.RuleID == _rule_id {
+			delete(s.states, key)
+		}
+	}
+}
+
+/*
+记录agent返回的告警状态，返回状态变化前的记录
+
+规则已删除或状态未变化时changed为false；首次评估即为恢复状态时只记录，不视为变化
+*/
+func (s *ruleStore) transition(_rule *Rule, _uuid, _ip string, _event *public.AlertEvent) (*HostState, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.rules[_rule.ID]; !ok {
+		return nil, false, nil
+	}
+	key := stateKey(_rule.ID, _uuid)
+	previous := s.states[key]
+	if previous != nil && previous.State == _event.State {
+		previous.Count = _event.Count
+		return nil, false, nil
+	}
+	s.states[key] = &HostState{
+		RuleID:  _rule.ID,
+		UUID:    _uuid,
+		IP:      _ip,
+		State:   _event.State,
+		Count:   _event.Count,
+		Since:   _event.Timestamp,
+		Message: _event.Message,
+	}
+	changed := previous != nil || _event.State == public.AlertFiring
+	return previous, changed, s.saveStates()
+}
+
+// 全部告警状态，_rule_id不为空时只返回该规则的状态
+func (s *ruleStore) listStates(_rule_id string) []*HostState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	states := []*HostState{}
+	for _, state := range s.states {
+		if _rule_id == "" || state.RuleID == _rule_id {
+			copied := *state
+			states = append(states, &copied)
+		}
+	}
+	sort.Slice(states, func(_i, _j int) bool {
+		if states[_i].RuleID != states[_j].RuleID {
+			return states[_i].RuleID < states[_j].RuleID
+		}
+		return states[_i].IP < states[_j].IP
+	})
+	return states
+}
+
+func (s *ruleStore) saveRules() error {
+	rules := make([]*Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, r)
+	}
+	return writeJSON(filepath.Join(s.path, rulesFile), rules)
+}
+
+func (s *ruleStore) saveStates() error {
+	states := make([]*HostState, 0, len(s.states))
+	for _, state := range s.states {
+		states = append(states, state)
+	}
+	return writeJSON(filepath.Join(s.path, statesFile), states)
+}
+
+// 文件不存在时不修改_v
+func readJSON(_file string, _v interface{}) error {
+	bytes, err := os.ReadFile(_file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Errorf("fail to read %s: %s", _file, err.Error())
+	}
+	if err := json.Unmarshal(bytes, _v); err != nil {
+		return errors.Errorf("fail to unmarshal %s: %s", _file, err.Error())
+	}
+	return nil
+}
+
+func writeJSON(_file string, _v interface{}) error {
+	bytes, err := json.MarshalIndent(_v, "", "  ")
+	if err != nil {
+		return errors.Errorf("fail to marshal %s: %s", filepath.Base(_file), err.Error())
+	}
+	tmp := _file + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0600); err != nil {
+		return errors.Errorf("fail to write %s: %s", filepath.Base(_file), err.Error())
+	}
+	if err := os.Rename(tmp, _file); err != nil {
+		return errors.Errorf("fail to write %s: %s", filepath.Base(_file), err.Error())
+	}
+	return nil
+}
+
+// 告警配置
+func config() *conf.AlertConf {
+	return conf.Global_Config.Alert
+}
@@ -0,0 +1,356 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 15:02:27 2026 +0800
+ */
+package archive
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+var priorityNames = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"warning": 4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+var (
+	// 启动序号或32位boot ID
+	bootRegexp = regexp.MustCompile(`^(-?[0-9]+|[0-9a-f]{32})$`)
+	// journal字段名只能包含大写字母、数字和下划线，且不能以数字开头
+	fieldNameRegexp = regexp.MustCompile(`^[A-Z_][A-Z0-9_]{0,63}$`)
+)
+
+// 对单条日志的过滤条件
+type filter func(_raw map[string]interface{}) bool
+
+// 与agent对JournalctlOptions的处理一致的查询条件
+type matcher struct {
+	filter filter
+	grep   *regexp.Regexp
+
+	// 微秒时间戳，0表示不限制
+	since uint64
+	until uint64
+}
+
+func (h *host) newMatcher(_options *public.JournalctlOptions) (*matcher, error) {
+	m := &matcher{}
+	filters := []filter{}
+	if _options.Notail && _options.Since != "" && _options.Until != "" {
+		var err error
+		if m.since, err = public.ParseJournalTime(_options.Since); err != nil {
+			return nil, err
+		}
+		if m.until, err = public.ParseJournalTime(_options.Until); err != nil {
+			return nil, err
+		}
+	}
+	if _options.Unit != "" {
+		unit := _options.Unit
+		if !strings.Contains(unit, ".") {
+			unit += ".service"
+		}
+		filters = append(filters, or(
+			fieldIn("_SYSTEMD_UNIT", unit),
+			and(fieldIn("MESSAGE_ID", "fc2e22bc6ee647b6b90729ab34a250b1"), fieldIn("_UID", "0"), fieldIn("COREDUMP_UNIT", unit)),
+			and(fieldIn("_PID", "1"), fieldIn("UNIT", unit)),
+			and(fieldIn("_UID", "0"), fieldIn("OBJECT_SYSTEMD_UNIT", unit)),
+		))
+	}
+	if _options.Identifier != "" {
+		filters = append(filters, fieldIn("SYSLOG_IDENTIFIER", _options.Identifier))
+	}
+	if _options.Severity != "" {
+		priorities, err := parsePriorityRange(_options.Severity)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fieldIn("PRIORITY", priorities...))
+	}
+	if _options.Transport != "" {
+		filters = append(filters, fieldIn("_TRANSPORT", _options.Transport))
+	}
+	if _options.User != "" {
+		user_split := strings.Split(_options.User, ":")
+		if len(user_split) != 2 || user_split[1] == "" {
+			return nil, errors.Errorf("user field in options is invalid: %s", _options.User)
+		}
+		filters = append(filters, fieldIn("_UID", user_split[1]))
+	}
+	if _options.Boot != "" {
+		boot_id, err := h.resolveBoot(_options.Boot)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fieldIn("_BOOT_ID", boot_id))
+	}
+	matches, err := fieldMatchFilter(_options.Matches)
+	if err != nil {
+		return nil, err
+	}
+	if matches != nil {
+		filters = append(filters, matches)
+	}
+	if m.grep, err = grepRegexp(_options); err != nil {
+		return nil, err
+	}
+	if m.grep != nil {
+		grep := m.grep
+		filters = append(filters, func(_raw map[string]interface{}) bool {
+			message, _ := _raw["MESSAGE"].(string)
+			return grep.MatchString(message)
+		})
+	}
+	if _options.Query != "" {
+		query, err := public.ParseQuery(_options.Query)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, queryFilter(query))
+	}
+	m.filter = and(filters...)
+	return m, nil
+}
+
+func (m *matcher) match(_r *record) bool {
+	return m.filter(_r.raw)
+}
+
+// 组内不同字段为AND、相同字段为OR，组之间为OR
+func fieldMatchFilter(_matches [][]public.FieldMatch) (filter, error) {
+	groups := []filter{}
+	for _, group := range _matches {
+		if len(group) == 0 {
+			continue
+		}
+		fields := []string{}
+		values := map[string][]string{}
+		for _, m := range group {
+			if !fieldNameRegexp.MatchString(m.Field) || strings.HasPrefix(m.Field, "__") {
+				return nil, errors.Errorf("invalid journal field name: %q", m.Field)
+			}
+			if _, ok := values[m.Field]; !ok {
+				fields = append(fields, m.Field)
+			}
+			values[m.Field] = append(values[m.Field], m.Value)
+		}
+		group_filters := []filter{}
+		for _, field := range fields {
+			group_filters = append(group_filters, fieldIn(field, values[field]...))
+		}
+		groups = append(groups, and(group_filters...))
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	return or(groups...), nil
+}
+
+// 字段等于任意一个取值，多值字段中任意一个值满足即可
+func fieldIn(_field string, _values ...string) filter {
+	return func(_raw map[string]interface{}) bool {
+		switch value := _raw[_field].(type) {
+		case string:
+			for _, v := range _values {
+				if value == v {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, item := range value {
+				if s, ok := item.(string); ok {
+					for _, v := range _values {
+						if s == v {
+							return true
+						}
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+// 与agent一致，二进制字段按字段不存在处理
+func queryFilter(_query *public.Query) filter {
+	return func(_raw map[string]interface{}) bool {
+		return _query.Match(func(_field string) (string, bool) {
+			value, ok := _raw[_field].(string)
+			return value, ok
+		})
+	}
+}
+
+func and(_filters ...filter) filter {
+	return func(_raw map[string]interface{}) bool {
+		for _, f := range _filters {
+			if !f(_raw) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func or(_filters ...filter) filter {
+	return func(_raw map[string]interface{}) bool {
+		for _, f := range _filters {
+			if f(_raw) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// 启动序号（0为最后一次启动，负数向前，正数从第一次启动开始）或boot ID
+func (h *host) resolveBoot(_boot string) (string, error) {
+	if !bootRegexp.MatchString(_boot) {
+		return "", errors.Errorf("invalid boot: %q", _boot)
+	}
+	if len(_boot) == 32 {
+		return _boot, nil
+	}
+	offset, _ := strconv.Atoi(_boot)
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	boots := h.state.Boots
+	index := offset - 1
+	if offset <= 0 {
+		index = len(boots) - 1 + offset
+	}
+	if index < 0 || index >= len(boots) {
+		return "", errors.Errorf("no journal boot entry found for boot %s", _boot)
+	}
+	return boots[index].BootID, nil
+}
+
+// --priority支持单个等级或"FROM..TO"范围，单个等级表示0..N
+func parsePriorityRange(_severity string) ([]string, error) {
+	parse := func(_s string) (int, error) {
+		if p, ok := priorityNames[_s]; ok {
+			return p, nil
+		}
+		p, err := strconv.Atoi(_s)
+		if err != nil || p < 0 || p > 7 {
+			return 0, errors.Errorf("invalid priority: %s", _s)
+		}
+		return p, nil
+	}
+
+	from, to := 0, 0
+	var err error
+	if r := strings.SplitN(_severity, "..", 2); len(r) == 2 {
+		if from, err = parse(r[0]); err != nil {
+			return nil, err
+		}
+		if to, err = parse(r[1]); err != nil {
+			return nil, err
+		}
+		if from > to {
+			from, to = to, from
+		}
+	} else if to, err = parse(_severity); err != nil {
+		return nil, err
+	}
+
+	priorities := []string{}
+	for p := from; p <= to; p++ {
+		priorities = append(priorities, strconv.Itoa(p))
+	}
+	return priorities, nil
+}
+
+// 查询条件中未设置grep时返回nil
+func grepRegexp(_options *public.JournalctlOptions) (*regexp.Regexp, error) {
+	if _options.Grep == "" {
+		return nil, nil
+	}
+	expr := _options.Grep
+	if !_options.GrepRegex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if _options.GrepIgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Errorf("invalid grep pattern %q: %s", _options.Grep, err)
+	}
+	return re, nil
+}
+
+/*
+生成返回客户端的日志条目，与agent返回的格式一致
+
+_fields: 额外返回的字段；_grep: 不为nil时标记MESSAGE中的匹配位置
+*/
+func generateEntry(_raw map[string]interface{}, _fields []string, _grep *regexp.Regexp) map[string]interface{} {
+	entry := map[string]interface{}{}
+	if realtime, ok := _raw["__REALTIME_TIMESTAMP"].(string); ok && realtime != "" {
+		if timestamp, err := strconv.ParseInt(realtime, 10, 64); err == nil {
+			entry["timestamp"] = strconv.FormatInt(timestamp/1000, 10)
+		}
+	}
+	if priority, ok := _raw["PRIORITY"].(string); ok && priority != "" {
+		entry["level"] = priority
+	}
+	if cursor, ok := _raw["__CURSOR"].(string); ok {
+		entry["cursor"] = cursor
+	}
+	message, _ := _raw["MESSAGE"].(string)
+	if message != "" {
+		entry["message"] = message
+	}
+	transport, _ := _raw["_TRANSPORT"].(string)
+	switch transport {
+	case "journal":
+		if unit, ok := _raw["UNIT"].(string); ok {
+			entry["targetname"] = unit
+		} else if identifier, ok := _raw["SYSLOG_IDENTIFIER"].(string); ok {
+			entry["targetname"] = identifier
+		}
+	case "syslog", "kernel", "audit":
+		if identifier, ok := _raw["SYSLOG_IDENTIFIER"].(string); ok {
+			entry["targetname"] = identifier
+		}
+	}
+
+	if len(_fields) > 0 {
+		extra := map[string]interface{}{}
+		for _, field := range _fields {
+			if value, ok := _raw[field]; ok {
+				extra[field] = value
+			}
+		}
+		entry["fields"] = extra
+	}
+
+	if _grep != nil {
+		offsets := [][2]int{}
+		for _, loc := range _grep.FindAllStringIndex(message, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			start := utf8.RuneCountInString(message[:loc[0]])
+			offsets = append(offsets, [2]int{start, start + utf8.RuneCountInString(message[loc[0]:loc[1]])})
+		}
+		entry["matches"] = offsets
+	}
+	return entry
+}
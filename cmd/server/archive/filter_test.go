@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 15:47:23 2026 +0800
+ */
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+var testBaseTime = time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+func testCursor(_seq int) string {
+	realtime := uint64(testBaseTime.Add(time.Duration(_seq) * time.Second).UnixMicro())
+	return "s=0;i=" + strconv.Itoa(_seq) + ";b=0;m=0;t=" + strconv.FormatUint(realtime, 16) + ";x=0"
+}
+
+/*
+归档中依次写入1-6六条日志，间隔1秒：
+
+奇数条PRIORITY为6、_COMM为sshd，偶数条PRIORITY为4、_COMM为nginx；第6条的MESSAGE为二进制
+*/
+func newTestHost(t *testing.T) *host {
+	h := &host{dir: t.TempDir(), segment: time.Hour}
+	buf := &bytes.Buffer{}
+	for seq := 1; seq <= 6; seq++ {
+		raw := map[string]interface{}{
+			"__CURSOR":             testCursor(seq),
+			"__REALTIME_TIMESTAMP": strconv.FormatInt(testBaseTime.Add(time.Duration(seq)*time.Second).UnixMicro(), 10),
+			"PRIORITY":             "6",
+			"_COMM":                "sshd",
+			"MESSAGE":              "entry " + strconv.Itoa(seq),
+		}
+		if seq%2 == 0 {
+			raw["PRIORITY"], raw["_COMM"] = "4", "nginx"
+		}
+		if seq == 6 {
+			raw["MESSAGE"] = []interface{}{101, 0, 54}
+		}
+		line, err := json.Marshal(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := appendSegment(h.segmentPath(h.segmentStart(uint64(testBaseTime.UnixMicro()))), buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func recordSeqs(_records []*record) []int {
+	seqs := []int{}
+	for _, r := range _records {
+		seq, _ := strconv.Atoi(r.raw["__CURSOR"].(string)[len("s=0;i="):][:1])
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+func TestMatcherQuery(t *testing.T) {
+	h := newTestHost(t)
+	tests := []struct {
+		options *public.JournalctlOptions
+		want    []int
+	}{
+		{&public.JournalctlOptions{Query: "priority<=warning"}, []int{2, 4, 6}},
+		{&public.JournalctlOptions{Query: "comm:sshd AND NOT msg~\"entry [15]\""}, []int{3}},
+		{&public.JournalctlOptions{Query: "msg=\"entry 1\" OR msg=\"entry 4\""}, []int{1, 4}},
+		// 二进制字段按字段不存在处理
+		{&public.JournalctlOptions{Query: "msg!=x AND priority=4"}, []int{2, 4, 6}},
+		{&public.JournalctlOptions{Query: "msg~entry AND priority=4"}, []int{2, 4}},
+		// 与其他查询条件取AND
+		{&public.JournalctlOptions{Query: "comm:nginx", Severity: "3"}, []int{}},
+		{&public.JournalctlOptions{Query: "comm:nginx", Matches: [][]public.FieldMatch{{{Field: "MESSAGE", Value: "entry 2"}}}}, []int{2}},
+		{&public.JournalctlOptions{
+			Query:  "comm:sshd",
+			Notail: true,
+			Since:  testBaseTime.Add(2 * time.Second).Local().Format("2006-01-02 15:04:05"),
+			Until:  testBaseTime.Add(5 * time.Second).Local().Format("2006-01-02 15:04:05"),
+		}, []int{3, 5}},
+	}
+	for _, tt := range tests {
+		m, err := h.newMatcher(tt.options)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.options.Query, err)
+		}
+		records, err := h.fetch(context.Background(), m, "", true, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recordSeqs(records); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.options, got, tt.want)
+		}
+	}
+
+	if _, err := h.newMatcher(&public.JournalctlOptions{Query: "priority<"}); err == nil {
+		t.Error("invalid query accepted")
+	}
+}
+
+// 按查询范围读取前后日志时同样按查询语句过滤，锚点不受限制
+func TestEntryContextScopeQuery(t *testing.T) {
+	s := &session{host: newTestHost(t), ctx: context.Background()}
+	tests := []struct {
+		anchor int
+		want   []string
+		index  int
+	}{
+		{3, []string{"entry 2", "entry 3", "entry 4", ""}, 1},
+		{4, []string{"entry 2", "entry 4", ""}, 1},
+	}
+	for _, tt := range tests {
+		context, err := s.entryContext(&public.JournalctlOptions{
+			Cursor:       testCursor(tt.anchor),
+			ContextScope: public.ContextScopeQuery,
+			Query:        "priority<=warning",
+			Before:       10,
+			After:        10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, hit := range context.Hits {
+			message, _ := hit["message"].(string)
+			got = append(got, message)
+		}
+		if !reflect.DeepEqual(got, tt.want) || context.Anchor != tt.index {
+			t.Errorf("anchor %d: got %q anchor at %d, want %q anchor at %d", tt.anchor, got, context.Anchor, tt.want, tt.index)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 15:40:09 2026 +0800
+ */
+package archive
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPageSize = 20
+	// 上下文查询时cursor前、后分别允许返回的最大条数
+	maxContextEntries = 500
+	// 实时模式下首次返回的日志条数，与journalctl --follow保持一致
+	followInitEntries = 10
+	// 时间轴时间段数量上限及自动选择时间段宽度时的目标数量
+	autoTimelineBuckets = 60
+	maxTimelineBuckets  = 1000
+	// 导出结果每段的大小，与agent一致
+	exportChunkSize = 64 * 1024
+)
+
+// 自动选择时间段宽度时的候选值
+var timelineIntervals = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+/*
+按时间顺序遍历满足查询条件的日志，_fn返回false时停止
+
+_cursor不为空时从该日志之后（_forward）或之前开始；归档中不存在该日志时按游标中的时间定位
+*/
+func (h *host) scan(_ctx context.Context, _m *matcher, _cursor string, _forward bool, _fn func(*record) bool) error {
+	h.mutex.RLock()
+	segments, err := h.segments()
+	h.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	position := uint64(0)
+	if _cursor != "" {
+		if position, err = cursorRealtime(_cursor); err != nil {
+			return err
+		}
+	}
+	if !_forward {
+		for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+			segments[i], segments[j] = segments[j], segments[i]
+		}
+	}
+
+	started := _cursor == ""
+	for _, seg := range segments {
+		if err := _ctx.Err(); err != nil {
+			return err
+		}
+		start, end := uint64(seg.start.UnixMicro()), uint64(seg.end.UnixMicro())
+		// 跳过查询范围及游标位置之外的分段
+		if (_m.since != 0 && end <= _m.since) || (_m.until != 0 && start > _m.until) {
+			continue
+		}
+		if !started && ((_forward && end <= position) || (!_forward && start > position)) {
+			continue
+		}
+
+		h.mutex.RLock()
+		records, err := readSegment(seg.path)
+		h.mutex.RUnlock()
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			r := records[i]
+			if !_forward {
+				r = records[len(records)-1-i]
+			}
+			if !started {
+				if r.cursor == _cursor {
+					started = true
+					continue
+				}
+				if (_forward && r.realtime <= position) || (!_forward && r.realtime >= position) {
+					continue
+				}
+				started = true
+			}
+			if _forward {
+				if _m.since != 0 && r.realtime < _m.since {
+					continue
+				}
+				if _m.until != 0 && r.realtime > _m.until {
+					return nil
+				}
+			} else {
+				if _m.until != 0 && r.realtime > _m.until {
+					continue
+				}
+				if _m.since != 0 && r.realtime < _m.since {
+					return nil
+				}
+			}
+			if _m.match(r) && !_fn(r) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// 读取_cursor之后（或之前）的至多_limit条日志，结果按时间升序排列
+func (h *host) fetch(_ctx context.Context, _m *matcher, _cursor string, _forward bool, _limit int) ([]*record, error) {
+	records := []*record{}
+	if _limit <= 0 {
+		return records, nil
+	}
+	err := h.scan(_ctx, _m, _cursor, _forward, func(_r *record) bool {
+		records = append(records, _r)
+		return len(records) < _limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !_forward {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	return records, nil
+}
+
+func (h *host) count(_ctx context.Context, _m *matcher) (int, error) {
+	total := 0
+	err := h.scan(_ctx, _m, "", true, func(_ *record) bool {
+		total++
+		return true
+	})
+	return total, err
+}
+
+// 根据游标查找单条日志，不存在时返回nil
+func (h *host) entryDetail(_ctx context.Context, _cursor string) (map[string]interface{}, error) {
+	position, err := cursorRealtime(_cursor)
+	if err != nil {
+		return nil, err
+	}
+	m := &matcher{filter: and(), since: position, until: position}
+	var detail map[string]interface{}
+	err = h.scan(_ctx, m, "", true, func(_r *record) bool {
+		if _r.cursor == _cursor {
+			detail = _r.raw
+			return false
+		}
+		return true
+	})
+	return detail, err
+}
+
+/*
+分页查询，与agent的分页语义一致
+
+cursor不为空或向前翻页时按游标读取，否则跳过前from条
+*/
+func (s *session) page(_options *public.JournalctlOptions) (*public.PageData, error) {
+	size := _options.Size
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	forward := _options.Direction != public.PageBackward
+
+	from := -1
+	var records []*record
+	var err error
+	switch {
+	case _options.Cursor != "" || !forward:
+		records, err = s.host.fetch(s.ctx, s.matcher, _options.Cursor, forward, size+1)
+	default:
+		from = _options.From
+		if from < 0 {
+			from = 0
+		}
+		cursor, skip := "", from
+		if from > 0 && from == s.nextFrom && s.nextCursor != "" {
+			cursor, skip = s.nextCursor, 0
+		}
+		if skip > 0 {
+			// 定位第from条日志，不足from条时返回空页
+			err = s.host.scan(s.ctx, s.matcher, "", true, func(_r *record) bool {
+				skip--
+				cursor = _r.cursor
+				return skip > 0
+			})
+		}
+		if err == nil && skip == 0 {
+			records, err = s.host.fetch(s.ctx, s.matcher, cursor, true, size+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(records) > size
+	if more {
+		if forward {
+			records = records[:size]
+		} else {
+			records = records[len(records)-size:]
+		}
+	}
+
+	page := &public.PageData{
+		Total: s.total,
+		Hits:  make([]map[string]interface{}, 0, len(records)),
+		More:  more,
+	}
+	for _, r := range records {
+		page.Hits = append(page.Hits, generateEntry(r.raw, s.options.Fields, s.matcher.grep))
+	}
+	if len(records) > 0 {
+		page.FirstCursor = records[0].cursor
+		page.LastCursor = records[len(records)-1].cursor
+		if from >= 0 {
+			s.nextFrom, s.nextCursor = from+len(records), page.LastCursor
+		}
+	}
+	return page, nil
+}
+
+// 查询cursor对应日志及其前后的日志，cursor对应的日志不受查询范围限制
+func (s *session) entryContext(_options *public.JournalctlOptions) (*public.EntryContext, error) {
+	if _options.Before < 0 || _options.After < 0 || _options.Before > maxContextEntries || _options.After > maxContextEntries {
+		return nil, errors.Errorf("before/after must be between 0 and %d", maxContextEntries)
+	}
+	anchor, err := s.host.entryDetail(s.ctx, _options.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &public.JournalctlOptions{Notail: true}
+	switch _options.ContextScope {
+	case "", public.ContextScopeAll:
+	case public.ContextScopeBoot:
+		if anchor != nil {
+			scope.Boot, _ = anchor["_BOOT_ID"].(string)
+		}
+	case public.ContextScopeQuery:
+		// 查询范围包括查询语句，由newMatcher按与查询时相同的条件过滤
+		*scope = *_options
+		scope.Notail = true
+	default:
+		return nil, errors.Errorf("unsupported context scope: %s", _options.ContextScope)
+	}
+	m, err := s.host.newMatcher(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*record{}
+	before, err := s.host.fetch(s.ctx, m, _options.Cursor, false, _options.Before)
+	if err != nil {
+		return nil, err
+	}
+	records = append(records, before...)
+	context := &public.EntryContext{Anchor: -1}
+	if anchor != nil {
+		context.Anchor = len(records)
+		records = append(records, &record{raw: anchor})
+	}
+	after, err := s.host.fetch(s.ctx, m, _options.Cursor, true, _options.After)
+	if err != nil {
+		return nil, err
+	}
+	records = append(records, after...)
+
+	grep, _ := grepRegexp(_options)
+	context.Hits = make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		context.Hits = append(context.Hits, generateEntry(r.raw, _options.Fields, grep))
+	}
+	return context, nil
+}
+
+// 按时间段统计since至until之间满足查询条件的日志条数
+func (s *session) timeline(_options *public.JournalctlOptions) (*public.Timeline, error) {
+	if _options.Since == "" || _options.Until == "" {
+		return nil, errors.New("since and until are required for timeline")
+	}
+	switch _options.SplitBy {
+	case "", public.SplitByUnit, public.SplitByPriority:
+	default:
+		return nil, errors.Errorf("unsupported split_by: %s", _options.SplitBy)
+	}
+	scope := *_options
+	scope.Notail = true
+	m, err := s.host.newMatcher(&scope)
+	if err != nil {
+		return nil, err
+	}
+	since, until := m.since, m.until
+	if since >= until {
+		return nil, errors.Errorf("since %s is not before until %s", _options.Since, _options.Until)
+	}
+
+	// 微秒
+	span := until - since
+	interval := uint64(_options.Interval) * uint64(time.Second/time.Microsecond)
+	if _options.Interval <= 0 {
+		interval = uint64(timelineIntervals[len(timelineIntervals)-1] / time.Microsecond)
+		for _, d := range timelineIntervals {
+			if span/uint64(d/time.Microsecond) < autoTimelineBuckets {
+				interval = uint64(d / time.Microsecond)
+				break
+			}
+		}
+	}
+	count := int((span + interval - 1) / interval)
+	if count > maxTimelineBuckets {
+		return nil, errors.Errorf("too many timeline buckets: %d > %d", count, maxTimelineBuckets)
+	}
+
+	timeline := &public.Timeline{
+		Since:    int64(since / 1000),
+		Until:    int64(until / 1000),
+		Interval: int64(interval / 1000),
+		Buckets:  make([]public.TimelineBucket, count),
+	}
+	for i := range timeline.Buckets {
+		timeline.Buckets[i].Timestamp = int64((since + uint64(i)*interval) / 1000)
+		if _options.SplitBy != "" {
+			timeline.Buckets[i].Groups = map[string]int{}
+		}
+	}
+	err = s.host.scan(s.ctx, m, "", true, func(_r *record) bool {
+		index := int((_r.realtime - since) / interval)
+		if index >= count {
+			index = count - 1
+		}
+		bucket := &timeline.Buckets[index]
+		bucket.Count++
+		if _options.SplitBy != "" {
+			bucket.Groups[timelineGroup(_r.raw, _options.SplitBy)]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
+
+// 日志所属的unit或priority，没有unit的内核、审计等日志按_TRANSPORT分组
+func timelineGroup(_raw map[string]interface{}, _split_by string) string {
+	field, fallback := "_SYSTEMD_UNIT", "_TRANSPORT"
+	if _split_by == public.SplitByPriority {
+		field, fallback = "PRIORITY", ""
+	}
+	if value, ok := _raw[field].(string); ok && value != "" {
+		return value
+	}
+	if value, ok := _raw[fallback].(string); ok && value != "" {
+		return value
+	}
+	return "-"
+}
+
+/*
+导出满足查询条件的全部日志，每积累exportChunkSize发送一段，最后发送done为true的一段
+
+返回已导出的日志条数，连接断开时停止导出
+*/
+func (s *session) export(_options *public.JournalctlOptions, _encoder public.ExportEncoder) (int, error) {
+	scope := *_options
+	scope.Notail = true
+	m, err := s.host.newMatcher(&scope)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := 0
+	buf := &bytes.Buffer{}
+	var encode_err error
+	err = s.host.scan(s.ctx, m, "", true, func(_r *record) bool {
+		if encode_err = _encoder.Encode(buf, _r.raw); encode_err != nil {
+			return false
+		}
+		entries++
+		if buf.Len() < exportChunkSize {
+			return true
+		}
+		if !s.writeData(public.ExportData, &public.ExportChunk{Data: buf.Bytes()}) {
+			encode_err = errors.New("archive session closed")
+			return false
+		}
+		buf = &bytes.Buffer{}
+		return true
+	})
+	if err == nil {
+		err = encode_err
+	}
+	if err != nil {
+		return entries, err
+	}
+	if buf.Len() > 0 && !s.writeData(public.ExportData, &public.ExportChunk{Data: buf.Bytes()}) {
+		return entries, errors.New("archive session closed")
+	}
+	return entries, nil
+}
+
+// 归档中的启动记录，序号与journalctl --list-boots一致
+func (h *host) listBoots() []public.BootInfo {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	boots := make([]public.BootInfo, 0, len(h.state.Boots))
+	for i, b := range h.state.Boots {
+		boots = append(boots, public.BootInfo{
+			Index:          i - len(h.state.Boots) + 1,
+			BootID:         b.BootID,
+			FirstTimestamp: int64(b.First / 1000),
+			LastTimestamp:  int64(b.Last / 1000),
+		})
+	}
+	return boots
+}
+
+// 与agent返回的服务单元列表格式一致，归档中只有日志中出现过的服务单元
+func (h *host) unitsMap() map[string][]string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return map[string][]string{
+		"user":      {"root:0"},
+		"transport": {"audit", "kernel"},
+		"systemd":   append([]string{}, h.state.Units...),
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 14:38:12 2026 +0800
+ */
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	segmentSuffix = ".jsonl.gz"
+	// 分段文件名为分段起始时间（UTC），按文件名排序即按时间排序
+	segmentLayout = "20060102T15"
+)
+
+/*
+分段文件：每行一条journalctl --output=json格式的日志，按日志时间划分分段
+
+每次上传追加一个gzip member，读取时逐个读取完整的member
+*/
+type segment struct {
+	path  string
+	start time.Time
+	end   time.Time
+	size  int64
+}
+
+// 分段中的一条日志
+type record struct {
+	realtime uint64
+	cursor   string
+	raw      map[string]interface{}
+}
+
+// 日志所在分段的起始时间，秒
+func (h *host) segmentStart(_realtime uint64) int64 {
+	seconds := int64(_realtime / 1000000)
+	return seconds - seconds%int64(h.segment/time.Second)
+}
+
+func (h *host) segmentPath(_start int64) string {
+	return filepath.Join(h.dir, time.Unix(_start, 0).UTC().Format(segmentLayout)+segmentSuffix)
+}
+
+// 按时间升序返回全部分段
+func (h *host) segments() ([]*segment, error) {
+	files, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, errors.Errorf("fail to read archive directory: %s", err.Error())
+	}
+	segments := []*segment{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		start, err := time.ParseInLocation(segmentLayout, strings.TrimSuffix(name, segmentSuffix), time.UTC)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{
+			path:  filepath.Join(h.dir, name),
+			start: start,
+			end:   start.Add(h.segment),
+			size:  info.Size(),
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+	return segments, nil
+}
+
+/*
+追加一个gzip member并同步至磁盘
+
+写入失败时截断至写入前的大小，避免不完整的member之后再追加数据；进程在写入过程中退出时，
+由repairSegment在下次追加前截断
+*/
+func appendSegment(_path string, _lines []byte) error {
+	file, err := os.OpenFile(_path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Errorf("fail to open archive segment: %s", err.Error())
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Errorf("fail to seek archive segment %s: %s", _path, err.Error())
+	}
+
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write(_lines)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		if terr := file.Truncate(offset); terr != nil {
+			return errors.Errorf("fail to write archive segment %s: %s, fail to truncate: %s", _path, err.Error(), terr.Error())
+		}
+		return errors.Errorf("fail to write archive segment %s: %s", _path, err.Error())
+	}
+	return nil
+}
+
+// 统计已读取字节数，gzip按字节读取时不会预读下一个member
+type countingReader struct {
+	reader *bufio.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(_p []byte) (int, error) {
+	n, err := r.reader.Read(_p)
+	r.count += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.count++
+	}
+	return b, err
+}
+
+/*
+返回分段中最后一个完整gzip member的结束位置
+
+_fn不为nil时依次读取每个完整member中的内容
+*/
+func scanSegment(_file io.Reader, _fn func(_member []byte)) int64 {
+	counter := &countingReader{reader: bufio.NewReader(_file)}
+	good := int64(0)
+	reader, err := gzip.NewReader(counter)
+	for err == nil {
+		reader.Multistream(false)
+		member, rerr := io.ReadAll(reader)
+		if rerr != nil {
+			break
+		}
+		good = counter.count
+		if _fn != nil {
+			_fn(member)
+		}
+		err = reader.Reset(counter)
+	}
+	return good
+}
+
+// 截断分段末尾不完整的gzip member，返回截断的字节数；分段不存在时不处理
+func repairSegment(_path string) (int64, error) {
+	file, err := os.OpenFile(_path, os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Errorf("fail to open archive segment: %s", err.Error())
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, errors.Errorf("fail to stat archive segment %s: %s", _path, err.Error())
+	}
+
+	good := scanSegment(file, nil)
+	if good == info.Size() {
+		return 0, nil
+	}
+	if err := file.Truncate(good); err != nil {
+		return 0, errors.Errorf("fail to truncate archive segment %s: %s", _path, err.Error())
+	}
+	if err := file.Sync(); err != nil {
+		return 0, errors.Errorf("fail to sync archive segment %s: %s", _path, err.Error())
+	}
+	return info.Size() - good, nil
+}
+
+/*
+读取分段中的全部日志并按时间升序排列
+
+只读取完整的gzip member；游标相同的日志只保留一条：分段写入后、state.json保存前进程退出时，
+agent会从已记录的游标重新上传这部分日志
+*/
+func readSegment(_path string) ([]*record, error) {
+	file, err := os.Open(_path)
+	if err != nil {
+		return nil, errors.Errorf("fail to open archive segment: %s", err.Error())
+	}
+	defer file.Close()
+
+	records := []*record{}
+	seen := map[string]struct{}{}
+	scanSegment(file, func(_member []byte) {
+		scanner := bufio.NewScanner(bytes.NewReader(_member))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			raw := map[string]interface{}{}
+			if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+				continue
+			}
+			realtime_str, _ := raw["__REALTIME_TIMESTAMP"].(string)
+			realtime, err := strconv.ParseUint(realtime_str, 10, 64)
+			if err != nil {
+				continue
+			}
+			cursor, _ := raw["__CURSOR"].(string)
+			if _, ok := seen[cursor]; ok {
+				continue
+			}
+			seen[cursor] = struct{}{}
+			records = append(records, &record{realtime: realtime, cursor: cursor, raw: raw})
+		}
+	})
+	sort.SliceStable(records, func(i, j int) bool { return records[i].realtime < records[j].realtime })
+	return records, nil
+}
+
+// journal游标中的t字段为日志的realtime（微秒，十六进制）
+func cursorRealtime(_cursor string) (uint64, error) {
+	for _, item := range strings.Split(_cursor, ";") {
+		if value, ok := strings.CutPrefix(item, "t="); ok {
+			return strconv.ParseUint(value, 16, 64)
+		}
+	}
+	return 0, errors.Errorf("invalid cursor: %s", _cursor)
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 16:58:30 2026 +0800
+ */
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 依次为_seqs中的日志生成分段中的行
+func segmentLines(t *testing.T, _seqs ...int) []byte {
+	buf := &bytes.Buffer{}
+	for _, seq := range _seqs {
+		line, err := json.Marshal(map[string]string{
+			"__CURSOR":             testCursor(seq),
+			"__REALTIME_TIMESTAMP": strconv.FormatInt(testBaseTime.Add(time.Duration(seq)*time.Second).UnixMicro(), 10),
+			"MESSAGE":              "entry " + strconv.Itoa(seq),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return buf.Bytes()
+}
+
+func readSeqs(t *testing.T, _path string) []int {
+	records, err := readSegment(_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recordSeqs(records)
+}
+
+func fileSize(t *testing.T, _path string) int64 {
+	info, err := os.Stat(_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// 进程在写入gzip member的过程中退出
+func TestSegmentTornMember(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20261001T08"+segmentSuffix)
+	for _, seqs := range [][]int{{1, 2}, {3}} {
+		if err := appendSegment(path, segmentLines(t, seqs...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good := fileSize(t, path)
+
+	member := &bytes.Buffer{}
+	writer := gzip.NewWriter(member)
+	writer.Write(segmentLines(t, 4))
+	writer.Close()
+	for _, torn := range []int{member.Len() / 2, member.Len() - 1, 5} {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write(member.Bytes()[:torn])
+		file.Close()
+
+		if got := readSeqs(t, path); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+			t.Errorf("torn %d bytes: read %v", torn, got)
+		}
+		truncated, err := repairSegment(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if truncated != int64(torn) || fileSize(t, path) != good {
+			t.Errorf("torn %d bytes: truncated %d, size %d, want %d", torn, truncated, fileSize(t, path), good)
+		}
+	}
+
+	if truncated, err := repairSegment(path); err != nil || truncated != 0 {
+		t.Errorf("complete segment: truncated %d, %v", truncated, err)
+	}
+	if err := appendSegment(path, segmentLines(t, 4, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSeqs(t, path); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("append after repair: read %v", got)
+	}
+}
+
+// 第一个member不完整时截断为空文件
+func TestSegmentTornFirstMember(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20261001T08"+segmentSuffix)
+	if err := os.WriteFile(path, []byte{0x1f, 0x8b, 8, 0}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSeqs(t, path); len(got) != 0 {
+		t.Errorf("read %v", got)
+	}
+	if truncated, err := repairSegment(path); err != nil || truncated != 4 {
+		t.Fatalf("truncated %d, %v", truncated, err)
+	}
+	if err := appendSegment(path, segmentLines(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSeqs(t, path); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("read %v", got)
+	}
+
+	if truncated, err := repairSegment(filepath.Join(filepath.Dir(path), "missing")); err != nil || truncated != 0 {
+		t.Errorf("missing segment: truncated %d, %v", truncated, err)
+	}
+}
+
+// 分段写入后、state.json保存前失败，agent从已记录的游标重新上传
+func TestSegmentDuplicateCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20261001T08"+segmentSuffix)
+	for _, seqs := range [][]int{{1, 2, 3}, {2, 3, 4}, {4, 5}} {
+		if err := appendSegment(path, segmentLines(t, seqs...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readSeqs(t, path); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("read %v", got)
+	}
+}
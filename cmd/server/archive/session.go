@@ -0,0 +1,372 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 16:21:45 2026 +0800
+ */
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+/*
+归档查询会话使用与agent相同的websocket协议，由进程内的http服务处理，
+
+websocket代理在agent不可达时连接该服务，权限检查、审计及多主机合并与查询agent时一致
+*/
+var (
+	sessionListener *pipeListener
+	sessionServer   *http.Server
+
+	sessionUpgrader = &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+)
+
+// 与agent一致，单个连接上同时存在的stream数上限
+const maxStreams = 16
+
+func startSessionServer() {
+	sessionListener = &pipeListener{
+		connCh: make(chan net.Conn),
+		done:   make(chan struct{}),
+	}
+	sessionServer = &http.Server{Handler: http.HandlerFunc(serveSession)}
+	go func() {
+		if err := sessionServer.Serve(sessionListener); err != nil && err != http.ErrServerClosed {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("archive session server exit: %s", err.Error()), false, false)
+		}
+	}()
+}
+
+func stopSessionServer() {
+	if sessionServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(global.RootCtx, 1*time.Second)
+	defer cancel()
+	sessionServer.Shutdown(ctx)
+}
+
+// 建立查询主机归档的websocket连接
+func Dial(_uuid string, _header http.Header) (*websocket.Conn, error) {
+	if lookup(_uuid) == nil {
+		return nil, errors.Errorf("no archive for machine %s", _uuid)
+	}
+	dialer := &websocket.Dialer{
+		NetDialContext: func(_ctx context.Context, _, _ string) (net.Conn, error) {
+			return sessionListener.dial(_ctx)
+		},
+		HandshakeTimeout: 10 * time.Second,
+	}
+	conn, _, err := dialer.Dial("ws://archive/ws/entry?uuid="+url.QueryEscape(_uuid), _header)
+	if err != nil {
+		return nil, errors.Errorf("dial to archive of %s failed: %s", _uuid, err.Error())
+	}
+	return conn, nil
+}
+
+// 查询单个主机归档的websocket会话，同一连接上的每个stream对应一个会话
+type session struct {
+	conn       *websocket.Conn
+	writeMutex *sync.Mutex
+	stream     string
+
+	host *host
+	ctx  context.Context
+
+	options *public.JournalctlOptions
+	matcher *matcher
+	total   int
+
+	// 上一次按From分页结束的位置，顺序翻页时直接从该游标继续读取
+	nextFrom   int
+	nextCursor string
+}
+
+func serveSession(_w http.ResponseWriter, _r *http.Request) {
+	uuid := _r.URL.Query().Get("uuid")
+	h := lookup(uuid)
+	if h == nil {
+		http.Error(_w, "archive not found", http.StatusNotFound)
+		return
+	}
+	conn, err := sessionUpgrader.Upgrade(_w, _r, nil)
+	if err != nil {
+		global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("failed to upgrade archive session: %s", err.Error()), false, false)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(global.RootCtx)
+	defer cancel()
+	write_mutex := &sync.Mutex{}
+	sessions := map[string]*session{}
+	// 归档中只有journal日志
+	file_source := _r.Header.Get("logSource") == "file"
+
+	for {
+		_, jmsgBytes, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		jmsg := &public.JMessage{}
+		if err := json.Unmarshal(jmsgBytes, jmsg); err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("error while unmarshalling json jmessage: %s, %s", err.Error(), string(jmsgBytes)), false, false)
+			return
+		}
+		if jmsg.Type == public.CancelStreamMsg {
+			delete(sessions, jmsg.Stream)
+			continue
+		}
+		s, ok := sessions[jmsg.Stream]
+		if !ok {
+			if len(sessions) >= maxStreams {
+				global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("too many streams on archive session of %s, drop stream %s", uuid, jmsg.Stream), false, false)
+				(&session{conn: conn, writeMutex: write_mutex, stream: jmsg.Stream}).writeError(public.ErrCodeTooManyStreams, errors.Errorf("too many streams, limit %d", maxStreams))
+				continue
+			}
+			s = &session{conn: conn, writeMutex: write_mutex, stream: jmsg.Stream, host: h, ctx: ctx}
+			sessions[jmsg.Stream] = s
+		}
+		if file_source {
+			s.writeData(public.LogEntryData, nil)
+			continue
+		}
+		s.handle(jmsg)
+	}
+}
+
+func (s *session) handle(_jmsg *public.JMessage) {
+	var err error
+	switch _jmsg.Type {
+	case public.UpdateOptionsMsg:
+		if _jmsg.JOptions == nil {
+			return
+		}
+		s.options = _jmsg.JOptions
+		s.nextFrom, s.nextCursor = 0, ""
+		if s.matcher, err = s.host.newMatcher(_jmsg.JOptions); err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, " "), false, false)
+			s.matcher = nil
+			s.writeError(public.ErrCodeInvalidOptions, err)
+			s.writeAbnormal()
+			return
+		}
+		if !_jmsg.JOptions.Notail {
+			// 主机离线时没有新的日志，实时模式只返回最近的若干条日志或after_cursor之后的日志
+			s.writeTail()
+			return
+		}
+		if s.total, err = s.host.count(s.ctx, s.matcher); err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to count archived entries"), false, false)
+		}
+		s.writePage(_jmsg.JOptions)
+	case public.UpdatePageMsg:
+		if s.matcher == nil || s.options == nil || !s.options.Notail || _jmsg.JOptions == nil {
+			return
+		}
+		page_options := *s.options
+		page_options.From = _jmsg.JOptions.From
+		page_options.Size = _jmsg.JOptions.Size
+		page_options.Cursor = _jmsg.JOptions.Cursor
+		page_options.Direction = _jmsg.JOptions.Direction
+		s.writePage(&page_options)
+	case public.UnitListMsg:
+		s.writeData(public.UnitData, s.host.unitsMap())
+	case public.BootListMsg:
+		s.writeData(public.BootData, s.host.listBoots())
+	case public.EntryDetailMsg:
+		var detail map[string]interface{}
+		if _jmsg.JOptions != nil {
+			if detail, err = s.host.entryDetail(s.ctx, _jmsg.JOptions.Cursor); err != nil {
+				global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to query entry detail"), false, false)
+				s.writeError(public.ErrCodeQueryFailed, err)
+			}
+		}
+		s.writeData(public.EntryDetailData, detail)
+	case public.ContextMsg:
+		var entry_context *public.EntryContext
+		if _jmsg.JOptions != nil {
+			if entry_context, err = s.entryContext(_jmsg.JOptions); err != nil {
+				global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to query entry context"), false, false)
+				s.writeError(public.ErrCodeQueryFailed, err)
+			}
+		}
+		s.writeData(public.ContextEntryData, entry_context)
+	case public.TimelineMsg:
+		var timeline *public.Timeline
+		if _jmsg.JOptions != nil {
+			if timeline, err = s.timeline(_jmsg.JOptions); err != nil {
+				global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to query timeline"), false, false)
+				s.writeError(public.ErrCodeQueryFailed, err)
+			}
+		}
+		s.writeData(public.TimelineData, timeline)
+	case public.ExportMsg:
+		format, _ := _jmsg.Data.(string)
+		if _jmsg.JOptions == nil {
+			_jmsg.JOptions = &public.JournalctlOptions{}
+		}
+		encoder, err := public.NewExportEncoder(format, _jmsg.JOptions.Fields)
+		if err != nil {
+			s.writeError(public.ErrCodeInvalidOptions, err)
+			s.writeData(public.ExportData, &public.ExportChunk{Done: true})
+			return
+		}
+		entries, err := s.export(_jmsg.JOptions, encoder)
+		if err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to export archived entries"), false, false)
+			s.writeError(public.ErrCodeQueryFailed, err)
+		}
+		s.writeData(public.ExportData, &public.ExportChunk{Done: true, Entries: entries})
+	default:
+		global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("unsupport message type: %+v", _jmsg), false, false)
+		s.writeError(public.ErrCodeUnsupported, errors.Errorf("unsupported message type: %d", _jmsg.Type))
+	}
+}
+
+func (s *session) writePage(_options *public.JournalctlOptions) {
+	page, err := s.page(_options)
+	if err != nil {
+		global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, "fail to query page"), false, false)
+		s.writeError(public.ErrCodeQueryFailed, err)
+		page = nil
+	}
+	s.writeData(public.LogEntryData, page)
+}
+
+// 断线重连时返回after_cursor之后的全部日志，否则返回最近的若干条
+func (s *session) writeTail() {
+	write := func(_r *record) bool {
+		return s.writeFollowEntry(generateEntry(_r.raw, s.options.Fields, s.matcher.grep), _r.cursor)
+	}
+	if after_cursor := s.options.AfterCursor; after_cursor != "" {
+		if err := s.host.scan(s.ctx, s.matcher, after_cursor, true, write); err != nil {
+			global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, " "), false, false)
+			s.writeError(public.ErrCodeJournalRead, err)
+			s.writeAbnormal()
+		}
+		return
+	}
+	records, err := s.host.fetch(s.ctx, s.matcher, "", false, followInitEntries)
+	if err != nil {
+		global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, " "), false, false)
+		return
+	}
+	for _, r := range records {
+		if !write(r) {
+			return
+		}
+	}
+}
+
+// 查询失败时通知前端，与agent一致：分页查询返回空的分页结果，实时查询返回空日志
+func (s *session) writeAbnormal() {
+	if s.options != nil && s.options.Notail {
+		s.writeData(public.LogEntryData, (*public.PageData)(nil))
+		return
+	}
+	after_cursor := ""
+	if s.options != nil {
+		after_cursor = s.options.AfterCursor
+	}
+	s.write(&public.StdoutData{Type: public.LogEntryData, Data: nil, Cursor: after_cursor})
+}
+
+func (s *session) writeData(_type public.StdoutDataType, _data interface{}) bool {
+	return s.write(&public.StdoutData{Type: _type, Data: _data})
+}
+
+// 实时查询的日志条目，与agent一致附带游标
+func (s *session) writeFollowEntry(_entry map[string]interface{}, _cursor string) bool {
+	return s.write(&public.StdoutData{Type: public.LogEntryData, Data: _entry, Cursor: _cursor})
+}
+
+func (s *session) write(_data *public.StdoutData) bool {
+	return s.writeMessage(&public.JMessage{
+		Type:   public.DataMsg,
+		Data:   _data,
+		Stream: s.stream,
+	})
+}
+
+// 查询失败时在返回空结果前通知前端错误原因
+func (s *session) writeError(_code string, _err error) {
+	s.writeMessage(&public.JMessage{
+		Type:   public.ErrorMsg,
+		Data:   &public.ErrorInfo{Code: _code, Message: _err.Error()},
+		Stream: s.stream,
+	})
+}
+
+func (s *session) writeMessage(_jmsg *public.JMessage) bool {
+	jmsgBytes, err := json.Marshal(_jmsg)
+	if err != nil {
+		global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("fail to marshal message: %s", err.Error()), false, false)
+		return true
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	if err := s.conn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		global.ERManager.ErrorTransmit("archive", "error", errors.Errorf("error while writing message to archive client: %s", err.Error()), false, false)
+		return false
+	}
+	return true
+}
+
+// 进程内的连接监听，每次dial创建一对net.Pipe连接
+type pipeListener struct {
+	connCh chan net.Conn
+	done   chan struct{}
+	once   sync.Once
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "archive" }
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connCh:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) dial(_ctx context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.connCh <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-_ctx.Done():
+		return nil, _ctx.Err()
+	}
+}
@@ -0,0 +1,423 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 14:06:51 2026 +0800
+ */
+package archive
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSegmentHours = 1
+	stateFile           = "state.json"
+	// 检查保留策略的周期
+	retentionPeriod = 10 * time.Minute
+)
+
+// agent上传的游标与logs server记录的不一致
+var ErrCursorMismatch = errors.New("archive cursor mismatch")
+
+var store *archiveStore
+
+type archiveStore struct {
+	path      string
+	segment   time.Duration
+	retention time.Duration
+	maxSize   int64
+
+	mutex sync.Mutex
+	hosts map[string]*host
+}
+
+// 单个主机的归档，目录名为机器UUID
+type host struct {
+	dir     string
+	segment time.Duration
+
+	mutex sync.RWMutex
+	state hostState
+	// 本次运行中已截断末尾不完整数据的分段
+	repaired map[string]bool
+}
+
+// 主机归档状态，每次上传后写入state.json
+type hostState struct {
+	UUID string `json:"uuid"`
+	IP   string `json:"ip"`
+	// 最后一条归档日志的游标
+	Cursor string `json:"cursor"`
+	// 最后一次上传的时间，毫秒
+	Updated int64 `json:"updated"`
+	// 日志中出现过的服务单元及启动记录，供服务单元列表、启动列表查询
+	Units []string     `json:"units"`
+	Boots []*bootRange `json:"boots"`
+}
+
+// 一次启动的第一条和最后一条归档日志的时间，微秒
+type bootRange struct {
+	BootID string `json:"boot_id"`
+	First  uint64 `json:"first"`
+	Last   uint64 `json:"last"`
+}
+
+// 加载已有的归档并启动保留策略检查，未开启时不归档
+func Init() error {
+	archiveconf := conf.Global_Config.Archive
+	if archiveconf == nil || !archiveconf.Enabled {
+		return nil
+	}
+	if archiveconf.Path == "" {
+		return errors.New("archive path is required when archive is enabled")
+	}
+	if err := checkTokens(archiveconf.Tokens); err != nil {
+		return err
+	}
+	s := &archiveStore{
+		path:      archiveconf.Path,
+		segment:   time.Duration(archiveconf.SegmentHours) * time.Hour,
+		retention: time.Duration(archiveconf.RetentionDays) * 24 * time.Hour,
+		maxSize:   archiveconf.MaxSize,
+		hosts:     map[string]*host{},
+	}
+	if archiveconf.SegmentHours <= 0 {
+		s.segment = defaultSegmentHours * time.Hour
+	}
+	if err := os.MkdirAll(s.path, 0700); err != nil {
+		return errors.Errorf("fail to create archive directory: %s", err.Error())
+	}
+
+	dirs, err := os.ReadDir(s.path)
+	if err != nil {
+		return errors.Errorf("fail to read archive directory: %s", err.Error())
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		h := &host{dir: filepath.Join(s.path, d.Name()), segment: s.segment}
+		bytes, err := os.ReadFile(filepath.Join(h.dir, stateFile))
+		if err != nil {
+			global.ERManager.ErrorTransmit("archive", "warn", errors.Errorf("skip archive %s: %s", d.Name(), err.Error()), false, false)
+			continue
+		}
+		if err := json.Unmarshal(bytes, &h.state); err != nil {
+			global.ERManager.ErrorTransmit("archive", "warn", errors.Errorf("skip archive %s: %s", d.Name(), err.Error()), false, false)
+			continue
+		}
+		s.hosts[d.Name()] = h
+	}
+
+	startSessionServer()
+	store = s
+
+	global.ERManager.Wg.Add(1)
+	go s.enforceRetention()
+	global.ERManager.ErrorTransmit("archive", "info", errors.Errorf("log archive enabled: %s, %d hosts", s.path, len(s.hosts)), false, false)
+	return nil
+}
+
+// 按令牌确定上传日志的机器，每台机器须配置不同的令牌
+func checkTokens(_tokens map[string]string) error {
+	if len(_tokens) == 0 {
+		return errors.New("archive tokens are required when archive is enabled")
+	}
+	seen := map[string]string{}
+	for uuid, token := range _tokens {
+		if token == "" {
+			return errors.Errorf("archive token of machine %s is empty", uuid)
+		}
+		if other, ok := seen[token]; ok {
+			return errors.Errorf("machines %s and %s share the same archive token", other, uuid)
+		}
+		seen[token] = uuid
+	}
+	return nil
+}
+
+/*
+根据agent上传时携带的令牌查找机器UUID
+
+逐个比较全部令牌，比较时间与令牌是否匹配无关
+*/
+func MachineByToken(_token string) (string, bool) {
+	uuid := ""
+	for u, token := range conf.Global_Config.Archive.Tokens {
+		if subtle.ConstantTimeCompare([]byte(_token), []byte(token)) == 1 {
+			uuid = u
+		}
+	}
+	return uuid, uuid != ""
+}
+
+func Enabled() bool {
+	return store != nil
+}
+
+func Close() {
+	stopSessionServer()
+}
+
+// 主机是否已有归档
+func Has(_uuid string) bool {
+	return lookup(_uuid) != nil
+}
+
+func lookup(_uuid string) *host {
+	if store == nil || _uuid == "" {
+		return nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.hosts[_uuid]
+}
+
+// 主机已归档的最后一条日志的游标
+func Cursor(_uuid string) string {
+	h := lookup(_uuid)
+	if h == nil {
+		return ""
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.state.Cursor
+}
+
+/*
+追加agent上传的一批日志，返回最后一条日志的游标
+
+批次的after与已记录的游标不一致时返回ErrCursorMismatch及已记录的游标，agent据此重新上传
+*/
+func Append(_machine *common.MachineNode, _batch *public.ArchiveBatch) (string, error) {
+	if store == nil {
+		return "", errors.New("archive is not enabled")
+	}
+	store.mutex.Lock()
+	h, ok := store.hosts[_machine.UUID]
+	if !ok {
+		h = &host{dir: filepath.Join(store.path, _machine.UUID), segment: store.segment}
+		h.state.UUID = _machine.UUID
+		if err := os.MkdirAll(h.dir, 0700); err != nil {
+			store.mutex.Unlock()
+			return "", errors.Errorf("fail to create archive directory: %s", err.Error())
+		}
+		store.hosts[_machine.UUID] = h
+	}
+	store.mutex.Unlock()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _batch.After != h.state.Cursor {
+		return h.state.Cursor, ErrCursorMismatch
+	}
+	if len(_batch.Entries) == 0 {
+		return h.state.Cursor, nil
+	}
+
+	// 过期的日志只更新游标，不写入分段
+	expire := uint64(0)
+	if store.retention > 0 {
+		expire = uint64(time.Now().Add(-store.retention).UnixMicro())
+	}
+	segments := map[int64]*bytes.Buffer{}
+	cursor := h.state.Cursor
+	for _, raw := range _batch.Entries {
+		entry := struct {
+			Cursor   string `json:"__CURSOR"`
+			Realtime string `json:"__REALTIME_TIMESTAMP"`
+			BootID   string `json:"_BOOT_ID"`
+			Unit     string `json:"_SYSTEMD_UNIT"`
+		}{}
+		if err := json.Unmarshal(raw, &entry); err != nil || entry.Cursor == "" {
+			return h.state.Cursor, errors.Errorf("invalid journal entry: %s", string(raw))
+		}
+		cursor = entry.Cursor
+		realtime, err := strconv.ParseUint(entry.Realtime, 10, 64)
+		if err != nil || realtime < expire {
+			continue
+		}
+
+		start := h.segmentStart(realtime)
+		buf, ok := segments[start]
+		if !ok {
+			buf = &bytes.Buffer{}
+			segments[start] = buf
+		}
+		if err := json.Compact(buf, raw); err != nil {
+			return h.state.Cursor, errors.Errorf("invalid journal entry: %s", err.Error())
+		}
+		buf.WriteByte('\n')
+		h.addUnit(entry.Unit)
+		h.addBoot(entry.BootID, realtime)
+	}
+
+	starts := []int64{}
+	for start := range segments {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts {
+		path := h.segmentPath(start)
+		if !h.repaired[path] {
+			truncated, err := repairSegment(path)
+			if err != nil {
+				return h.state.Cursor, err
+			}
+			if truncated > 0 {
+				global.ERManager.ErrorTransmit("archive", "warn", errors.Errorf("incomplete data at the end of archive segment %s truncated: %d bytes", path, truncated), false, false)
+			}
+			if h.repaired == nil {
+				h.repaired = map[string]bool{}
+			}
+			h.repaired[path] = true
+		}
+		if err := appendSegment(path, segments[start].Bytes()); err != nil {
+			return h.state.Cursor, err
+		}
+	}
+
+	h.state.IP = _machine.IP
+	h.state.Cursor = cursor
+	h.state.Updated = time.Now().UnixMilli()
+	if err := h.saveState(); err != nil {
+		return h.state.Cursor, err
+	}
+	return cursor, nil
+}
+
+func (h *host) addUnit(_unit string) {
+	unit := strings.TrimSuffix(_unit, ".service")
+	if unit == "" || unit == _unit {
+		return
+	}
+	i := sort.SearchStrings(h.state.Units, unit)
+	if i < len(h.state.Units) && h.state.Units[i] == unit {
+		return
+	}
+	h.state.Units = append(h.state.Units, "")
+	copy(h.state.Units[i+1:], h.state.Units[i:])
+	h.state.Units[i] = unit
+}
+
+func (h *host) addBoot(_boot_id string, _realtime uint64) {
+	if _boot_id == "" {
+		return
+	}
+	for _, b := range h.state.Boots {
+		if b.BootID == _boot_id {
+			if _realtime < b.First {
+				b.First = _realtime
+			}
+			if _realtime > b.Last {
+				b.Last = _realtime
+			}
+			return
+		}
+	}
+	h.state.Boots = append(h.state.Boots, &bootRange{BootID: _boot_id, First: _realtime, Last: _realtime})
+	sort.SliceStable(h.state.Boots, func(i, j int) bool { return h.state.Boots[i].First < h.state.Boots[j].First })
+}
+
+// 先写入临时文件再重命名，避免进程退出时state.json不完整
+func (h *host) saveState() error {
+	bytes, err := json.Marshal(&h.state)
+	if err != nil {
+		return errors.Errorf("fail to marshal archive state: %s", err.Error())
+	}
+	tmp := filepath.Join(h.dir, stateFile+".tmp")
+	if err := os.WriteFile(tmp, bytes, 0600); err != nil {
+		return errors.Errorf("fail to write archive state: %s", err.Error())
+	}
+	if err := os.Rename(tmp, filepath.Join(h.dir, stateFile)); err != nil {
+		return errors.Errorf("fail to write archive state: %s", err.Error())
+	}
+	return nil
+}
+
+// 定期删除超过保留天数或超出主机最大字节数的最早分段
+func (s *archiveStore) enforceRetention() {
+	defer global.ERManager.Wg.Done()
+
+	ticker := time.NewTicker(retentionPeriod)
+	defer ticker.Stop()
+	for {
+		s.mutex.Lock()
+		hosts := make([]*host, 0, len(s.hosts))
+		for _, h := range s.hosts {
+			hosts = append(hosts, h)
+		}
+		s.mutex.Unlock()
+
+		for _, h := range hosts {
+			if err := h.removeExpired(s.retention, s.maxSize); err != nil {
+				global.ERManager.ErrorTransmit("archive", "error", errors.Wrap(err, " "), false, false)
+			}
+		}
+
+		select {
+		case <-global.ERManager.GoCancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *host) removeExpired(_retention time.Duration, _max_size int64) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	segments, err := h.segments()
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	for _, seg := range segments {
+		total += seg.size
+	}
+	now := time.Now()
+	for len(segments) > 0 {
+		seg := segments[0]
+		expired := _retention > 0 && now.Sub(seg.end) > _retention
+		oversize := _max_size > 0 && total > _max_size
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(seg.path); err != nil {
+			return errors.Errorf("fail to remove archive segment: %s", err.Error())
+		}
+		total -= seg.size
+		segments = segments[1:]
+		global.ERManager.ErrorTransmit("archive", "info", errors.Errorf("archive segment removed: %s", seg.path), false, false)
+	}
+
+	// 删除已没有归档日志的启动记录
+	boots := []*bootRange{}
+	for _, b := range h.state.Boots {
+		if len(segments) > 0 && b.Last >= uint64(segments[0].start.UnixMicro()) {
+			boots = append(boots, b)
+		}
+	}
+	if len(boots) != len(h.state.Boots) {
+		h.state.Boots = boots
+		return h.saveState()
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 16:20:51 2026 +0800
+ */
+package archive
+
+import (
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+)
+
+func TestCheckTokens(t *testing.T) {
+	tests := []struct {
+		tokens map[string]string
+		ok     bool
+	}{
+		{map[string]string{"uuid-1": "token-1", "uuid-2": "token-2"}, true},
+		{nil, false},
+		{map[string]string{}, false},
+		{map[string]string{"uuid-1": ""}, false},
+		{map[string]string{"uuid-1": "token", "uuid-2": "token"}, false},
+	}
+	for _, tt := range tests {
+		if err := checkTokens(tt.tokens); (err == nil) != tt.ok {
+			t.Errorf("%v: got error %v", tt.tokens, err)
+		}
+	}
+}
+
+func TestMachineByToken(t *testing.T) {
+	conf.Global_Config = &conf.ServerConfig{Archive: &conf.ArchiveConf{
+		Tokens: map[string]string{"uuid-1": "token-1", "uuid-2": "token-2"},
+	}}
+	t.Cleanup(func() { conf.Global_Config = nil })
+
+	tests := []struct {
+		token string
+		uuid  string
+	}{
+		{"token-1", "uuid-1"},
+		{"token-2", "uuid-2"},
+		{"token-3", ""},
+		{"token", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		uuid, ok := MachineByToken(tt.token)
+		if uuid != tt.uuid || ok != (tt.uuid != "") {
+			t.Errorf("%q: got %q %v, want %q", tt.token, uuid, ok, tt.uuid)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 09:12:40 2026 +0800
+ */
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMaxSize  = 100 * 1024 * 1024
+	defaultMaxFiles = 10
+)
+
+// 审计事件
+const (
+	EventSessionStart = "session_start"
+	EventQuery        = "query"
+	EventSessionEnd   = "session_end"
+)
+
+// 审计日志中的一条记录，时间戳单位为毫秒
+type Record struct {
+	Event     string   `json:"event"`
+	SessionID string   `json:"session_id"`
+	User      string   `json:"user"`
+	SourceIP  string   `json:"source_ip"`
+	Agents    []string `json:"agents"`
+	// 会话或查询的开始、结束时间，session_start事件的结束时间为0
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// 查询消息类型及查询条件
+	Type    int                       `json:"type,omitempty"`
+	Options *public.JournalctlOptions `json:"options,omitempty"`
+	// 同一连接上并发查询的stream
+	Stream string `json:"stream,omitempty"`
+	// 返回客户端的日志条数
+	Entries int `json:"entries"`
+	// 未通过权限检查的原因
+	Denied string `json:"denied,omitempty"`
+}
+
+var writer *auditWriter
+
+type auditWriter struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// 打开审计日志文件，未配置时不记录
+func Init() error {
+	if conf.Global_Config.Audit == nil || conf.Global_Config.Audit.Path == "" {
+		global.ERManager.ErrorTransmit("audit", "warn", errors.New("audit path is empty, log queries are not audited"), false, false)
+		return nil
+	}
+	auditconf := conf.Global_Config.Audit
+	w := &auditWriter{
+		path:     auditconf.Path,
+		maxSize:  auditconf.MaxSize,
+		maxFiles: auditconf.MaxFiles,
+	}
+	if w.maxSize <= 0 {
+		w.maxSize = defaultMaxSize
+	}
+	if w.maxFiles <= 0 {
+		w.maxFiles = defaultMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return errors.Errorf("fail to create audit directory: %s", err.Error())
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	writer = w
+	return nil
+}
+
+func Close() {
+	if writer == nil {
+		return
+	}
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.file.Close()
+}
+
+func (w *auditWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Errorf("fail to open audit file: %s", err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Errorf("fail to stat audit file: %s", err.Error())
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// 每条记录占一行，文件超过maxSize时轮转为path.1、path.2...
+func (w *auditWriter) write(_record *Record) {
+	line, err := json.Marshal(_record)
+	if err != nil {
+		global.ERManager.ErrorTransmit("audit", "error", errors.Errorf("fail to marshal audit record: %s", err.Error()), false, false)
+		return
+	}
+	line = append(line, '\n')
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			global.ERManager.ErrorTransmit("audit", "error", errors.Wrap(err, " "), false, false)
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		global.ERManager.ErrorTransmit("audit", "error", errors.Errorf("fail to write audit record: %s", err.Error()), false, false)
+	}
+}
+
+func (w *auditWriter) rotate() error {
+	w.file.Close()
+	os.Remove(rotatedPath(w.path, w.maxFiles))
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedPath(w.path, i), rotatedPath(w.path, i+1))
+	}
+	if err := os.Rename(w.path, rotatedPath(w.path, 1)); err != nil {
+		global.ERManager.ErrorTransmit("audit", "error", errors.Errorf("fail to rotate audit file: %s", err.Error()), false, false)
+	}
+	return w.open()
+}
+
+func rotatedPath(_path string, _i int) string {
+	return fmt.Sprintf("%s.%d", _path, _i)
+}
+
+/*
+一次websocket会话的审计，查询在被同一stream的下一次查询替代、stream结束或会话结束时记录
+
+同一连接上不同stream的查询并发进行，分别统计返回的日志条数
+*/
+type Session struct {
+	mutex sync.Mutex
+
+	base    Record
+	queries map[string]*Record
+	total   int
+}
+
+// 记录会话开始，未开启审计时返回nil，nil的Session的方法均不做任何操作
+func NewSession(_user, _source_ip string, _agents []string) *Session {
+	if writer == nil {
+		return nil
+	}
+	now := time.Now()
+	s := &Session{
+		base: Record{
+			SessionID: fmt.Sprintf("%x", now.UnixNano()),
+			User:      _user,
+			SourceIP:  _source_ip,
+			Agents:    _agents,
+			Start:     now.UnixMilli(),
+		},
+		queries: map[string]*Record{},
+	}
+	record := s.base
+	record.Event = EventSessionStart
+	writer.write(&record)
+	return s
+}
+
+// 开始_jmsg.Stream上新的查询，结束该stream上一次查询，被拒绝的查询立即记录
+func (s *Session) Query(_jmsg *public.JMessage, _denied string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.endQuery(_jmsg.Stream)
+
+	record := s.base
+	record.Event = EventQuery
+	record.Start = time.Now().UnixMilli()
+	record.Type = _jmsg.Type
+	record.Options = _jmsg.JOptions
+	record.Stream = _jmsg.Stream
+	if _denied != "" {
+		record.Denied = _denied
+		record.End = record.Start
+		writer.write(&record)
+		return
+	}
+	s.queries[_jmsg.Stream] = &record
+}
+
+// 累加_stream上返回客户端的日志条数
+func (s *Session) AddEntries(_stream string, _n int) {
+	if s == nil || _n == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.total += _n
+	if query, ok := s.queries[_stream]; ok {
+		query.Entries += _n
+	}
+}
+
+// 统计发送至客户端的消息中的日志条数，计入所属stream的查询
+func (s *Session) AddMessage(_message []byte) {
+	if s == nil {
+		return
+	}
+	s.AddEntries(CountEntries(_message))
+}
+
+// stream结束时记录其查询
+func (s *Session) EndStream(_stream string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.endQuery(_stream)
+}
+
+func (s *Session) Close() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.base.End != 0 {
+		return
+	}
+	streams := make([]string, 0, len(s.queries))
+	for stream := range s.queries {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	for _, stream := range streams {
+		s.endQuery(stream)
+	}
+	s.base.End = time.Now().UnixMilli()
+	record := s.base
+	record.Event = EventSessionEnd
+	record.Entries = s.total
+	writer.write(&record)
+}
+
+func (s *Session) endQuery(_stream string) {
+	query, ok := s.queries[_stream]
+	if !ok {
+		return
+	}
+	query.End = time.Now().UnixMilli()
+	writer.write(query)
+	delete(s.queries, _stream)
+}
+
+// 是否为需要审计的查询消息
+func IsQuery(_jmsg_type int) bool {
+	switch _jmsg_type {
+	case public.UpdateOptionsMsg, public.EntryDetailMsg, public.ContextMsg, public.TimelineMsg, public.ExportMsg, public.AlertRuleMsg:
+		return true
+	}
+	return false
+}
+
+// 统计发送至客户端的消息中包含的日志条数，返回消息所属的stream及条数
+func CountEntries(_message []byte) (string, int) {
+	msg := struct {
+		Type   int    `json:"type"`
+		Stream string `json:"stream"`
+		Data   *struct {
+			Type public.StdoutDataType `json:"type"`
+			Data json.RawMessage       `json:"data"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(_message, &msg); err != nil || msg.Type != public.DataMsg || msg.Data == nil {
+		return msg.Stream, 0
+	}
+	return msg.Stream, countData(msg.Data.Type, msg.Data.Data)
+}
+
+func countData(_type public.StdoutDataType, _data json.RawMessage) int {
+	hits := struct {
+		Total *int              `json:"total"`
+		Hits  []json.RawMessage `json:"hits"`
+	}{}
+	switch _type {
+	case public.LogEntryData:
+		if err := json.Unmarshal(_data, &hits); err != nil {
+			return 0
+		}
+		// 分页结果包含total，实时查询每条消息为一条日志
+		if hits.Total != nil {
+			return len(hits.Hits)
+		}
+		if string(_data) == "null" {
+			return 0
+		}
+		return 1
+	case public.ExportData:
+		// 导出结果在最后一段中返回总条数
+		chunk := struct {
+			Done    bool `json:"done"`
+			Entries int  `json:"entries"`
+		}{}
+		if err := json.Unmarshal(_data, &chunk); err != nil || !chunk.Done {
+			return 0
+		}
+		return chunk.Entries
+	case public.ContextEntryData:
+		if err := json.Unmarshal(_data, &hits); err != nil {
+			return 0
+		}
+		return len(hits.Hits)
+	case public.EntryDetailData:
+		if string(_data) == "null" {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
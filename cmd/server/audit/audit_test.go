@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 17:52:06 2026 +0800
+ */
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func openTestWriter(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w := &auditWriter{path: path, maxSize: defaultMaxSize, maxFiles: defaultMaxFiles}
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+	writer = w
+	t.Cleanup(func() {
+		w.file.Close()
+		writer = nil
+	})
+	return path
+}
+
+func readRecords(t *testing.T, _path string) []*Record {
+	f, err := os.Open(_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records := []*Record{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// 分页结果消息，_n为日志条数
+func pageMessage(t *testing.T, _stream string, _n int) []byte {
+	hits := make([]map[string]interface{}, _n)
+	message, err := json.Marshal(&public.JMessage{
+		Type:   public.DataMsg,
+		Stream: _stream,
+		Data:   &public.StdoutData{Type: public.LogEntryData, Data: &public.PageData{Total: _n, Hits: hits}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestSessionStreams(t *testing.T) {
+	path := openTestWriter(t)
+	s := NewSession("admin", "10.0.0.1", []string{"10.0.0.2"})
+
+	s.Query(&public.JMessage{Type: public.UpdateOptionsMsg}, "")
+	s.Query(&public.JMessage{Type: public.UpdateOptionsMsg, Stream: "a"}, "")
+	s.Query(&public.JMessage{Type: public.TimelineMsg, Stream: "b"}, "denied")
+	s.AddMessage(pageMessage(t, "", 3))
+	s.AddMessage(pageMessage(t, "a", 5))
+	// 并发查询互不替代：stream a的新查询只结束a上一次查询
+	s.Query(&public.JMessage{Type: public.UpdateOptionsMsg, Stream: "a"}, "")
+	s.AddMessage(pageMessage(t, "a", 2))
+	s.AddMessage(pageMessage(t, "", 1))
+	s.EndStream("a")
+	s.AddMessage(pageMessage(t, "a", 7))
+	s.Close()
+
+	type summary struct {
+		event   string
+		stream  string
+		entries int
+		denied  bool
+	}
+	want := []summary{
+		{EventSessionStart, "", 0, false},
+		{EventQuery, "b", 0, true},
+		{EventQuery, "a", 5, false},
+		{EventQuery, "a", 2, false},
+		{EventQuery, "", 4, false},
+		{EventSessionEnd, "", 18, false},
+	}
+	records := readRecords(t, path)
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, record := range records {
+		got := summary{record.Event, record.Stream, record.Entries, record.Denied != ""}
+		if got != want[i] {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want[i])
+		}
+		if record.Event != EventSessionStart && record.End < record.Start {
+			t.Errorf("record %d: end %d before start %d", i, record.End, record.Start)
+		}
+	}
+}
+
+// 未开启审计时Session为nil，不解析消息
+func TestNilSession(t *testing.T) {
+	writer = nil
+	s := NewSession("admin", "10.0.0.1", nil)
+	if s != nil {
+		t.Fatal("got a session without audit writer")
+	}
+	s.Query(&public.JMessage{Type: public.UpdateOptionsMsg}, "")
+	s.AddMessage([]byte("not json"))
+	s.EndStream("a")
+	s.Close()
+}
+
+func TestCountEntries(t *testing.T) {
+	marshal := func(_jmsg *public.JMessage) []byte {
+		message, err := json.Marshal(_jmsg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+	tests := []struct {
+		name    string
+		message []byte
+		stream  string
+		entries int
+	}{
+		{"page", pageMessage(t, "s1", 4), "s1", 4},
+		{"follow", marshal(&public.JMessage{Type: public.DataMsg, Data: &public.StdoutData{Type: public.LogEntryData, Data: map[string]string{"message": "x"}}}), "", 1},
+		{"detail", marshal(&public.JMessage{Type: public.DataMsg, Stream: "d", Data: &public.StdoutData{Type: public.EntryDetailData, Data: map[string]string{"MESSAGE": "x"}}}), "d", 1},
+		{"not data", marshal(&public.JMessage{Type: public.ErrorMsg, Stream: "e"}), "e", 0},
+		{"invalid", []byte("{"), "", 0},
+	}
+	for _, tt := range tests {
+		stream, entries := CountEntries(tt.message)
+		if stream != tt.stream || entries != tt.entries {
+			t.Errorf("%s: got %q %d, want %q %d", tt.name, stream, entries, tt.stream, tt.entries)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Mon Oct 19 09:47:03 2026 +0800
+ */
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const maxSearchSize = 1000
+
+// 审计日志查询条件，为空的字段不限制
+type Filter struct {
+	User      string
+	SourceIP  string
+	Agent     string
+	Event     string
+	SessionID string
+	// 记录开始时间范围，毫秒
+	Since int64
+	Until int64
+	// 从1开始的页码及每页条数
+	Page int
+	Size int
+}
+
+func (f *Filter) match(_record *Record) bool {
+	if f.User != "" && _record.User != f.User {
+		return false
+	}
+	if f.SourceIP != "" && _record.SourceIP != f.SourceIP {
+		return false
+	}
+	if f.Event != "" && _record.Event != f.Event {
+		return false
+	}
+	if f.SessionID != "" && _record.SessionID != f.SessionID {
+		return false
+	}
+	if f.Since != 0 && _record.Start < f.Since {
+		return false
+	}
+	if f.Until != 0 && _record.Start > f.Until {
+		return false
+	}
+	if f.Agent != "" {
+		for _, agent := range _record.Agents {
+			if agent == f.Agent {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// 按时间倒序分页返回满足条件的记录及总数
+func Search(_filter *Filter) ([]*Record, int, error) {
+	if writer == nil {
+		return nil, 0, errors.New("audit is not enabled")
+	}
+	if _filter.Page <= 0 {
+		_filter.Page = 1
+	}
+	if _filter.Size <= 0 {
+		_filter.Size = 20
+	}
+	if _filter.Size > maxSearchSize {
+		_filter.Size = maxSearchSize
+	}
+
+	// 第一次遍历统计总数，第二次遍历取出当前页
+	total := 0
+	if err := writer.scan(_filter, func(_ *Record) { total++ }); err != nil {
+		return nil, 0, err
+	}
+	// 倒序第[first, last)条对应正序的[total-last, total-first)
+	first := (_filter.Page - 1) * _filter.Size
+	last := first + _filter.Size
+	if first >= total {
+		return []*Record{}, total, nil
+	}
+	if last > total {
+		last = total
+	}
+	records := make([]*Record, 0, last-first)
+	index := 0
+	err := writer.scan(_filter, func(_record *Record) {
+		if index >= total-last && index < total-first {
+			records = append(records, _record)
+		}
+		index++
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, k := 0, len(records)-1; i < k; i, k = i+1, k-1 {
+		records[i], records[k] = records[k], records[i]
+	}
+	return records, total, nil
+}
+
+// 从最早的轮转文件开始按写入顺序遍历满足条件的记录
+func (w *auditWriter) scan(_filter *Filter, _fn func(*Record)) error {
+	w.mutex.Lock()
+	files := []string{}
+	for i := w.maxFiles; i >= 1; i-- {
+		files = append(files, rotatedPath(w.path, i))
+	}
+	files = append(files, w.path)
+	w.mutex.Unlock()
+
+	for _, path := range files {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Errorf("fail to open audit file: %s", err.Error())
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			record := &Record{}
+			if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+				continue
+			}
+			if _filter.match(record) {
+				_fn(record)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return errors.Errorf("fail to read audit file %s: %s", path, err.Error())
+		}
+	}
+	return nil
+}
@@ -27,6 +27,12 @@ var config_dir string
 type ServerConfig struct {
 	Logs    *LogsConf
 	PilotGo *PilotGoConf
+	ACL     *ACLConf        `yaml:"acl"`
+	Audit   *AuditConf      `yaml:"audit"`
+	Archive *ArchiveConf    `yaml:"archive"`
+	Alert   *AlertConf      `yaml:"alert"`
+	Metrics *MetricsConf    `yaml:"metrics"`
+	Search  *SearchConf     `yaml:"saved_search"`
 	Logopts *logger.LogOpts `yaml:"log"`
 }
 
@@ -13,8 +13,110 @@ type LogsConf struct {
 	KeyFile       string `yaml:"key_file"`
 	Addr          string `yaml:"server_listen_addr"`
 	Addr_target   string `yaml:"server_target_addr"`
+	// 连接logs agent时使用的CA及客户端证书（mTLS），为空时不校验agent证书
+	AgentCAFile   string `yaml:"agent_ca_file"`
+	AgentCertFile string `yaml:"agent_client_cert_file"`
+	AgentKeyFile  string `yaml:"agent_client_key_file"`
 }
 
 type PilotGoConf struct {
 	Addr string `yaml:"addr"`
 }
+
+// 日志查询权限控制，未开启时不限制
+type ACLConf struct {
+	Enabled bool `yaml:"enabled"`
+	// 校验PilotGo用户token（HS256）的密钥，与PilotGo配置文件中的jwt密钥一致
+	JwtSecret string `yaml:"jwt_secret"`
+	// 携带token的cookie名称，也可以通过token查询参数或Authorization请求头传递
+	TokenCookie string `yaml:"token_cookie"`
+	// token中用户名对应的字段
+	UsernameClaim string       `yaml:"username_claim"`
+	Policies      []*ACLPolicy `yaml:"policies"`
+	// 可以查询审计记录的用户
+	Admins []string `yaml:"admins"`
+}
+
+// 用户满足任意一条策略时允许查询；策略中为空的字段不限制
+type ACLPolicy struct {
+	// 用户名，*匹配全部用户
+	Users []string `yaml:"users"`
+	// 机器UUID或IP及部门，二者满足其一即可
+	Machines    []string `yaml:"machines"`
+	Departments []string `yaml:"departments"`
+	// 服务单元（支持通配符）及_TRANSPORT
+	Units      []string `yaml:"units"`
+	Transports []string `yaml:"transports"`
+	// 允许查看的最低严重程度，如err表示只允许查看emerg至err的日志
+	Priority string `yaml:"priority"`
+	// 文本日志文件（支持通配符）
+	Files []string `yaml:"files"`
+}
+
+// 日志查询审计记录
+type AuditConf struct {
+	// 审计日志文件路径，为空时不记录
+	Path string `yaml:"path"`
+	// 单个文件的最大字节数及保留的历史文件数
+	MaxSize  int64 `yaml:"max_size"`
+	MaxFiles int   `yaml:"max_files"`
+}
+
+// 日志归档：agent上传的journal日志按时间分段压缩存储，agent不可达时从归档查询
+type ArchiveConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// 每个分段包含的小时数
+	SegmentHours int `yaml:"segment_hours"`
+	// 分段保留天数及单个主机归档的最大字节数，为0时不限制
+	RetentionDays int   `yaml:"retention_days"`
+	MaxSize       int64 `yaml:"max_size"`
+	// key: 机器UUID，value: 该机器agent上传日志时携带的令牌；按令牌确定上传日志的机器，开启归档时必须配置
+	Tokens map[string]string `yaml:"tokens"`
+}
+
+// 告警规则：agent实时统计满足规则查询条件的日志，告警触发及恢复时发送通知
+type AlertConf struct {
+	Enabled bool `yaml:"enabled"`
+	// 告警规则及告警状态的保存目录
+	Path    string            `yaml:"path"`
+	Webhook *AlertWebhookConf `yaml:"webhook"`
+	SMTP    *AlertSMTPConf    `yaml:"smtp"`
+	PilotGo *AlertPilotGoConf `yaml:"pilotgo"`
+}
+
+// 以json格式POST告警通知，url为空时不发送
+type AlertWebhookConf struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// 以邮件发送告警通知，addr为空时不发送
+type AlertSMTPConf struct {
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// 通过PilotGo消息通道发布告警通知
+type AlertPilotGoConf struct {
+	Enabled     bool `yaml:"enabled"`
+	MessageType int  `yaml:"message_type"`
+}
+
+// 在/metrics以Prometheus文本格式提供指标
+type MetricsConf struct {
+	Enabled bool `yaml:"enabled"`
+	// 不为空时要求请求携带Authorization: Bearer <token>
+	Token string `yaml:"token"`
+}
+
+// 用户及团队保存的查询条件
+type SearchConf struct {
+	// 保存目录，为空时不保存
+	Path string `yaml:"path"`
+	// key: 团队名称，value: 团队成员的用户名
+	Teams map[string][]string `yaml:"teams"`
+}
@@ -8,11 +8,16 @@
 package main
 
 import (
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/alert"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/archive"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/audit"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/logger"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/resourcemanage"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/search"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/signal"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver/proxy"
@@ -47,7 +52,25 @@ func main() {
 	/*
 		websocket proxy management
 	*/
+	if err := acl.Init(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
+	if err := audit.Init(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
+	if err := archive.Init(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
+	if err := proxy.InitAgentTLS(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
 	proxy.CreateWebsocketProxyManagement()
+	if err := alert.Init(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
+	if err := search.Init(); err != nil {
+		sdklogger.Fatal("%s", err.Error())
+	}
 
 	/*
 		init web server
@@ -69,4 +92,6 @@ func Close() {
 	if proxy.WebsocketProxyManager != nil {
 		proxy.WebsocketProxyManager.CloseAll()
 	}
+	audit.Close()
+	archive.Close()
 }
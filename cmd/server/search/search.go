@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 14:26:51 2026 +0800
+ */
+package search
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"github.com/pkg/errors"
+)
+
+const searchesFile = "searches.json"
+
+var (
+	ErrSearchNotFound = errors.New("saved search not found")
+
+	store *searchStore
+)
+
+/*
+保存的查询条件：主机、查询参数及相对时间范围
+
+team为空时只有owner可以查看及修改，否则team的成员均可以查看及修改
+*/
+type Search struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	Team  string `json:"team"`
+	// 机器UUID或IP，为空时不限定主机
+	Machines []string                  `json:"machines"`
+	Options  *public.JournalctlOptions `json:"options"`
+	// 相对时间范围，如1h、30m、7d、last 2w；不为空时忽略options中的since、until
+	Range string `json:"range"`
+
+	// 毫秒时间戳
+	Created int64 `json:"created"`
+	Updated int64 `json:"updated"`
+}
+
+type searchStore struct {
+	path string
+
+	mutex    sync.Mutex
+	searches map[string]*Search
+}
+
+// 加载保存的查询条件，未配置path时不保存
+func Init() error {
+	searchconf := conf.Global_Config.Search
+	if searchconf == nil || searchconf.Path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(searchconf.Path, 0700); err != nil {
+		return errors.Errorf("fail to create saved search directory: %s", err.Error())
+	}
+	s := &searchStore{path: searchconf.Path, searches: map[string]*Search{}}
+	bytes, err := os.ReadFile(filepath.Join(s.path, searchesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Errorf("fail to read %s: %s", searchesFile, err.Error())
+	}
+	if err == nil {
+		searches := []*Search{}
+		if err := json.Unmarshal(bytes, &searches); err != nil {
+			return errors.Errorf("fail to unmarshal %s: %s", searchesFile, err.Error())
+		}
+		for _, search := range searches {
+			s.searches[search.ID] = search
+		}
+	}
+	store = s
+	return nil
+}
+
+func Enabled() bool {
+	return store != nil
+}
+
+/*
+解析相对时间范围：支持time.ParseDuration的格式及d（天）、w（周），可以带last前缀
+
+如1h、90m、1h30m、7d、last 2w
+*/
+func ParseRange(_range string) (time.Duration, error) {
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(_range), "last"))
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(value, "d"), strings.HasSuffix(value, "w"):
+		var n int
+		n, err = strconv.Atoi(value[:len(value)-1])
+		d = time.Duration(n) * 24 * time.Hour
+		if strings.HasSuffix(value, "w") {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d <= 0 {
+		return 0, errors.Errorf("invalid range %q, expected a positive duration such as 1h, 30m or 7d", _range)
+	}
+	return d, nil
+}
+
+func (s *Search) validate() error {
+	if s.Name == "" {
+		return errors.New("search name is required")
+	}
+	if s.Options == nil {
+		s.Options = &public.JournalctlOptions{}
+	}
+	if err := public.CheckQuery(s.Options); err != nil {
+		return err
+	}
+	if s.Range != "" {
+		if _, err := ParseRange(s.Range); err != nil {
+			return err
+		}
+	}
+	if s.Team != "" {
+		if _, ok := teams()[s.Team]; !ok {
+			return errors.Errorf("unknown team: %s", s.Team)
+		}
+	}
+	return nil
+}
+
+// 用户能否查看及修改；未开启权限控制时用户为空，不限制
+func (s *Search) accessible(_user string, _admin bool) bool {
+	if _user == "" || _admin || s.Owner == _user {
+		return true
+	}
+	return s.Team != "" && inTeam(s.Team, _user)
+}
+
+/*
+将相对时间范围换算为since、until，返回可直接用于查询的参数
+
+时间范围只在分页查询时生效，以UTC表示，与logs server及agent的时区无关；未设置range时返回保存的参数
+*/
+func (s *Search) ResolveOptions(_now time.Time) *public.JournalctlOptions {
+	options := *s.Options
+	if d, err := ParseRange(s.Range); err == nil {
+		options.Notail = true
+		options.Since = _now.Add(-d).UTC().Format(public.JournalTimeLayoutUTC)
+		options.Until = _now.UTC().Format(public.JournalTimeLayoutUTC)
+	}
+	return &options
+}
+
+// 用户可以查看的查询条件，按名称排序
+func List(_user string, _admin bool) []*Search {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	searches := []*Search{}
+	for _, s := range store.searches {
+		if s.accessible(_user, _admin) {
+			copied := *s
+			searches = append(searches, &copied)
+		}
+	}
+	sort.Slice(searches, func(_i, _j int) bool {
+		if searches[_i].Name != searches[_j].Name {
+			return searches[_i].Name < searches[_j].Name
+		}
+		return searches[_i].Created < searches[_j].Created
+	})
+	return searches
+}
+
+// 用户无权查看时同样返回ErrSearchNotFound
+func Get(_id, _user string, _admin bool) (*Search, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	s, ok := store.searches[_id]
+	if !ok || !s.accessible(_user, _admin) {
+		return nil, ErrSearchNotFound
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func Create(_search *Search, _user string, _admin bool) error {
+	if err := _search.validate(); err != nil {
+		return err
+	}
+	if _search.Team != "" && _user != "" && !_admin && !inTeam(_search.Team, _user) {
+		return errors.Errorf("permission denied: user %s is not a member of team %s", _user, _search.Team)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return errors.Errorf("fail to generate search id: %s", err.Error())
+	}
+	_search.ID = hex.EncodeToString(id)
+	_search.Owner = _user
+	_search.Created = time.Now().UnixMilli()
+	_search.Updated = _search.Created
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.searches[_search.ID] = _search
+	if err := store.save(); err != nil {
+		delete(store.searches, _search.ID)
+		return err
+	}
+	return nil
+}
+
+// id、owner及创建时间不变，链接在修改后仍然有效
+func Update(_id string, _search *Search, _user string, _admin bool) error {
+	if err := _search.validate(); err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	old, ok := store.searches[_id]
+	if !ok || !old.accessible(_user, _admin) {
+		return ErrSearchNotFound
+	}
+	if _search.Team != "" && _search.Team != old.Team && _user != "" && !_admin && !inTeam(_search.Team, _user) {
+		return errors.Errorf("permission denied: user %s is not a member of team %s", _user, _search.Team)
+	}
+	_search.ID = _id
+	_search.Owner = old.Owner
+	_search.Created = old.Created
+	_search.Updated = time.Now().UnixMilli()
+	store.searches[_id] = _search
+	if err := store.save(); err != nil {
+		store.searches[_id] = old
+		return err
+	}
+	return nil
+}
+
+func Delete(_id, _user string, _admin bool) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	s, ok := store.searches[_id]
+	if !ok || !s.accessible(_user, _admin) {
+		return ErrSearchNotFound
+	}
+	delete(store.searches, _id)
+	if err := store.save(); err != nil {
+		store.searches[_id] = s
+		return err
+	}
+	return nil
+}
+
+// 调用方需持有mutex，写入失败时由调用方恢复内存中的修改
+func (s *searchStore) save() error {
+	searches := make([]*Search, 0, len(s.searches))
+	for _, search := range s.searches {
+		searches = append(searches, search)
+	}
+	bytes, err := json.MarshalIndent(searches, "", "  ")
+	if err != nil {
+		return errors.Errorf("fail to marshal saved searches: %s", err.Error())
+	}
+	file := filepath.Join(s.path, searchesFile)
+	if err := os.WriteFile(file+".tmp", bytes, 0600); err != nil {
+		return errors.Errorf("fail to write %s: %s", searchesFile, err.Error())
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return errors.Errorf("fail to write %s: %s", searchesFile, err.Error())
+	}
+	return nil
+}
+
+func teams() map[string][]string {
+	return conf.Global_Config.Search.Teams
+}
+
+func inTeam(_team, _user string) bool {
+	for _, member := range teams()[_team] {
+		if member == _user {
+			return true
+		}
+	}
+	return false
+}
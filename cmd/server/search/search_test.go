@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 20:15:09 2026 +0800
+ */
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+func TestResolveOptions(t *testing.T) {
+	now := time.Date(2026, 10, 1, 16, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	s := &Search{Options: &public.JournalctlOptions{Unit: "nginx", Since: "2020-01-01"}, Range: "last 2h"}
+	options := s.ResolveOptions(now)
+	if options.Since != "2026-10-01 06:30:00 UTC" || options.Until != "2026-10-01 08:30:00 UTC" || !options.Notail {
+		t.Errorf("got since %q until %q notail %v", options.Since, options.Until, options.Notail)
+	}
+	// agent按UTC解析，与双方的时区无关
+	since, err := public.ParseJournalTime(options.Since)
+	if err != nil || since != uint64(now.Add(-2*time.Hour).UnixMicro()) {
+		t.Errorf("since %q parsed as %d, error %v", options.Since, since, err)
+	}
+	if s.Options.Since != "2020-01-01" || s.Options.Notail {
+		t.Error("saved options are modified")
+	}
+
+	s.Range = ""
+	if options := s.ResolveOptions(now); !reflect.DeepEqual(options, s.Options) {
+		t.Errorf("without range: got %+v", options)
+	}
+}
+
+// 保存失败时内存中的查询条件不变
+func TestSaveFailureRollback(t *testing.T) {
+	dir := t.TempDir()
+	store = &searchStore{path: dir, searches: map[string]*Search{}}
+	defer func() { store = nil }()
+
+	saved := &Search{Name: "errors", Options: &public.JournalctlOptions{Severity: "err"}}
+	if err := Create(saved, "alice", false); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := List("alice", false)
+
+	// 以同名文件代替目录，写入失败
+	store.path = filepath.Join(dir, "not-a-dir")
+	if err := os.WriteFile(store.path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Create(&Search{Name: "new"}, "alice", false); err == nil {
+		t.Error("create: save did not fail")
+	}
+	if err := Update(saved.ID, &Search{Name: "renamed"}, "alice", false); err == nil {
+		t.Error("update: save did not fail")
+	}
+	if err := Delete(saved.ID, "alice", false); err == nil {
+		t.Error("delete: save did not fail")
+	}
+	if got := List("alice", false); !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("got %+v, want %+v", got, snapshot)
+	}
+
+	// 保存成功后重新加载与内存一致
+	store.path = dir
+	if err := Update(saved.ID, &Search{Name: "renamed"}, "alice", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Get(saved.ID, "alice", false); err != nil || got.Name != "renamed" || got.Created != saved.Created {
+		t.Errorf("after update: got %+v, error %v", got, err)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 17:30:44 2026 +0800
+ */
+package webserver
+
+import (
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/alert"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo/sdk/response"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 告警规则只允许acl.admins中的用户管理，未开启告警时返回错误
+func checkAlert(_ctx *gin.Context) bool {
+	if !alert.Enabled() {
+		response.Fail(_ctx, nil, "alert is not enabled")
+		return false
+	}
+	if err := acl.CheckAdmin(_ctx.Request); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("alert request from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return false
+	}
+	return true
+}
+
+func AlertRuleListHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	response.Success(_ctx, alert.ListRules(), "")
+}
+
+func AlertRuleGetHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	rule, err := alert.GetRule(_ctx.Param("id"))
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, rule, "")
+}
+
+func AlertRuleCreateHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	rule := &alert.Rule{}
+	if err := _ctx.ShouldBindJSON(rule); err != nil {
+		response.Fail(_ctx, nil, "invalid alert rule: "+err.Error())
+		return
+	}
+	user, _ := acl.ResolveUser(_ctx.Request)
+	if err := alert.CreateRule(rule, user); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, rule, "")
+}
+
+func AlertRuleUpdateHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	rule := &alert.Rule{}
+	if err := _ctx.ShouldBindJSON(rule); err != nil {
+		response.Fail(_ctx, nil, "invalid alert rule: "+err.Error())
+		return
+	}
+	if err := alert.UpdateRule(_ctx.Param("id"), rule); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, rule, "")
+}
+
+func AlertRuleDeleteHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	if err := alert.DeleteRule(_ctx.Param("id")); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, nil, "")
+}
+
+// 各规则在每台机器上的告警状态，rule_id为空时返回全部规则的状态
+func AlertStateListHandle(_ctx *gin.Context) {
+	if !checkAlert(_ctx) {
+		return
+	}
+	response.Success(_ctx, alert.ListStates(_ctx.Query("rule_id")), "")
+}
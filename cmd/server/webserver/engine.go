@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
@@ -29,14 +30,20 @@ func InitWebServer() {
 	}
 
 	engine := gin.New()
+	// 不信任X-Forwarded-For等请求头，ClientIP返回连接的对端地址
+	if err := engine.SetTrustedProxies(nil); err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("fail to set trusted proxies: %s", err.Error()), true, false)
+	}
 	engine.Use(gin.Recovery(), middleware.Logger([]string{
 		"/plugin_manage/bind",
+		"/metrics",
 		"/",
 	}))
 	gin.SetMode(gin.ReleaseMode)
 	pluginclient.Global_Client.RegisterHandlers(engine)
 	pluginRouter(engine)
 	proxyRouter(engine)
+	metricsRouter(engine)
 	frontendResource.StaticRouter(engine)
 
 	web := &http.Server{
@@ -96,11 +103,45 @@ func pluginRouter(_engine *gin.Engine) {
 		pilotgoApi.GET("/ip_list", GetIpListHandle)
 
 		pilotgoApi.POST("/runcommand", RunCommandHandle)
+
+		pilotgoApi.GET("/audit", AuditSearchHandle)
+
+		pilotgoApi.POST("/query", QueryHandle)
+		pilotgoApi.GET("/units", UnitListHandle)
+		pilotgoApi.GET("/export", ExportHandle)
+		pilotgoApi.POST("/export", ExportHandle)
+
+		pilotgoApi.GET("/archive/cursor", ArchiveCursorHandle)
+		pilotgoApi.POST("/archive/entries", ArchiveEntriesHandle)
+
+		pilotgoApi.GET("/alert/rules", AlertRuleListHandle)
+		pilotgoApi.POST("/alert/rules", AlertRuleCreateHandle)
+		pilotgoApi.GET("/alert/rules/:id", AlertRuleGetHandle)
+		pilotgoApi.PUT("/alert/rules/:id", AlertRuleUpdateHandle)
+		pilotgoApi.DELETE("/alert/rules/:id", AlertRuleDeleteHandle)
+		pilotgoApi.GET("/alert/states", AlertStateListHandle)
+
+		pilotgoApi.GET("/searches", SearchListHandle)
+		pilotgoApi.POST("/searches", SearchCreateHandle)
+		pilotgoApi.GET("/searches/:id", SearchGetHandle)
+		pilotgoApi.PUT("/searches/:id", SearchUpdateHandle)
+		pilotgoApi.DELETE("/searches/:id", SearchDeleteHandle)
+		pilotgoApi.GET("/searches/:id/resolve", SearchResolveHandle)
+	}
+}
+
+// 开启指标时在/metrics以Prometheus文本格式提供指标
+func metricsRouter(_engine *gin.Engine) {
+	metricsconf := conf.Global_Config.Metrics
+	if metricsconf == nil || !metricsconf.Enabled {
+		return
 	}
+	_engine.GET("/metrics", gin.WrapH(public.MetricsHandler(metricsconf.Token)))
 }
 
 func proxyRouter(_engine *gin.Engine) {
 	_engine.GET("/ws/proxy", WebsocketProxyHandle)
+	_engine.GET("/ws/multi", MultiHostProxyHandle)
 }
 
 // func testRouter(_engine *gin.Engine) {
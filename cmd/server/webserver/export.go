@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Thu Oct 22 11:05:19 2026 +0800
+ */
+package webserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver/proxy"
+	"gitee.com/openeuler/PilotGo/sdk/response"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 导出完成后以trailer返回导出的日志条数或失败原因
+	exportEntriesTrailer = "X-Export-Entries"
+	exportErrorTrailer   = "X-Export-Error"
+)
+
+// 导出格式对应的Content-Type及文件扩展名
+var exportContentTypes = map[string][2]string{
+	public.ExportNDJSON:   {ndjsonContentType, "ndjson"},
+	public.ExportCSV:      {"text/csv; charset=utf-8", "csv"},
+	public.ExportShortISO: {"text/plain; charset=utf-8", "log"},
+	public.ExportJournal:  {"application/vnd.fdo.journal", "journal"},
+}
+
+// 导出请求，uuid与ip任选其一；GET请求时各字段为同名url参数，options为json字符串
+type exportRequest struct {
+	UUID    string                    `json:"uuid"`
+	IP      string                    `json:"ip"`
+	Format  string                    `json:"format"`
+	Gzip    bool                      `json:"gzip"`
+	Options *public.JournalctlOptions `json:"options"`
+}
+
+/*
+导出满足查询条件的全部日志，以附件形式返回，忽略分页参数
+
+agent按段返回导出结果，logs server收到后立即写入响应，不缓存全部结果；
+开始返回数据后出现的错误无法再修改状态码，通过X-Export-Error trailer返回
+*/
+func ExportHandle(_ctx *gin.Context) {
+	user, err := acl.ResolveUser(_ctx.Request)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject export from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+	req, err := bindExportRequest(_ctx)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	content_type, ok := exportContentTypes[req.Format]
+	if !ok {
+		response.Fail(_ctx, nil, fmt.Sprintf("unsupported export format: %s", req.Format))
+		return
+	}
+	if err := public.CheckQuery(req.Options); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+
+	machine, err := queryMachine(req.UUID, req.IP)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	session, err := proxy.DialAgent(_ctx.Request.Context(), _ctx.Request, user, _ctx.ClientIP(), machine)
+	if err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrapf(err, "export %s from %s", machine.IP, _ctx.ClientIP()), false, false)
+		return
+	}
+	defer session.Close()
+
+	req.Options.Notail = true
+	if err := session.Send(&public.JMessage{Type: public.ExportMsg, JOptions: req.Options, Data: req.Format}); err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		return
+	}
+	// 收到第一段结果后再返回响应头，查询条件错误等仍以普通响应返回
+	chunk, err := readExportChunk(session)
+	if err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("logs-%s-%s.%s", machine.IP, time.Now().Format("20060102T150405"), content_type[1])
+	_ctx.Header("Content-Type", content_type[0])
+	if req.Gzip {
+		filename += ".gz"
+		_ctx.Header("Content-Type", "application/gzip")
+	}
+	_ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_ctx.Header("Trailer", exportEntriesTrailer+", "+exportErrorTrailer)
+	_ctx.Status(http.StatusOK)
+
+	var writer io.Writer = _ctx.Writer
+	var gzip_writer *gzip.Writer
+	if req.Gzip {
+		gzip_writer = gzip.NewWriter(_ctx.Writer)
+		writer = gzip_writer
+	}
+	for err == nil && !chunk.Done {
+		if _, err = writer.Write(chunk.Data); err != nil {
+			break
+		}
+		if gzip_writer != nil {
+			gzip_writer.Flush()
+		}
+		_ctx.Writer.Flush()
+		chunk, err = readExportChunk(session)
+	}
+	if gzip_writer != nil {
+		gzip_writer.Close()
+	}
+
+	if err != nil {
+		if _ctx.Request.Context().Err() != nil {
+			return
+		}
+		info := proxy.ErrorInfo(err)
+		_ctx.Writer.Header().Set(exportErrorTrailer, info.Code+": "+strings.ReplaceAll(info.Message, "\n", " "))
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Wrapf(err, "fail to export logs of %s", machine.IP), false, false)
+		return
+	}
+	_ctx.Writer.Header().Set(exportEntriesTrailer, strconv.Itoa(chunk.Entries))
+}
+
+func bindExportRequest(_ctx *gin.Context) (*exportRequest, error) {
+	req := &exportRequest{}
+	if _ctx.Request.Method == http.MethodPost {
+		if err := _ctx.ShouldBindJSON(req); err != nil {
+			return nil, errors.New("parameter error")
+		}
+	} else {
+		req.UUID = _ctx.Query("uuid")
+		req.IP = _ctx.Query("ip")
+		req.Format = _ctx.Query("format")
+		req.Gzip, _ = strconv.ParseBool(_ctx.Query("gzip"))
+		if options := _ctx.Query("options"); options != "" {
+			if err := json.Unmarshal([]byte(options), &req.Options); err != nil {
+				return nil, errors.Errorf("invalid options: %s", err.Error())
+			}
+		}
+	}
+	if req.Format == "" {
+		req.Format = public.ExportNDJSON
+	}
+	if req.Options == nil {
+		req.Options = &public.JournalctlOptions{}
+	}
+	return req, nil
+}
+
+// 读取下一段导出结果
+func readExportChunk(_session *proxy.AgentSession) (*public.ExportChunk, error) {
+	for {
+		data, err := _session.Read()
+		if err != nil {
+			return nil, err
+		}
+		if data.Type != public.ExportData {
+			continue
+		}
+		chunk := &public.ExportChunk{}
+		if err := json.Unmarshal(data.Data, chunk); err != nil {
+			return nil, errors.Errorf("fail to unmarshal export data: %s", err.Error())
+		}
+		return chunk, nil
+	}
+}
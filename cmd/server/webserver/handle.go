@@ -8,9 +8,18 @@
 package webserver
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/archive"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/audit"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver/proxy"
@@ -22,6 +31,9 @@ import (
 
 var ResultOptMsg = []string{"安装成功", "卸载成功"}
 
+// agent单次上传的最大字节数（解压后）
+const maxArchiveBatchBytes = 64 * 1024 * 1024
+
 const (
 	CommandInstall_Cmd = "yum install -y PilotGo-plugin-logs-agent && (echo '安装成功'; systemctl start PilotGo-plugin-logs-agent) || echo '安装失败'"
 	CommandRemove_Cmd  = "yum remove -y PilotGo-plugin-logs-agent && echo '卸载成功' || echo '卸载失败'"
@@ -112,6 +124,7 @@ func GetIpListHandle(_ctx *gin.Context) {
 func WebsocketProxyHandle(_ctx *gin.Context) {
 	wsproxy := proxy.NewWebsocketForwardProxy()
 	wsproxy.ID = _ctx.Request.Header.Get("clientId")
+	wsproxy.SourceIP = _ctx.ClientIP()
 	wsproxy.Active = true
 	if proxy.WebsocketProxyManager == nil {
 		global.ERManager.ErrorTransmit("webserver", "error", errors.New("WebsocketProxyManager is nil"), true, false)
@@ -121,3 +134,133 @@ func WebsocketProxyHandle(_ctx *gin.Context) {
 	proxy.WebsocketProxyManager.Add(wsproxy.ID, wsproxy)
 	wsproxy.ServeHTTP(_ctx.Writer, _ctx.Request)
 }
+
+// 多主机查询：按机器UUID或批次分发查询请求并合并结果
+func MultiHostProxyHandle(_ctx *gin.Context) {
+	mhproxy := proxy.NewMultiHostProxy()
+	mhproxy.ID = _ctx.Request.Header.Get("clientId")
+	mhproxy.SourceIP = _ctx.ClientIP()
+	mhproxy.Active = true
+	if proxy.WebsocketProxyManager == nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.New("WebsocketProxyManager is nil"), true, false)
+		_ctx.JSON(http.StatusInternalServerError, "WebsocketProxyManager is nil")
+		return
+	}
+	proxy.WebsocketProxyManager.AddMultiHost(mhproxy.ID, mhproxy)
+	mhproxy.ServeHTTP(_ctx.Writer, _ctx.Request)
+}
+
+// 查询日志查询审计记录，开启权限控制时只允许acl.admins中的用户查询
+func AuditSearchHandle(_ctx *gin.Context) {
+	if err := acl.CheckAdmin(_ctx.Request); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("audit search from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+
+	filter := &audit.Filter{
+		User:      _ctx.Query("user"),
+		SourceIP:  _ctx.Query("source_ip"),
+		Agent:     _ctx.Query("agent"),
+		Event:     _ctx.Query("event"),
+		SessionID: _ctx.Query("session_id"),
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(_ctx.Query("since")); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	if filter.Until, err = parseAuditTime(_ctx.Query("until")); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	filter.Page, _ = strconv.Atoi(_ctx.Query("page"))
+	filter.Size, _ = strconv.Atoi(_ctx.Query("size"))
+
+	records, total, err := audit.Search(filter)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, "fail to search audit records"), false, false)
+		return
+	}
+	response.Success(_ctx, gin.H{"total": total, "records": records}, "")
+}
+
+// 毫秒时间戳或RFC3339格式的时间
+func parseAuditTime(_value string) (int64, error) {
+	if _value == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(_value, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, _value)
+	if err != nil {
+		return 0, errors.Errorf("invalid time: %s", _value)
+	}
+	return t.UnixMilli(), nil
+}
+
+// agent查询logs server已归档的最后一条日志的游标
+func ArchiveCursorHandle(_ctx *gin.Context) {
+	machine, err := archiveMachine(_ctx)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("archive cursor request from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+	response.Success(_ctx, &public.ArchiveCursor{Cursor: archive.Cursor(machine.UUID)}, "")
+}
+
+// agent上传journal日志，请求体为ArchiveBatch，可以使用gzip压缩
+func ArchiveEntriesHandle(_ctx *gin.Context) {
+	machine, err := archiveMachine(_ctx)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("archive upload from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+
+	var body io.Reader = _ctx.Request.Body
+	if _ctx.GetHeader("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(_ctx.Request.Body)
+		if err != nil {
+			response.Fail(_ctx, nil, "invalid gzip body")
+			return
+		}
+		defer reader.Close()
+		body = reader
+	}
+	batch := &public.ArchiveBatch{}
+	if err := json.NewDecoder(io.LimitReader(body, maxArchiveBatchBytes)).Decode(batch); err != nil {
+		response.Fail(_ctx, nil, "parameter error")
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("invalid archive batch from %s: %s", machine.IP, err.Error()), false, false)
+		return
+	}
+
+	cursor, err := archive.Append(machine, batch)
+	if err != nil {
+		response.Fail(_ctx, &public.ArchiveCursor{Cursor: cursor}, err.Error())
+		if !errors.Is(err, archive.ErrCursorMismatch) {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Wrapf(err, "fail to archive entries of %s", machine.IP), false, false)
+		}
+		return
+	}
+	response.Success(_ctx, &public.ArchiveCursor{Cursor: cursor}, "")
+}
+
+// 根据上传令牌确定agent所在机器，不使用请求来源地址
+func archiveMachine(_ctx *gin.Context) (*common.MachineNode, error) {
+	if !archive.Enabled() {
+		return nil, errors.New("archive is not enabled")
+	}
+	auth, ok := strings.CutPrefix(_ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || auth == "" {
+		return nil, errors.New("unauthenticated: archive token is required")
+	}
+	uuid, ok := archive.MachineByToken(auth)
+	if !ok {
+		return nil, errors.New("unauthenticated: invalid archive token")
+	}
+	return acl.MachineByUUID(uuid)
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 14:18:33 2026 +0800
+ */
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/archive"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/audit"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+/*
+http接口使用的单主机查询会话
+
+与websocket代理相同：连接agent失败时查询归档，发往agent的请求经过相同的权限检查并记录审计
+*/
+type AgentSession struct {
+	Machine *common.MachineNode
+	// agent不可达，查询的是主机归档
+	Archived bool
+
+	conn      *websocket.Conn
+	grant     *acl.Grant
+	logSource string
+	audit     *audit.Session
+
+	// gorilla/websocket不允许并发写，Send与Close共用
+	writeMutex sync.Mutex
+	once       sync.Once
+}
+
+// 会话中agent发送的数据
+type AgentData struct {
+	Type   public.StdoutDataType
+	Data   json.RawMessage
+	Cursor string
+}
+
+// agent通过ErrorMsg返回的查询失败原因
+type AgentError struct {
+	*public.ErrorInfo
+}
+
+func (e *AgentError) Error() string {
+	return e.Message
+}
+
+/*
+检查用户权限后连接主机的agent，_ctx结束时关闭会话
+
+返回的错误可以通过ErrorInfo转换为发送给客户端的错误码
+*/
+func DialAgent(_ctx context.Context, _r *http.Request, _user, _source_ip string, _machine *common.MachineNode) (*AgentSession, error) {
+	grant, err := acl.Authorize(_user, _machine)
+	if err != nil {
+		return nil, err
+	}
+	s := &AgentSession{
+		Machine:   _machine,
+		grant:     grant,
+		logSource: logSource(_r),
+	}
+	// agent按clientId管理连接，每个会话使用不同的id
+	header := forwardHeader(_r, fmt.Sprintf("api-%x", time.Now().UnixNano()))
+	addr := net.JoinHostPort(_machine.IP, agentPort)
+	target_url, err := agentURL(addr)
+	if err == nil {
+		s.conn, _, err = DefaultDialer.Dial(target_url, header)
+		if err != nil {
+			err = newDialError(err, "dial to target WebSocket %s failed: %s", addr, err.Error())
+		}
+	}
+	if err != nil {
+		if !archive.Has(_machine.UUID) {
+			return nil, err
+		}
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrapf(err, "query archive of %s", _machine.UUID), false, false)
+		if s.conn, err = archive.Dial(_machine.UUID, header); err != nil {
+			return nil, err
+		}
+		s.Archived = true
+	}
+	s.audit = audit.NewSession(_user, _source_ip, []string{_machine.IP})
+
+	go func() {
+		<-_ctx.Done()
+		s.Close()
+	}()
+	return s, nil
+}
+
+/*
+logs server自身使用的agent会话，如评估告警规则：不检查权限、不记录审计，agent不可达时不查询归档
+
+_ctx结束时关闭会话
+*/
+func DialAgentService(_ctx context.Context, _machine *common.MachineNode, _client_id string) (*AgentSession, error) {
+	header := http.Header{}
+	header.Set("clientId", _client_id)
+	addr := net.JoinHostPort(_machine.IP, agentPort)
+	target_url, err := agentURL(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := DefaultDialer.DialContext(_ctx, target_url, header)
+	if err != nil {
+		return nil, newDialError(err, "dial to target WebSocket %s failed: %s", addr, err.Error())
+	}
+	s := &AgentSession{Machine: _machine, conn: conn}
+
+	go func() {
+		<-_ctx.Done()
+		s.Close()
+	}()
+	return s, nil
+}
+
+// 检查并发送请求，未通过权限检查的请求记录审计后返回错误
+func (s *AgentSession) Send(_jmsg *public.JMessage) error {
+	if err := s.grant.Check(_jmsg, s.logSource); err != nil {
+		s.audit.Query(_jmsg, err.Error())
+		return err
+	}
+	if audit.IsQuery(_jmsg.Type) {
+		s.audit.Query(_jmsg, "")
+	}
+	jmsgBytes, err := json.Marshal(_jmsg)
+	if err != nil {
+		return errors.Errorf("error while marshalling json jmessage: %s", err.Error())
+	}
+	s.writeMutex.Lock()
+	err = s.conn.WriteMessage(websocket.TextMessage, jmsgBytes)
+	s.writeMutex.Unlock()
+	if err != nil {
+		return errors.Errorf("error while writing message to agent %s: %s", s.Machine.IP, err.Error())
+	}
+	return nil
+}
+
+// 读取agent返回的下一条数据，ErrorMsg以AgentError返回
+func (s *AgentSession) Read() (*AgentData, error) {
+	for {
+		_, jmsgBytes, err := s.conn.ReadMessage()
+		if err != nil {
+			return nil, errors.Errorf("agent %s closed: %s", s.Machine.IP, err.Error())
+		}
+		jmsg := &struct {
+			Type int             `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}{}
+		if err := json.Unmarshal(jmsgBytes, jmsg); err != nil {
+			return nil, errors.Errorf("error while unmarshalling agent message: %s", err.Error())
+		}
+		switch jmsg.Type {
+		case public.ErrorMsg:
+			info := &public.ErrorInfo{}
+			if err := json.Unmarshal(jmsg.Data, info); err != nil {
+				return nil, errors.Errorf("error while unmarshalling agent error message: %s", err.Error())
+			}
+			return nil, &AgentError{ErrorInfo: info}
+		case public.DataMsg:
+			s.audit.AddMessage(jmsgBytes)
+			data := &struct {
+				Type   public.StdoutDataType `json:"type"`
+				Data   json.RawMessage       `json:"data"`
+				Cursor string                `json:"cursor"`
+			}{}
+			if err := json.Unmarshal(jmsg.Data, data); err != nil {
+				return nil, errors.Errorf("error while unmarshalling agent data: %s", err.Error())
+			}
+			return &AgentData{Type: data.Type, Data: data.Data, Cursor: data.Cursor}, nil
+		}
+	}
+}
+
+func (s *AgentSession) Close() {
+	s.once.Do(func() {
+		s.writeMutex.Lock()
+		s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		s.writeMutex.Unlock()
+		s.conn.Close()
+		s.audit.Close()
+	})
+}
+
+// 错误对应的ErrorInfo，权限检查失败为permission_denied，连接agent失败为dial_failed或handshake_failed
+func ErrorInfo(_err error) *public.ErrorInfo {
+	agent_err := &AgentError{}
+	if errors.As(_err, &agent_err) {
+		return agent_err.ErrorInfo
+	}
+	if acl.IsPermissionDenied(_err) {
+		return &public.ErrorInfo{Code: public.ErrCodePermissionDenied, Message: _err.Error()}
+	}
+	return &public.ErrorInfo{Code: dialErrorCode(_err), Message: errors.Cause(_err).Error()}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 11:26:07 2026 +0800
+ */
+package proxy
+
+import (
+	"net"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// 连接agent失败，code为通过ErrorMsg发送给客户端的错误码
+type dialError struct {
+	code string
+	err  error
+}
+
+func (e *dialError) Error() string {
+	return e.err.Error()
+}
+
+// websocket.Dialer.Dial的错误：tcp连接失败为dial_failed，tls或websocket握手失败为handshake_failed
+func newDialError(_err error, _format string, _args ...interface{}) error {
+	code := public.ErrCodeHandshakeFailed
+	op_err := &net.OpError{}
+	if _err != websocket.ErrBadHandshake && errors.As(_err, &op_err) && op_err.Op == "dial" {
+		code = public.ErrCodeDialFailed
+	}
+	agentDialFailures.Inc(code)
+	return &dialError{code: code, err: errors.Errorf(_format, _args...)}
+}
+
+// 未标记错误码的连接错误（如连接归档失败）均视为dial_failed
+func dialErrorCode(_err error) string {
+	dial_err := &dialError{}
+	if errors.As(_err, &dial_err) {
+		return dial_err.code
+	}
+	return public.ErrCodeDialFailed
+}
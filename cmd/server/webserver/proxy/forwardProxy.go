@@ -19,8 +19,10 @@ import (
 	"time"
 
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/archive"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/audit"
 	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
-	"gitee.com/openeuler/PilotGo/sdk/utils/httputils"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 )
@@ -105,6 +107,23 @@ type WebsocketForwardProxy struct {
 
 	request        *http.Request
 	responseWriter http.ResponseWriter
+
+	// PilotGo用户及其对当前agent生效的权限策略
+	User      string
+	grant     *acl.Grant
+	logSource string
+
+	// 查询审计
+	SourceIP   string
+	agentAddr  string
+	audit      *audit.Session
+	auditMutex sync.Mutex
+
+	// agent不可达时查询的归档主机UUID，为空时查询agent
+	archiveUUID string
+
+	// 已转发、尚未返回消息的查询的开始时间，纳秒
+	queryStart int64
 }
 
 func NewWebsocketForwardProxy() *WebsocketForwardProxy {
@@ -129,6 +148,7 @@ func (w *WebsocketForwardProxy) ServeHTTP(_w http.ResponseWriter, _r *http.Reque
 
 	w.responseWriter = _w
 	w.request = _r
+	proxySessions.Inc()
 
 	w.client_wsconn, err = w.Upgrader.Upgrade(_w, _r, nil)
 	if err != nil {
@@ -138,6 +158,15 @@ func (w *WebsocketForwardProxy) ServeHTTP(_w http.ResponseWriter, _r *http.Reque
 		return
 	}
 
+	w.logSource = logSource(_r)
+	w.User, err = acl.ResolveUser(_r)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject ws client %s: %s", _r.RemoteAddr, err.Error()), false, false)
+		w.client_closemsg = err.Error()
+		w.Close(true, false, false)
+		return
+	}
+
 	if err := w.readMessageAgentAddr(); err != nil {
 		global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, " "), false, false)
 		w.client_closemsg = errors.Cause(err).Error()
@@ -147,11 +176,14 @@ func (w *WebsocketForwardProxy) ServeHTTP(_w http.ResponseWriter, _r *http.Reque
 
 	if err := w.dialTarget(_r); err != nil {
 		global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, " "), false, false)
+		w.writeError(dialErrorCode(err), err)
 		w.client_closemsg = errors.Cause(err).Error()
 		w.Close(true, false, false)
 		return
 	}
 
+	w.startAudit()
+
 	w.wg.Add(1)
 	go w.writeMessage2Client(public.ConnectedMsg)
 	go w.processError()
@@ -159,9 +191,19 @@ func (w *WebsocketForwardProxy) ServeHTTP(_w http.ResponseWriter, _r *http.Reque
 
 func (w *WebsocketForwardProxy) dialTarget(_r *http.Request) error {
 	w.CancelCtx, w.CancelFunc = context.WithCancel(WebsocketProxyCtx)
-	w.target_wsconn, _, err = w.Dialer.Dial(w.targetURL, w.targetDirector(_r))
-	if err != nil {
-		return errors.Errorf("dial to target WebSocket failed: %s", err.Error())
+	if w.archiveUUID == "" {
+		w.target_wsconn, _, err = w.Dialer.Dial(w.targetURL, w.targetDirector(_r))
+		if err != nil {
+			if w.archiveUUID = archivedMachine(w.agentAddr); w.archiveUUID == "" {
+				return newDialError(err, "dial to target WebSocket failed: %s", err.Error())
+			}
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("dial to agent %s failed, query archive: %s", w.agentAddr, err.Error()), false, false)
+		}
+	}
+	if w.archiveUUID != "" {
+		if w.target_wsconn, err = archive.Dial(w.archiveUUID, w.targetDirector(_r)); err != nil {
+			return err
+		}
 	}
 
 	go w.transferMessages(w.client_wsconn, w.target_wsconn, true)
@@ -188,6 +230,11 @@ func (w *WebsocketForwardProxy) processError() {
 }
 
 func (w *WebsocketForwardProxy) targetDirector(_r *http.Request) http.Header {
+	return forwardHeader(_r, w.ID)
+}
+
+// 与agent建立websocket连接时携带的请求头
+func forwardHeader(_r *http.Request, _client_id string) http.Header {
 	header := http.Header{}
 
 	header.Set("Host", _r.Host)
@@ -205,10 +252,21 @@ func (w *WebsocketForwardProxy) targetDirector(_r *http.Request) http.Header {
 		header.Set("X-Forwarded-Proto", "https")
 	}
 
-	header.Set("clientId", w.ID)
+	header.Set("clientId", _client_id)
+
+	header.Set("logSource", logSource(_r))
 	return header
 }
 
+// 日志来源：空值为journald，file为文本日志文件
+func logSource(_r *http.Request) string {
+	source := _r.Header.Get("logSource")
+	if source == "" {
+		source = _r.URL.Query().Get("source")
+	}
+	return source
+}
+
 func (w *WebsocketForwardProxy) ResponseDirector(_resp *http.Response, _header *http.Header) {
 	if hdr := _resp.Header.Get("Sec-Websocket-Protocol"); hdr != "" {
 		_header.Set("Sec-Websocket-Protocol", hdr)
@@ -245,7 +303,11 @@ func (w *WebsocketForwardProxy) transferMessages(_srcConn, _dstConn *websocket.C
 			}
 			messageType, message, err := _srcConn.ReadMessage()
 			if err != nil {
-				if websocket.IsCloseError(err, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
+				if websocket.IsCloseError(err, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure, websocket.ClosePolicyViolation) {
+					// agent拒绝未认证的连接时将关闭原因转发至客户端
+					if ce, ok := err.(*websocket.CloseError); ok && ce.Code == websocket.ClosePolicyViolation {
+						w.client_closemsg = ce.Text
+					}
 					w.errChan <- &WebsocketError{
 						Code:    WebsocketProxyReadError,
 						SrcConn: _srcConn,
@@ -289,28 +351,55 @@ func (w *WebsocketForwardProxy) transferMessages(_srcConn, _dstConn *websocket.C
 				}
 				switch jmsg.Type {
 				case public.AgentAddrMsg:
+					grant, err := w.authorize(jmsg.Data.(string))
+					if err != nil {
+						global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrap(err, " "), false, false)
+						w.writePermissionDenied(err.Error(), "")
+						continue
+					}
+					w.grant = grant
 					w.Close(false, false, true)
-					ishttp, err := httputils.ServerIsHttp("http://" + jmsg.Data.(string))
+					w.agentAddr = jmsg.Data.(string)
+					w.archiveUUID = ""
+					w.targetURL, err = agentURL(jmsg.Data.(string))
 					if err != nil {
+						w.archiveUUID = archivedMachine(w.agentAddr)
+					}
+					if err != nil && w.archiveUUID == "" {
+						w.writeError(dialErrorCode(err), err)
+						w.wg.Add(1)
 						w.writeMessage2Client(public.DialFailedMsg)
-						global.ERManager.ErrorTransmit("journald", "error", errors.Errorf("fail to detect remote http/https: %s", err.Error()), false, true)
+						global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, true)
+						w.client_closemsg = err.Error()
 						w.Close(true, false, false)
 						return
 					}
-					if ishttp {
-						w.targetURL = fmt.Sprintf("ws://%s/ws/entry", jmsg.Data.(string))
-					}
-					if !ishttp {
-						w.targetURL = fmt.Sprintf("wss://%s/ws/entry", jmsg.Data.(string))
-					}
 					if err := w.dialTarget(w.request); err != nil {
 						global.ERManager.ErrorTransmit("journald", "error", errors.Wrap(err, " "), false, true)
+						w.writeError(dialErrorCode(err), err)
 						w.Close(true, false, false)
+						return
 					}
+					w.startAudit()
 					w.wg.Add(1)
 					go w.writeMessage2Client(public.ConnectedMsg)
 					return
 				}
+				if err := w.grant.Check(jmsg, w.logSource); err != nil {
+					global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("user %s: %s", w.User, err.Error()), false, false)
+					if audit.IsQuery(jmsg.Type) {
+						w.auditSession().Query(jmsg, err.Error())
+					}
+					w.writePermissionDenied(err.Error(), jmsg.Stream)
+					continue
+				}
+				if audit.IsQuery(jmsg.Type) {
+					w.auditSession().Query(jmsg, "")
+					w.queryForwarded()
+				}
+				if jmsg.Type == public.CancelStreamMsg {
+					w.auditSession().EndStream(jmsg.Stream)
+				}
 			}
 
 			if isC2T {
@@ -336,6 +425,8 @@ func (w *WebsocketForwardProxy) transferMessages(_srcConn, _dstConn *websocket.C
 				w.targetWriteMutex.Unlock()
 			} else {
 				w.clientWriteMutex.Unlock()
+				w.replyForwarded()
+				w.auditSession().AddMessage(message)
 			}
 		}
 	}
@@ -358,6 +449,9 @@ func (w *WebsocketForwardProxy) writeMessage2Client(_jmsg_type int) {
 			return
 		default:
 			jmsg := &public.JMessage{Type: _jmsg_type}
+			if _jmsg_type == public.ConnectedMsg && w.archiveUUID != "" {
+				jmsg.Data = public.ArchiveSource
+			}
 			jmsgBytes, err := json.Marshal(jmsg)
 			if err != nil {
 				w.errChan <- &WebsocketError{
@@ -406,19 +500,86 @@ func (w *WebsocketForwardProxy) readMessageAgentAddr() error {
 		return errors.Errorf("the first message must be the agent addr: %d", jmsg.Type)
 	}
 
-	target_addr := jmsg.Data.(string)
-	ishttp, err := httputils.ServerIsHttp("http://" + target_addr)
+	w.agentAddr = jmsg.Data.(string)
+	w.grant, err = w.authorize(jmsg.Data.(string))
+	if err != nil {
+		w.writePermissionDenied(err.Error(), "")
+		return err
+	}
+
+	w.targetURL, err = agentURL(jmsg.Data.(string))
 	if err != nil {
+		if w.archiveUUID = archivedMachine(w.agentAddr); w.archiveUUID != "" {
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("agent %s unavailable, query archive: %s", w.agentAddr, err.Error()), false, false)
+			return nil
+		}
+		w.writeError(dialErrorCode(err), err)
+		w.wg.Add(1)
 		w.writeMessage2Client(public.DialFailedMsg)
-		return errors.Errorf("fail to detect remote http/https: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 检查当前用户能否查看agent所在主机的日志
+func (w *WebsocketForwardProxy) authorize(_addr string) (*acl.Grant, error) {
+	if !acl.Enabled() {
+		return nil, nil
+	}
+	machine, err := acl.MachineByAddr(_addr)
+	if err != nil {
+		return nil, err
 	}
-	if ishttp {
-		w.targetURL = fmt.Sprintf("ws://%s/ws/entry", target_addr)
+	return acl.Authorize(w.User, machine)
+}
+
+// agent不可达时查询归档，返回已归档主机的UUID，未开启归档或主机没有归档时返回空字符串
+func archivedMachine(_addr string) string {
+	if !archive.Enabled() {
+		return ""
 	}
-	if !ishttp {
-		w.targetURL = fmt.Sprintf("wss://%s/ws/entry", target_addr)
+	machine, err := acl.MachineByAddr(_addr)
+	if err != nil || !archive.Has(machine.UUID) {
+		return ""
+	}
+	return machine.UUID
+}
+
+// 与agent建立连接后开始新的审计会话
+func (w *WebsocketForwardProxy) startAudit() {
+	w.auditMutex.Lock()
+	defer w.auditMutex.Unlock()
+	w.audit.Close()
+	w.audit = audit.NewSession(w.User, w.SourceIP, []string{w.agentAddr})
+}
+
+func (w *WebsocketForwardProxy) auditSession() *audit.Session {
+	w.auditMutex.Lock()
+	defer w.auditMutex.Unlock()
+	return w.audit
+}
+
+// 向客户端发送权限检查失败的原因，请求不会转发至agent；_stream为被拒绝的请求所属的stream
+func (w *WebsocketForwardProxy) writePermissionDenied(_reason, _stream string) {
+	w.writeJMessage(&public.JMessage{Type: public.PermissionDeniedMsg, Data: _reason, Stream: _stream})
+}
+
+// 向客户端发送连接agent失败的原因，之后仍发送DialFailedMsg或关闭连接
+func (w *WebsocketForwardProxy) writeError(_code string, _err error) {
+	w.writeJMessage(&public.JMessage{Type: public.ErrorMsg, Data: &public.ErrorInfo{Code: _code, Message: errors.Cause(_err).Error()}})
+}
+
+func (w *WebsocketForwardProxy) writeJMessage(_jmsg *public.JMessage) {
+	jmsgBytes, err := json.Marshal(_jmsg)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while marshalling json jmessage: %s", err.Error()), false, false)
+		return
+	}
+	w.clientWriteMutex.Lock()
+	defer w.clientWriteMutex.Unlock()
+	if err := w.client_wsconn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while writing message %d to client: %s", _jmsg.Type, err.Error()), false, false)
 	}
-	return nil
 }
 
 func (w *WebsocketForwardProxy) Close(_close_A, _close_C, _close_T bool) {
@@ -456,7 +617,10 @@ func (w *WebsocketForwardProxy) Close(_close_A, _close_C, _close_T bool) {
 			close(w.errEndChan)
 			close(w.errChan)
 
+			w.auditSession().Close()
+
 			w.Active = false
+			proxySessions.Dec()
 
 			time.Sleep(100 * time.Millisecond)
 		})
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 11:17:48 2026 +0800
+ */
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+)
+
+// 代理的目标：agent或归档
+const (
+	targetAgent   = "agent"
+	targetArchive = "archive"
+)
+
+var (
+	proxySessions = public.NewGaugeVec("logs_server_proxy_sessions",
+		"Active websocket proxy sessions between clients and agents.")
+
+	agentDialFailures = public.NewCounterVec("logs_server_agent_dial_failures_total",
+		"Failed websocket connections to agents, by error code.", "code")
+
+	proxyLatency = public.NewHistogramVec("logs_server_proxy_latency_seconds",
+		"Time from forwarding a query to the first message returned for it.", public.DefaultLatencyBuckets, "target")
+)
+
+// 转发查询时记录开始时间，已有未返回的查询时不覆盖
+func (w *WebsocketForwardProxy) queryForwarded() {
+	atomic.CompareAndSwapInt64(&w.queryStart, 0, time.Now().UnixNano())
+}
+
+// 转发目标返回的消息时记录延迟
+func (w *WebsocketForwardProxy) replyForwarded() {
+	start := atomic.SwapInt64(&w.queryStart, 0)
+	if start == 0 {
+		return
+	}
+	target := targetAgent
+	if w.archiveUUID != "" {
+		target = targetArchive
+	}
+	proxyLatency.Observe(time.Since(time.Unix(0, start)).Seconds(), target)
+}
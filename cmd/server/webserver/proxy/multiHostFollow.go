@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 11:40:53 2026 +0800
+ */
+package proxy
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+实时查询合并各主机日志时的排序缓冲
+
+每台主机的水位为其最近一条日志的时间戳，agent按时间顺序返回日志，时间戳不超过所有主机最低水位的日志之前不会再有更早的日志到达，按时间顺序发送。
+
+为避免没有新日志的主机阻挡其他主机：超过holdback未返回日志的主机不参与计算最低水位，缓存超过holdback的日志也直接发送。
+因此主机之间时钟偏差较大、或某台主机的日志延迟超过holdback到达时，发送顺序只是尽力而为
+*/
+type followMerger struct {
+	holdback time.Duration
+
+	// key: 主机UUID
+	watermarks map[string]*followWatermark
+	// 按时间戳升序
+	buff []*followEntry
+}
+
+type followWatermark struct {
+	timestamp int64
+	received  time.Time
+}
+
+type followEntry struct {
+	entry     map[string]interface{}
+	uuid      string
+	timestamp int64
+	received  time.Time
+}
+
+func newFollowMerger(_holdback time.Duration) *followMerger {
+	return &followMerger{
+		holdback:   _holdback,
+		watermarks: map[string]*followWatermark{},
+	}
+}
+
+func (f *followMerger) add(_entry map[string]interface{}, _now time.Time) {
+	uuid, _ := _entry["uuid"].(string)
+	timestamp := entryTimestamp(_entry)
+	watermark, ok := f.watermarks[uuid]
+	if !ok {
+		watermark = &followWatermark{}
+		f.watermarks[uuid] = watermark
+	}
+	if timestamp > watermark.timestamp {
+		watermark.timestamp = timestamp
+	}
+	watermark.received = _now
+
+	i := sort.Search(len(f.buff), func(_i int) bool { return f.buff[_i].timestamp > timestamp })
+	f.buff = append(f.buff, nil)
+	copy(f.buff[i+1:], f.buff[i:])
+	f.buff[i] = &followEntry{entry: _entry, uuid: uuid, timestamp: timestamp, received: _now}
+}
+
+// 可以发送的日志，按时间戳升序
+func (f *followMerger) flush(_now time.Time) []*followEntry {
+	low := int64(math.MaxInt64)
+	for _, watermark := range f.watermarks {
+		if _now.Sub(watermark.received) < f.holdback && watermark.timestamp < low {
+			low = watermark.timestamp
+		}
+	}
+	// 发送到最后一条可以发送的日志为止，其前面时间更早的日志一并发送
+	n := 0
+	for i, e := range f.buff {
+		if e.timestamp <= low || _now.Sub(e.received) >= f.holdback {
+			n = i + 1
+		}
+	}
+	released := f.buff[:n:n]
+	f.buff = append([]*followEntry{}, f.buff[n:]...)
+	return released
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 25 11:58:30 2026 +0800
+ */
+package proxy
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func followTestEntry(_uuid string, _timestamp int64) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":      _uuid,
+		"timestamp": strconv.FormatInt(_timestamp, 10),
+		"message":   _uuid + "@" + strconv.FormatInt(_timestamp, 10),
+	}
+}
+
+func followMessages(_entries []*followEntry) []string {
+	messages := []string{}
+	for _, e := range _entries {
+		messages = append(messages, e.entry["message"].(string))
+	}
+	return messages
+}
+
+func TestFollowMergerWatermark(t *testing.T) {
+	now := testBaseTime
+	f := newFollowMerger(time.Second)
+
+	// 只有一台主机时直接发送
+	f.add(followTestEntry("a", 100), now)
+	f.add(followTestEntry("a", 110), now)
+	if got, want := followMessages(f.flush(now)), []string{"a@100", "a@110"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("single host: got %v, want %v", got, want)
+	}
+
+	// b的日志晚于a的水位，等待a
+	f.add(followTestEntry("b", 130), now)
+	f.add(followTestEntry("b", 140), now)
+	if got := followMessages(f.flush(now)); len(got) != 0 {
+		t.Fatalf("held entries: got %v", got)
+	}
+
+	// a到达更早的日志后按时间合并，水位之后的日志继续等待
+	now = now.Add(500 * time.Millisecond)
+	f.add(followTestEntry("a", 120), now)
+	f.add(followTestEntry("a", 135), now)
+	if got, want := followMessages(f.flush(now)), []string{"a@120", "b@130", "a@135"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged: got %v, want %v", got, want)
+	}
+
+	// b超过holdback未返回日志后不再阻挡a
+	now = now.Add(700 * time.Millisecond)
+	f.add(followTestEntry("a", 150), now)
+	if got, want := followMessages(f.flush(now)), []string{"b@140", "a@150"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("quiet host: got %v, want %v", got, want)
+	}
+	if len(f.buff) != 0 {
+		t.Errorf("entries left: %v", followMessages(f.buff))
+	}
+}
+
+// 缓存超过holdback的日志直接发送，连同其前面时间更早的日志
+func TestFollowMergerHoldback(t *testing.T) {
+	now := testBaseTime
+	f := newFollowMerger(time.Second)
+	f.add(followTestEntry("a", 100), now)
+	f.add(followTestEntry("b", 200), now)
+	f.flush(now)
+
+	// a持续返回日志但时间戳落后于b
+	for i := 1; i <= 3; i++ {
+		now = now.Add(400 * time.Millisecond)
+		f.add(followTestEntry("a", 100+int64(i)), now)
+		f.add(followTestEntry("b", 200+int64(i)), now)
+	}
+	got := followMessages(f.flush(now))
+	want := []string{"a@101", "a@102", "a@103", "b@200"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if left := followMessages(f.buff); !reflect.DeepEqual(left, []string{"b@201", "b@202", "b@203"}) {
+		t.Errorf("entries left: %v", left)
+	}
+}
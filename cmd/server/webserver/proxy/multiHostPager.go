@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 21:38:12 2026 +0800
+ */
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMultiHostPageSize = 20
+	// 多主机分页只能按from顺序合并，跳转时允许跳过的最大日志条数
+	maxMultiHostSkip = 10000
+)
+
+// 单个主机的分页状态，按游标依次向agent请求后续日志
+type hostPageState struct {
+	host *hostConn
+
+	buff      []map[string]interface{}
+	cursor    string
+	started   bool
+	more      bool
+	total     int
+	estimated bool
+	failed    bool
+}
+
+// 多主机分页查询：各主机结果按时间戳归并，每个主机只缓存一页日志
+type multiHostPager struct {
+	proxy   *MultiHostProxy
+	options public.JournalctlOptions
+
+	states []*hostPageState
+	// 已归并的日志条数，与下一页的from相等时无需重新查询
+	offset int
+
+	reqCh chan *public.JournalctlOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newMultiHostPager(_proxy *MultiHostProxy, _options *public.JournalctlOptions) *multiHostPager {
+	ctx, cancel := context.WithCancel(_proxy.CancelCtx)
+	p := &multiHostPager{
+		proxy:   _proxy,
+		options: *_options,
+		reqCh:   make(chan *public.JournalctlOptions, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	// 合并查询不支持按游标翻页
+	p.options.Cursor = ""
+	p.options.Direction = ""
+	return p
+}
+
+// 只保留最新的分页请求
+func (p *multiHostPager) request(_options *public.JournalctlOptions) {
+	select {
+	case <-p.reqCh:
+	default:
+	}
+	p.reqCh <- _options
+}
+
+func (p *multiHostPager) run() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case options := <-p.reqCh:
+			page, err := p.page(options.From, options.Size)
+			if p.ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, "multi host page query failed"), false, false)
+				page = nil
+			}
+			p.proxy.writeMessage2Client(&public.JMessage{
+				Type: public.DataMsg,
+				Data: &public.StdoutData{Type: public.LogEntryData, Data: page},
+			})
+		}
+	}
+}
+
+func (p *multiHostPager) page(_from, _size int) (*public.PageData, error) {
+	if _size <= 0 {
+		_size = defaultMultiHostPageSize
+	}
+	if _from < 0 {
+		_from = 0
+	}
+	if p.states == nil || _from < p.offset {
+		p.restart(_size)
+	}
+	if _from-p.offset > maxMultiHostSkip {
+		return nil, errors.Errorf("page from %d is too far from current offset %d", _from, p.offset)
+	}
+	for p.offset < _from {
+		if p.next(_size) == nil {
+			break
+		}
+	}
+
+	page := &public.PageData{Hits: []map[string]interface{}{}}
+	for len(page.Hits) < _size {
+		entry := p.next(_size)
+		if entry == nil {
+			break
+		}
+		page.Hits = append(page.Hits, entry)
+	}
+	if p.ctx.Err() != nil {
+		return nil, p.ctx.Err()
+	}
+
+	failed := 0
+	for _, s := range p.states {
+		if s.failed {
+			failed++
+			continue
+		}
+		page.Total += s.total
+		page.Estimated = page.Estimated || s.estimated
+		page.More = page.More || len(s.buff) > 0 || s.more
+	}
+	if failed == len(p.states) {
+		return nil, errors.New("all hosts failed")
+	}
+	return page, nil
+}
+
+// 从第一条日志开始重新查询全部主机
+func (p *multiHostPager) restart(_size int) {
+	p.states = []*hostPageState{}
+	for _, h := range p.proxy.aliveHosts() {
+		p.states = append(p.states, &hostPageState{host: h})
+	}
+	p.offset = 0
+
+	var wg sync.WaitGroup
+	for _, s := range p.states {
+		wg.Add(1)
+		go func(_s *hostPageState) {
+			defer wg.Done()
+			p.fetch(_s, _size)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// 取出各主机缓存中时间戳最小的日志，缓存为空时向对应主机请求下一页
+func (p *multiHostPager) next(_size int) map[string]interface{} {
+	var min *hostPageState
+	for _, s := range p.states {
+		if len(s.buff) == 0 && s.more && !s.failed {
+			p.fetch(s, _size)
+		}
+		if len(s.buff) == 0 {
+			continue
+		}
+		if min == nil || entryTimestamp(s.buff[0]) < entryTimestamp(min.buff[0]) {
+			min = s
+		}
+	}
+	if min == nil {
+		return nil
+	}
+	entry := min.buff[0]
+	min.buff = min.buff[1:]
+	p.offset++
+	return entry
+}
+
+// 向主机请求游标之后的一页日志，第一页通过UpdateOptionsMsg发起查询
+func (p *multiHostPager) fetch(_s *hostPageState, _size int) {
+	options := p.options
+	options.From = 0
+	options.Size = _size
+	jmsg := &public.JMessage{Type: public.UpdateOptionsMsg, JOptions: &options}
+	if _s.started {
+		options.Cursor = _s.cursor
+		options.Direction = public.PageForward
+		jmsg.Type = public.UpdatePageMsg
+	}
+
+	select {
+	case <-_s.host.pageCh:
+	default:
+	}
+	_s.host.writeMessage(jmsg)
+	_s.started = true
+
+	var page *public.PageData
+	select {
+	case <-p.ctx.Done():
+		_s.failed = true
+		return
+	case <-time.After(hostResponseTimeout):
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("multi host proxy %s: page query of %s timeout", p.proxy.ID, _s.host.IP), false, false)
+	case page = <-_s.host.pageCh:
+	}
+	if page == nil {
+		_s.failed = true
+		_s.buff = nil
+		return
+	}
+
+	for _, entry := range page.Hits {
+		tagEntry(entry, _s.host)
+	}
+	_s.buff = page.Hits
+	_s.more = page.More
+	_s.total = page.Total
+	_s.estimated = page.Estimated
+	if page.LastCursor != "" {
+		_s.cursor = page.LastCursor
+	}
+}
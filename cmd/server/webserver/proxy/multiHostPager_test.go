@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sat Oct 24 14:26:09 2026 +0800
+ */
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"github.com/gorilla/websocket"
+)
+
+var testBaseTime = time.Date(2026, 10, 1, 8, 0, 0, 0, time.Local)
+
+/*
+模拟agent的分页查询：按--since/--until筛选后，从游标之后（或第from条）返回一页日志
+
+与agent一致，按游标翻页时仍须携带since/until，否则超出时间范围的日志会被返回
+*/
+type fakeAgent struct {
+	host    *hostConn
+	seconds []int
+
+	mutex    sync.Mutex
+	requests []*public.JMessage
+}
+
+func (a *fakeAgent) serve(t *testing.T, _conn *websocket.Conn) {
+	for {
+		jmsg := &public.JMessage{}
+		if err := _conn.ReadJSON(jmsg); err != nil {
+			return
+		}
+		a.mutex.Lock()
+		a.requests = append(a.requests, jmsg)
+		a.mutex.Unlock()
+		a.host.pageCh <- a.page(t, jmsg.JOptions)
+	}
+}
+
+func (a *fakeAgent) page(t *testing.T, _options *public.JournalctlOptions) *public.PageData {
+	since, err := time.ParseInLocation("2006-01-02 15:04:05", _options.Since, time.Local)
+	if err != nil {
+		t.Errorf("%s: invalid since %q", a.host.IP, _options.Since)
+		return nil
+	}
+	until, err := time.ParseInLocation("2006-01-02 15:04:05", _options.Until, time.Local)
+	if err != nil {
+		t.Errorf("%s: invalid until %q", a.host.IP, _options.Until)
+		return nil
+	}
+
+	hits := []map[string]interface{}{}
+	for _, second := range a.seconds {
+		ts := testBaseTime.Add(time.Duration(second) * time.Second)
+		if ts.Before(since) || ts.After(until) {
+			continue
+		}
+		hits = append(hits, map[string]interface{}{
+			"timestamp": strconv.FormatInt(ts.UnixMilli(), 10),
+			"cursor":    a.host.IP + ":" + strconv.Itoa(second),
+			"message":   strconv.Itoa(second),
+		})
+	}
+	total := len(hits)
+
+	start := _options.From
+	if _options.Cursor != "" {
+		start = len(hits)
+		for i, hit := range hits {
+			if hit["cursor"] == _options.Cursor {
+				start = i + 1
+			}
+		}
+	}
+	if start > len(hits) {
+		start = len(hits)
+	}
+	hits = hits[start:]
+	page := &public.PageData{Total: total, More: len(hits) > _options.Size}
+	if page.More {
+		hits = hits[:_options.Size]
+	}
+	page.Hits = hits
+	if len(hits) > 0 {
+		page.FirstCursor = hits[0]["cursor"].(string)
+		page.LastCursor = hits[len(hits)-1]["cursor"].(string)
+	}
+	return page
+}
+
+func newFakeAgent(t *testing.T, _ip string, _seconds []int) *fakeAgent {
+	agent := &fakeAgent{seconds: _seconds}
+	upgrader := &websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(_w http.ResponseWriter, _r *http.Request) {
+		conn, err := upgrader.Upgrade(_w, _r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		agent.serve(t, conn)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	agent.host = &hostConn{
+		HostInfo: public.HostInfo{UUID: _ip, IP: _ip},
+		conn:     conn,
+		pageCh:   make(chan *public.PageData, 1),
+	}
+	return agent
+}
+
+func TestMultiHostPageTimeRange(t *testing.T) {
+	agents := []*fakeAgent{
+		newFakeAgent(t, "10.0.0.1", []int{1, 3, 5, 7, 9, 11, 13, 15}),
+		newFakeAgent(t, "10.0.0.2", []int{2, 4, 6, 8, 10, 12, 14, 16}),
+		newFakeAgent(t, "10.0.0.3", []int{0, 20}),
+	}
+	m := &MultiHostProxy{ID: "test"}
+	m.CancelCtx, m.CancelFunc = WebsocketProxyCtx, func() {}
+	for _, a := range agents {
+		m.hosts = append(m.hosts, a.host)
+	}
+	options := &public.JournalctlOptions{
+		Notail: true,
+		Since:  testBaseTime.Add(4 * time.Second).Format("2006-01-02 15:04:05"),
+		Until:  testBaseTime.Add(13 * time.Second).Format("2006-01-02 15:04:05"),
+	}
+	p := newMultiHostPager(m, options)
+	defer p.cancel()
+
+	seconds := []string{}
+	hosts := map[string]string{}
+	for from := 0; ; from += 3 {
+		page, err := p.page(from, 3)
+		if err != nil {
+			t.Fatalf("page from %d: %s", from, err)
+		}
+		if page.Total != 10 {
+			t.Errorf("page from %d: total %d, want 10", from, page.Total)
+		}
+		for _, hit := range page.Hits {
+			seconds = append(seconds, hit["message"].(string))
+			hosts[hit["message"].(string)] = hit["host"].(string)
+		}
+		if !page.More {
+			break
+		}
+	}
+	want := []string{"4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+	if !reflect.DeepEqual(seconds, want) {
+		t.Fatalf("got %v, want %v", seconds, want)
+	}
+	if hosts["5"] != "10.0.0.1" || hosts["6"] != "10.0.0.2" {
+		t.Errorf("entries are not tagged with their host: %v", hosts)
+	}
+	for _, s := range p.states {
+		if s.failed {
+			t.Errorf("host %s marked failed", s.host.IP)
+		}
+	}
+
+	// 第一页发起查询，之后按游标翻页，均保留时间范围
+	for _, a := range agents[:2] {
+		a.mutex.Lock()
+		requests := a.requests
+		a.mutex.Unlock()
+		if len(requests) < 2 {
+			t.Fatalf("%s: got %d requests, want paging past the first page", a.host.IP, len(requests))
+		}
+		for i, jmsg := range requests {
+			if jmsg.JOptions.Since != options.Since || jmsg.JOptions.Until != options.Until {
+				t.Errorf("%s request %d: since/until %q/%q dropped", a.host.IP, i, jmsg.JOptions.Since, jmsg.JOptions.Until)
+			}
+			if i == 0 && (jmsg.Type != public.UpdateOptionsMsg || jmsg.JOptions.Cursor != "") {
+				t.Errorf("%s request %d: got type %d cursor %q, want a new query", a.host.IP, i, jmsg.Type, jmsg.JOptions.Cursor)
+			}
+			if i > 0 && (jmsg.Type != public.UpdatePageMsg || jmsg.JOptions.Cursor == "") {
+				t.Errorf("%s request %d: got type %d cursor %q, want a page after cursor", a.host.IP, i, jmsg.Type, jmsg.JOptions.Cursor)
+			}
+		}
+	}
+}
@@ -0,0 +1,667 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 21:05:37 2026 +0800
+ */
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/archive"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/audit"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	// logs agent websocket服务端口
+	agentPort = "9995"
+	// 单次多主机查询允许的最大主机数
+	maxMultiHosts = 64
+	// 实时查询时缓存各主机日志，按时间戳排序后发送的周期
+	followMergePeriod = 500 * time.Millisecond
+	// 实时查询时等待其他主机日志的最长时间
+	followHoldback = 2 * followMergePeriod
+	// 等待agent返回查询结果的超时时间
+	hostResponseTimeout = 30 * time.Second
+)
+
+// 与单个agent之间的websocket连接
+type hostConn struct {
+	public.HostInfo
+
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+
+	// 用户对该主机生效的权限策略
+	grant *acl.Grant
+
+	// agent返回的分页查询结果及服务单元列表
+	pageCh chan *public.PageData
+	unitCh chan map[string][]string
+
+	closed bool
+}
+
+// 通过权限检查的主机
+type hostGrant struct {
+	public.HostInfo
+	grant *acl.Grant
+}
+
+// agent发送的消息，data延迟解析
+type agentMessage struct {
+	Type int `json:"type"`
+	Data *struct {
+		Type public.StdoutDataType `json:"type"`
+		Data json.RawMessage       `json:"data"`
+	} `json:"data"`
+}
+
+// 多主机查询：将客户端请求分发至多个agent，按时间戳合并各主机的日志
+type MultiHostProxy struct {
+	ID string
+
+	Active bool
+
+	Upgrader *websocket.Upgrader
+
+	Dialer *websocket.Dialer
+
+	client_wsconn    *websocket.Conn
+	clientWriteMutex sync.Mutex
+	client_closemsg  string
+
+	hostsMutex sync.Mutex
+	hosts      []*hostConn
+
+	optionsMutex sync.RWMutex
+	options      *public.JournalctlOptions
+
+	// 实时查询时各主机的日志条目
+	followCh chan map[string]interface{}
+
+	pagerMutex sync.Mutex
+	pager      *multiHostPager
+
+	once sync.Once
+
+	CancelCtx  context.Context
+	CancelFunc context.CancelFunc
+
+	request *http.Request
+
+	User      string
+	logSource string
+
+	SourceIP string
+	audit    *audit.Session
+}
+
+func NewMultiHostProxy() *MultiHostProxy {
+	ctx, cancel := context.WithCancel(WebsocketProxyCtx)
+	return &MultiHostProxy{
+		Upgrader:   DefaultUpgrader,
+		Dialer:     DefaultDialer,
+		options:    &public.JournalctlOptions{},
+		followCh:   make(chan map[string]interface{}, 1024),
+		CancelCtx:  ctx,
+		CancelFunc: cancel,
+	}
+}
+
+func (m *MultiHostProxy) ServeHTTP(_w http.ResponseWriter, _r *http.Request) {
+	m.request = _r
+
+	m.client_wsconn, err = m.Upgrader.Upgrade(_w, _r, nil)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("failed to upgrade client connection to WebSocket: %s", err.Error()), false, false)
+		m.Close()
+		return
+	}
+
+	m.logSource = logSource(_r)
+	m.User, err = acl.ResolveUser(_r)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject ws client %s: %s", _r.RemoteAddr, err.Error()), false, false)
+		m.client_closemsg = err.Error()
+		m.Close()
+		return
+	}
+
+	host_infos, err := m.readMessageHosts()
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, " "), false, false)
+		m.client_closemsg = errors.Cause(err).Error()
+		m.Close()
+		return
+	}
+
+	m.dialHosts(host_infos)
+	if len(m.hosts) == 0 {
+		m.client_closemsg = "no logs agent available"
+		m.Close()
+		return
+	}
+
+	connected := []public.HostInfo{}
+	agents := []string{}
+	for _, h := range m.hosts {
+		connected = append(connected, h.HostInfo)
+		agents = append(agents, h.IP)
+	}
+	m.audit = audit.NewSession(m.User, m.SourceIP, agents)
+	for _, h := range m.hosts {
+		go m.readFromHost(h)
+	}
+	go m.mergeFollowEntries()
+	go m.readFromClient()
+	m.writeMessage2Client(&public.JMessage{Type: public.ConnectedMsg, Data: connected})
+}
+
+// 从客户端读取需要查询的主机，与agent地址相同，只保留agent端口可访问的主机
+func (m *MultiHostProxy) readMessageHosts() ([]hostGrant, error) {
+	_, jmsgBytes, err := m.client_wsconn.ReadMessage()
+	if err != nil {
+		return nil, errors.Errorf("error while reading message: %s", err.Error())
+	}
+	jmsg := &public.JMessage{Data: &public.HostSelector{}}
+	if err := json.Unmarshal(jmsgBytes, jmsg); err != nil {
+		return nil, errors.Errorf("error while unmarshalling json jmessage: %s, %s", err.Error(), string(jmsgBytes))
+	}
+	// 确保与client建立websocket连接之后client发送的第一条消息为HostsMsg
+	if jmsg.Type != public.HostsMsg {
+		return nil, errors.Errorf("the first message must be the host list: %d", jmsg.Type)
+	}
+	selector, ok := jmsg.Data.(*public.HostSelector)
+	if !ok {
+		return nil, errors.New("host list is empty")
+	}
+
+	uuids := append([]string{}, selector.MachineUUIDs...)
+	if selector.BatchID != "" {
+		uuids = append(uuids, pluginclient.Global_Client.BatchUUIDList(selector.BatchID)...)
+	}
+	host_infos := []hostGrant{}
+	seen := map[string]bool{}
+	for _, uuid := range uuids {
+		if uuid == "" || seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+		machine, err := pluginclient.Global_Client.MachineInfoByUUID(uuid)
+		if err != nil || machine == nil {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("fail to get machine info %s: %v", uuid, err), false, false)
+			m.writeMessage2Client(&public.JMessage{Type: public.DialFailedMsg, Host: uuid, Data: "machine not found"})
+			continue
+		}
+		grant, err := acl.Authorize(m.User, machine)
+		if err != nil {
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrap(err, " "), false, false)
+			m.writeMessage2Client(&public.JMessage{Type: public.PermissionDeniedMsg, Host: uuid, Data: err.Error()})
+			continue
+		}
+		host_infos = append(host_infos, hostGrant{HostInfo: public.HostInfo{UUID: uuid, IP: machine.IP}, grant: grant})
+	}
+	if len(host_infos) == 0 {
+		return nil, errors.New("host list is empty")
+	}
+	if len(host_infos) > maxMultiHosts {
+		return nil, errors.Errorf("too many hosts: %d > %d", len(host_infos), maxMultiHosts)
+	}
+	return host_infos, nil
+}
+
+// 并发连接各主机的agent，连接失败的主机通过DialFailedMsg通知客户端
+func (m *MultiHostProxy) dialHosts(_host_infos []hostGrant) {
+	hosts := make([]*hostConn, len(_host_infos))
+	var wg sync.WaitGroup
+	for i, info := range _host_infos {
+		wg.Add(1)
+		go func(_i int, _info hostGrant) {
+			defer wg.Done()
+			conn, err := m.dialHost(_info.HostInfo)
+			if err != nil && archive.Has(_info.UUID) {
+				// agent不可达时查询该主机的归档
+				global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrapf(err, "query archive of %s", _info.UUID), false, false)
+				conn, err = archive.Dial(_info.UUID, forwardHeader(m.request, m.ID))
+				_info.Archived = err == nil
+			}
+			if err != nil {
+				global.ERManager.ErrorTransmit("webserver", "error", errors.Wrap(err, " "), false, false)
+				m.writeError(_info.UUID, "", dialErrorCode(err), errors.Cause(err).Error())
+				m.writeMessage2Client(&public.JMessage{Type: public.DialFailedMsg, Host: _info.UUID, Data: errors.Cause(err).Error()})
+				return
+			}
+			hosts[_i] = &hostConn{
+				HostInfo: _info.HostInfo,
+				grant:    _info.grant,
+				conn:     conn,
+				pageCh:   make(chan *public.PageData, 1),
+				unitCh:   make(chan map[string][]string, 1),
+			}
+		}(i, info)
+	}
+	wg.Wait()
+
+	for _, h := range hosts {
+		if h != nil {
+			m.hosts = append(m.hosts, h)
+		}
+	}
+}
+
+func (m *MultiHostProxy) dialHost(_info public.HostInfo) (*websocket.Conn, error) {
+	addr := net.JoinHostPort(_info.IP, agentPort)
+	target_url, err := agentURL(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := m.Dialer.Dial(target_url, forwardHeader(m.request, m.ID))
+	if err != nil {
+		return nil, newDialError(err, "dial to target WebSocket %s failed: %s", addr, err.Error())
+	}
+	return conn, nil
+}
+
+func (m *MultiHostProxy) readFromClient() {
+	defer global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("multi host proxy %s: readFromClient goroutine done", m.ID), false, false)
+
+	for {
+		_, jmsgBytes, err := m.client_wsconn.ReadMessage()
+		if err != nil {
+			if m.CancelCtx.Err() == nil {
+				global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("multi host proxy %s: client closed: %s", m.ID, err.Error()), false, false)
+			}
+			m.Close()
+			return
+		}
+		jmsg := &public.JMessage{}
+		if err := json.Unmarshal(jmsgBytes, jmsg); err != nil {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while unmarshalling json jmessage: %s, %s", err.Error(), string(jmsgBytes)), false, false)
+			m.client_closemsg = "invalid message"
+			m.Close()
+			return
+		}
+
+		// 多主机查询按连接合并各主机的结果，不支持同一连接上的并发查询
+		if jmsg.Stream != "" {
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("multi host proxy %s: streams are not supported, drop message %d of stream %s", m.ID, jmsg.Type, jmsg.Stream), false, false)
+			m.writeError("", jmsg.Stream, public.ErrCodeUnsupported, "streams are not supported on multi host queries")
+			continue
+		}
+
+		switch jmsg.Type {
+		case public.UpdateOptionsMsg:
+			if jmsg.JOptions == nil {
+				continue
+			}
+			// 任意主机未通过权限检查时拒绝整个查询
+			if err := m.checkHosts(jmsg, m.aliveHosts()); err != nil {
+				continue
+			}
+			m.audit.Query(jmsg, "")
+			m.stopPager()
+			m.optionsMutex.Lock()
+			m.options = jmsg.JOptions
+			m.optionsMutex.Unlock()
+			if jmsg.JOptions.Notail {
+				m.startPager(jmsg.JOptions)
+				continue
+			}
+			m.sendFollowOptions(jmsg)
+		case public.UpdatePageMsg:
+			if jmsg.JOptions == nil {
+				continue
+			}
+			m.pagerMutex.Lock()
+			if m.pager != nil {
+				m.pager.request(jmsg.JOptions)
+			}
+			m.pagerMutex.Unlock()
+		case public.UnitListMsg:
+			go m.mergeUnitList(jmsg)
+		default:
+			if audit.IsQuery(jmsg.Type) {
+				m.audit.Query(jmsg, "")
+			}
+			m.sendToHosts(jmsg)
+		}
+	}
+}
+
+// 读取agent发送的消息：日志条目按查询模式分别合并，其他消息标记所属主机后转发至客户端
+func (m *MultiHostProxy) readFromHost(_h *hostConn) {
+	defer global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("multi host proxy %s: readFromHost %s goroutine done", m.ID, _h.IP), false, false)
+
+	for {
+		_, jmsgBytes, err := _h.conn.ReadMessage()
+		if err != nil {
+			if m.CancelCtx.Err() != nil {
+				return
+			}
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("multi host proxy %s: agent %s closed: %s", m.ID, _h.IP, err.Error()), false, false)
+			m.writeMessage2Client(&public.JMessage{Type: public.DialFailedMsg, Host: _h.UUID, Data: err.Error()})
+			if m.hostClosed(_h) {
+				m.client_closemsg = "all logs agents closed"
+				m.Close()
+			}
+			return
+		}
+		amsg := &agentMessage{}
+		if err := json.Unmarshal(jmsgBytes, amsg); err != nil {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while unmarshalling agent message: %s, %s", err.Error(), string(jmsgBytes)), false, false)
+			continue
+		}
+		if amsg.Type == public.ErrorMsg {
+			emsg := &struct {
+				Data *public.ErrorInfo `json:"data"`
+			}{}
+			if err := json.Unmarshal(jmsgBytes, emsg); err != nil {
+				global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while unmarshalling agent error message: %s, %s", err.Error(), string(jmsgBytes)), false, false)
+				continue
+			}
+			m.writeMessage2Client(&public.JMessage{Type: public.ErrorMsg, Host: _h.UUID, Data: emsg.Data})
+			continue
+		}
+		if amsg.Type != public.DataMsg || amsg.Data == nil {
+			m.writeMessage2Client(&public.JMessage{Type: amsg.Type, Host: _h.UUID})
+			continue
+		}
+
+		switch amsg.Data.Type {
+		case public.LogEntryData:
+			if m.currentOptions().Notail {
+				var page *public.PageData
+				if err := json.Unmarshal(amsg.Data.Data, &page); err != nil {
+					global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("fail to unmarshal page data from %s: %s", _h.IP, err.Error()), false, false)
+					page = nil
+				}
+				// 只保留最新的分页结果
+				select {
+				case <-_h.pageCh:
+				default:
+				}
+				_h.pageCh <- page
+				continue
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal(amsg.Data.Data, &entry); err != nil || entry == nil {
+				continue
+			}
+			tagEntry(entry, _h)
+			select {
+			case <-m.CancelCtx.Done():
+				return
+			case m.followCh <- entry:
+			}
+		case public.UnitData:
+			var units map[string][]string
+			if err := json.Unmarshal(amsg.Data.Data, &units); err != nil {
+				units = nil
+			}
+			select {
+			case <-_h.unitCh:
+			default:
+			}
+			_h.unitCh <- units
+		default:
+			m.writeMessage2Client(&public.JMessage{
+				Type: public.DataMsg,
+				Host: _h.UUID,
+				Data: &public.StdoutData{Type: amsg.Data.Type, Data: amsg.Data.Data},
+			})
+		}
+	}
+}
+
+// 实时查询：周期性地将各主机的日志按时间戳合并后发送至客户端，见followMerger
+func (m *MultiHostProxy) mergeFollowEntries() {
+	ticker := time.NewTicker(followMergePeriod)
+	defer ticker.Stop()
+
+	merger := newFollowMerger(followHoldback)
+	for {
+		select {
+		case <-m.CancelCtx.Done():
+			return
+		case entry := <-m.followCh:
+			merger.add(entry, time.Now())
+		case <-ticker.C:
+			for _, e := range merger.flush(time.Now()) {
+				cursor, _ := e.entry["cursor"].(string)
+				m.writeMessage2Client(&public.JMessage{
+					Type: public.DataMsg,
+					Host: e.uuid,
+					Data: &public.StdoutData{Type: public.LogEntryData, Data: e.entry, Cursor: cursor},
+				})
+			}
+		}
+	}
+}
+
+// 向各主机查询服务单元列表，合并后发送至客户端
+func (m *MultiHostProxy) mergeUnitList(_jmsg *public.JMessage) {
+	hosts := m.aliveHosts()
+	for _, h := range hosts {
+		select {
+		case <-h.unitCh:
+		default:
+		}
+		h.writeMessage(_jmsg)
+	}
+
+	merged := map[string][]string{}
+	seen := map[string]bool{}
+	timeout := time.After(hostResponseTimeout)
+	for _, h := range hosts {
+		var units map[string][]string
+		select {
+		case <-m.CancelCtx.Done():
+			return
+		case <-timeout:
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("multi host proxy %s: unit list of %s timeout", m.ID, h.IP), false, false)
+			continue
+		case units = <-h.unitCh:
+		}
+		for kind, names := range units {
+			if _, ok := merged[kind]; !ok {
+				merged[kind] = []string{}
+			}
+			for _, name := range names {
+				if !seen[kind+"/"+name] {
+					seen[kind+"/"+name] = true
+					merged[kind] = append(merged[kind], name)
+				}
+			}
+		}
+	}
+	for _, names := range merged {
+		sort.Strings(names)
+	}
+	m.writeMessage2Client(&public.JMessage{
+		Type: public.DataMsg,
+		Data: &public.StdoutData{Type: public.UnitData, Data: merged},
+	})
+}
+
+// 发送至jmsg.host指定的主机，未指定时发送至全部主机
+func (m *MultiHostProxy) sendToHosts(_jmsg *public.JMessage) {
+	target := _jmsg.Host
+	_jmsg.Host = ""
+	for _, h := range m.aliveHosts() {
+		if target != "" && target != h.UUID {
+			continue
+		}
+		if m.checkHosts(_jmsg, []*hostConn{h}) == nil {
+			h.writeMessage(_jmsg)
+		}
+	}
+}
+
+/*
+实时查询：各主机的after_cursor取after_cursors中对应主机的游标
+
+客户端断线重连时根据每条日志的host及cursor记录各主机的游标，各主机分别从对应的游标之后继续
+*/
+func (m *MultiHostProxy) sendFollowOptions(_jmsg *public.JMessage) {
+	for _, h := range m.aliveHosts() {
+		options := *_jmsg.JOptions
+		options.AfterCursor = _jmsg.JOptions.AfterCursors[h.UUID]
+		options.AfterCursors = nil
+		h.writeMessage(&public.JMessage{Type: _jmsg.Type, JOptions: &options})
+	}
+}
+
+// 权限检查，未通过时通知客户端
+func (m *MultiHostProxy) checkHosts(_jmsg *public.JMessage, _hosts []*hostConn) error {
+	for _, h := range _hosts {
+		if err := h.grant.Check(_jmsg, m.logSource); err != nil {
+			global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("user %s: %s", m.User, err.Error()), false, false)
+			m.audit.Query(_jmsg, err.Error())
+			m.writeMessage2Client(&public.JMessage{Type: public.PermissionDeniedMsg, Host: h.UUID, Data: err.Error()})
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MultiHostProxy) startPager(_options *public.JournalctlOptions) {
+	m.pagerMutex.Lock()
+	defer m.pagerMutex.Unlock()
+	m.pager = newMultiHostPager(m, _options)
+	go m.pager.run()
+	m.pager.request(_options)
+}
+
+func (m *MultiHostProxy) stopPager() {
+	m.pagerMutex.Lock()
+	defer m.pagerMutex.Unlock()
+	if m.pager != nil {
+		m.pager.cancel()
+		m.pager = nil
+	}
+}
+
+func (m *MultiHostProxy) currentOptions() *public.JournalctlOptions {
+	m.optionsMutex.RLock()
+	defer m.optionsMutex.RUnlock()
+	return m.options
+}
+
+func (m *MultiHostProxy) aliveHosts() []*hostConn {
+	m.hostsMutex.Lock()
+	defer m.hostsMutex.Unlock()
+	hosts := []*hostConn{}
+	for _, h := range m.hosts {
+		if !h.closed {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// 标记主机连接已关闭，返回是否全部主机均已关闭
+func (m *MultiHostProxy) hostClosed(_h *hostConn) bool {
+	m.hostsMutex.Lock()
+	defer m.hostsMutex.Unlock()
+	_h.closed = true
+	for _, h := range m.hosts {
+		if !h.closed {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MultiHostProxy) writeMessage2Client(_jmsg *public.JMessage) {
+	jmsgBytes, err := json.Marshal(_jmsg)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while marshalling json jmessage: %s", err.Error()), false, false)
+		return
+	}
+	m.clientWriteMutex.Lock()
+	defer m.clientWriteMutex.Unlock()
+	if err := m.client_wsconn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		if m.CancelCtx.Err() == nil {
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while writing message %d to client %s: %s", _jmsg.Type, m.ID, err.Error()), false, false)
+		}
+		return
+	}
+	m.audit.AddMessage(jmsgBytes)
+}
+
+// 通知客户端查询失败，_host为空时表示整个多主机查询失败
+func (m *MultiHostProxy) writeError(_host, _stream, _code, _message string) {
+	m.writeMessage2Client(&public.JMessage{
+		Type:   public.ErrorMsg,
+		Host:   _host,
+		Stream: _stream,
+		Data:   &public.ErrorInfo{Code: _code, Message: _message},
+	})
+}
+
+func (h *hostConn) writeMessage(_jmsg *public.JMessage) {
+	jmsgBytes, err := json.Marshal(_jmsg)
+	if err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while marshalling json jmessage: %s", err.Error()), false, false)
+		return
+	}
+	h.writeMutex.Lock()
+	defer h.writeMutex.Unlock()
+	if err := h.conn.WriteMessage(websocket.TextMessage, jmsgBytes); err != nil {
+		global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("error while writing message %d to agent %s: %s", _jmsg.Type, h.IP, err.Error()), false, false)
+	}
+}
+
+func (m *MultiHostProxy) Close() {
+	m.once.Do(func() {
+		m.CancelFunc()
+		m.stopPager()
+		m.audit.Close()
+
+		if m.client_wsconn != nil {
+			m.clientWriteMutex.Lock()
+			if err := m.client_wsconn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, m.client_closemsg)); err != nil {
+				global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("write close message to client_wsconn error: %s", err.Error()), false, false)
+			}
+			m.clientWriteMutex.Unlock()
+			m.client_wsconn.Close()
+		}
+		for _, h := range m.hosts {
+			h.writeMutex.Lock()
+			h.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			h.writeMutex.Unlock()
+			h.conn.Close()
+		}
+
+		m.Active = false
+	})
+}
+
+// 日志条目中添加所属主机
+func tagEntry(_entry map[string]interface{}, _h *hostConn) {
+	_entry["host"] = _h.IP
+	_entry["uuid"] = _h.UUID
+}
+
+// 日志条目的时间戳，毫秒
+func entryTimestamp(_entry map[string]interface{}) int64 {
+	timestamp_str, _ := _entry["timestamp"].(string)
+	timestamp, _ := strconv.ParseInt(timestamp_str, 10, 64)
+	return timestamp
+}
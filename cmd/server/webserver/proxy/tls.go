@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Sun Oct 18 22:31:46 2026 +0800
+ */
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/conf"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo/sdk/utils/httputils"
+	"github.com/pkg/errors"
+)
+
+// 配置agent_ca_file后只通过https连接agent
+var agentTLSRequired bool
+
+// 根据配置文件设置连接agent时使用的CA及客户端证书
+func InitAgentTLS() error {
+	logsconf := conf.Global_Config.Logs
+	if logsconf.AgentCAFile == "" {
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.New("agent_ca_file is empty, logs agent certificates are not verified"), false, false)
+		return nil
+	}
+	if logsconf.AgentCertFile == "" || logsconf.AgentKeyFile == "" {
+		return errors.New("agent_client_cert_file and agent_client_key_file are required when agent_ca_file is set")
+	}
+
+	ca, err := global.FileReadBytes(logsconf.AgentCAFile)
+	if err != nil {
+		return errors.Errorf("fail to read agent ca file %s: %s", logsconf.AgentCAFile, err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return errors.Errorf("no valid certificate in agent ca file %s", logsconf.AgentCAFile)
+	}
+	cert, err := tls.LoadX509KeyPair(logsconf.AgentCertFile, logsconf.AgentKeyFile)
+	if err != nil {
+		return errors.Errorf("fail to load agent client certificate: %s", err.Error())
+	}
+
+	DefaultDialer.TLSClientConfig = &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	agentTLSRequired = true
+	return nil
+}
+
+// agent websocket服务地址，开启mTLS时拒绝连接只提供http服务的agent
+func agentURL(_addr string) (string, error) {
+	ishttp, err := httputils.ServerIsHttp("http://" + _addr)
+	if err != nil {
+		return "", &dialError{code: public.ErrCodeDialFailed, err: errors.Errorf("fail to detect remote http/https: %s", err.Error())}
+	}
+	if !ishttp {
+		return fmt.Sprintf("wss://%s/ws/entry", _addr), nil
+	}
+	if agentTLSRequired {
+		return "", &dialError{code: public.ErrCodeHandshakeFailed, err: errors.Errorf("agent %s does not serve https, mutual tls required", _addr)}
+	}
+	return fmt.Sprintf("ws://%s/ws/entry", _addr), nil
+}
@@ -20,6 +20,8 @@ var WebsocketProxyManager *WebsocketProxyManagement
 type WebsocketProxyManagement struct {
 	// key: web client id
 	WebsocketProxyMap map[string]*WebsocketForwardProxy
+	// key: web client id，多主机查询
+	MultiHostProxyMap map[string]*MultiHostProxy
 
 	// 终止采集组件状态检测
 	heartbeatDone chan struct{}
@@ -30,6 +32,7 @@ type WebsocketProxyManagement struct {
 func CreateWebsocketProxyManagement() {
 	WebsocketProxyManager = &WebsocketProxyManagement{
 		WebsocketProxyMap: make(map[string]*WebsocketForwardProxy),
+		MultiHostProxyMap: make(map[string]*MultiHostProxy),
 		heartbeatDone:     make(chan struct{}),
 	}
 
@@ -44,6 +47,14 @@ func (wpm *WebsocketProxyManagement) Delete(_id string) {
 	delete(wpm.WebsocketProxyMap, _id)
 }
 
+func (wpm *WebsocketProxyManagement) AddMultiHost(_id string, _mhproxy *MultiHostProxy) {
+	wpm.MultiHostProxyMap[_id] = _mhproxy
+}
+
+func (wpm *WebsocketProxyManagement) DeleteMultiHost(_id string) {
+	delete(wpm.MultiHostProxyMap, _id)
+}
+
 func (wpm *WebsocketProxyManagement) heartbeatDetect() {
 	for {
 		select {
@@ -56,6 +67,12 @@ func (wpm *WebsocketProxyManagement) heartbeatDetect() {
 					wpm.Delete(_id)
 				}
 			}
+			for _id, _mh := range wpm.MultiHostProxyMap {
+				if !_mh.Active {
+					global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("remove multi host proxy client: %s", _id), false, false)
+					wpm.DeleteMultiHost(_id)
+				}
+			}
 		}
 	}
 }
@@ -69,4 +86,9 @@ func (wpm *WebsocketProxyManagement) CloseAll() {
 		global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("shutdown websocket proxy: %s", id), false, false)
 		wsproxy.Close(true, false, false)
 	}
+
+	for id, mhproxy := range wpm.MultiHostProxyMap {
+		global.ERManager.ErrorTransmit("webserver", "info", errors.Errorf("shutdown multi host proxy: %s", id), false, false)
+		mhproxy.Close()
+	}
 }
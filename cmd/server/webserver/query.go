@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Wed Oct 21 15:02:40 2026 +0800
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/webserver/proxy"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"gitee.com/openeuler/PilotGo/sdk/response"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	formatNDJSON      = "ndjson"
+	ndjsonContentType = "application/x-ndjson"
+	// ndjson分页查询时每次向agent请求的日志条数
+	ndjsonPageSize = 500
+)
+
+// http查询请求，uuid与ip任选其一，options与websocket查询的joptions一致
+type queryRequest struct {
+	UUID    string                    `json:"uuid"`
+	IP      string                    `json:"ip"`
+	Options *public.JournalctlOptions `json:"options"`
+	// 实时查询，只支持ndjson格式
+	Follow bool `json:"follow"`
+	// ndjson格式最多返回的日志条数，为0时不限制
+	Limit int `json:"limit"`
+}
+
+// json格式的查询结果
+type queryResult struct {
+	UUID     string           `json:"uuid"`
+	IP       string           `json:"ip"`
+	Archived bool             `json:"archived"`
+	Page     *public.PageData `json:"page"`
+}
+
+/*
+http查询接口，供脚本等非浏览器客户端使用
+
+默认返回一页json格式的查询结果，format=ndjson或Accept为application/x-ndjson时每行返回一条日志：
+分页查询持续翻页直至没有更多日志或达到limit，实时查询持续返回新的日志直至客户端断开；
+开始返回日志后出现的错误以{"error":ErrorInfo}作为最后一行返回
+*/
+func QueryHandle(_ctx *gin.Context) {
+	user, err := acl.ResolveUser(_ctx.Request)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject query from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+	req := &queryRequest{}
+	if err := _ctx.ShouldBindJSON(req); err != nil || req.Options == nil {
+		response.Fail(_ctx, nil, "parameter error")
+		return
+	}
+	ndjson := _ctx.Query("format") == formatNDJSON || strings.Contains(_ctx.GetHeader("Accept"), ndjsonContentType)
+	if req.Follow && !ndjson {
+		response.Fail(_ctx, nil, "follow requires format=ndjson")
+		return
+	}
+	req.Options.Notail = !req.Follow
+	if err := public.CheckQuery(req.Options); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+
+	machine, err := queryMachine(req.UUID, req.IP)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	session, err := proxy.DialAgent(_ctx.Request.Context(), _ctx.Request, user, _ctx.ClientIP(), machine)
+	if err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrapf(err, "query %s from %s", machine.IP, _ctx.ClientIP()), false, false)
+		return
+	}
+	defer session.Close()
+
+	if ndjson && req.Options.Size <= 0 {
+		req.Options.Size = ndjsonPageSize
+	}
+	if err := session.Send(&public.JMessage{Type: public.UpdateOptionsMsg, JOptions: req.Options}); err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		return
+	}
+
+	if ndjson {
+		streamQuery(_ctx, session, req)
+		return
+	}
+	page, err := readPage(session)
+	if err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		return
+	}
+	response.Success(_ctx, &queryResult{UUID: machine.UUID, IP: machine.IP, Archived: session.Archived, Page: page}, "")
+}
+
+// 查询主机的服务单元列表，uuid与ip任选其一
+func UnitListHandle(_ctx *gin.Context) {
+	user, err := acl.ResolveUser(_ctx.Request)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("reject unit list request from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return
+	}
+	machine, err := queryMachine(_ctx.Query("uuid"), _ctx.Query("ip"))
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	session, err := proxy.DialAgent(_ctx.Request.Context(), _ctx.Request, user, _ctx.ClientIP(), machine)
+	if err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Wrapf(err, "unit list of %s from %s", machine.IP, _ctx.ClientIP()), false, false)
+		return
+	}
+	defer session.Close()
+
+	if err := session.Send(&public.JMessage{Type: public.UnitListMsg}); err != nil {
+		response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+		return
+	}
+	for {
+		data, err := session.Read()
+		if err != nil {
+			response.Fail(_ctx, proxy.ErrorInfo(err), err.Error())
+			return
+		}
+		if data.Type != public.UnitData {
+			continue
+		}
+		var units map[string][]string
+		if err := json.Unmarshal(data.Data, &units); err != nil || units == nil {
+			response.Fail(_ctx, nil, "fail to list units")
+			global.ERManager.ErrorTransmit("webserver", "error", errors.Errorf("fail to list units of %s: %v", machine.IP, err), false, false)
+			return
+		}
+		response.Success(_ctx, units, "")
+		return
+	}
+}
+
+// 根据uuid或ip查找机器
+func queryMachine(_uuid, _ip string) (*common.MachineNode, error) {
+	switch {
+	case _uuid != "":
+		machine, err := pluginclient.Global_Client.MachineInfoByUUID(_uuid)
+		if err != nil || machine == nil {
+			return nil, errors.Errorf("machine not found: %s", _uuid)
+		}
+		return machine, nil
+	case _ip != "":
+		return acl.MachineByAddr(_ip)
+	}
+	return nil, errors.New("uuid or ip is required")
+}
+
+// 读取下一页查询结果，agent返回空结果时视为查询失败
+func readPage(_session *proxy.AgentSession) (*public.PageData, error) {
+	for {
+		data, err := _session.Read()
+		if err != nil {
+			return nil, err
+		}
+		if data.Type != public.LogEntryData {
+			continue
+		}
+		var page *public.PageData
+		if err := json.Unmarshal(data.Data, &page); err != nil {
+			return nil, errors.Errorf("fail to unmarshal page data: %s", err.Error())
+		}
+		if page == nil {
+			return nil, errors.New("query failed")
+		}
+		return page, nil
+	}
+}
+
+func streamQuery(_ctx *gin.Context, _session *proxy.AgentSession, _req *queryRequest) {
+	_ctx.Header("Content-Type", ndjsonContentType)
+	_ctx.Status(http.StatusOK)
+	_ctx.Writer.Flush()
+
+	count := 0
+	write := func(_line []byte) bool {
+		if _, err := _ctx.Writer.Write(append(_line, '\n')); err != nil {
+			return false
+		}
+		count++
+		return _req.Limit <= 0 || count < _req.Limit
+	}
+
+	var err error
+	if _req.Follow {
+		err = streamFollow(_ctx, _session, write)
+	} else {
+		err = streamPages(_ctx, _session, _req.Options, write)
+	}
+	if err != nil && _ctx.Request.Context().Err() == nil {
+		line, _ := json.Marshal(map[string]interface{}{"error": proxy.ErrorInfo(err)})
+		_ctx.Writer.Write(append(line, '\n'))
+		_ctx.Writer.Flush()
+	}
+}
+
+// 按查询方向持续翻页
+func streamPages(_ctx *gin.Context, _session *proxy.AgentSession, _options *public.JournalctlOptions, _write func([]byte) bool) error {
+	for {
+		page, err := readPage(_session)
+		if err != nil {
+			return err
+		}
+		for _, hit := range page.Hits {
+			line, err := json.Marshal(hit)
+			if err != nil {
+				continue
+			}
+			if !_write(line) {
+				_ctx.Writer.Flush()
+				return nil
+			}
+		}
+		_ctx.Writer.Flush()
+		if !page.More || len(page.Hits) == 0 {
+			return nil
+		}
+
+		next := &public.JournalctlOptions{Size: _options.Size, Direction: _options.Direction, Cursor: page.LastCursor}
+		if _options.Direction == public.PageBackward {
+			next.Cursor = page.FirstCursor
+		}
+		if err := _session.Send(&public.JMessage{Type: public.UpdatePageMsg, JOptions: next}); err != nil {
+			return err
+		}
+	}
+}
+
+// 实时查询：每收到一条日志返回一行
+func streamFollow(_ctx *gin.Context, _session *proxy.AgentSession, _write func([]byte) bool) error {
+	for {
+		data, err := _session.Read()
+		if err != nil {
+			return err
+		}
+		if data.Type != public.LogEntryData || string(data.Data) == "null" {
+			continue
+		}
+		if !_write(data.Data) {
+			_ctx.Writer.Flush()
+			return nil
+		}
+		_ctx.Writer.Flush()
+	}
+}
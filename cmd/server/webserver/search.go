@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) KylinSoft  Co., Ltd. 2024.All rights reserved.
+ * PilotGo-plugin-logs licensed under the Mulan Permissive Software License, Version 2.
+ * See LICENSE file for more details.
+ * Author: Wangjunqi123 <wangjunqi@kylinos.cn>
+ * Date: Fri Oct 23 15:08:13 2026 +0800
+ */
+package webserver
+
+import (
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/public"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/acl"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/global"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/pluginclient"
+	"gitee.com/openeuler/PilotGo-plugin-logs/cmd/server/search"
+	"gitee.com/openeuler/PilotGo/sdk/common"
+	"gitee.com/openeuler/PilotGo/sdk/response"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 保存的查询条件换算后可直接使用的查询
+type resolvedSearch struct {
+	Search *search.Search `json:"search"`
+	// 用户有权查看的主机
+	Machines []*common.MachineNode     `json:"machines"`
+	Options  *public.JournalctlOptions `json:"options"`
+}
+
+// 请求用户及其是否为管理员，未开启保存查询条件时返回错误
+func searchUser(_ctx *gin.Context) (string, bool, bool) {
+	if !search.Enabled() {
+		response.Fail(_ctx, nil, "saved search is not enabled")
+		return "", false, false
+	}
+	user, err := acl.ResolveUser(_ctx.Request)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		global.ERManager.ErrorTransmit("webserver", "warn", errors.Errorf("saved search request from %s: %s", _ctx.ClientIP(), err.Error()), false, false)
+		return "", false, false
+	}
+	return user, acl.CheckAdmin(_ctx.Request) == nil, true
+}
+
+func SearchListHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	response.Success(_ctx, search.List(user, admin), "")
+}
+
+func SearchGetHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	s, err := search.Get(_ctx.Param("id"), user, admin)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, s, "")
+}
+
+func SearchCreateHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	s := &search.Search{}
+	if err := _ctx.ShouldBindJSON(s); err != nil {
+		response.Fail(_ctx, nil, "invalid saved search: "+err.Error())
+		return
+	}
+	if err := search.Create(s, user, admin); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, s, "")
+}
+
+func SearchUpdateHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	s := &search.Search{}
+	if err := _ctx.ShouldBindJSON(s); err != nil {
+		response.Fail(_ctx, nil, "invalid saved search: "+err.Error())
+		return
+	}
+	if err := search.Update(_ctx.Param("id"), s, user, admin); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, s, "")
+}
+
+func SearchDeleteHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	if err := search.Delete(_ctx.Param("id"), user, admin); err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	response.Success(_ctx, nil, "")
+}
+
+/*
+按当前时间换算保存的查询条件，返回主机及查询参数；id在修改后不变，可作为固定链接
+
+未指定主机时返回用户有权查看的全部主机
+*/
+func SearchResolveHandle(_ctx *gin.Context) {
+	user, admin, ok := searchUser(_ctx)
+	if !ok {
+		return
+	}
+	s, err := search.Get(_ctx.Param("id"), user, admin)
+	if err != nil {
+		response.Fail(_ctx, nil, err.Error())
+		return
+	}
+	machines, err := pluginclient.Global_Client.MachineList()
+	if err != nil {
+		response.Fail(_ctx, nil, "fail to get machine list: "+err.Error())
+		return
+	}
+	resolved := &resolvedSearch{
+		Search:   s,
+		Machines: []*common.MachineNode{},
+		Options:  s.ResolveOptions(time.Now()),
+	}
+	for _, machine := range machines {
+		if !searchMachine(s, machine) {
+			continue
+		}
+		if _, err := acl.Authorize(user, machine); err != nil {
+			continue
+		}
+		resolved.Machines = append(resolved.Machines, machine)
+	}
+	response.Success(_ctx, resolved, "")
+}
+
+func searchMachine(_search *search.Search, _machine *common.MachineNode) bool {
+	if len(_search.Machines) == 0 {
+		return true
+	}
+	for _, id := range _search.Machines {
+		if id == _machine.UUID || id == _machine.IP {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ require (
 	gitee.com/openeuler/PilotGo/sdk v0.0.0-20250121031234-c5439c613a24
 	github.com/gin-gonic/gin v1.9.1
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.4
 	github.com/pkg/errors v0.9.1
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -50,6 +50,8 @@ popd
 pushd cmd/agent
 GOWORK=off GO111MODULE=on go build -o PilotGo-plugin-logs-agent main.go
 popd
+# logsctl
+GOWORK=off GO111MODULE=on go build -o cmd/logsctl/logsctl ./cmd/logsctl
 
 %install
 mkdir -p %{buildroot}/opt/PilotGo/plugin/logs/server/log
@@ -58,6 +60,7 @@ mkdir -p %{buildroot}/opt/PilotGo/plugin/logs/agent/log
 install -D -m 0755 %{_builddir}/PilotGo-plugin-logs/cmd/server/PilotGo-plugin-logs-server %{buildroot}/opt/PilotGo/plugin/logs/server
 install -D -m 0644 %{_builddir}/PilotGo-plugin-logs/cmd/server/logs_server.yaml.template %{buildroot}/opt/PilotGo/plugin/logs/server/logs_server.yaml
 install -D -m 0644 %{_builddir}/PilotGo-plugin-logs/scripts/PilotGo-plugin-logs-server.service %{buildroot}%{_unitdir}/PilotGo-plugin-logs-server.service
+install -D -m 0755 %{_builddir}/PilotGo-plugin-logs/cmd/logsctl/logsctl %{buildroot}%{_bindir}/logsctl
 # agent
 install -D -m 0755 %{_builddir}/PilotGo-plugin-logs/cmd/agent/PilotGo-plugin-logs-agent %{buildroot}/opt/PilotGo/plugin/logs/agent
 install -D -m 0644 %{_builddir}/PilotGo-plugin-logs/cmd/agent/logs_agent.yaml.template %{buildroot}/opt/PilotGo/plugin/logs/agent/logs_agent.yaml
@@ -72,6 +75,7 @@ install -D -m 0644 %{_builddir}/PilotGo-plugin-logs/scripts/PilotGo-plugin-logs-
 /opt/PilotGo/plugin/logs/server/PilotGo-plugin-logs-server
 /opt/PilotGo/plugin/logs/server/logs_server.yaml
 %{_unitdir}/PilotGo-plugin-logs-server.service
+%{_bindir}/logsctl
 
 %files          agent
 %dir /opt/PilotGo